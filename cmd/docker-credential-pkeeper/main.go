@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+
+	"github.com/7StaSH7/practicum-diploma/internal/client/cli"
+)
+
+// Docker finds credential helpers by name: "credsStore": "pkeeper" runs
+// docker-credential-pkeeper get|store|erase|list.
+func main() {
+	app := cli.New(os.Stdout, os.Stderr)
+	os.Exit(app.Run(append([]string{"docker-credential"}, os.Args[1:]...)))
+}
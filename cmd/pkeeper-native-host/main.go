@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+
+	"github.com/7StaSH7/practicum-diploma/internal/client/cli"
+)
+
+// Browser native-messaging manifests name a binary and cannot pass it a
+// subcommand; the arguments browsers add (the extension origin, the
+// manifest path) are ignored by native-host.
+func main() {
+	app := cli.New(os.Stdout, os.Stderr)
+	os.Exit(app.Run(append([]string{"native-host"}, os.Args[1:]...)))
+}
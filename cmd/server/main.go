@@ -10,13 +10,22 @@ import (
 
 	"github.com/7StaSH7/practicum-diploma/internal/config"
 	"github.com/7StaSH7/practicum-diploma/internal/db"
+	attachmenthandler "github.com/7StaSH7/practicum-diploma/internal/handler/attachment"
 	authhandler "github.com/7StaSH7/practicum-diploma/internal/handler/auth"
+	eventhandler "github.com/7StaSH7/practicum-diploma/internal/handler/event"
 	secrethandler "github.com/7StaSH7/practicum-diploma/internal/handler/secret"
 	"github.com/7StaSH7/practicum-diploma/internal/logger"
+	attachmentrepository "github.com/7StaSH7/practicum-diploma/internal/repository/attachment"
 	authrepository "github.com/7StaSH7/practicum-diploma/internal/repository/auth"
+	"github.com/7StaSH7/practicum-diploma/internal/repository/blob"
+	eventrepository "github.com/7StaSH7/practicum-diploma/internal/repository/event"
+	idempotencyrepository "github.com/7StaSH7/practicum-diploma/internal/repository/idempotency"
 	secretrepository "github.com/7StaSH7/practicum-diploma/internal/repository/secret"
 	"github.com/7StaSH7/practicum-diploma/internal/server"
+	attachmentservice "github.com/7StaSH7/practicum-diploma/internal/service/attachment"
 	authservice "github.com/7StaSH7/practicum-diploma/internal/service/auth"
+	eventservice "github.com/7StaSH7/practicum-diploma/internal/service/event"
+	idempotencyservice "github.com/7StaSH7/practicum-diploma/internal/service/idempotency"
 	secretservice "github.com/7StaSH7/practicum-diploma/internal/service/secret"
 	"go.uber.org/fx"
 	"golang.org/x/sync/errgroup"
@@ -41,10 +50,24 @@ func main() {
 		fx.Provide(authrepository.NewUserRepository),
 		fx.Provide(authrepository.NewTokenRepository),
 		fx.Provide(secretrepository.NewSecretRepository),
+		fx.Provide(idempotencyrepository.NewKeyRepository),
+		fx.Provide(eventrepository.NewEventRepository),
+		fx.Provide(attachmentrepository.NewAttachmentRepository),
+		fx.Provide(blob.NewStore),
 		fx.Provide(authservice.NewService),
+		fx.Provide(eventservice.NewService),
+		fx.Invoke(eventservice.RegisterPurge),
+		fx.Invoke(eventservice.RegisterListener),
 		fx.Provide(secretservice.NewService),
+		fx.Invoke(secretservice.RegisterTrashPurge),
+		fx.Provide(idempotencyservice.NewService),
+		fx.Invoke(idempotencyservice.RegisterPurge),
+		fx.Provide(attachmentservice.NewService),
+		fx.Invoke(attachmentservice.RegisterGC),
 		fx.Provide(authhandler.New),
 		fx.Provide(secrethandler.New),
+		fx.Provide(eventhandler.New),
+		fx.Provide(attachmenthandler.New),
 		fx.Provide(server.NewRouter),
 		fx.Invoke(server.RegisterRoutes),
 		fx.Invoke(server.StartHTTPServer),
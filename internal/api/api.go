@@ -2,19 +2,24 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
+	dtoattachment "github.com/7StaSH7/practicum-diploma/internal/dto/attachment"
 	dtoauth "github.com/7StaSH7/practicum-diploma/internal/dto/auth"
+	dtoevent "github.com/7StaSH7/practicum-diploma/internal/dto/event"
 	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
 	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
 )
 
 type API struct {
 	client *apiclient.Client
+	cipher Cipher
 }
 
 func New(baseURL string, httpClient *http.Client) *API {
@@ -69,6 +74,28 @@ func (a *API) ListSecrets(ctx context.Context, accessToken, since string) ([]dto
 	if err != nil {
 		return nil, err
 	}
+	if err := a.openSecrets(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListSecretChanges is ListSecrets including secrets deleted since then,
+// marked by DeletedAt.
+func (a *API) ListSecretChanges(ctx context.Context, accessToken, since string) ([]dtosecret.SecretResponse, error) {
+	query := url.Values{"include_deleted": {"true"}}
+	if strings.TrimSpace(since) != "" {
+		query.Set("since", since)
+	}
+
+	var out []dtosecret.SecretResponse
+	err := a.client.DoJSON(ctx, http.MethodGet, "/secrets?"+query.Encode(), authHeader(accessToken), nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.openSecrets(out); err != nil {
+		return nil, err
+	}
 	return out, nil
 }
 
@@ -78,24 +105,41 @@ func (a *API) GetSecret(ctx context.Context, accessToken, id string) (dtosecret.
 	if err != nil {
 		return dtosecret.SecretResponse{}, err
 	}
+	if err := a.openFields([]*string{&out.Ciphertext}); err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
 	return out, nil
 }
 
 func (a *API) CreateSecret(ctx context.Context, accessToken string, payload dtosecret.SecretPayload) (dtosecret.SecretResponse, error) {
+	payload, err := a.sealPayload(ctx, payload)
+	if err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
 	var out dtosecret.SecretResponse
-	err := a.client.DoJSON(ctx, http.MethodPost, "/secrets", authHeader(accessToken), payload, &out)
+	err = a.client.DoJSON(ctx, http.MethodPost, "/secrets", authHeader(accessToken), payload, &out)
 	if err != nil {
 		return dtosecret.SecretResponse{}, err
 	}
+	if err := a.openFields([]*string{&out.Ciphertext}); err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
 	return out, nil
 }
 
 func (a *API) UpdateSecret(ctx context.Context, accessToken, id string, payload dtosecret.SecretPayload) (dtosecret.SecretResponse, error) {
+	payload, err := a.sealPayload(ctx, payload)
+	if err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
 	var out dtosecret.SecretResponse
-	err := a.client.DoJSON(ctx, http.MethodPut, "/secrets/"+id, authHeader(accessToken), payload, &out)
+	err = a.client.DoJSON(ctx, http.MethodPut, "/secrets/"+id, authHeader(accessToken), payload, &out)
 	if err != nil {
 		return dtosecret.SecretResponse{}, err
 	}
+	if err := a.openFields([]*string{&out.Ciphertext}); err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
 	return out, nil
 }
 
@@ -103,6 +147,159 @@ func (a *API) DeleteSecret(ctx context.Context, accessToken, id string) error {
 	return a.client.DoJSON(ctx, http.MethodDelete, "/secrets/"+id, authHeader(accessToken), nil, nil)
 }
 
+func (a *API) ListSecretVersions(ctx context.Context, accessToken, id string) ([]dtosecret.SecretVersionResponse, error) {
+	var out []dtosecret.SecretVersionResponse
+	err := a.client.DoJSON(ctx, http.MethodGet, "/secrets/"+id+"/versions", authHeader(accessToken), nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.openVersions(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (a *API) GetSecretVersion(ctx context.Context, accessToken, id string, version int64) (dtosecret.SecretVersionResponse, error) {
+	var out dtosecret.SecretVersionResponse
+	path := "/secrets/" + id + "/versions/" + strconv.FormatInt(version, 10)
+	err := a.client.DoJSON(ctx, http.MethodGet, path, authHeader(accessToken), nil, &out)
+	if err != nil {
+		return dtosecret.SecretVersionResponse{}, err
+	}
+	if err := a.openFields([]*string{&out.Ciphertext}); err != nil {
+		return dtosecret.SecretVersionResponse{}, err
+	}
+	return out, nil
+}
+
+func (a *API) RestoreSecret(ctx context.Context, accessToken, id string, version int64) (dtosecret.SecretResponse, error) {
+	var out dtosecret.SecretResponse
+	err := a.client.DoJSON(ctx, http.MethodPost, "/secrets/"+id+"/restore", authHeader(accessToken), dtosecret.RestoreRequest{
+		Version: version,
+	}, &out)
+	if err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
+	if err := a.openFields([]*string{&out.Ciphertext}); err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
+	return out, nil
+}
+
+func (a *API) ListTrash(ctx context.Context, accessToken string) ([]dtosecret.SecretResponse, error) {
+	var out []dtosecret.SecretResponse
+	err := a.client.DoJSON(ctx, http.MethodGet, "/trash", authHeader(accessToken), nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.openSecrets(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (a *API) RestoreFromTrash(ctx context.Context, accessToken, id string) (dtosecret.SecretResponse, error) {
+	var out dtosecret.SecretResponse
+	err := a.client.DoJSON(ctx, http.MethodPost, "/trash/"+id+"/restore", authHeader(accessToken), nil, &out)
+	if err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
+	if err := a.openFields([]*string{&out.Ciphertext}); err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
+	return out, nil
+}
+
+func (a *API) PurgeSecret(ctx context.Context, accessToken, id string) error {
+	return a.client.DoJSON(ctx, http.MethodDelete, "/trash/"+id, authHeader(accessToken), nil, nil)
+}
+
+func (a *API) BatchSecrets(ctx context.Context, accessToken string, req dtosecret.BatchRequest) (dtosecret.BatchResponse, error) {
+	req, err := a.sealBatch(ctx, req)
+	if err != nil {
+		return dtosecret.BatchResponse{}, err
+	}
+	var out dtosecret.BatchResponse
+	err = a.client.DoJSON(ctx, http.MethodPost, "/secrets/batch", authHeader(accessToken), req, &out)
+	if err != nil {
+		return dtosecret.BatchResponse{}, err
+	}
+	if err := a.openBatch(&out); err != nil {
+		return dtosecret.BatchResponse{}, err
+	}
+	return out, nil
+}
+
+func (a *API) CreateAttachment(ctx context.Context, accessToken, secretID, name string, size int64) (dtoattachment.AttachmentResponse, error) {
+	var out dtoattachment.AttachmentResponse
+	err := a.client.DoJSON(ctx, http.MethodPost, "/secrets/"+secretID+"/attachments", authHeader(accessToken), dtoattachment.CreateAttachmentRequest{
+		Name: name,
+		Size: size,
+	}, &out)
+	if err != nil {
+		return dtoattachment.AttachmentResponse{}, err
+	}
+	return out, nil
+}
+
+func (a *API) ListAttachments(ctx context.Context, accessToken, secretID string) ([]dtoattachment.AttachmentResponse, error) {
+	var out []dtoattachment.AttachmentResponse
+	err := a.client.DoJSON(ctx, http.MethodGet, "/secrets/"+secretID+"/attachments", authHeader(accessToken), nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (a *API) GetAttachment(ctx context.Context, accessToken, id string) (dtoattachment.AttachmentResponse, error) {
+	var out dtoattachment.AttachmentResponse
+	err := a.client.DoJSON(ctx, http.MethodGet, "/attachments/"+id, authHeader(accessToken), nil, &out)
+	if err != nil {
+		return dtoattachment.AttachmentResponse{}, err
+	}
+	return out, nil
+}
+
+// UploadAttachmentChunk stores data at offset. The server answers 409 when
+// offset is not where the upload currently stands.
+func (a *API) UploadAttachmentChunk(ctx context.Context, accessToken, id string, offset int64, data []byte) (dtoattachment.AttachmentResponse, error) {
+	headers := authHeader(accessToken)
+	if headers == nil {
+		headers = make(map[string]string, 1)
+	}
+	headers["Upload-Offset"] = strconv.FormatInt(offset, 10)
+
+	var out dtoattachment.AttachmentResponse
+	err := a.client.DoRaw(ctx, http.MethodPut, "/attachments/"+id+"/content", headers, data, &out)
+	if err != nil {
+		return dtoattachment.AttachmentResponse{}, err
+	}
+	return out, nil
+}
+
+func (a *API) DeleteAttachment(ctx context.Context, accessToken, id string) error {
+	return a.client.DoJSON(ctx, http.MethodDelete, "/attachments/"+id, authHeader(accessToken), nil, nil)
+}
+
+// StreamEvents follows the secret event stream, resuming after lastEventID
+// when it is set. fn receives the stream position along with every event.
+func (a *API) StreamEvents(ctx context.Context, accessToken, lastEventID string, fn func(id string, event dtoevent.EventResponse) error) error {
+	headers := authHeader(accessToken)
+	if lastEventID != "" {
+		if headers == nil {
+			headers = make(map[string]string, 1)
+		}
+		headers["Last-Event-ID"] = lastEventID
+	}
+	return a.client.Stream(ctx, "/events", headers, func(event apiclient.Event) error {
+		var out dtoevent.EventResponse
+		if err := json.Unmarshal([]byte(event.Data), &out); err != nil {
+			return err
+		}
+		return fn(event.ID, out)
+	})
+}
+
 func IsHTTPStatus(err error, statusCode int) bool {
 	var httpErr *apiclient.HTTPError
 	if !errors.As(err, &httpErr) {
@@ -0,0 +1,303 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	dtoattachment "github.com/7StaSH7/practicum-diploma/internal/dto/attachment"
+	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
+)
+
+// A sealed attachment starts with a header of magic, version byte, chunk
+// size and file size. Each chunk follows as its sealed length and the sealed
+// tag of the attachment, chunk index, last-chunk flag and file bytes, so
+// chunks cannot be reordered, dropped or moved to another attachment.
+const (
+	attachmentMagic      = "\x00PKA"
+	attachmentVersion    = 1
+	attachmentHeaderSize = len(attachmentMagic) + 1 + 4 + 8
+	attachmentTagSize    = 16
+	chunkPrefixSize      = attachmentTagSize + 8 + 1
+	recordLengthSize     = 4
+	// maxRecordOverhead bounds what a cipher may add to a chunk, so a
+	// damaged length cannot make the download allocate without limit.
+	maxRecordOverhead = 1 << 10
+)
+
+var ErrAttachmentDamaged = errors.New("attachment is damaged or sealed for another attachment")
+
+// AttachmentLayout is how a file of Size bytes is stored as attachment ID:
+// as it is while the vault is not encrypted, sealed in chunks of Chunk
+// bytes once it is.
+type AttachmentLayout struct {
+	ID       string
+	Size     int64
+	Chunk    int64
+	overhead int64
+}
+
+// AttachmentLayout returns the layout uploads of a file take with the
+// current cipher. id may be empty until the attachment is created.
+func (a *API) AttachmentLayout(id string, size, chunk int64) AttachmentLayout {
+	layout := AttachmentLayout{ID: id, Size: size, Chunk: chunk}
+	if a.cipher != nil {
+		layout.overhead = int64(a.cipher.Overhead())
+	}
+	return layout
+}
+
+// Sealed reports whether chunks are sealed before upload.
+func (l AttachmentLayout) Sealed() bool {
+	return l.overhead > 0
+}
+
+func (l AttachmentLayout) record() int64 {
+	return recordLengthSize + l.overhead + chunkPrefixSize + l.Chunk
+}
+
+// StoredSize is the size to create the attachment with.
+func (l AttachmentLayout) StoredSize() int64 {
+	if !l.Sealed() {
+		return l.Size
+	}
+	// Even an empty file is sealed as one chunk, the last.
+	chunks := max(1, (l.Size+l.Chunk-1)/l.Chunk)
+	return int64(attachmentHeaderSize) + chunks*(l.record()-l.Chunk) + l.Size
+}
+
+// FileOffset maps how much of the attachment the server holds to where the
+// upload continues in the file.
+func (l AttachmentLayout) FileOffset(received int64) (int64, error) {
+	if !l.Sealed() || received == 0 {
+		return received, nil
+	}
+	body := received - int64(attachmentHeaderSize)
+	if body <= 0 || body%l.record() != 0 {
+		return 0, fmt.Errorf("server holds %d bytes, not a whole number of sealed chunks", received)
+	}
+	return body / l.record() * l.Chunk, nil
+}
+
+// SealAttachmentChunk returns what to upload for the file bytes data read
+// at offset, which must start a chunk. Chunks are sealed under the request
+// idempotency key, so a retried chunk is sent as the same bytes.
+func (a *API) SealAttachmentChunk(ctx context.Context, layout AttachmentLayout, offset int64, data []byte) ([]byte, error) {
+	if !layout.Sealed() {
+		return data, nil
+	}
+	if offset%layout.Chunk != 0 || int64(len(data)) > layout.Chunk {
+		return nil, fmt.Errorf("chunk at %d does not match the attachment layout", offset)
+	}
+	index := offset / layout.Chunk
+	last := offset+int64(len(data)) == layout.Size
+
+	plain := make([]byte, 0, chunkPrefixSize+len(data))
+	plain = append(plain, attachmentTag(layout.ID)...)
+	plain = binary.BigEndian.AppendUint64(plain, uint64(index))
+	plain = append(plain, boolByte(last))
+	plain = append(plain, data...)
+	key, _ := apiclient.IdempotencyKey(ctx)
+	sealed, err := a.cipher.Seal(key, []string{base64.StdEncoding.EncodeToString(plain)})
+	if err != nil {
+		return nil, err
+	}
+	record, err := base64.StdEncoding.DecodeString(sealed[0])
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(record)) != layout.overhead+int64(len(plain)) {
+		return nil, errors.New("cipher overhead does not match the attachment layout")
+	}
+
+	var out []byte
+	if index == 0 {
+		out = append(out, attachmentMagic...)
+		out = append(out, attachmentVersion)
+		out = binary.BigEndian.AppendUint32(out, uint32(layout.Chunk))
+		out = binary.BigEndian.AppendUint64(out, uint64(layout.Size))
+	}
+	out = binary.BigEndian.AppendUint32(out, uint32(len(record)))
+	return append(out, record...), nil
+}
+
+// DownloadAttachment writes the file stored as meta to w, opening it chunk
+// by chunk when it is sealed, and returns how many file bytes it wrote.
+func (a *API) DownloadAttachment(ctx context.Context, accessToken string, meta dtoattachment.AttachmentResponse, w io.Writer) (int64, error) {
+	content, wait := a.openDownload(ctx, accessToken, meta.ID)
+	written, err := a.openAttachment(meta.ID, content, w)
+	content.CloseWithError(err)
+	stored, downloadErr := wait()
+	if downloadErr != nil && (err == nil || !errors.Is(downloadErr, err)) {
+		return written, downloadErr
+	}
+	if err != nil {
+		return written, err
+	}
+	if stored != meta.Size {
+		return written, fmt.Errorf("downloaded %d of %d bytes", stored, meta.Size)
+	}
+	return written, nil
+}
+
+// AttachmentFileSize returns the size of the file stored as meta. For a
+// sealed attachment it reads the header at the start of its content.
+func (a *API) AttachmentFileSize(ctx context.Context, accessToken string, meta dtoattachment.AttachmentResponse) (int64, error) {
+	_, size, err := a.peekAttachment(ctx, accessToken, meta)
+	return size, err
+}
+
+// AttachmentSealed reports whether the content stored as meta is sealed.
+func (a *API) AttachmentSealed(ctx context.Context, accessToken string, meta dtoattachment.AttachmentResponse) (bool, error) {
+	sealed, _, err := a.peekAttachment(ctx, accessToken, meta)
+	return sealed, err
+}
+
+// peekAttachment reads just the header of an attachment's content.
+func (a *API) peekAttachment(ctx context.Context, accessToken string, meta dtoattachment.AttachmentResponse) (bool, int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	content, wait := a.openDownload(ctx, accessToken, meta.ID)
+	head := make([]byte, attachmentHeaderSize)
+	n, err := io.ReadFull(content, head)
+	cancel()
+	content.CloseWithError(context.Canceled)
+	_, _ = wait()
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, 0, err
+	}
+	if !isSealedAttachment(head[:n]) {
+		return false, meta.Size, nil
+	}
+	_, size, err := parseAttachmentHeader(head[:n])
+	return true, size, err
+}
+
+// openDownload streams the stored content of attachment id. wait returns
+// how many bytes the server sent and how the transfer ended; the caller
+// closes the reader first if it stops reading early.
+func (a *API) openDownload(ctx context.Context, accessToken, id string) (*io.PipeReader, func() (int64, error)) {
+	pr, pw := io.Pipe()
+	type result struct {
+		n   int64
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := a.client.Download(ctx, "/attachments/"+id+"/content", authHeader(accessToken), pw)
+		_ = pw.CloseWithError(err)
+		done <- result{n: n, err: err}
+	}()
+	return pr, func() (int64, error) {
+		r := <-done
+		return r.n, r.err
+	}
+}
+
+func (a *API) openAttachment(id string, content io.Reader, w io.Writer) (int64, error) {
+	r := bufio.NewReader(content)
+	head, err := r.Peek(attachmentHeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	if !isSealedAttachment(head) {
+		return io.Copy(w, r)
+	}
+	if a.cipher == nil {
+		return 0, errors.New("attachment is sealed and no vault key is available")
+	}
+	chunk, size, err := parseAttachmentHeader(head)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.Discard(attachmentHeaderSize); err != nil {
+		return 0, err
+	}
+
+	tag := attachmentTag(id)
+	var written int64
+	for index := uint64(0); ; index++ {
+		var length [recordLengthSize]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return written, fmt.Errorf("%w: chunks missing", ErrAttachmentDamaged)
+			}
+			return written, err
+		}
+		recordSize := int64(binary.BigEndian.Uint32(length[:]))
+		if recordSize > chunk+chunkPrefixSize+maxRecordOverhead {
+			return written, fmt.Errorf("%w: chunk too large", ErrAttachmentDamaged)
+		}
+		record := make([]byte, recordSize)
+		if _, err := io.ReadFull(r, record); err != nil {
+			return written, err
+		}
+		opened, err := a.cipher.Open([]string{base64.StdEncoding.EncodeToString(record)})
+		if err != nil {
+			return written, err
+		}
+		plain, err := base64.StdEncoding.DecodeString(opened[0])
+		if err != nil || len(plain) < chunkPrefixSize || !bytes.Equal(plain[:attachmentTagSize], tag) ||
+			binary.BigEndian.Uint64(plain[attachmentTagSize:]) != index {
+			return written, ErrAttachmentDamaged
+		}
+		last := plain[chunkPrefixSize-1] == 1
+		data := plain[chunkPrefixSize:]
+		if int64(len(data)) > chunk || (!last && int64(len(data)) != chunk) || written+int64(len(data)) > size {
+			return written, ErrAttachmentDamaged
+		}
+		n, err := w.Write(data)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		if last {
+			break
+		}
+	}
+	if written != size {
+		return written, fmt.Errorf("%w: %d of %d bytes", ErrAttachmentDamaged, written, size)
+	}
+	if _, err := r.ReadByte(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return written, err
+		}
+		return written, fmt.Errorf("%w: data after the last chunk", ErrAttachmentDamaged)
+	}
+	return written, nil
+}
+
+func isSealedAttachment(head []byte) bool {
+	return len(head) >= len(attachmentMagic) && bytes.HasPrefix(head, []byte(attachmentMagic))
+}
+
+func parseAttachmentHeader(head []byte) (chunk, size int64, err error) {
+	if len(head) < attachmentHeaderSize || head[len(attachmentMagic)] != attachmentVersion {
+		return 0, 0, fmt.Errorf("%w: unknown header", ErrAttachmentDamaged)
+	}
+	chunk = int64(binary.BigEndian.Uint32(head[len(attachmentMagic)+1:]))
+	size = int64(binary.BigEndian.Uint64(head[len(attachmentMagic)+5:]))
+	if chunk == 0 || size < 0 {
+		return 0, 0, fmt.Errorf("%w: unknown header", ErrAttachmentDamaged)
+	}
+	return chunk, size, nil
+}
+
+// attachmentTag ties sealed chunks to the attachment they were uploaded to.
+func attachmentTag(id string) []byte {
+	sum := sha256.Sum256([]byte("pkeeper attachment\x00" + id))
+	return sum[:attachmentTagSize]
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
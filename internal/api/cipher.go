@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
+)
+
+// Cipher encrypts secret payloads before upload and decrypts the ones
+// downloaded, so the server only keeps data it cannot read. Both work on
+// the base64 ciphertext field, all secrets of a call at once.
+//
+// Seal gets the idempotency key of the request, empty when it has none.
+// Sealing the same payloads under the same key must give the same result,
+// or the server would take a retried upload for a reused key.
+//
+// Overhead is how many bytes Seal adds to a payload, zero while it uploads
+// payloads as they are.
+type Cipher interface {
+	Seal(requestKey string, ciphertexts []string) ([]string, error)
+	Open(ciphertexts []string) ([]string, error)
+	Overhead() int
+}
+
+// WithCipher makes every secret call of a go through cipher.
+func (a *API) WithCipher(cipher Cipher) *API {
+	a.cipher = cipher
+	return a
+}
+
+func (a *API) sealPayload(ctx context.Context, payload dtosecret.SecretPayload) (dtosecret.SecretPayload, error) {
+	if a.cipher == nil {
+		return payload, nil
+	}
+	key, _ := apiclient.IdempotencyKey(ctx)
+	sealed, err := a.cipher.Seal(key, []string{payload.Ciphertext})
+	if err != nil {
+		return dtosecret.SecretPayload{}, err
+	}
+	payload.Ciphertext = sealed[0]
+	return payload, nil
+}
+
+// sealBatch seals the secrets of req without touching the caller's ones.
+func (a *API) sealBatch(ctx context.Context, req dtosecret.BatchRequest) (dtosecret.BatchRequest, error) {
+	if a.cipher == nil {
+		return req, nil
+	}
+	ops := make([]dtosecret.BatchOperation, len(req.Operations))
+	copy(ops, req.Operations)
+	var plain []string
+	for _, op := range ops {
+		if op.Secret != nil {
+			plain = append(plain, op.Secret.Ciphertext)
+		}
+	}
+	if len(plain) == 0 {
+		return req, nil
+	}
+	key, _ := apiclient.IdempotencyKey(ctx)
+	sealed, err := a.cipher.Seal(key, plain)
+	if err != nil {
+		return dtosecret.BatchRequest{}, err
+	}
+	for i := range ops {
+		if ops[i].Secret == nil {
+			continue
+		}
+		secret := *ops[i].Secret
+		secret.Ciphertext, sealed = sealed[0], sealed[1:]
+		ops[i].Secret = &secret
+	}
+	req.Operations = ops
+	return req, nil
+}
+
+// openFields decrypts the ciphertext fields in place.
+func (a *API) openFields(fields []*string) error {
+	if a.cipher == nil || len(fields) == 0 {
+		return nil
+	}
+	sealed := make([]string, len(fields))
+	for i, field := range fields {
+		sealed[i] = *field
+	}
+	plain, err := a.cipher.Open(sealed)
+	if err != nil {
+		return err
+	}
+	for i, field := range fields {
+		*field = plain[i]
+	}
+	return nil
+}
+
+func (a *API) openSecrets(secrets []dtosecret.SecretResponse) error {
+	fields := make([]*string, len(secrets))
+	for i := range secrets {
+		fields[i] = &secrets[i].Ciphertext
+	}
+	return a.openFields(fields)
+}
+
+func (a *API) openVersions(versions []dtosecret.SecretVersionResponse) error {
+	fields := make([]*string, len(versions))
+	for i := range versions {
+		fields[i] = &versions[i].Ciphertext
+	}
+	return a.openFields(fields)
+}
+
+func (a *API) openBatch(resp *dtosecret.BatchResponse) error {
+	var fields []*string
+	for _, result := range resp.Results {
+		if result.Secret != nil {
+			fields = append(fields, &result.Secret.Ciphertext)
+		}
+	}
+	return a.openFields(fields)
+}
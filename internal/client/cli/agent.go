@@ -0,0 +1,545 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultagent"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultcache"
+	"github.com/charmbracelet/x/term"
+)
+
+const (
+	agentSocketEnv   = "PKEEPER_AGENT_SOCK"
+	agentStartWait   = 3 * time.Second
+	agentStartPoll   = 50 * time.Millisecond
+	agentIdleUsage   = "Lock after this long without use"
+	agentMaxUsage    = "Lock this long after unlocking"
+	agentSocketUsage = "Agent socket path"
+)
+
+var (
+	errEmptyPassword     = errors.New("master password is empty")
+	errVaultNotEncrypted = errors.New("vault is not encrypted yet, run pkeeper unlock first")
+)
+
+// readMasterPassword asks for the master password on the terminal, or reads
+// the first line of stdin when it is not one. It never comes from flags or
+// the environment, where other processes could see it.
+var readMasterPassword = func(prompt io.Writer) ([]byte, error) {
+	return readHidden(prompt, "Master password: ")
+}
+
+// readHidden reads a line from the terminal without echoing it, or the first
+// line of stdin when it is not a terminal.
+func readHidden(prompt io.Writer, label string) ([]byte, error) {
+	if term.IsTerminal(os.Stdin.Fd()) {
+		_, _ = fmt.Fprint(prompt, label)
+		line, err := term.ReadPassword(os.Stdin.Fd())
+		_, _ = fmt.Fprintln(prompt)
+		return line, err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(string(line), "\r\n")), nil
+}
+
+// spawnAgent starts pkeeper agent serve in the background. Tests replace it
+// with an agent served in-process.
+var spawnAgent = func(socket string, opts vaultagent.Options) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return vaultagent.Spawn(exe, "agent", "serve",
+		"--socket", socket,
+		"--idle", opts.Idle.String(),
+		"--max", opts.Max.String())
+}
+
+// agentSocketPath is where the agent listens: $PKEEPER_AGENT_SOCK or a
+// socket next to the session file.
+func agentSocketPath() (string, error) {
+	if override := os.Getenv(agentSocketEnv); override != "" {
+		return override, nil
+	}
+	path, err := sessionPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "agent.sock"), nil
+}
+
+func newAgentClient() (*vaultagent.Client, error) {
+	path, err := agentSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	return vaultagent.NewClient(path), nil
+}
+
+// keyCheckPath is the file proving a master password right for an account.
+// Once it exists, the account's vault is encrypted and uploads are sealed.
+func keyCheckPath(sess session) (string, error) {
+	path, err := sessionPath()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(sess.UserID))
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf("vault-%x.check", sum[:8])), nil
+}
+
+func loadKeyCheck(sess session) ([]byte, error) {
+	path, err := keyCheckPath(sess)
+	if err != nil {
+		return nil, err
+	}
+	check, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return check, err
+}
+
+func saveKeyCheck(sess session, check []byte) error {
+	path, err := keyCheckPath(sess)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, check, 0o600)
+}
+
+// requireEncryptedVault refuses to go on while uploads to the account's vault
+// would be stored as they are.
+func requireEncryptedVault(sess session) error {
+	check, err := loadKeyCheck(sess)
+	if err != nil {
+		return err
+	}
+	if len(check) == 0 {
+		return errVaultNotEncrypted
+	}
+	return nil
+}
+
+// agentCipher seals and opens secret payloads through the vault agent, so
+// the server only stores what it cannot read.
+type agentCipher struct {
+	client  *vaultagent.Client
+	account string
+	// required is set once the vault is encrypted. Before that, uploads go
+	// as they are and only sealed downloads need the agent.
+	required bool
+}
+
+func newAgentCipher(sess session) (api.Cipher, error) {
+	client, err := newAgentClient()
+	if err != nil {
+		return nil, err
+	}
+	path, err := keyCheckPath(sess)
+	if err != nil {
+		return nil, err
+	}
+	_, statErr := os.Stat(path)
+	return agentCipher{client: client, account: sess.UserID, required: statErr == nil}, nil
+}
+
+func (c agentCipher) Seal(requestKey string, ciphertexts []string) ([]string, error) {
+	if !c.required {
+		return ciphertexts, nil
+	}
+	data := make([][]byte, len(ciphertexts))
+	for i, ciphertext := range ciphertexts {
+		decoded, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("ciphertext must be valid base64: %w", err)
+		}
+		data[i] = decoded
+	}
+	sealed, err := c.client.Seal(c.account, requestKey, data)
+	if err != nil {
+		return nil, err
+	}
+	return encodePayloads(sealed), nil
+}
+
+func (c agentCipher) Open(ciphertexts []string) ([]string, error) {
+	data := make([][]byte, len(ciphertexts))
+	anySealed := false
+	for i, ciphertext := range ciphertexts {
+		decoded, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			// Not something Seal produced; leave it to the caller.
+			decoded = nil
+		}
+		data[i] = decoded
+		anySealed = anySealed || vaultagent.IsSealed(decoded)
+	}
+	if !anySealed {
+		return ciphertexts, nil
+	}
+	opened, err := c.client.Open(c.account, data)
+	if err != nil {
+		return nil, err
+	}
+	out := encodePayloads(opened)
+	for i := range out {
+		if data[i] == nil {
+			out[i] = ciphertexts[i]
+		}
+	}
+	return out, nil
+}
+
+func (c agentCipher) Overhead() int {
+	if !c.required {
+		return 0
+	}
+	return vaultagent.Overhead
+}
+
+// agentKeySealer seals the offline cache key with the vault key.
+type agentKeySealer struct {
+	client  *vaultagent.Client
+	account string
+}
+
+func (s agentKeySealer) SealKey(key []byte) ([]byte, error) {
+	sealed, err := s.client.Seal(s.account, "", [][]byte{key})
+	if err != nil {
+		return nil, err
+	}
+	return sealed[0], nil
+}
+
+func (s agentKeySealer) OpenKey(sealed []byte) ([]byte, error) {
+	// The agent passes unsealed data through; a plain key here was not
+	// written by SealKey.
+	if !vaultagent.IsSealed(sealed) {
+		return nil, vaultcache.ErrDecrypt
+	}
+	opened, err := s.client.Open(s.account, [][]byte{sealed})
+	if err != nil {
+		return nil, err
+	}
+	return opened[0], nil
+}
+
+func encodePayloads(data [][]byte) []string {
+	out := make([]string, len(data))
+	for i, item := range data {
+		out[i] = base64.StdEncoding.EncodeToString(item)
+	}
+	return out
+}
+
+func runAgent(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("agent command is required: start, serve, status or stop")
+	}
+	switch args[0] {
+	case "start":
+		return runAgentStart(args[1:], stdout)
+	case "serve":
+		return runAgentServe(args[1:], stdout, stderr)
+	case "status":
+		return runAgentStatus(args[1:], stdout)
+	case "stop":
+		return runAgentStop(args[1:], stdout)
+	default:
+		return fmt.Errorf("unknown agent command: %s", args[0])
+	}
+}
+
+func parseAgentOptions(fs *flag.FlagSet) *vaultagent.Options {
+	opts := &vaultagent.Options{}
+	fs.DurationVar(&opts.Idle, "idle", vaultagent.DefaultIdle, agentIdleUsage)
+	fs.DurationVar(&opts.Max, "max", vaultagent.DefaultMax, agentMaxUsage)
+	return opts
+}
+
+func runAgentStart(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("agent start", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	opts := parseAgentOptions(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, started, err := ensureAgent(*opts)
+	if err != nil {
+		return err
+	}
+	if !started {
+		_, err = fmt.Fprintf(stdout, "vault agent already running on %s\n", client.Path())
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "vault agent started on %s\n", client.Path())
+	return err
+}
+
+// ensureAgent returns a client of a running agent, starting one with opts
+// when there is none.
+func ensureAgent(opts vaultagent.Options) (*vaultagent.Client, bool, error) {
+	client, err := newAgentClient()
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := client.Status(); err == nil {
+		return client, false, nil
+	} else if !errors.Is(err, vaultagent.ErrNoAgent) {
+		return nil, false, err
+	}
+	if err := spawnAgent(client.Path(), opts); err != nil {
+		return nil, false, fmt.Errorf("start vault agent: %w", err)
+	}
+	deadline := time.Now().Add(agentStartWait)
+	for {
+		_, err := client.Status()
+		if err == nil {
+			return client, true, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, false, fmt.Errorf("vault agent did not start: %w", err)
+		}
+		time.Sleep(agentStartPoll)
+	}
+}
+
+// runAgentServe runs the agent in the foreground until it is stopped or
+// interrupted.
+func runAgentServe(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("agent serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	socket := fs.String("socket", "", agentSocketUsage)
+	opts := parseAgentOptions(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path := strings.TrimSpace(*socket)
+	if path == "" {
+		var err error
+		if path, err = agentSocketPath(); err != nil {
+			return err
+		}
+	}
+
+	ln, err := vaultagent.Listen(path)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+	_, _ = fmt.Fprintf(stdout, "vault agent listening on %s\n", path)
+	if err := vaultagent.New(*opts).Serve(ln); err != nil {
+		_, _ = fmt.Fprintf(stderr, "vault agent stopped: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+type agentStatusView struct {
+	Running  bool   `json:"running"`
+	Socket   string `json:"socket"`
+	Unlocked bool   `json:"unlocked"`
+	Account  string `json:"account,omitempty"`
+	LocksAt  string `json:"locks_at,omitempty"`
+}
+
+func runAgentStatus(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("agent status", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := newAgentClient()
+	if err != nil {
+		return err
+	}
+	view := agentStatusView{Socket: client.Path()}
+	status, err := client.Status()
+	switch {
+	case errors.Is(err, vaultagent.ErrNoAgent):
+	case err != nil:
+		return err
+	default:
+		view.Running = true
+		view.Unlocked = status.Unlocked
+		view.Account = status.Account
+		if status.Unlocked {
+			view.LocksAt = status.LocksAt.UTC().Format(time.RFC3339)
+		}
+	}
+	return printJSON(stdout, view)
+}
+
+func runAgentStop(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("agent stop", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := newAgentClient()
+	if err != nil {
+		return err
+	}
+	err = client.Stop()
+	if errors.Is(err, vaultagent.ErrNoAgent) {
+		_, err = fmt.Fprintln(stdout, "vault agent is not running")
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, "vault agent stopped")
+	return err
+}
+
+func runUnlock(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("unlock", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	opts := parseAgentOptions(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	password, err := readMasterPassword(stderr)
+	if err != nil {
+		return err
+	}
+	status, err := unlockVault(strings.TrimSpace(*serverURL), password, *opts)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "vault unlocked until %s\n", status.LocksAt.Local().Format(time.RFC3339))
+	return err
+}
+
+// UnlockVault unlocks the vault of the signed-in account with the default
+// agent timeouts and returns when it locks again.
+func UnlockVault(password []byte) (time.Time, error) {
+	status, err := unlockVault("", password, vaultagent.Options{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return status.LocksAt, nil
+}
+
+// unlockVault hands the master password to the agent, starting it first if
+// needed. The first unlock of an account also encrypts its vault from now
+// on; the password is then checked against secrets already sealed on the
+// server, so a typo cannot split the vault between two keys, and whatever
+// is still stored unsealed is sealed before the vault counts as encrypted.
+func unlockVault(overrideURL string, password []byte, opts vaultagent.Options) (vaultagent.Status, error) {
+	defer func() {
+		for i := range password {
+			password[i] = 0
+		}
+	}()
+	if len(password) == 0 {
+		return vaultagent.Status{}, errEmptyPassword
+	}
+	sess, err := loadSession()
+	if err != nil {
+		return vaultagent.Status{}, err
+	}
+	if sess.UserID == "" || sess.KDFSalt == "" {
+		return vaultagent.Status{}, errors.New("session has no key salt, run signin again")
+	}
+	salt, err := base64.StdEncoding.DecodeString(sess.KDFSalt)
+	if err != nil {
+		return vaultagent.Status{}, fmt.Errorf("session key salt is damaged: %w", err)
+	}
+	check, err := loadKeyCheck(sess)
+	if err != nil {
+		return vaultagent.Status{}, err
+	}
+
+	client, _, err := ensureAgent(opts)
+	if err != nil {
+		return vaultagent.Status{}, err
+	}
+	status, newCheck, err := client.Unlock(sess.UserID, password, salt, check)
+	if err != nil || len(check) > 0 {
+		return status, err
+	}
+
+	if err := verifyServerSeal(overrideURL); err != nil {
+		_ = client.Lock()
+		if errors.Is(err, vaultagent.ErrDecrypt) {
+			return vaultagent.Status{}, vaultagent.ErrWrongPassword
+		}
+		return vaultagent.Status{}, fmt.Errorf("cannot check the master password against the server: %w", err)
+	}
+	if err := sealVault(overrideURL, client); err != nil {
+		_ = client.Lock()
+		return vaultagent.Status{}, fmt.Errorf("cannot encrypt the vault: %w", err)
+	}
+	if err := saveKeyCheck(sess, newCheck); err != nil {
+		_ = client.Lock()
+		return vaultagent.Status{}, err
+	}
+	return status, nil
+}
+
+// verifyServerSeal opens every secret on the server with the key just
+// unlocked; one sealed under another key fails with ErrDecrypt.
+func verifyServerSeal(overrideURL string) error {
+	sess, _, err := runAuthorizedRequest(overrideURL, func(ctx context.Context, client *api.API, accessToken string, sess session) ([]dtosecret.SecretResponse, error) {
+		return client.ListSecrets(ctx, accessToken, "")
+	})
+	if err != nil {
+		return err
+	}
+	return saveSession(sess)
+}
+
+func runLock(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("lock", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := LockVault(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(stdout, "vault locked")
+	return err
+}
+
+// LockVault makes the agent forget the vault key. A vault without a running
+// agent is locked already.
+func LockVault() error {
+	client, err := newAgentClient()
+	if err != nil {
+		return err
+	}
+	if err := client.Lock(); err != nil && !errors.Is(err, vaultagent.ErrNoAgent) {
+		return err
+	}
+	return nil
+}
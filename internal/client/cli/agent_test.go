@@ -0,0 +1,472 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	dtoattachment "github.com/7StaSH7/practicum-diploma/internal/dto/attachment"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultagent"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultcache"
+)
+
+// installTestAgent makes the CLI start its agent in-process on a short
+// socket path and answer the password prompt with *password.
+func installTestAgent(t *testing.T, password *string) {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "pkcli")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	t.Setenv(agentSocketEnv, filepath.Join(dir, "agent.sock"))
+
+	prevSpawn, prevRead := spawnAgent, readMasterPassword
+	var listener io.Closer
+	done := make(chan error, 1)
+	spawnAgent = func(socket string, opts vaultagent.Options) error {
+		ln, err := vaultagent.Listen(socket)
+		if err != nil {
+			return err
+		}
+		listener = ln
+		go func() { done <- vaultagent.New(opts).Serve(ln) }()
+		return nil
+	}
+	readMasterPassword = func(io.Writer) ([]byte, error) {
+		return []byte(*password), nil
+	}
+	t.Cleanup(func() {
+		spawnAgent, readMasterPassword = prevSpawn, prevRead
+		if listener != nil {
+			_ = listener.Close()
+			<-done
+		}
+	})
+}
+
+// unlockTestVault signs in a session whose vault is encrypted and unlocked
+// in an in-process agent.
+func unlockTestVault(t *testing.T) {
+	t.Helper()
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	sess := session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		KDFSalt:      base64.StdEncoding.EncodeToString([]byte("salt-of-u-1")),
+	}
+	if err := saveSession(sess); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	password := "master"
+	installTestAgent(t, &password)
+	client, _, err := ensureAgent(vaultagent.Options{})
+	if err != nil {
+		t.Fatalf("start agent: %v", err)
+	}
+	_, check, err := client.Unlock(sess.UserID, []byte(password), []byte("salt-of-u-1"), nil)
+	if err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if err := saveKeyCheck(sess, check); err != nil {
+		t.Fatalf("save key check: %v", err)
+	}
+}
+
+// serveSealBatch applies the updates of a batch to stored the way the server
+// does, refusing those that expect another version.
+func serveSealBatch(t *testing.T, req *http.Request, stored []dtosecret.SecretResponse) *http.Response {
+	t.Helper()
+	var batch dtosecret.BatchRequest
+	if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+		t.Fatalf("decode batch: %v", err)
+	}
+	resp := dtosecret.BatchResponse{Mode: batch.Mode, Committed: true}
+	for i, op := range batch.Operations {
+		item := dtosecret.BatchItemResult{Index: i, Op: op.Op, ID: op.ID, Status: dtosecret.BatchStatusFailed, Error: "not_found"}
+		for j := range stored {
+			if stored[j].ID != op.ID {
+				continue
+			}
+			if op.Op != dtosecret.BatchOpUpdate || op.ExpectedVersion != stored[j].Version {
+				item.Error = "version_conflict"
+				break
+			}
+			stored[j].Ciphertext = op.Secret.Ciphertext
+			stored[j].Version++
+			secret := stored[j]
+			item.Status, item.Error, item.Secret = dtosecret.BatchStatusOK, "", &secret
+			break
+		}
+		resp.Results = append(resp.Results, item)
+	}
+	return jsonResponse(http.StatusOK, resp)
+}
+
+func TestUnlockedAgentSealsUploadsAndOpensDownloads(t *testing.T) {
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	if err := saveSession(session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		KDFSalt:      base64.StdEncoding.EncodeToString([]byte("salt-of-u-1")),
+	}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	password := "master"
+	installTestAgent(t, &password)
+
+	stored := []dtosecret.SecretResponse{
+		{ID: "s-1", Type: "note", MetaOpen: models.MetaOpen{Title: "legacy"}, Ciphertext: "b25l", Version: 1, UpdatedAt: "2026-03-01T10:00:00Z"},
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, stored), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets/s-2":
+			return jsonResponse(http.StatusOK, stored[1]), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/batch":
+			return serveSealBatch(t, req, stored), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets/s-1/attachments":
+			return jsonResponse(http.StatusOK, []any{}), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets":
+			var payload dtosecret.SecretPayload
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+			secret := dtosecret.SecretResponse{ID: "s-2", Type: payload.Type, MetaOpen: payload.MetaOpen, Ciphertext: payload.Ciphertext, Version: 1, UpdatedAt: "2026-03-02T09:00:00Z"}
+			stored = append(stored, secret)
+			return jsonResponse(http.StatusCreated, secret), nil
+		default:
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			return nil, nil
+		}
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"unlock"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("unlock: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "vault unlocked until") {
+		t.Fatalf("unexpected unlock output: %s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "create", "--type", "note", "--ciphertext", "bmV3", "--title", "new"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("create: exit code=%d stderr=%s", code, stderr.String())
+	}
+	uploaded, err := base64.StdEncoding.DecodeString(stored[1].Ciphertext)
+	if err != nil || !vaultagent.IsSealed(uploaded) {
+		t.Fatalf("the server must get a sealed payload, got %q", stored[1].Ciphertext)
+	}
+	if !strings.Contains(stdout.String(), `"bmV3"`) {
+		t.Fatalf("the command must print the opened payload: %s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "list"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("list: exit code=%d stderr=%s", code, stderr.String())
+	}
+	ciphertexts := map[string]string{}
+	for _, secret := range decodeSecretList(t, stdout.Bytes()) {
+		ciphertexts[secret.ID] = secret.Ciphertext
+	}
+	if ciphertexts["s-1"] != "b25l" || ciphertexts["s-2"] != "bmV3" {
+		t.Fatalf("unexpected list: %v", ciphertexts)
+	}
+
+	if code := run([]string{"lock"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("lock: exit code=%d stderr=%s", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"secrets", "get", "--id", "s-2"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected a locked vault to refuse sealed secrets")
+	}
+	if code := run([]string{"secrets", "create", "--type", "note", "--ciphertext", "bmV3"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected a locked vault to refuse uploads")
+	}
+	if !strings.Contains(stderr.String(), "vault is locked") {
+		t.Fatalf("unexpected error: %s", stderr.String())
+	}
+
+	password = "typo"
+	stderr.Reset()
+	if code := run([]string{"unlock"}, &stdout, &stderr); code == 0 || !strings.Contains(stderr.String(), "wrong master password") {
+		t.Fatalf("expected a wrong password to be refused, code=%d stderr=%s", code, stderr.String())
+	}
+
+	// On a new device there is no key check yet: the password is checked
+	// against what the server holds.
+	sess, err := loadSession()
+	if err != nil {
+		t.Fatalf("load session: %v", err)
+	}
+	checkPath, err := keyCheckPath(sess)
+	if err != nil {
+		t.Fatalf("check path: %v", err)
+	}
+	if err := os.Remove(checkPath); err != nil {
+		t.Fatalf("remove key check: %v", err)
+	}
+	stderr.Reset()
+	if code := run([]string{"unlock"}, &stdout, &stderr); code == 0 || !strings.Contains(stderr.String(), "wrong master password") {
+		t.Fatalf("expected a wrong first password to be refused, code=%d stderr=%s", code, stderr.String())
+	}
+	if _, err := os.Stat(checkPath); !os.IsNotExist(err) {
+		t.Fatalf("a refused password must not be kept: %v", err)
+	}
+}
+
+func TestLockedVaultKeepsOfflineCacheClosed(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(dir, "session.json"))
+	if err := saveSession(session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		KDFSalt:      base64.StdEncoding.EncodeToString([]byte("salt-of-u-1")),
+	}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	// A cache written before the vault was encrypted, under the device key.
+	if err := vaultcache.NewStore(dir, "u-1").Update(func(c *vaultcache.Cache) error {
+		c.Replace(offlineSecrets())
+		return nil
+	}); err != nil {
+		t.Fatalf("seed cache: %v", err)
+	}
+	password := "master"
+	installTestAgent(t, &password)
+	stored := offlineSecrets()
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, stored), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/batch":
+			return serveSealBatch(t, req, stored), nil
+		case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/attachments"):
+			return jsonResponse(http.StatusOK, []any{}), nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"unlock"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("unlock: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if code := run([]string{"secrets", "list"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("list: exit code=%d stderr=%s", code, stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"secrets", "list", "--offline"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("offline list while unlocked: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if len(decodeSecretList(t, stdout.Bytes())) != len(offlineSecrets()) {
+		t.Fatalf("unexpected offline list: %s", stdout.String())
+	}
+
+	if code := run([]string{"lock"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("lock: exit code=%d stderr=%s", code, stderr.String())
+	}
+	for _, args := range [][]string{
+		{"secrets", "list", "--offline"},
+		{"secrets", "get", "--id", offlineSecrets()[0].ID, "--offline"},
+	} {
+		stdout.Reset()
+		stderr.Reset()
+		if code := run(args, &stdout, &stderr); code == 0 {
+			t.Fatalf("%v: expected a locked vault to keep the cache closed, got %s", args, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), "vault is locked") {
+			t.Fatalf("%v: unexpected error: %s", args, stderr.String())
+		}
+	}
+
+	// Without the agent the key file next to the cache is no help.
+	if err := os.Remove(filepath.Join(dir, "vault.key")); err != nil && !os.IsNotExist(err) {
+		t.Fatalf("remove device key: %v", err)
+	}
+	stderr.Reset()
+	if code := run([]string{"secrets", "list", "--offline"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected the offline list to stay locked")
+	}
+}
+
+func TestFirstUnlockSealsWhatTheServerHolds(t *testing.T) {
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	if err := saveSession(session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		KDFSalt:      base64.StdEncoding.EncodeToString([]byte("salt-of-u-1")),
+	}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	password := "master"
+	installTestAgent(t, &password)
+	prevChunk := attachmentChunkSize
+	attachmentChunkSize = 4
+	t.Cleanup(func() { attachmentChunkSize = prevChunk })
+
+	stored := []dtosecret.SecretResponse{
+		{ID: "s-1", Type: "note", MetaOpen: models.MetaOpen{Title: "wifi"}, Ciphertext: "b25l", Version: 1, UpdatedAt: "2026-03-01T10:00:00Z"},
+		{ID: "s-2", Type: "note", MetaOpen: models.MetaOpen{Title: "door"}, Ciphertext: "dHdv", Version: 2, UpdatedAt: "2026-03-01T11:00:00Z"},
+	}
+	content := []byte("0123456789")
+	files := map[string][]byte{"a-1": content}
+	attachments := map[string]dtoattachment.AttachmentResponse{
+		"a-1": {ID: "a-1", SecretID: "s-1", Name: "key.bin", Size: 10, Received: 10, Complete: true},
+	}
+	state := func(id string) dtoattachment.AttachmentResponse {
+		meta := attachments[id]
+		meta.Received = int64(len(files[id]))
+		meta.Complete = meta.Received == meta.Size
+		return meta
+	}
+	batches := 0
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		id := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/attachments/"), "/content")
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, stored), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/batch":
+			batches++
+			if batches == 1 {
+				// Another device edits s-2 after it was listed.
+				stored[1].Ciphertext, stored[1].Version = "ZWRpdGVk", 3
+			}
+			return serveSealBatch(t, req, stored), nil
+		case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/attachments"):
+			var list []dtoattachment.AttachmentResponse
+			for attachmentID, meta := range attachments {
+				if "/secrets/"+meta.SecretID+"/attachments" == req.URL.Path {
+					list = append(list, state(attachmentID))
+				}
+			}
+			return jsonResponse(http.StatusOK, list), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/s-1/attachments":
+			var payload dtoattachment.CreateAttachmentRequest
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode create: %v", err)
+			}
+			attachments["a-2"] = dtoattachment.AttachmentResponse{ID: "a-2", SecretID: "s-1", Name: payload.Name, Size: payload.Size}
+			return jsonResponse(http.StatusCreated, state("a-2")), nil
+		case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/content"):
+			return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(bytes.NewReader(files[id]))}, nil
+		case req.Method == http.MethodPut && req.URL.Path == "/attachments/a-2/content":
+			if req.Header.Get("Upload-Offset") != strconv.Itoa(len(files["a-2"])) {
+				return jsonResponse(http.StatusConflict, nil), nil
+			}
+			body, _ := io.ReadAll(req.Body)
+			files["a-2"] = append(files["a-2"], body...)
+			return jsonResponse(http.StatusOK, state("a-2")), nil
+		case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/attachments/"):
+			return jsonResponse(http.StatusOK, state(id)), nil
+		case req.Method == http.MethodDelete && strings.HasPrefix(req.URL.Path, "/attachments/"):
+			delete(attachments, id)
+			delete(files, id)
+			return jsonResponse(http.StatusNoContent, nil), nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"unlock"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("unlock: exit code=%d stderr=%s", code, stderr.String())
+	}
+	for _, secret := range stored {
+		data, err := base64.StdEncoding.DecodeString(secret.Ciphertext)
+		if err != nil || !vaultagent.IsSealed(data) {
+			t.Fatalf("secret %s must be sealed, got %q", secret.ID, secret.Ciphertext)
+		}
+	}
+	if _, ok := attachments["a-1"]; ok || len(attachments) != 1 {
+		t.Fatalf("the plain attachment must be replaced by a sealed copy: %v", attachments)
+	}
+	if bytes.Contains(files["a-2"], []byte("0123")) {
+		t.Fatalf("the server must not keep file bytes: %q", files["a-2"])
+	}
+	sess, err := loadSession()
+	if err != nil {
+		t.Fatalf("load session: %v", err)
+	}
+	if check, err := loadKeyCheck(sess); err != nil || len(check) == 0 {
+		t.Fatalf("expected the key check to be saved once the vault is sealed: %v", err)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "list"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("list: exit code=%d stderr=%s", code, stderr.String())
+	}
+	ciphertexts := map[string]string{}
+	for _, secret := range decodeSecretList(t, stdout.Bytes()) {
+		ciphertexts[secret.ID] = secret.Ciphertext
+	}
+	if ciphertexts["s-1"] != "b25l" || ciphertexts["s-2"] != "ZWRpdGVk" {
+		t.Fatalf("the edit made meanwhile must be kept: %v", ciphertexts)
+	}
+	out := filepath.Join(t.TempDir(), "restored.bin")
+	if code := run([]string{"secrets", "download", "--attachment", "a-2", "--out", out}, &stdout, &stderr); code != 0 {
+		t.Fatalf("download: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if data, err := os.ReadFile(out); err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("unexpected download: %q %v", data, err)
+	}
+}
+
+func TestFirstUnlockKeepsVaultUnencryptedWhenSealingFails(t *testing.T) {
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	sess := session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		KDFSalt:      base64.StdEncoding.EncodeToString([]byte("salt-of-u-1")),
+	}
+	if err := saveSession(sess); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	password := "master"
+	installTestAgent(t, &password)
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, offlineSecrets()), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/batch":
+			return jsonResponse(http.StatusOK, dtosecret.BatchResponse{
+				Mode:    dtosecret.BatchModePerItem,
+				Results: []dtosecret.BatchItemResult{{Index: 0, Op: dtosecret.BatchOpUpdate, Status: dtosecret.BatchStatusFailed, Error: "invalid_ciphertext"}},
+			}), nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"unlock"}, &stdout, &stderr); code == 0 || !strings.Contains(stderr.String(), "cannot encrypt the vault") {
+		t.Fatalf("expected the unlock to fail, code=%d stderr=%s", code, stderr.String())
+	}
+	if check, err := loadKeyCheck(sess); err != nil || len(check) != 0 {
+		t.Fatalf("the vault must not count as encrypted: check=%d err=%v", len(check), err)
+	}
+}
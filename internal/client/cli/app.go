@@ -1,9 +1,13 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/7StaSH7/practicum-diploma/internal/exporter"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
 	"github.com/7StaSH7/practicum-diploma/internal/version"
 )
 
@@ -28,6 +32,26 @@ func Execute(args []string, stdout, stderr io.Writer) int {
 }
 
 func run(args []string, stdout, stderr io.Writer) int {
+	profile, rest, ok, err := splitProfileFlag(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	if ok {
+		code := 0
+		if err := withProfile(profile, func() error {
+			code = runCommand(rest, stdout, stderr)
+			return nil
+		}); err != nil {
+			_, _ = fmt.Fprintf(stderr, "error: %v\n", err)
+			return 2
+		}
+		return code
+	}
+	return runCommand(args, stdout, stderr)
+}
+
+func runCommand(args []string, stdout, stderr io.Writer) int {
 	if len(args) == 0 {
 		printHelp(stdout)
 		return 0
@@ -44,7 +68,41 @@ func run(args []string, stdout, stderr io.Writer) int {
 	case "refresh":
 		err = runRefresh(args[1:], stdout)
 	case "secrets":
-		err = runSecrets(args[1:], stdout)
+		err = runSecrets(args[1:], stdout, stderr)
+	case "profile":
+		err = runProfile(args[1:], stdout, stderr)
+	case "trash":
+		err = runTrash(args[1:], stdout)
+	case "agent":
+		err = runAgent(args[1:], stdout, stderr)
+	case "unlock":
+		err = runUnlock(args[1:], stdout, stderr)
+	case "lock":
+		err = runLock(args[1:], stdout)
+	case "ssh-agent":
+		err = runSSHAgent(args[1:], stdout, stderr)
+	case "ssh-keygen":
+		err = runSSHKeygen(args[1:], stdout, stderr)
+	case "git-credential":
+		err = runGitCredential(args[1:], stdout, stderr)
+	case "docker-credential":
+		err = runDockerCredential(args[1:], stdout, stderr)
+	case "native-host":
+		err = runNativeHost(args[1:], stdout, stderr)
+	case "conflicts":
+		err = runConflicts(args[1:], stdout)
+	case "generate":
+		err = runGenerate(args[1:], stdout)
+	case "import":
+		err = runImport(args[1:], stdout)
+	case "export":
+		err = runExport(args[1:], stdout, stderr)
+	case "restore":
+		err = runRestore(args[1:], stdout, stderr)
+	case "run":
+		err = runRun(args[1:], stdout, stderr)
+	case "inject":
+		err = runInject(args[1:], stdout, stderr)
 	case "help", "-h", "--help":
 		printHelp(stdout)
 	default:
@@ -53,6 +111,10 @@ func run(args []string, stdout, stderr io.Writer) int {
 		return 2
 	}
 
+	var exitErr exitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
 	if err != nil {
 		_, _ = fmt.Fprintf(stderr, "error: %v\n", err)
 		return 1
@@ -67,10 +129,56 @@ func printHelp(w io.Writer) {
 	_, _ = fmt.Fprintln(w, "  signup [--server URL] --login LOGIN --password PASSWORD")
 	_, _ = fmt.Fprintln(w, "  signin [--server URL] --login LOGIN --password PASSWORD")
 	_, _ = fmt.Fprintln(w, "  refresh [--server URL]")
-	_, _ = fmt.Fprintln(w, "  secrets list [--server URL] [--since RFC3339]")
+	_, _ = fmt.Fprintln(w, "  profile list")
+	_, _ = fmt.Fprintln(w, "  profile use NAME [--server URL]   (--server pins the profile to a server)")
+	_, _ = fmt.Fprintln(w, "  profile remove NAME")
+	_, _ = fmt.Fprintln(w, "  unlock [--server URL] [--idle DURATION] [--max DURATION]   (reads the master password from the terminal or stdin)")
+	_, _ = fmt.Fprintln(w, "  lock")
+	_, _ = fmt.Fprintln(w, "  agent start [--idle DURATION] [--max DURATION]")
+	_, _ = fmt.Fprintln(w, "  agent serve [--socket PATH] [--idle DURATION] [--max DURATION]")
+	_, _ = fmt.Fprintln(w, "  agent status|stop")
+	_, _ = fmt.Fprintln(w, "  secrets list [--server URL] [--since RFC3339] [--offline]")
 	_, _ = fmt.Fprintln(w, "  secrets sync [--server URL] [--since RFC3339] [--once]")
-	_, _ = fmt.Fprintln(w, "  secrets get [--server URL] --id UUID")
-	_, _ = fmt.Fprintln(w, "  secrets create [--server URL] --type TYPE --ciphertext BASE64 [--title TEXT] [--tags a,b] [--site URL]")
-	_, _ = fmt.Fprintln(w, "  secrets update [--server URL] --id UUID --type TYPE --ciphertext BASE64 [--title TEXT] [--tags a,b] [--site URL]")
-	_, _ = fmt.Fprintln(w, "  secrets delete [--server URL] --id UUID")
+	_, _ = fmt.Fprintln(w, "  secrets pending")
+	_, _ = fmt.Fprintln(w, "  secrets get [--server URL] --id UUID [--offline]")
+	_, _ = fmt.Fprintln(w, "  secrets create [--server URL] --type TYPE --ciphertext BASE64 [--title TEXT] [--tags a,b] [--site URL] [--match domain|host|regex] [--keys field=NAME,...] [--offline]")
+	_, _ = fmt.Fprintln(w, "  secrets update [--server URL] --id UUID --type TYPE --ciphertext BASE64 [--title TEXT] [--tags a,b] [--site URL] [--match domain|host|regex] [--keys field=NAME,...] [--offline]")
+	_, _ = fmt.Fprintln(w, "  secrets create|update ... --generate|--passphrase [generate options] [--username NAME] [--show]   (login with a generated password, --ciphertext optional)")
+	_, _ = fmt.Fprintln(w, "  secrets match [--server URL] --url URL [--field NAME] [--offline]   (logins for a page, best match first)")
+	_, _ = fmt.Fprintln(w, "  secrets delete [--server URL] --id UUID [--offline]")
+	_, _ = fmt.Fprintln(w, "  secrets history [--server URL] --id UUID [--version N]")
+	_, _ = fmt.Fprintln(w, "  secrets restore [--server URL] --id UUID --version N")
+	_, _ = fmt.Fprintln(w, "  secrets batch [--server URL] --file PATH|- [--per-item]")
+	_, _ = fmt.Fprintln(w, "  secrets attach [--server URL] --id UUID --file PATH [--name NAME] [--resume ATTACHMENT_ID]")
+	_, _ = fmt.Fprintln(w, "  secrets attachments [--server URL] --id UUID")
+	_, _ = fmt.Fprintln(w, "  secrets download [--server URL] --attachment UUID [--out PATH]")
+	_, _ = fmt.Fprintln(w, "  secrets detach [--server URL] --attachment UUID")
+	_, _ = fmt.Fprintln(w, "  secrets totp [--server URL] --id UUID")
+	_, _ = fmt.Fprintln(w, "  secrets totp-import [--server URL] --uri otpauth://totp/... [--title TEXT] [--tags a,b]")
+	_, _ = fmt.Fprintln(w, "  trash list [--server URL]")
+	_, _ = fmt.Fprintln(w, "  trash restore [--server URL] --id UUID")
+	_, _ = fmt.Fprintln(w, "  trash delete [--server URL] --id UUID")
+	_, _ = fmt.Fprintln(w, "  conflicts [list]")
+	_, _ = fmt.Fprintln(w, "  conflicts resolve --id UUID --keep mine|theirs|both")
+	_, _ = fmt.Fprintln(w, "  conflicts log")
+	_, _ = fmt.Fprintln(w, "  import [--server URL] --format keepass-xml|bitwarden-json|chrome-csv|1password-csv --file PATH [--tags a,b] [--dry-run]   (needs an unlocked vault)")
+	_, _ = fmt.Fprintln(w, "  export [--server URL] --out PATH.pkx   (reads the passphrase from $"+exportPassphraseEnv+", the terminal or stdin)")
+	_, _ = fmt.Fprintln(w, "  export [--server URL] --format "+strings.Join(exporter.Formats(), "|")+" --out PATH|- [--tag a,b] [--name NAME]")
+	_, _ = fmt.Fprintln(w, "  restore [--server URL] --in PATH.pkx   (reads the passphrase from $"+exportPassphraseEnv+", the terminal or stdin)")
+	_, _ = fmt.Fprintln(w, "  run [--server URL] --tag a,b [--mask] [--offline] -- COMMAND [ARGS...]")
+	_, _ = fmt.Fprintln(w, "  inject [--server URL] -i TEMPLATE -o PATH|- [--offline]   (references: {{ pkeeper \"title-or-id\" \"field\" }})")
+	_, _ = fmt.Fprintln(w, "  ssh-agent [--server URL] [--socket PATH] [--tag a,b] [--confirm] [--offline]")
+	_, _ = fmt.Fprintln(w, "  ssh-keygen [--server URL] --title TEXT [--comment TEXT] [--tags a,b]")
+	_, _ = fmt.Fprintln(w, "  git-credential [--server URL] [--offline] get|store|erase   (git credential helper, reads the request from stdin)")
+	_, _ = fmt.Fprintln(w, "  docker-credential [--server URL] [--offline] get|store|erase|list   (docker credential helper, also installed as docker-credential-pkeeper)")
+	_, _ = fmt.Fprintln(w, "  native-host   (browser native messaging host, also installed as pkeeper-native-host)")
+	_, _ = fmt.Fprintln(w, "  generate [--length N] [--no-lower] [--no-upper] [--no-digits] [--no-symbols] [--exclude-ambiguous] [--no-require]")
+	_, _ = fmt.Fprintln(w, "  generate --passphrase [--words N] [--separator S] [--capitalize] [--number]")
+	_, _ = fmt.Fprintln(w, "Every command runs in a profile: --profile NAME before the command, $"+profileEnv+", the one picked with profile use, or \""+defaultProfile+"\"")
+	_, _ = fmt.Fprintln(w, "The session file is encrypted with a key from $"+sessionKeyEnv+": auto (keyring, then $"+sessionPassphraseEnv+", then a key file), keyring, passphrase or file ($"+sessionKeyFileEnv+")")
+	_, _ = fmt.Fprintln(w, "Archive passphrase defaults to $"+exportPassphraseEnv)
+	_, _ = fmt.Fprintln(w, "After the first unlock secrets are encrypted through the agent on $"+agentSocketEnv+" (default: next to the session file)")
+	_, _ = fmt.Fprintln(w, "Reads fall back to the offline cache and writes are queued while the server is unreachable")
+	_, _ = fmt.Fprintln(w, "Queued writes are merged with server changes on sync; overlapping edits wait in conflicts")
+	_, _ = fmt.Fprintln(w, "Secret types: "+strings.Join(secretkind.Kinds(), ", "))
 }
@@ -3,6 +3,7 @@ package cli
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"path/filepath"
@@ -258,3 +259,124 @@ func TestSecretsSyncUpdatesLastSyncAt(t *testing.T) {
 		t.Fatalf("unexpected last sync: %s", sess.LastSyncAt)
 	}
 }
+
+func TestSecretsCreateRetriesWithSameIdempotencyKey(t *testing.T) {
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	if err := saveSession(session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+	}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	prevDelay := retryDelay
+	retryDelay = 0
+	t.Cleanup(func() { retryDelay = prevDelay })
+
+	var keys []string
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			return nil, errors.New("connection reset by peer")
+		}
+		return jsonResponse(http.StatusOK, dtosecret.SecretResponse{ID: "s-1"}), nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"secrets", "create", "--type", "note", "--ciphertext", "YQ=="}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected one retry, got %d requests", len(keys))
+	}
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Fatalf("expected the same idempotency key on retry, got %q", keys)
+	}
+}
+
+func TestSecretsCreateWaitsForInFlightAttempt(t *testing.T) {
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	if err := saveSession(session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+	}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	prevDelay, prevRetryAfter := retryDelay, maxRetryAfter
+	retryDelay, maxRetryAfter = 0, 0
+	t.Cleanup(func() { retryDelay, maxRetryAfter = prevDelay, prevRetryAfter })
+
+	calls := 0
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			resp := jsonResponse(http.StatusConflict, nil)
+			resp.Header.Set("Retry-After", "1")
+			return resp, nil
+		}
+		return jsonResponse(http.StatusOK, dtosecret.SecretResponse{ID: "s-1"}), nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"secrets", "create", "--type", "note", "--ciphertext", "YQ=="}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if calls != 2 {
+		t.Fatalf("expected the in-flight conflict to be retried, got %d requests", calls)
+	}
+}
+
+func TestSecretsCreateDoesNotRetryPlainConflict(t *testing.T) {
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	if err := saveSession(session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+	}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	prevDelay := retryDelay
+	retryDelay = 0
+	t.Cleanup(func() { retryDelay = prevDelay })
+
+	calls := 0
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		calls++
+		return jsonResponse(http.StatusConflict, map[string]string{"error": "conflict"}), nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"secrets", "create", "--type", "note", "--ciphertext", "YQ=="}, &stdout, &stderr)
+	if code == 0 {
+		t.Fatalf("expected the conflict to fail the request")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a conflict without Retry-After not to be retried, got %d requests", calls)
+	}
+}
+
+func TestSecretsCreateRejectsUnknownType(t *testing.T) {
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request to %s", req.URL)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"secrets", "create", "--type", "password", "--ciphertext", "YQ=="}, &stdout, &stderr)
+	if code == 0 {
+		t.Fatal("expected non-zero exit code")
+	}
+	if !strings.Contains(stderr.String(), "unknown --type") {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+}
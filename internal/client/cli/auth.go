@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtoauth "github.com/7StaSH7/practicum-diploma/internal/dto/auth"
 )
 
 func runSignup(args []string, stdout io.Writer) error {
@@ -22,14 +23,7 @@ func runSignup(args []string, stdout io.Writer) error {
 	if err != nil {
 		return err
 	}
-	sess := session{
-		ServerURL:    cfg.serverURL,
-		UserID:       resp.UserID,
-		AccessToken:  resp.AccessToken,
-		RefreshToken: resp.RefreshToken,
-		KDFSalt:      resp.KDFSalt,
-	}
-	if err := saveSession(sess); err != nil {
+	if err := saveSignedInSession(cfg.serverURL, resp); err != nil {
 		return err
 	}
 	_, err = fmt.Fprintf(stdout, "signup successful, user_id=%s\n", resp.UserID)
@@ -46,18 +40,32 @@ func runSignin(args []string, stdout io.Writer) error {
 	if err != nil {
 		return err
 	}
+	if err := saveSignedInSession(cfg.serverURL, resp); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "signin successful, user_id=%s\n", resp.UserID)
+	return err
+}
+
+// saveSignedInSession stores the tokens of a signup or signin in the active
+// profile. Pinned settings stay; the sync cursor only does for the same user.
+func saveSignedInSession(serverURL string, resp dtoauth.AuthResponse) error {
+	current, err := loadSession()
+	if err != nil && !errors.Is(err, errNoSession) {
+		return err
+	}
 	sess := session{
-		ServerURL:    cfg.serverURL,
+		ServerURL:    serverURL,
 		UserID:       resp.UserID,
 		AccessToken:  resp.AccessToken,
 		RefreshToken: resp.RefreshToken,
 		KDFSalt:      resp.KDFSalt,
+		Pinned:       current.Pinned,
 	}
-	if err := saveSession(sess); err != nil {
-		return err
+	if current.UserID == resp.UserID {
+		sess.LastSyncAt = current.LastSyncAt
 	}
-	_, err = fmt.Fprintf(stdout, "signin successful, user_id=%s\n", resp.UserID)
-	return err
+	return saveSession(sess)
 }
 
 func runRefresh(args []string, stdout io.Writer) error {
@@ -72,7 +80,7 @@ func runRefresh(args []string, stdout io.Writer) error {
 	if err != nil {
 		return err
 	}
-	sess.ServerURL = effectiveServerURL(*serverURL, sess.ServerURL)
+	sess.ServerURL = effectiveServerURL(*serverURL, sess)
 	if sess.ServerURL == "" {
 		return errors.New("server URL is required (--server or SERVER_URL)")
 	}
@@ -113,7 +121,16 @@ func parseAuthFlags(name string, args []string) (struct {
 			password  string
 		}{}, err
 	}
-	resolvedServerURL := effectiveServerURL(*serverURL, "")
+	// Signing in keeps the profile's pinned server, not its old tokens.
+	current, err := loadSession()
+	if err != nil && !errors.Is(err, errNoSession) {
+		return struct {
+			serverURL string
+			login     string
+			password  string
+		}{}, err
+	}
+	resolvedServerURL := effectiveServerURL(*serverURL, session{Pinned: current.Pinned})
 	if resolvedServerURL == "" {
 		return struct {
 			serverURL string
@@ -154,17 +171,25 @@ func withAutoRefresh(sess session, client *api.API, fn func(accessToken string)
 	if sess.RefreshToken == "" {
 		return sess, err
 	}
-	refreshed, refreshErr := client.Refresh(context.Background(), sess.RefreshToken)
+	sess, refreshErr := refreshSession(sess, client)
 	if refreshErr != nil {
 		return sess, refreshErr
 	}
+	if requestErr := fn(sess.AccessToken); requestErr != nil {
+		return sess, requestErr
+	}
+	return sess, nil
+}
+
+func refreshSession(sess session, client *api.API) (session, error) {
+	refreshed, err := client.Refresh(context.Background(), sess.RefreshToken)
+	if err != nil {
+		return sess, err
+	}
 	sess.UserID = refreshed.UserID
 	sess.AccessToken = refreshed.AccessToken
 	sess.RefreshToken = refreshed.RefreshToken
 	sess.KDFSalt = refreshed.KDFSalt
-	if requestErr := fn(sess.AccessToken); requestErr != nil {
-		return sess, requestErr
-	}
 	return sess, nil
 }
 
@@ -173,9 +198,13 @@ func loadSessionAndClient(overrideURL string) (session, *api.API, error) {
 	if err != nil {
 		return session{}, nil, err
 	}
-	sess.ServerURL = effectiveServerURL(overrideURL, sess.ServerURL)
+	sess.ServerURL = effectiveServerURL(overrideURL, sess)
 	if sess.ServerURL == "" {
 		return session{}, nil, errors.New("server URL is missing; use --server or set SERVER_URL")
 	}
-	return sess, api.New(sess.ServerURL, apiHTTPClientFactory()), nil
+	cipher, err := newAgentCipher(sess)
+	if err != nil {
+		return session{}, nil, err
+	}
+	return sess, api.New(sess.ServerURL, apiHTTPClientFactory()).WithCipher(cipher), nil
 }
@@ -14,14 +14,19 @@ func defaultServerURL() string {
 	return strings.TrimSpace(cfg.ServerURL)
 }
 
-func effectiveServerURL(overrideURL, sessionURL string) string {
+// effectiveServerURL picks the server: --server, the profile's pinned
+// server, SERVER_URL, the server the session was signed in on.
+func effectiveServerURL(overrideURL string, sess session) string {
 	override := strings.TrimSpace(overrideURL)
 	if override != "" {
 		return override
 	}
+	if pinned := strings.TrimSpace(sess.Pinned.ServerURL); pinned != "" {
+		return pinned
+	}
 	fromConfig := defaultServerURL()
 	if fromConfig != "" {
 		return fromConfig
 	}
-	return strings.TrimSpace(sessionURL)
+	return strings.TrimSpace(sess.ServerURL)
 }
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// confirmMu keeps concurrent requests from prompting at the same time.
+var confirmMu sync.Mutex
+
+// confirmPrompt asks a yes/no question for commands that run without a
+// usable stdin, such as helpers started by ssh or git: through
+// $SSH_ASKPASS, as ssh-add -c does, or on the controlling terminal.
+// Without either the answer is no.
+var confirmPrompt = func(prompt string) bool {
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+	if askpass := os.Getenv("SSH_ASKPASS"); askpass != "" {
+		cmd := exec.Command(askpass, prompt)
+		cmd.Env = append(os.Environ(), "SSH_ASKPASS_PROMPT=confirm")
+		return cmd.Run() == nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = tty.Close() }()
+	_, _ = fmt.Fprintf(tty, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
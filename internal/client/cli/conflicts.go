@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultcache"
+)
+
+// conflictView is one queued write waiting for a decision, with the three
+// versions it was merged from.
+type conflictView struct {
+	OpID       string                    `json:"op_id"`
+	Op         string                    `json:"op"`
+	SecretID   string                    `json:"secret_id"`
+	Title      string                    `json:"title,omitempty"`
+	Fields     []string                  `json:"fields"`
+	Base       *dtosecret.SecretResponse `json:"base,omitempty"`
+	Mine       *dtosecret.SecretPayload  `json:"mine,omitempty"`
+	Theirs     *dtosecret.SecretResponse `json:"theirs,omitempty"`
+	DetectedAt string                    `json:"detected_at"`
+}
+
+func runConflicts(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return runConflictsList(args, stdout)
+	}
+	switch args[0] {
+	case "list":
+		return runConflictsList(args[1:], stdout)
+	case "resolve":
+		return runConflictsResolve(args[1:], stdout)
+	case "log":
+		return runConflictsLog(args[1:], stdout)
+	default:
+		return fmt.Errorf("unknown conflicts command: %s", args[0])
+	}
+}
+
+func runConflictsList(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("conflicts list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cache, err := loadCache()
+	if err != nil {
+		return err
+	}
+	conflicts := make([]conflictView, 0)
+	for _, op := range cache.Conflicts() {
+		item := conflictView{
+			OpID:       op.ID,
+			Op:         op.Kind,
+			SecretID:   op.SecretID,
+			Fields:     op.Conflict.Fields,
+			Base:       op.Base,
+			Mine:       op.Secret,
+			Theirs:     op.Conflict.Theirs,
+			DetectedAt: op.Conflict.DetectedAt.UTC().Format(time.RFC3339),
+		}
+		switch {
+		case op.Secret != nil:
+			item.Title = op.Secret.MetaOpen.Title
+		case op.Base != nil:
+			item.Title = op.Base.MetaOpen.Title
+		}
+		conflicts = append(conflicts, item)
+	}
+	return printJSON(stdout, conflicts)
+}
+
+// runConflictsResolve records the user's decision for one conflict. The
+// resulting write, if any, is uploaded by the next secrets sync.
+func runConflictsResolve(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("conflicts resolve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	secretID := fs.String("id", "", "Secret ID")
+	keep := fs.String("keep", "", "mine, theirs or both")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	trimmedID := strings.TrimSpace(*secretID)
+	if trimmedID == "" {
+		return errors.New("--id is required")
+	}
+
+	sess, err := loadSession()
+	if err != nil {
+		return err
+	}
+	store, err := cacheStore(sess)
+	if err != nil {
+		return err
+	}
+	var decision vaultcache.Decision
+	if err := store.Update(func(c *vaultcache.Cache) error {
+		var decideErr error
+		decision, decideErr = c.Decide(trimmedID, strings.TrimSpace(*keep), time.Now().UTC())
+		return decideErr
+	}); err != nil {
+		if errors.Is(err, vaultcache.ErrNoConflict) {
+			return fmt.Errorf("secret %s: %w", trimmedID, err)
+		}
+		return err
+	}
+	return printJSON(stdout, decision)
+}
+
+func runConflictsLog(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("conflicts log", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cache, err := loadCache()
+	if err != nil {
+		return err
+	}
+	decisions := cache.Decisions
+	if decisions == nil {
+		decisions = []vaultcache.Decision{}
+	}
+	return printJSON(stdout, decisions)
+}
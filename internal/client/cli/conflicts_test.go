@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultcache"
+)
+
+// serverEditedWifi is s-1 after another device changed its payload.
+func serverEditedWifi() dtosecret.SecretResponse {
+	return dtosecret.SecretResponse{ID: "s-1", Type: "note", MetaOpen: models.MetaOpen{Title: "wifi"}, Ciphertext: "dGhlaXJz", Version: 3, UpdatedAt: "2026-03-02T08:00:00Z"}
+}
+
+// syncAgainstEditedServer runs secrets sync against a server where s-1 moved
+// on to serverEditedWifi. Batch operations are answered by onBatch.
+func syncAgainstEditedServer(t *testing.T, onBatch func(op dtosecret.BatchOperation) dtosecret.BatchItemResult) string {
+	t.Helper()
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/batch":
+			var batch dtosecret.BatchRequest
+			if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			return jsonResponse(http.StatusOK, dtosecret.BatchResponse{Results: []dtosecret.BatchItemResult{onBatch(batch.Operations[0])}}), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets/s-1":
+			return jsonResponse(http.StatusOK, serverEditedWifi()), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, []dtosecret.SecretResponse{}), nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL)
+		return nil, nil
+	})
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "sync", "--once"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("sync: exit code=%d stderr=%s", code, stderr.String())
+	}
+	return stderr.String()
+}
+
+func versionConflictUnlessAt(version int64, op dtosecret.BatchOperation) dtosecret.BatchItemResult {
+	if op.ExpectedVersion != version {
+		return dtosecret.BatchItemResult{Op: op.Op, ID: op.ID, Status: dtosecret.BatchStatusFailed, Error: "version_conflict"}
+	}
+	secret := dtosecret.SecretResponse{ID: op.ID, Type: op.Secret.Type, MetaOpen: op.Secret.MetaOpen, Ciphertext: op.Secret.Ciphertext, Version: version + 1, UpdatedAt: "2026-03-02T09:00:00Z"}
+	return dtosecret.BatchItemResult{Op: op.Op, ID: op.ID, Status: dtosecret.BatchStatusOK, Secret: &secret}
+}
+
+func runJSON(t *testing.T, args []string, out any) {
+	t.Helper()
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("%v: exit code=%d stderr=%s", args, code, stderr.String())
+	}
+	if err := json.Unmarshal(stdout.Bytes(), out); err != nil {
+		t.Fatalf("%v: decode: %v\n%s", args, err, stdout.String())
+	}
+}
+
+func TestSyncMergesDisjointOfflineEdits(t *testing.T) {
+	seedOfflineCache(t)
+	var queued dtosecret.SecretResponse
+	runJSON(t, []string{"secrets", "update", "--offline", "--id", "s-1", "--type", "note", "--ciphertext", "b25l", "--title", "home wifi"}, &queued)
+
+	var uploads []dtosecret.BatchOperation
+	stderr := syncAgainstEditedServer(t, func(op dtosecret.BatchOperation) dtosecret.BatchItemResult {
+		uploads = append(uploads, op)
+		return versionConflictUnlessAt(3, op)
+	})
+	if strings.Contains(stderr, "conflict") {
+		t.Fatalf("disjoint edits must merge silently: %s", stderr)
+	}
+	if len(uploads) != 2 {
+		t.Fatalf("expected the merged write to be retried once, got %+v", uploads)
+	}
+	merged := uploads[1].Secret
+	if merged.MetaOpen.Title != "home wifi" || merged.Ciphertext != "dGhlaXJz" {
+		t.Fatalf("merge must keep both edits: %+v", merged)
+	}
+
+	var pending []pendingWrite
+	runJSON(t, []string{"secrets", "pending"}, &pending)
+	if len(pending) != 0 {
+		t.Fatalf("queue must be drained: %+v", pending)
+	}
+	var decisions []vaultcache.Decision
+	runJSON(t, []string{"conflicts", "log"}, &decisions)
+	if len(decisions) != 1 || decisions[0].Choice != vaultcache.Merged || decisions[0].SecretID != "s-1" {
+		t.Fatalf("the merge must be recorded: %+v", decisions)
+	}
+}
+
+func TestConflictsResolveKeepBoth(t *testing.T) {
+	seedOfflineCache(t)
+	var queued dtosecret.SecretResponse
+	runJSON(t, []string{"secrets", "update", "--offline", "--id", "s-1", "--type", "note", "--ciphertext", "bWluZQ==", "--title", "wifi"}, &queued)
+
+	stderr := syncAgainstEditedServer(t, func(op dtosecret.BatchOperation) dtosecret.BatchItemResult {
+		return versionConflictUnlessAt(3, op)
+	})
+	if !strings.Contains(stderr, "run pkeeper conflicts") {
+		t.Fatalf("expected a pointer to pkeeper conflicts: %s", stderr)
+	}
+
+	var conflicts []conflictView
+	runJSON(t, []string{"conflicts"}, &conflicts)
+	if len(conflicts) != 1 || strings.Join(conflicts[0].Fields, ",") != "payload" ||
+		conflicts[0].Mine.Ciphertext != "bWluZQ==" || conflicts[0].Theirs.Ciphertext != "dGhlaXJz" || conflicts[0].Base.Ciphertext != "b25l" {
+		t.Fatalf("unexpected conflicts: %+v", conflicts)
+	}
+
+	var stdout bytes.Buffer
+	var errOut bytes.Buffer
+	if code := run([]string{"conflicts", "resolve", "--id", "s-1", "--keep", "nobody"}, &stdout, &errOut); code == 0 {
+		t.Fatal("expected an unknown decision to fail")
+	}
+
+	var decision vaultcache.Decision
+	runJSON(t, []string{"conflicts", "resolve", "--id", "s-1", "--keep", "both"}, &decision)
+	if decision.Choice != vaultcache.KeepBoth || decision.NextOpID == "" {
+		t.Fatalf("unexpected decision: %+v", decision)
+	}
+
+	var pending []pendingWrite
+	runJSON(t, []string{"secrets", "pending"}, &pending)
+	if len(pending) != 1 || pending[0].Op != dtosecret.BatchOpCreate || pending[0].Title != "wifi"+vaultcache.ConflictCopySuffix {
+		t.Fatalf("keep both must queue a copy of the local version: %+v", pending)
+	}
+	var list []dtosecret.SecretResponse
+	runJSON(t, []string{"secrets", "list", "--offline"}, &list)
+	ciphertexts := map[string]string{}
+	for _, secret := range list {
+		ciphertexts[secret.MetaOpen.Title] = secret.Ciphertext
+	}
+	if ciphertexts["wifi"] != "dGhlaXJz" || ciphertexts["wifi"+vaultcache.ConflictCopySuffix] != "bWluZQ==" {
+		t.Fatalf("both versions must be listed: %v", ciphertexts)
+	}
+}
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+const (
+	dockerTag = "docker"
+	// errDockerNotFound is the exact text docker looks for on stdout to
+	// tell missing credentials from a failing helper.
+	errDockerNotFound = "credentials not found in native keychain"
+)
+
+// dockerCredentialInput is where docker writes the request.
+var dockerCredentialInput io.Reader = os.Stdin
+
+// dockerCredentials is the JSON docker exchanges with credential helpers.
+type dockerCredentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// runDockerCredential implements the docker credential helper protocol.
+// Docker runs docker-credential-pkeeper, which forwards here, for
+//
+//	{"credsStore": "pkeeper"}
+//
+// Errors go to stdout with exit code 1, where docker expects them.
+func runDockerCredential(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("docker-credential", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	offline := fs.Bool("offline", false, offlineFlagUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("docker-credential operation is required: get, store, erase or list")
+	}
+	trimmedURL := strings.TrimSpace(*serverURL)
+
+	var err error
+	switch fs.Arg(0) {
+	case "get":
+		err = dockerCredentialGet(trimmedURL, *offline, stdout, stderr)
+	case "store":
+		err = dockerCredentialStore(trimmedURL, *offline, stderr)
+	case "erase":
+		err = dockerCredentialErase(trimmedURL, *offline, stderr)
+	case "list":
+		err = dockerCredentialList(trimmedURL, *offline, stdout, stderr)
+	default:
+		err = fmt.Errorf("unknown docker-credential operation: %s", fs.Arg(0))
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(stdout, err)
+		return exitCodeError{code: 1}
+	}
+	return nil
+}
+
+// registryKey normalises a registry address, so https://index.docker.io/v1/
+// and index.docker.io/v1 name the same registry.
+func registryKey(serverURL string) string {
+	raw := strings.TrimSpace(serverURL)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return strings.ToLower(strings.TrimSpace(serverURL))
+	}
+	return strings.ToLower(parsed.Host) + strings.TrimRight(parsed.Path, "/")
+}
+
+func readDockerServerURL() (string, error) {
+	raw, err := io.ReadAll(dockerCredentialInput)
+	if err != nil {
+		return "", err
+	}
+	serverURL := strings.TrimSpace(string(raw))
+	if serverURL == "" {
+		return "", errors.New("no credentials server URL")
+	}
+	return serverURL, nil
+}
+
+// dockerLogins lists the docker-tagged login secrets, most recently
+// updated first.
+func dockerLogins(serverURL string, offline bool, stderr io.Writer) ([]matchedLogin, error) {
+	secrets, err := listSecrets(serverURL, offline, stderr)
+	if err != nil {
+		return nil, err
+	}
+	var logins []matchedLogin
+	for _, secret := range secrets {
+		if secret.Type != secretkind.Login || !slices.Contains(secret.MetaOpen.Tags, dockerTag) || secret.MetaOpen.Site == "" {
+			continue
+		}
+		payload, err := decodeSecretPayload(secret.Type, secret.Ciphertext)
+		if err != nil || payload.Login == nil {
+			continue
+		}
+		logins = append(logins, matchedLogin{secret: secret, login: *payload.Login})
+	}
+	slices.SortStableFunc(logins, func(a, b matchedLogin) int {
+		return strings.Compare(b.secret.UpdatedAt, a.secret.UpdatedAt)
+	})
+	return logins, nil
+}
+
+func findDockerLogin(logins []matchedLogin, registry string) (matchedLogin, bool) {
+	key := registryKey(registry)
+	for _, login := range logins {
+		if registryKey(login.secret.MetaOpen.Site) == key {
+			return login, true
+		}
+	}
+	return matchedLogin{}, false
+}
+
+func dockerCredentialGet(serverURL string, offline bool, stdout, stderr io.Writer) error {
+	registry, err := readDockerServerURL()
+	if err != nil {
+		return err
+	}
+	logins, err := dockerLogins(serverURL, offline, stderr)
+	if err != nil {
+		return err
+	}
+	match, ok := findDockerLogin(logins, registry)
+	if !ok {
+		return errors.New(errDockerNotFound)
+	}
+	return json.NewEncoder(stdout).Encode(dockerCredentials{
+		ServerURL: registry,
+		Username:  match.login.Username,
+		Secret:    match.login.Password,
+	})
+}
+
+// dockerCredentialStore saves what docker login accepted, replacing the
+// credentials kept for the registry before.
+func dockerCredentialStore(serverURL string, offline bool, stderr io.Writer) error {
+	var creds dockerCredentials
+	if err := json.NewDecoder(dockerCredentialInput).Decode(&creds); err != nil {
+		return fmt.Errorf("decode credentials: %w", err)
+	}
+	if strings.TrimSpace(creds.ServerURL) == "" {
+		return errors.New("no credentials server URL")
+	}
+	logins, err := dockerLogins(serverURL, offline, stderr)
+	if err != nil {
+		return err
+	}
+
+	if match, ok := findDockerLogin(logins, creds.ServerURL); ok {
+		if match.login.Username == creds.Username && match.login.Password == creds.Secret {
+			return nil
+		}
+		login := match.login
+		login.Username, login.Password = creds.Username, creds.Secret
+		payload, err := loginSecretPayload(match.secret.MetaOpen, login)
+		if err != nil {
+			return err
+		}
+		_, err = updateSecret(serverURL, match.secret.ID, payload, offline, stderr)
+		return err
+	}
+
+	payload, err := loginSecretPayload(models.MetaOpen{
+		Title: "docker " + registryKey(creds.ServerURL),
+		Site:  creds.ServerURL,
+		Tags:  []string{dockerTag},
+	}, secretkind.LoginData{Username: creds.Username, Password: creds.Secret})
+	if err != nil {
+		return err
+	}
+	_, err = createSecret(serverURL, payload, offline, stderr)
+	return err
+}
+
+// dockerCredentialErase runs on docker logout and moves the registry's
+// credentials to the trash. Nothing to erase is not an error.
+func dockerCredentialErase(serverURL string, offline bool, stderr io.Writer) error {
+	registry, err := readDockerServerURL()
+	if err != nil {
+		return err
+	}
+	logins, err := dockerLogins(serverURL, offline, stderr)
+	if err != nil {
+		return err
+	}
+	key := registryKey(registry)
+	for _, login := range logins {
+		if registryKey(login.secret.MetaOpen.Site) != key {
+			continue
+		}
+		if _, err := deleteSecret(serverURL, login.secret.ID, offline, stderr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dockerCredentialList maps every registry with credentials to its user.
+func dockerCredentialList(serverURL string, offline bool, stdout, stderr io.Writer) error {
+	logins, err := dockerLogins(serverURL, offline, stderr)
+	if err != nil {
+		return err
+	}
+	registries := make(map[string]string, len(logins))
+	seen := make(map[string]bool, len(logins))
+	for _, login := range logins {
+		key := registryKey(login.secret.MetaOpen.Site)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		registries[login.secret.MetaOpen.Site] = login.login.Username
+	}
+	return json.NewEncoder(stdout).Encode(registries)
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+func runDockerHelper(t *testing.T, input string, op string) (string, int) {
+	t.Helper()
+	prev := dockerCredentialInput
+	dockerCredentialInput = strings.NewReader(input)
+	t.Cleanup(func() { dockerCredentialInput = prev })
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"docker-credential", op}, &stdout, &stderr)
+	return stdout.String(), code
+}
+
+func TestDockerCredentialHelper(t *testing.T) {
+	saveTestSession(t)
+	untagged := loginSecret(t, "plain", "https://registry.example.com", "alice", "web-password", "2026-03-01T10:00:00Z")
+	secrets := []dtosecret.SecretResponse{untagged}
+	var writes []string
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, secrets), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets":
+			var payload dtosecret.SecretPayload
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+			secret := dtosecret.SecretResponse{ID: "s-docker", Type: payload.Type, MetaOpen: payload.MetaOpen, Ciphertext: payload.Ciphertext, UpdatedAt: "2026-03-02T10:00:00Z"}
+			secrets = append(secrets, secret)
+			writes = append(writes, "create")
+			return jsonResponse(http.StatusCreated, secret), nil
+		case req.Method == http.MethodDelete && req.URL.Path == "/secrets/s-docker":
+			secrets = secrets[:1]
+			writes = append(writes, "delete")
+			return jsonResponse(http.StatusNoContent, nil), nil
+		default:
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			return nil, nil
+		}
+	})
+
+	out, code := runDockerHelper(t, "https://registry.example.com\n", "get")
+	if code != 1 || strings.TrimSpace(out) != errDockerNotFound {
+		t.Fatalf("logins without the docker tag must not answer: code=%d out=%q", code, out)
+	}
+
+	if out, code := runDockerHelper(t, `{"ServerURL":"https://registry.example.com/","Username":"ci","Secret":"token"}`, "store"); code != 0 {
+		t.Fatalf("store: code=%d out=%s", code, out)
+	}
+	stored := secrets[1]
+	if stored.Type != secretkind.Login || !strings.Contains(strings.Join(stored.MetaOpen.Tags, ","), dockerTag) {
+		t.Fatalf("expected a docker tagged login, got %+v", stored)
+	}
+	if out, code := runDockerHelper(t, `{"ServerURL":"registry.example.com","Username":"ci","Secret":"token"}`, "store"); code != 0 || len(writes) != 1 {
+		t.Fatalf("storing the same credentials again must not write: code=%d out=%s writes=%v", code, out, writes)
+	}
+
+	out, code = runDockerHelper(t, "registry.example.com", "get")
+	var creds dockerCredentials
+	if code != 0 || json.Unmarshal([]byte(out), &creds) != nil || creds.Username != "ci" || creds.Secret != "token" {
+		t.Fatalf("get: code=%d out=%q", code, out)
+	}
+	out, code = runDockerHelper(t, "", "list")
+	if code != 0 || strings.TrimSpace(out) != `{"https://registry.example.com/":"ci"}` {
+		t.Fatalf("list: code=%d out=%q", code, out)
+	}
+
+	if out, code := runDockerHelper(t, "https://registry.example.com", "erase"); code != 0 {
+		t.Fatalf("erase: code=%d out=%s", code, out)
+	}
+	if strings.Join(writes, ",") != "create,delete" {
+		t.Fatalf("unexpected writes: %v", writes)
+	}
+	if _, code := runDockerHelper(t, "https://registry.example.com", "erase"); code != 0 {
+		t.Fatal("erasing missing credentials must succeed")
+	}
+}
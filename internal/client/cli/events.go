@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtoevent "github.com/7StaSH7/practicum-diploma/internal/dto/event"
+)
+
+// WatchChanges follows the server event stream and signals changes for every
+// secret event. Signals are coalesced: a pending one is never duplicated. It
+// returns when the stream ends, fails or ctx is cancelled.
+func WatchChanges(ctx context.Context, overrideURL string, changes chan<- struct{}) error {
+	_, err := streamEvents(ctx, overrideURL, "", changes)
+	return err
+}
+
+// watchAndSync runs sync on every change reported by the event stream. While
+// the stream is unavailable it falls back to polling every syncInterval and
+// tries to resubscribe on each tick, resuming from the last seen event.
+func watchAndSync(ctx context.Context, overrideURL string, sync func() error) error {
+	type streamResult struct {
+		lastEventID string
+		err         error
+	}
+	changes := make(chan struct{}, 1)
+	done := make(chan streamResult, 1)
+	lastEventID := ""
+	subscribe := func() {
+		go func(from string) {
+			id, err := streamEvents(ctx, overrideURL, from, changes)
+			done <- streamResult{lastEventID: id, err: err}
+		}(lastEventID)
+	}
+
+	streaming := true
+	supported := true
+	subscribe()
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changes:
+			if err := sync(); err != nil {
+				return err
+			}
+		case result := <-done:
+			if ctx.Err() != nil {
+				return nil
+			}
+			streaming = false
+			lastEventID = result.lastEventID
+			if api.IsHTTPStatus(result.err, http.StatusNotFound) {
+				supported = false
+			}
+		case <-ticker.C:
+			if streaming {
+				continue
+			}
+			if err := sync(); err != nil {
+				return err
+			}
+			if supported {
+				streaming = true
+				subscribe()
+			}
+		}
+	}
+}
+
+// streamEvents holds one subscription and returns the id of the last event it
+// saw. An expired access token is refreshed once and stored right away, so
+// syncs running next to the stream keep using a valid refresh token.
+func streamEvents(ctx context.Context, overrideURL, lastEventID string, changes chan<- struct{}) (string, error) {
+	sess, client, err := loadSessionAndClient(strings.TrimSpace(overrideURL))
+	if err != nil {
+		return lastEventID, err
+	}
+	onEvent := func(id string, _ dtoevent.EventResponse) error {
+		if id != "" {
+			lastEventID = id
+		}
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+
+	err = client.StreamEvents(ctx, sess.AccessToken, lastEventID, onEvent)
+	if !api.IsHTTPStatus(err, http.StatusUnauthorized) || sess.RefreshToken == "" {
+		return lastEventID, err
+	}
+	sess, err = refreshSession(sess, client)
+	if err != nil {
+		return lastEventID, err
+	}
+	if err := storeTokens(sess); err != nil {
+		return lastEventID, err
+	}
+	err = client.StreamEvents(ctx, sess.AccessToken, lastEventID, onEvent)
+	return lastEventID, err
+}
+
+// storeTokens writes refreshed credentials without clobbering fields that
+// other commands updated in the meantime, such as LastSyncAt.
+func storeTokens(sess session) error {
+	current, err := loadSession()
+	if err != nil && !errors.Is(err, errNoSession) {
+		return err
+	}
+	current.ServerURL = sess.ServerURL
+	current.UserID = sess.UserID
+	current.AccessToken = sess.AccessToken
+	current.RefreshToken = sess.RefreshToken
+	current.KDFSalt = sess.KDFSalt
+	return saveSession(current)
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchAndSyncRunsSyncOnStreamEvent(t *testing.T) {
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	if err := saveSession(session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+	}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/events" {
+			t.Fatalf("unexpected path: %s", req.URL.Path)
+		}
+		if req.Header.Get("Authorization") != "Bearer access" {
+			t.Fatalf("unexpected authorization: %s", req.Header.Get("Authorization"))
+		}
+		body := "id:3\nevent:created\ndata:{\"seq\":3,\"type\":\"created\",\"secret_id\":\"s-1\"}\n\n"
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+		}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	syncs := 0
+	err := watchAndSync(ctx, "", func() error {
+		syncs++
+		cancel()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if syncs != 1 {
+		t.Fatalf("expected one sync triggered by the event, got %d", syncs)
+	}
+}
+
+func TestStreamEventsRefreshesExpiredToken(t *testing.T) {
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	if err := saveSession(session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "expired",
+		RefreshToken: "refresh-1",
+		LastSyncAt:   "2026-02-06T08:00:00Z",
+	}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/auth/refresh":
+			return jsonResponse(http.StatusOK, map[string]string{
+				"user_id":       "u-1",
+				"access_token":  "access-2",
+				"refresh_token": "refresh-2",
+			}), nil
+		case "/events":
+			if req.Header.Get("Authorization") == "Bearer expired" {
+				return jsonResponse(http.StatusUnauthorized, nil), nil
+			}
+			if req.Header.Get("Last-Event-ID") != "9" {
+				t.Fatalf("expected resume from 9, got %q", req.Header.Get("Last-Event-ID"))
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     make(http.Header),
+				Body:       io.NopCloser(bytes.NewReader([]byte("id:10\nevent:updated\ndata:{}\n\n"))),
+			}, nil
+		}
+		t.Fatalf("unexpected path: %s", req.URL.Path)
+		return nil, nil
+	})
+
+	changes := make(chan struct{}, 1)
+	lastID, err := streamEvents(context.Background(), "", "9", changes)
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if lastID != "10" || len(changes) != 1 {
+		t.Fatalf("unexpected stream result: last=%s pending=%d", lastID, len(changes))
+	}
+	sess, err := loadSession()
+	if err != nil {
+		t.Fatalf("load session: %v", err)
+	}
+	if sess.AccessToken != "access-2" || sess.RefreshToken != "refresh-2" || sess.LastSyncAt != "2026-02-06T08:00:00Z" {
+		t.Fatalf("unexpected session after refresh: %+v", sess)
+	}
+}
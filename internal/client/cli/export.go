@@ -0,0 +1,470 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtoattachment "github.com/7StaSH7/practicum-diploma/internal/dto/attachment"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/exporter"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultarchive"
+	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
+	"github.com/charmbracelet/x/term"
+)
+
+// exportPassphraseEnv lets scripts pass the archive passphrase without
+// putting it on the command line, where other processes could see it.
+const exportPassphraseEnv = "PKEEPER_EXPORT_PASSPHRASE"
+
+const minExportPassphrase = 8
+
+type exportSummary struct {
+	Path        string `json:"path"`
+	Secrets     int    `json:"secrets"`
+	Attachments int    `json:"attachments"`
+	Bytes       int64  `json:"bytes"`
+}
+
+type plaintextExportSummary struct {
+	Path      string `json:"path"`
+	Format    string `json:"format"`
+	Secrets   int    `json:"secrets"`
+	Variables int    `json:"variables"`
+}
+
+type restoreError struct {
+	SecretID string `json:"secret_id"`
+	Title    string `json:"title"`
+	Error    string `json:"error"`
+}
+
+type restoreSummary struct {
+	Secrets     int            `json:"secrets"`
+	Restored    int            `json:"restored"`
+	Duplicates  int            `json:"duplicates"`
+	Attachments int            `json:"attachments"`
+	Errors      []restoreError `json:"errors,omitempty"`
+}
+
+// readArchivePassphrase asks for the archive passphrase on the terminal, or
+// reads the first line of stdin when it is not one. A new archive asks twice
+// on a terminal, as a typo would make it impossible to restore.
+var readArchivePassphrase = func(prompt io.Writer, confirm bool) ([]byte, error) {
+	pass, err := readHidden(prompt, "Archive passphrase: ")
+	if err != nil || !confirm || !term.IsTerminal(os.Stdin.Fd()) {
+		return pass, err
+	}
+	again, err := readHidden(prompt, "Repeat passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(pass, again) {
+		return nil, errors.New("passphrases do not match")
+	}
+	return pass, nil
+}
+
+// archivePassphrase takes the passphrase from $PKEEPER_EXPORT_PASSPHRASE or
+// asks for it.
+func archivePassphrase(prompt io.Writer, confirm bool) ([]byte, error) {
+	if pass := os.Getenv(exportPassphraseEnv); pass != "" {
+		return []byte(pass), nil
+	}
+	return readArchivePassphrase(prompt, confirm)
+}
+
+func runExport(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	out := fs.String("out", "", "Output file, - for stdout in plaintext formats")
+	format := fs.String("format", "", "Plaintext format instead of an encrypted archive")
+	tags := fs.String("tag", "", "Comma-separated tags a secret must have to be exported")
+	name := fs.String("name", "", "Kubernetes Secret name for k8s-secret")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path := strings.TrimSpace(*out)
+	if path == "" {
+		return errors.New("--out is required")
+	}
+	if strings.TrimSpace(*format) != "" {
+		return runPlaintextExport(strings.TrimSpace(*serverURL), strings.TrimSpace(*format), path, parseCSV(*tags), strings.TrimSpace(*name), stdout)
+	}
+	if path == "-" {
+		return errors.New("encrypted archives must be written to a file")
+	}
+	pass, err := archivePassphrase(stderr, true)
+	if err != nil {
+		return err
+	}
+	if len(pass) < minExportPassphrase {
+		return fmt.Errorf("archive passphrase must be at least %d characters", minExportPassphrase)
+	}
+
+	sess, summary, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (exportSummary, error) {
+		secrets, err := client.ListSecrets(ctx, accessToken, "")
+		if err != nil {
+			return exportSummary{}, err
+		}
+		archived := make([]vaultarchive.Secret, 0, len(secrets))
+		stored := make(map[string]dtoattachment.AttachmentResponse)
+		for _, secret := range secrets {
+			entry, err := archiveSecret(ctx, client, accessToken, secret, stored)
+			if err != nil {
+				return exportSummary{}, err
+			}
+			archived = append(archived, entry)
+		}
+		manifest := vaultarchive.Manifest{
+			CreatedAt: time.Now().UTC(),
+			Server:    sess.ServerURL,
+			UserID:    sess.UserID,
+		}
+		return writeArchive(ctx, client, accessToken, path, pass, manifest, archived, stored)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	return printJSON(stdout, summary)
+}
+
+// runPlaintextExport decrypts the selected secrets and renders them for
+// deployment tooling. Any secret that cannot be rendered fails the whole
+// export, so a config file never silently misses a value.
+func runPlaintextExport(serverURL, format, path string, tags []string, name string, stdout io.Writer) error {
+	if !slices.Contains(exporter.Formats(), format) {
+		return fmt.Errorf("unknown --format %q (expected one of: %s)", format, strings.Join(exporter.Formats(), ", "))
+	}
+	sess, secrets, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) ([]dtosecret.SecretResponse, error) {
+		return client.ListSecrets(ctx, accessToken, "")
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+
+	vars, exported, err := collectVariables(secrets, tags)
+	if err != nil {
+		return err
+	}
+
+	var rendered bytes.Buffer
+	if err := exporter.Render(&rendered, format, vars, exporter.Options{Name: name}); err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err := stdout.Write(rendered.Bytes())
+		return err
+	}
+	if _, err := writePrivateFile(path, func(w io.Writer) error {
+		_, err := w.Write(rendered.Bytes())
+		return err
+	}); err != nil {
+		return err
+	}
+	return printJSON(stdout, plaintextExportSummary{Path: path, Format: format, Secrets: exported, Variables: len(vars)})
+}
+
+// collectVariables decrypts the secrets carrying all tags and maps them to
+// variables. It also reports how many secrets matched.
+func collectVariables(secrets []dtosecret.SecretResponse, tags []string) ([]exporter.Var, int, error) {
+	var vars []exporter.Var
+	matched := 0
+	for _, secret := range secrets {
+		if !hasAllTags(secret.MetaOpen.Tags, tags) {
+			continue
+		}
+		payload, err := decodeSecretPayload(secret.Type, secret.Ciphertext)
+		if err != nil {
+			return nil, 0, fmt.Errorf("secret %s (%s): %w", secret.ID, secret.MetaOpen.Title, err)
+		}
+		secretVars, err := exporter.Variables(secret.MetaOpen, payload)
+		if err != nil {
+			return nil, 0, fmt.Errorf("secret %s (%s): %w", secret.ID, secret.MetaOpen.Title, err)
+		}
+		vars = append(vars, secretVars...)
+		matched++
+	}
+	return vars, matched, nil
+}
+
+func hasAllTags(have, want []string) bool {
+	for _, tag := range want {
+		if !slices.Contains(have, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// archiveSecret lists the attachments of a secret and records them in
+// stored as the server holds them. The archive keeps them opened, so it
+// declares the size of the file. Unfinished uploads have no content worth
+// keeping and are left out.
+func archiveSecret(ctx context.Context, client *api.API, accessToken string, secret dtosecret.SecretResponse, stored map[string]dtoattachment.AttachmentResponse) (vaultarchive.Secret, error) {
+	entry := vaultarchive.Secret{
+		ID:         secret.ID,
+		Type:       secret.Type,
+		MetaOpen:   secret.MetaOpen,
+		Ciphertext: secret.Ciphertext,
+		Version:    secret.Version,
+		UpdatedAt:  secret.UpdatedAt,
+	}
+	attachments, err := client.ListAttachments(ctx, accessToken, secret.ID)
+	if err != nil {
+		return vaultarchive.Secret{}, err
+	}
+	for _, attachment := range attachments {
+		if !attachment.Complete {
+			continue
+		}
+		size, err := client.AttachmentFileSize(ctx, accessToken, attachment)
+		if err != nil {
+			return vaultarchive.Secret{}, err
+		}
+		stored[attachment.ID] = attachment
+		entry.Attachments = append(entry.Attachments, vaultarchive.Attachment{
+			ID:   attachment.ID,
+			Name: attachment.Name,
+			Size: size,
+		})
+	}
+	return entry, nil
+}
+
+// writeArchive streams attachment content straight into the archive.
+func writeArchive(ctx context.Context, client *api.API, accessToken, path string, passphrase []byte, manifest vaultarchive.Manifest, secrets []vaultarchive.Secret, stored map[string]dtoattachment.AttachmentResponse) (exportSummary, error) {
+	summary := exportSummary{Path: path, Secrets: len(secrets)}
+	size, err := writePrivateFile(path, func(w io.Writer) error {
+		archive, err := vaultarchive.NewWriter(w, passphrase, manifest, secrets)
+		if err != nil {
+			return err
+		}
+		for _, secret := range secrets {
+			for _, attachment := range secret.Attachments {
+				aw, err := archive.AddAttachment(attachment.ID)
+				if err != nil {
+					return err
+				}
+				written, err := client.DownloadAttachment(ctx, accessToken, stored[attachment.ID], aw)
+				if err != nil {
+					return err
+				}
+				if written != attachment.Size {
+					return fmt.Errorf("attachment %s: downloaded %d of %d bytes", attachment.ID, written, attachment.Size)
+				}
+				summary.Attachments++
+			}
+		}
+		return archive.Close()
+	})
+	if err != nil {
+		return exportSummary{}, err
+	}
+	summary.Bytes = size
+	return summary, nil
+}
+
+// writePrivateFile writes into a 0600 temporary file next to path and
+// renames it into place, so an interrupted export never leaves a truncated
+// file and a pre-existing file never keeps wider permissions.
+func writePrivateFile(path string, write func(w io.Writer) error) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.part")
+	if err != nil {
+		return 0, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := tmp.Chmod(0o600); err != nil {
+		return 0, err
+	}
+	if err := write(tmp); err != nil {
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	committed = true
+	return info.Size(), nil
+}
+
+func runRestore(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	in := fs.String("in", "", "Archive file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path := strings.TrimSpace(*in)
+	if path == "" {
+		return errors.New("--in is required")
+	}
+	pass, err := archivePassphrase(stderr, false)
+	if err != nil {
+		return err
+	}
+	if len(pass) == 0 {
+		return errors.New("archive passphrase is required")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// Authenticate the whole archive before touching the vault, so a
+	// damaged file never leaves a half-restored account behind.
+	verified, err := vaultarchive.Verify(file, pass)
+	if err != nil {
+		return err
+	}
+	owners := make(map[string]string)
+	for _, secret := range verified.Secrets {
+		for _, attachment := range secret.Attachments {
+			owners[attachment.ID] = secret.ID
+		}
+	}
+
+	// Progress survives a token refresh or retry so the replay continues
+	// where the previous attempt stopped.
+	summary := restoreSummary{Secrets: len(verified.Secrets)}
+	secretsDone := false
+	restored := map[string]string{}
+	started := map[string]string{}
+	uploaded := map[string]bool{}
+	sess, _, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (struct{}, error) {
+		if !secretsDone {
+			if err := restoreSecrets(ctx, client, accessToken, verified.Secrets, restored, &summary); err != nil {
+				return struct{}{}, err
+			}
+			secretsDone = true
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return struct{}{}, err
+		}
+		archive, err := vaultarchive.Open(file, pass)
+		if err != nil {
+			return struct{}{}, err
+		}
+		for {
+			attachment, content, err := archive.Next()
+			if errors.Is(err, io.EOF) {
+				return struct{}{}, nil
+			}
+			if err != nil {
+				return struct{}{}, err
+			}
+			secretID, ok := restored[owners[attachment.ID]]
+			if !ok || uploaded[attachment.ID] {
+				continue
+			}
+			// The command key already went with the batch; reusing it for
+			// a different request is rejected by the server.
+			attachmentCtx := ctx
+			if key, ok := apiclient.IdempotencyKey(ctx); ok {
+				attachmentCtx = apiclient.WithIdempotencyKey(ctx, key+"-att-"+attachment.ID)
+			}
+			var current dtoattachment.AttachmentResponse
+			if id, ok := started[attachment.ID]; ok {
+				current, err = client.GetAttachment(attachmentCtx, accessToken, id)
+			} else {
+				size := client.AttachmentLayout("", attachment.Size, attachmentChunkSize).StoredSize()
+				current, err = client.CreateAttachment(attachmentCtx, accessToken, secretID, attachment.Name, size)
+			}
+			if err != nil {
+				return struct{}{}, err
+			}
+			started[attachment.ID] = current.ID
+			if _, err := uploadAttachment(attachmentCtx, client, accessToken, content, attachment.Size, current); err != nil {
+				return struct{}{}, err
+			}
+			uploaded[attachment.ID] = true
+			summary.Attachments++
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	return printJSON(stdout, summary)
+}
+
+// restoreSecrets creates the archived secrets that the vault does not
+// already hold and records the new ID of each one. Duplicates keep their
+// existing attachments, so theirs are not uploaded again.
+func restoreSecrets(ctx context.Context, client *api.API, accessToken string, secrets []vaultarchive.Secret, restored map[string]string, summary *restoreSummary) error {
+	existing, err := client.ListSecrets(ctx, accessToken, "")
+	if err != nil {
+		return err
+	}
+	seen := existingFingerprints(existing)
+	operations := make([]dtosecret.BatchOperation, 0, len(secrets))
+	queued := make([]vaultarchive.Secret, 0, len(secrets))
+	for _, secret := range secrets {
+		if fingerprint, ok := secretFingerprint(secret.Type, secret.MetaOpen, secret.Ciphertext); ok {
+			if _, duplicate := seen[fingerprint]; duplicate {
+				summary.Duplicates++
+				continue
+			}
+			seen[fingerprint] = struct{}{}
+		}
+		operations = append(operations, dtosecret.BatchOperation{Op: dtosecret.BatchOpCreate, Secret: &dtosecret.SecretPayload{
+			Type:       secret.Type,
+			MetaOpen:   secret.MetaOpen,
+			Ciphertext: secret.Ciphertext,
+		}})
+		queued = append(queued, secret)
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+	result, err := sendBatch(ctx, client, accessToken, dtosecret.BatchModePerItem, operations)
+	if err != nil {
+		return err
+	}
+	for _, item := range result.Results {
+		secret := queued[item.Index]
+		if item.Status != dtosecret.BatchStatusOK {
+			summary.Errors = append(summary.Errors, restoreError{SecretID: secret.ID, Title: secret.MetaOpen.Title, Error: item.Error})
+			continue
+		}
+		restored[secret.ID] = item.ID
+		summary.Restored++
+	}
+	return nil
+}
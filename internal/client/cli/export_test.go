@@ -0,0 +1,386 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	dtoattachment "github.com/7StaSH7/practicum-diploma/internal/dto/attachment"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
+)
+
+const testArchivePassphrase = "archive-passphrase"
+
+// exportTestArchive exports a vault of two secrets, one with an attachment.
+func exportTestArchive(t *testing.T) (string, dtosecret.SecretResponse) {
+	t.Helper()
+	saveTestSession(t)
+	shop := existingShopSecret(t)
+	note := dtosecret.SecretResponse{ID: "s-note", Type: "note", MetaOpen: models.MetaOpen{Title: "Wifi"}, Ciphertext: "aHVudGVyMg=="}
+	content := []byte("attachment content")
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, []dtosecret.SecretResponse{shop, note}), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets/existing/attachments":
+			return jsonResponse(http.StatusOK, []dtoattachment.AttachmentResponse{
+				{ID: "a-1", SecretID: "existing", Name: "recovery.txt", Size: int64(len(content)), Received: int64(len(content)), Complete: true},
+				{ID: "a-2", SecretID: "existing", Name: "partial.bin", Size: 10, Received: 4},
+			}), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets/s-note/attachments":
+			return jsonResponse(http.StatusOK, []dtoattachment.AttachmentResponse{}), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/attachments/a-1/content":
+			return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(bytes.NewReader(content))}, nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	t.Setenv(exportPassphraseEnv, testArchivePassphrase)
+	path := filepath.Join(t.TempDir(), "vault.pkx")
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"export", "--out", path}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	var summary exportSummary
+	if err := json.Unmarshal(stdout.Bytes(), &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if summary.Secrets != 2 || summary.Attachments != 1 || summary.Bytes == 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	return path, shop
+}
+
+func TestExportRequiresStrongPassphrase(t *testing.T) {
+	t.Setenv(exportPassphraseEnv, "short")
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"export", "--out", filepath.Join(t.TempDir(), "vault.pkx")}, &stdout, &stderr); code == 0 {
+		t.Fatalf("expected a short passphrase to be rejected")
+	}
+}
+
+func TestArchivePassphraseIsNeverAFlag(t *testing.T) {
+	t.Setenv(exportPassphraseEnv, "")
+	prev := readArchivePassphrase
+	t.Cleanup(func() { readArchivePassphrase = prev })
+	var confirmed []bool
+	readArchivePassphrase = func(_ io.Writer, confirm bool) ([]byte, error) {
+		confirmed = append(confirmed, confirm)
+		return []byte("short"), nil
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	out := filepath.Join(t.TempDir(), "vault.pkx")
+	if code := run([]string{"export", "--out", out, "--passphrase", testArchivePassphrase}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected --passphrase to be refused")
+	}
+	if code := run([]string{"export", "--out", out}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected a short prompted passphrase to be rejected")
+	}
+	if code := run([]string{"restore", "--in", out}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected restore of a missing archive to fail")
+	}
+	if len(confirmed) != 2 || !confirmed[0] || confirmed[1] {
+		t.Fatalf("expected export to confirm the prompted passphrase and restore not to: %v", confirmed)
+	}
+}
+
+func TestRestoreSkipsSecretsAlreadyInVault(t *testing.T) {
+	path, shop := exportTestArchive(t)
+
+	var batch dtosecret.BatchRequest
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			// The target vault already holds the shop login.
+			return jsonResponse(http.StatusOK, []dtosecret.SecretResponse{shop}), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/batch":
+			if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			results := make([]dtosecret.BatchItemResult, 0, len(batch.Operations))
+			for i := range batch.Operations {
+				results = append(results, dtosecret.BatchItemResult{Index: i, Op: dtosecret.BatchOpCreate, ID: "new-note", Status: dtosecret.BatchStatusOK})
+			}
+			return jsonResponse(http.StatusOK, dtosecret.BatchResponse{Mode: batch.Mode, Committed: true, Results: results}), nil
+		}
+		// Attachments of duplicate secrets are not uploaded again.
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"restore", "--in", path}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if len(batch.Operations) != 1 || batch.Operations[0].Secret.MetaOpen.Title != "Wifi" || batch.Operations[0].Secret.Ciphertext != "aHVudGVyMg==" {
+		t.Fatalf("expected only the missing note to be created: %+v", batch.Operations)
+	}
+	var summary restoreSummary
+	if err := json.Unmarshal(stdout.Bytes(), &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if summary.Secrets != 2 || summary.Restored != 1 || summary.Duplicates != 1 || summary.Attachments != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRestoreUploadsAttachmentsIntoEmptyVault(t *testing.T) {
+	path, _ := exportTestArchive(t)
+
+	var uploaded []byte
+	// Like the idempotency middleware, a key seen with another request is
+	// rejected.
+	keys := map[string]string{}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if key := req.Header.Get(apiclient.IdempotencyKeyHeader); key != "" {
+			body, _ := io.ReadAll(req.Body)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			request := req.Method + " " + req.URL.RequestURI() + "\n" + string(body)
+			if seen, ok := keys[key]; ok && seen != request {
+				return jsonResponse(http.StatusUnprocessableEntity, map[string]string{"error": "idempotency key reused"}), nil
+			}
+			keys[key] = request
+		}
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, []dtosecret.SecretResponse{}), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/batch":
+			var batch dtosecret.BatchRequest
+			if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			results := make([]dtosecret.BatchItemResult, 0, len(batch.Operations))
+			for i, op := range batch.Operations {
+				results = append(results, dtosecret.BatchItemResult{Index: i, Op: dtosecret.BatchOpCreate, ID: "new-" + op.Secret.MetaOpen.Title, Status: dtosecret.BatchStatusOK})
+			}
+			return jsonResponse(http.StatusOK, dtosecret.BatchResponse{Mode: batch.Mode, Committed: true, Results: results}), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/new-Shop/attachments":
+			var payload dtoattachment.CreateAttachmentRequest
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode attachment: %v", err)
+			}
+			if payload.Name != "recovery.txt" {
+				t.Fatalf("unexpected attachment: %+v", payload)
+			}
+			return jsonResponse(http.StatusCreated, dtoattachment.AttachmentResponse{ID: "b-1", Size: payload.Size}), nil
+		case req.Method == http.MethodPut && req.URL.Path == "/attachments/b-1/content":
+			body, _ := io.ReadAll(req.Body)
+			uploaded = append(uploaded, body...)
+			return jsonResponse(http.StatusOK, dtoattachment.AttachmentResponse{ID: "b-1", Size: int64(len(uploaded)), Received: int64(len(uploaded)), Complete: true}), nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"restore", "--in", path}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if string(uploaded) != "attachment content" {
+		t.Fatalf("unexpected attachment content: %q", uploaded)
+	}
+	var summary restoreSummary
+	if err := json.Unmarshal(stdout.Bytes(), &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if summary.Restored != 2 || summary.Attachments != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRestoreStreamsAttachmentPastStoredChunks(t *testing.T) {
+	path, _ := exportTestArchive(t)
+	prevChunk, prevDelay := attachmentChunkSize, retryDelay
+	attachmentChunkSize, retryDelay = 4, 0
+	t.Cleanup(func() { attachmentChunkSize, retryDelay = prevChunk, prevDelay })
+
+	var stored []byte
+	failed := false
+	state := func() dtoattachment.AttachmentResponse {
+		size := int64(len("attachment content"))
+		received := int64(len(stored))
+		return dtoattachment.AttachmentResponse{ID: "b-1", Size: size, Received: received, Complete: received == size}
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, []dtosecret.SecretResponse{}), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/batch":
+			var batch dtosecret.BatchRequest
+			if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			results := make([]dtosecret.BatchItemResult, 0, len(batch.Operations))
+			for i, op := range batch.Operations {
+				results = append(results, dtosecret.BatchItemResult{Index: i, Op: dtosecret.BatchOpCreate, ID: "new-" + op.Secret.MetaOpen.Title, Status: dtosecret.BatchStatusOK})
+			}
+			return jsonResponse(http.StatusOK, dtosecret.BatchResponse{Mode: batch.Mode, Committed: true, Results: results}), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/new-Shop/attachments":
+			return jsonResponse(http.StatusCreated, state()), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/attachments/b-1":
+			return jsonResponse(http.StatusOK, state()), nil
+		case req.Method == http.MethodPut && req.URL.Path == "/attachments/b-1/content":
+			if req.Header.Get("Upload-Offset") != strconv.Itoa(len(stored)) {
+				return jsonResponse(http.StatusConflict, nil), nil
+			}
+			body, _ := io.ReadAll(req.Body)
+			stored = append(stored, body...)
+			// The second chunk is stored but its response is lost, so
+			// the retry starts over from a fresh archive stream.
+			if len(stored) == 8 && !failed {
+				failed = true
+				return jsonResponse(http.StatusBadGateway, nil), nil
+			}
+			return jsonResponse(http.StatusOK, state()), nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"restore", "--in", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if !failed || string(stored) != "attachment content" {
+		t.Fatalf("unexpected attachment content: %q", stored)
+	}
+}
+
+func TestRestoreRejectsDamagedArchiveBeforeWriting(t *testing.T) {
+	path, _ := exportTestArchive(t)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	data[len(data)-1] ^= 1
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		t.Fatalf("damaged archive must not reach the server: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	for _, passphrase := range []string{testArchivePassphrase, "wrong-passphrase"} {
+		t.Setenv(exportPassphraseEnv, passphrase)
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+		if code := run([]string{"restore", "--in", path}, &stdout, &stderr); code == 0 {
+			t.Fatalf("expected restore to fail with passphrase %q", passphrase)
+		}
+	}
+}
+
+func TestPlaintextExportRendersTaggedSecrets(t *testing.T) {
+	saveTestSession(t)
+	encode := func(payload secretkind.Payload) string {
+		encoded, err := secretkind.Encode(payload)
+		if err != nil {
+			t.Fatalf("encode payload: %v", err)
+		}
+		return base64.StdEncoding.EncodeToString(encoded)
+	}
+	secrets := []dtosecret.SecretResponse{
+		{ID: "s-1", Type: secretkind.Login, MetaOpen: models.MetaOpen{Title: "db", Tags: []string{"prod"}, Keys: map[string]string{"username": "DB_USER", "password": "DB_PASSWORD"}},
+			Ciphertext: encode(secretkind.Payload{Kind: secretkind.Login, Login: &secretkind.LoginData{Username: "app", Password: "s3cr\"et"}})},
+		{ID: "s-2", Type: secretkind.APIToken, MetaOpen: models.MetaOpen{Title: "stripe key", Tags: []string{"prod", "billing"}},
+			Ciphertext: encode(secretkind.Payload{Kind: secretkind.APIToken, APIToken: &secretkind.APITokenData{Token: "sk_live"}})},
+		{ID: "s-3", Type: secretkind.Note, MetaOpen: models.MetaOpen{Title: "staging only", Tags: []string{"staging"}}, Ciphertext: "c3RhZ2luZw=="},
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet || req.URL.Path != "/secrets" {
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		}
+		return jsonResponse(http.StatusOK, secrets), nil
+	})
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("stale"), 0o644); err != nil {
+		t.Fatalf("write stale file: %v", err)
+	}
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"export", "--tag", "prod", "--format", "dotenv", "--out", path}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	want := "DB_PASSWORD=\"s3cr\\\"et\"\nDB_USER=\"app\"\nSTRIPE_KEY=\"sk_live\"\n"
+	if string(data) != want {
+		t.Fatalf("unexpected dotenv:\n%s", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat export: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 permissions, got %v", info.Mode().Perm())
+	}
+	var summary plaintextExportSummary
+	if err := json.Unmarshal(stdout.Bytes(), &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if summary.Secrets != 2 || summary.Variables != 3 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	stdout.Reset()
+	code = run([]string{"export", "--tag", "prod,billing", "--format", "k8s-secret", "--name", "billing", "--out", "-"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "  name: billing\n") || !strings.Contains(stdout.String(), "  STRIPE_KEY: c2tfbGl2ZQ==\n") || strings.Contains(stdout.String(), "DB_USER") {
+		t.Fatalf("unexpected manifest:\n%s", stdout.String())
+	}
+}
+
+func TestSecretsCreateValidatesKeyMapping(t *testing.T) {
+	saveTestSession(t)
+	var created dtosecret.SecretPayload
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if err := json.NewDecoder(req.Body).Decode(&created); err != nil {
+			t.Fatalf("decode create: %v", err)
+		}
+		return jsonResponse(http.StatusOK, dtosecret.SecretResponse{ID: "s-1"}), nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"secrets", "create", "--type", "login", "--ciphertext", "YQ==", "--keys", "cvv=DB_CVV"}, &stdout, &stderr)
+	if code == 0 || !strings.Contains(stderr.String(), "unknown field") {
+		t.Fatalf("expected unknown field to be rejected, stderr=%s", stderr.String())
+	}
+
+	stderr.Reset()
+	code = run([]string{"secrets", "create", "--type", "login", "--ciphertext", "YQ==", "--keys", "password=DB_PASSWORD"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if created.MetaOpen.Keys["password"] != "DB_PASSWORD" {
+		t.Fatalf("expected keys to be sent: %+v", created.MetaOpen)
+	}
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"math"
+
+	"github.com/7StaSH7/practicum-diploma/internal/passgen"
+)
+
+type generateOutput struct {
+	Value       string  `json:"value,omitempty"`
+	EntropyBits float64 `json:"entropy_bits"`
+	Strength    string  `json:"strength"`
+}
+
+// generatorFlags are the password and passphrase options, shared by
+// generate and the secret writes that generate a password in place.
+type generatorFlags struct {
+	length           *int
+	noLower          *bool
+	noUpper          *bool
+	noDigits         *bool
+	noSymbols        *bool
+	excludeAmbiguous *bool
+	noRequire        *bool
+	passphrase       *bool
+	words            *int
+	separator        *string
+	capitalize       *bool
+	number           *bool
+}
+
+func addGeneratorFlags(fs *flag.FlagSet) *generatorFlags {
+	defaults := passgen.DefaultPolicy()
+	phraseDefaults := passgen.DefaultPassphrasePolicy()
+	return &generatorFlags{
+		length:           fs.Int("length", defaults.Length, "Password length"),
+		noLower:          fs.Bool("no-lower", false, "Exclude lower case letters"),
+		noUpper:          fs.Bool("no-upper", false, "Exclude upper case letters"),
+		noDigits:         fs.Bool("no-digits", false, "Exclude digits"),
+		noSymbols:        fs.Bool("no-symbols", false, "Exclude symbols"),
+		excludeAmbiguous: fs.Bool("exclude-ambiguous", false, "Exclude look-alike characters"),
+		noRequire:        fs.Bool("no-require", false, "Do not require every enabled class"),
+		passphrase:       fs.Bool("passphrase", false, "Generate a diceware passphrase"),
+		words:            fs.Int("words", phraseDefaults.Words, "Passphrase word count"),
+		separator:        fs.String("separator", phraseDefaults.Separator, "Passphrase word separator"),
+		capitalize:       fs.Bool("capitalize", false, "Capitalize passphrase words"),
+		number:           fs.Bool("number", false, "Append a digit to one passphrase word"),
+	}
+}
+
+func (g *generatorFlags) generate() (generateOutput, error) {
+	var (
+		value   string
+		entropy float64
+		err     error
+	)
+	if *g.passphrase {
+		policy := passgen.PassphrasePolicy{Words: *g.words, Separator: *g.separator, Capitalize: *g.capitalize, Number: *g.number}
+		value, err = policy.Generate()
+		entropy = policy.Entropy()
+	} else {
+		policy := passgen.Policy{
+			Length:           *g.length,
+			Lower:            !*g.noLower,
+			Upper:            !*g.noUpper,
+			Digits:           !*g.noDigits,
+			Symbols:          !*g.noSymbols,
+			ExcludeAmbiguous: *g.excludeAmbiguous,
+			RequireEach:      !*g.noRequire,
+		}
+		value, err = policy.Generate()
+		entropy = policy.Entropy()
+	}
+	if err != nil {
+		return generateOutput{}, err
+	}
+	return generateOutput{
+		Value:       value,
+		EntropyBits: math.Round(entropy*10) / 10,
+		Strength:    passgen.Strength(entropy),
+	}, nil
+}
+
+func runGenerate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	generator := addGeneratorFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	out, err := generator.generate()
+	if err != nil {
+		return err
+	}
+	return printJSON(stdout, out)
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+func TestGeneratePrintsPasswordWithEntropy(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"generate", "--length", "16", "--no-symbols", "--exclude-ambiguous"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	var out generateOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if len(out.Value) != 16 || strings.ContainsAny(out.Value, "!#$%&*+-.:;=?@^_~0O1lI") {
+		t.Fatalf("unexpected password: %q", out.Value)
+	}
+	if out.EntropyBits <= 0 || out.Strength == "" {
+		t.Fatalf("expected entropy estimate: %+v", out)
+	}
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"generate", "--passphrase", "--words", "5", "--separator", "."}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	var out generateOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if len(strings.Split(out.Value, ".")) != 5 {
+		t.Fatalf("unexpected passphrase: %q", out.Value)
+	}
+}
+
+func TestGenerateRejectsEmptyAlphabet(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"generate", "--no-lower", "--no-upper", "--no-digits", "--no-symbols"}, &stdout, &stderr)
+	if code == 0 {
+		t.Fatal("expected non-zero exit code")
+	}
+}
+
+func TestSecretsCreateGeneratesLoginPassword(t *testing.T) {
+	saveTestSession(t)
+	var uploaded dtosecret.SecretPayload
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodPost || req.URL.Path != "/secrets" {
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		}
+		if err := json.NewDecoder(req.Body).Decode(&uploaded); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		return jsonResponse(http.StatusCreated, dtosecret.SecretResponse{ID: "s-1", Type: uploaded.Type, MetaOpen: uploaded.MetaOpen, Ciphertext: uploaded.Ciphertext, Version: 1}), nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "create", "--title", "mail", "--generate", "--length", "24", "--username", "bob"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if uploaded.Type != secretkind.Login {
+		t.Fatalf("expected a login secret, got %q", uploaded.Type)
+	}
+	payload, err := decodeSecretPayload(uploaded.Type, uploaded.Ciphertext)
+	if err != nil || payload.Login == nil {
+		t.Fatalf("decode uploaded login: %v", err)
+	}
+	if payload.Login.Username != "bob" || len(payload.Login.Password) != 24 {
+		t.Fatalf("unexpected login: %+v", payload.Login)
+	}
+	if strings.Contains(stdout.String(), payload.Login.Password) || strings.Contains(stdout.String(), uploaded.Ciphertext) {
+		t.Fatalf("the password must not be printed without --show: %s", stdout.String())
+	}
+	var out generatedSecretOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if out.ID != "s-1" || out.Generated.EntropyBits <= 0 {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "create", "--title", "mail", "--passphrase", "--words", "4", "--show"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	payload, err = decodeSecretPayload(uploaded.Type, uploaded.Ciphertext)
+	if err != nil || payload.Login == nil {
+		t.Fatalf("decode uploaded login: %v", err)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if out.Generated.Value == "" || out.Generated.Value != payload.Login.Password || len(strings.Split(out.Generated.Value, "-")) != 4 {
+		t.Fatalf("--show must print the stored passphrase: %+v", out.Generated)
+	}
+}
+
+func TestSecretsUpdateRotatesGeneratedPassword(t *testing.T) {
+	saveTestSession(t)
+	current, err := loginSecretPayload(models.MetaOpen{Title: "mail", Tags: []string{"work"}}, secretkind.LoginData{Username: "bob", Password: "old", URL: "https://mail.example.com"})
+	if err != nil {
+		t.Fatalf("encode login: %v", err)
+	}
+	var uploaded dtosecret.SecretPayload
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, []dtosecret.SecretResponse{
+				{ID: "s-1", Type: current.Type, MetaOpen: current.MetaOpen, Ciphertext: current.Ciphertext, Version: 1},
+				{ID: "s-2", Type: secretkind.Note, MetaOpen: models.MetaOpen{Title: "note"}, Ciphertext: "b25l", Version: 1},
+			}), nil
+		case req.Method == http.MethodPut && req.URL.Path == "/secrets/s-1":
+			if err := json.NewDecoder(req.Body).Decode(&uploaded); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+			return jsonResponse(http.StatusOK, dtosecret.SecretResponse{ID: "s-1", Type: uploaded.Type, MetaOpen: uploaded.MetaOpen, Ciphertext: uploaded.Ciphertext, Version: 2}), nil
+		default:
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			return nil, nil
+		}
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "update", "--id", "s-1", "--generate", "--tags", "home"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	payload, err := decodeSecretPayload(uploaded.Type, uploaded.Ciphertext)
+	if err != nil || payload.Login == nil {
+		t.Fatalf("decode uploaded login: %v", err)
+	}
+	login := payload.Login
+	if login.Username != "bob" || login.Password == "old" || login.Password == "" || login.URL != "https://mail.example.com" {
+		t.Fatalf("expected only the password to change: %+v", login)
+	}
+	if uploaded.MetaOpen.Title != "mail" || len(uploaded.MetaOpen.Tags) != 1 || uploaded.MetaOpen.Tags[0] != "home" {
+		t.Fatalf("expected the title kept and the tags replaced: %+v", uploaded.MetaOpen)
+	}
+
+	if code := run([]string{"secrets", "update", "--id", "s-2", "--generate"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected a note secret to be refused")
+	}
+	if code := run([]string{"secrets", "create", "--type", secretkind.Note, "--generate"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected --generate with a non-login type to be refused")
+	}
+}
@@ -0,0 +1,279 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+// gitCredentialInput is where git writes the credential description.
+var gitCredentialInput io.Reader = os.Stdin
+
+// gitCredential is the part of git's credential description pkeeper uses.
+type gitCredential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// matchedLogin is a login secret with its decoded payload.
+type matchedLogin struct {
+	secret dtosecret.SecretResponse
+	login  secretkind.LoginData
+}
+
+// runGitCredential implements the git credential helper protocol:
+//
+//	git config --global credential.helper '!pkeeper git-credential'
+//
+// Git appends the operation to the configured command, so flags may come
+// before it as well as after.
+func runGitCredential(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("git-credential", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	offline := fs.Bool("offline", false, offlineFlagUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("git-credential operation is required: get, store or erase")
+	}
+	operation := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected git-credential arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	cred, err := readGitCredential(gitCredentialInput)
+	if err != nil {
+		return err
+	}
+	trimmedURL := strings.TrimSpace(*serverURL)
+
+	switch operation {
+	case "get":
+		return gitCredentialGet(trimmedURL, cred, *offline, stdout, stderr)
+	case "store":
+		return gitCredentialStore(trimmedURL, cred, *offline, stderr)
+	case "erase":
+		return gitCredentialErase(trimmedURL, cred, *offline, stderr)
+	default:
+		// Git may add operations; helpers are expected to ignore them.
+		return nil
+	}
+}
+
+// readGitCredential reads key=value lines up to a blank line or EOF.
+func readGitCredential(r io.Reader) (gitCredential, error) {
+	var cred gitCredential
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return gitCredential{}, fmt.Errorf("malformed credential line %q", line)
+		}
+		switch key {
+		case "protocol":
+			cred.Protocol = value
+		case "host":
+			cred.Host = value
+		case "path":
+			cred.Path = value
+		case "username":
+			cred.Username = value
+		case "password":
+			cred.Password = value
+		case "url":
+			parsed, err := url.Parse(value)
+			if err != nil {
+				return gitCredential{}, fmt.Errorf("malformed credential url: %w", err)
+			}
+			cred.Protocol, cred.Host, cred.Path = parsed.Scheme, parsed.Host, strings.TrimPrefix(parsed.Path, "/")
+			if parsed.User != nil {
+				cred.Username = parsed.User.Username()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return gitCredential{}, err
+	}
+	if cred.Protocol == "" || cred.Host == "" {
+		return gitCredential{}, errors.New("credential needs a protocol and a host")
+	}
+	return cred, nil
+}
+
+// site is how a credential stored by pkeeper names its remote.
+func (c gitCredential) site() string {
+	site := c.Protocol + "://" + c.Host
+	if c.Path != "" {
+		site += "/" + strings.Trim(c.Path, "/")
+	}
+	return site
+}
+
+// matchesSite reports whether a login site names the remote git asks
+// about. Sites without a scheme are taken as https. A site with a path
+// only covers repositories under it, when git sends the path at all.
+func (c gitCredential) matchesSite(site string) bool {
+	site = strings.TrimSpace(site)
+	if site == "" {
+		return false
+	}
+	if !strings.Contains(site, "://") {
+		site = "https://" + site
+	}
+	parsed, err := url.Parse(site)
+	if err != nil || !strings.EqualFold(parsed.Scheme, c.Protocol) || !strings.EqualFold(parsed.Host, c.Host) {
+		return false
+	}
+	prefix := strings.Trim(parsed.Path, "/")
+	path := strings.Trim(c.Path, "/")
+	if prefix == "" || path == "" {
+		return true
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// gitLogins returns the login secrets matching cred, most recently updated
+// first. With a username in cred only logins of that user match.
+func gitLogins(secrets []dtosecret.SecretResponse, cred gitCredential) []matchedLogin {
+	var matches []matchedLogin
+	for _, secret := range secrets {
+		if secret.Type != secretkind.Login || !cred.matchesSite(secret.MetaOpen.Site) {
+			continue
+		}
+		payload, err := decodeSecretPayload(secret.Type, secret.Ciphertext)
+		if err != nil || payload.Login == nil {
+			continue
+		}
+		if cred.Username != "" && payload.Login.Username != cred.Username {
+			continue
+		}
+		matches = append(matches, matchedLogin{secret: secret, login: *payload.Login})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].secret.UpdatedAt > matches[j].secret.UpdatedAt
+	})
+	return matches
+}
+
+func gitCredentialGet(serverURL string, cred gitCredential, offline bool, stdout, stderr io.Writer) error {
+	secrets, err := listSecrets(serverURL, offline, stderr)
+	if err != nil {
+		return err
+	}
+	matches := gitLogins(secrets, cred)
+	if len(matches) == 0 {
+		// No answer lets git try the next helper or prompt.
+		return nil
+	}
+	login := matches[0].login
+	if login.Username != "" {
+		if _, err := fmt.Fprintf(stdout, "username=%s\n", login.Username); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(stdout, "password=%s\n", login.Password)
+	return err
+}
+
+// gitCredentialStore keeps credentials git just used successfully: the
+// matching login gets the new password, or a new login is created.
+func gitCredentialStore(serverURL string, cred gitCredential, offline bool, stderr io.Writer) error {
+	if cred.Password == "" {
+		return nil
+	}
+	secrets, err := listSecrets(serverURL, offline, stderr)
+	if err != nil {
+		return err
+	}
+
+	if matches := gitLogins(secrets, cred); len(matches) > 0 {
+		match := matches[0]
+		if match.login.Password == cred.Password {
+			return nil
+		}
+		login := match.login
+		login.Password = cred.Password
+		payload, err := loginSecretPayload(match.secret.MetaOpen, login)
+		if err != nil {
+			return err
+		}
+		_, err = updateSecret(serverURL, match.secret.ID, payload, offline, stderr)
+		return err
+	}
+
+	title := cred.Host
+	if cred.Username != "" {
+		title = cred.Username + "@" + cred.Host
+	}
+	payload, err := loginSecretPayload(models.MetaOpen{Title: title, Site: cred.site()}, secretkind.LoginData{
+		Username: cred.Username,
+		Password: cred.Password,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = createSecret(serverURL, payload, offline, stderr)
+	return err
+}
+
+// loginSecretPayload encodes login as the payload of a login secret.
+func loginSecretPayload(meta models.MetaOpen, login secretkind.LoginData) (dtosecret.SecretPayload, error) {
+	encoded, err := secretkind.Encode(secretkind.Payload{Kind: secretkind.Login, Login: &login})
+	if err != nil {
+		return dtosecret.SecretPayload{}, err
+	}
+	return dtosecret.SecretPayload{
+		Type:       secretkind.Login,
+		Ciphertext: base64.StdEncoding.EncodeToString(encoded),
+		MetaOpen:   meta,
+	}, nil
+}
+
+// gitCredentialErase moves credentials git rejected to the trash, after
+// the user confirms. Only logins holding the rejected password are
+// touched, so a password changed meanwhile survives.
+func gitCredentialErase(serverURL string, cred gitCredential, offline bool, stderr io.Writer) error {
+	secrets, err := listSecrets(serverURL, offline, stderr)
+	if err != nil {
+		return err
+	}
+	for _, match := range gitLogins(secrets, cred) {
+		if cred.Password != "" && match.login.Password != cred.Password {
+			continue
+		}
+		title := match.secret.MetaOpen.Title
+		if title == "" {
+			title = match.secret.ID
+		}
+		if !confirmPrompt(fmt.Sprintf("git rejected the credentials %q for %s. Move them to the trash?", title, cred.site())) {
+			_, _ = fmt.Fprintf(stderr, "pkeeper: kept %q\n", title)
+			continue
+		}
+		if _, err := deleteSecret(serverURL, match.secret.ID, offline, stderr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+func loginSecret(t *testing.T, id, site, username, password, updatedAt string) dtosecret.SecretResponse {
+	t.Helper()
+	encoded, err := secretkind.Encode(secretkind.Payload{Kind: secretkind.Login, Login: &secretkind.LoginData{Username: username, Password: password}})
+	if err != nil {
+		t.Fatalf("encode login: %v", err)
+	}
+	return dtosecret.SecretResponse{
+		ID:         id,
+		Type:       secretkind.Login,
+		MetaOpen:   models.MetaOpen{Title: id, Site: site},
+		Ciphertext: base64.StdEncoding.EncodeToString(encoded),
+		Version:    1,
+		UpdatedAt:  updatedAt,
+	}
+}
+
+func runGitHelper(t *testing.T, input string, args ...string) string {
+	t.Helper()
+	prev := gitCredentialInput
+	gitCredentialInput = strings.NewReader(input)
+	t.Cleanup(func() { gitCredentialInput = prev })
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run(append([]string{"git-credential"}, args...), &stdout, &stderr); code != 0 {
+		t.Fatalf("%v: exit code=%d stderr=%s", args, code, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestGitCredentialHelper(t *testing.T) {
+	saveTestSession(t)
+	secrets := []dtosecret.SecretResponse{
+		loginSecret(t, "old", "https://git.example.com", "alice", "old-token", "2026-01-01T10:00:00Z"),
+		loginSecret(t, "new", "git.example.com/team", "alice", "new-token", "2026-02-01T10:00:00Z"),
+		loginSecret(t, "bob", "https://git.example.com", "bob", "bob-token", "2026-03-01T10:00:00Z"),
+		loginSecret(t, "other", "https://other.example.com", "alice", "other-token", "2026-03-01T10:00:00Z"),
+	}
+	var writes []string
+	var updated secretkind.Payload
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, secrets), nil
+		case req.Method == http.MethodPut || req.Method == http.MethodPost:
+			var payload dtosecret.SecretPayload
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+			decoded, err := decodeSecretPayload(payload.Type, payload.Ciphertext)
+			if err != nil {
+				t.Fatalf("decode login: %v", err)
+			}
+			updated = decoded
+			writes = append(writes, req.Method+" "+req.URL.Path+" "+payload.MetaOpen.Site)
+			return jsonResponse(http.StatusOK, dtosecret.SecretResponse{ID: "s-new", Type: payload.Type, MetaOpen: payload.MetaOpen, Ciphertext: payload.Ciphertext}), nil
+		case req.Method == http.MethodDelete:
+			writes = append(writes, req.Method+" "+req.URL.Path)
+			return jsonResponse(http.StatusNoContent, nil), nil
+		default:
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			return nil, nil
+		}
+	})
+
+	got := runGitHelper(t, "protocol=https\nhost=git.example.com\npath=team/app.git\nusername=alice\n\n", "get")
+	if got != "username=alice\npassword=new-token\n" {
+		t.Fatalf("expected the newest matching login, got %q", got)
+	}
+	got = runGitHelper(t, "protocol=https\nhost=git.example.com\npath=solo/app.git\nusername=alice\n", "get")
+	if got != "username=alice\npassword=old-token\n" {
+		t.Fatalf("a site with a path must not cover other repositories, got %q", got)
+	}
+	if got := runGitHelper(t, "protocol=https\nhost=unknown.example.com\n", "get"); got != "" {
+		t.Fatalf("an unknown host must get no answer, got %q", got)
+	}
+
+	runGitHelper(t, "protocol=https\nhost=git.example.com\nusername=bob\npassword=bob-token\n", "store")
+	if len(writes) != 0 {
+		t.Fatalf("storing known credentials must not write, got %v", writes)
+	}
+	runGitHelper(t, "protocol=https\nhost=git.example.com\nusername=bob\npassword=rotated\n", "--server", "http://example.test", "store")
+	runGitHelper(t, "protocol=https\nhost=fresh.example.com\nusername=carol\npassword=first\n", "store")
+	if strings.Join(writes, "|") != "PUT /secrets/bob https://git.example.com|POST /secrets https://fresh.example.com" {
+		t.Fatalf("unexpected writes: %v", writes)
+	}
+	if updated.Login == nil || updated.Login.Username != "carol" || updated.Login.Password != "first" {
+		t.Fatalf("unexpected stored login: %+v", updated.Login)
+	}
+
+	writes = nil
+	var prompts []string
+	answer := false
+	prevConfirm := confirmPrompt
+	confirmPrompt = func(prompt string) bool {
+		prompts = append(prompts, prompt)
+		return answer
+	}
+	t.Cleanup(func() { confirmPrompt = prevConfirm })
+	erase := "protocol=https\nhost=other.example.com\nusername=alice\npassword=other-token\n"
+	runGitHelper(t, erase, "erase")
+	if len(prompts) != 1 || len(writes) != 0 {
+		t.Fatalf("erase must ask and keep the login when refused: prompts=%v writes=%v", prompts, writes)
+	}
+	runGitHelper(t, "protocol=https\nhost=other.example.com\nusername=alice\npassword=stale\n", "erase")
+	if len(prompts) != 1 {
+		t.Fatalf("a login with another password must not be offered for erase: %v", prompts)
+	}
+	answer = true
+	runGitHelper(t, erase, "erase")
+	if strings.Join(writes, "|") != "DELETE /secrets/other" {
+		t.Fatalf("unexpected writes: %v", writes)
+	}
+}
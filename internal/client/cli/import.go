@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/importer"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+type importSummary struct {
+	Format     string              `json:"format"`
+	DryRun     bool                `json:"dry_run"`
+	Parsed     int                 `json:"parsed"`
+	Duplicates int                 `json:"duplicates"`
+	ToImport   int                 `json:"to_import"`
+	Imported   int                 `json:"imported"`
+	ByKind     map[string]int      `json:"by_kind"`
+	Errors     []importer.RowError `json:"errors,omitempty"`
+}
+
+func runImport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	format := fs.String("format", "", "Export format")
+	file := fs.String("file", "", "Export file")
+	tags := fs.String("tags", "", "Comma-separated tags added to every imported secret")
+	dryRun := fs.Bool("dry-run", false, "Show what would be imported without uploading")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*format) == "" {
+		return errors.New("--format is required (" + strings.Join(importer.Formats(), "|") + ")")
+	}
+	if strings.TrimSpace(*file) == "" {
+		return errors.New("--file is required")
+	}
+
+	f, err := os.Open(strings.TrimSpace(*file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	parsed, err := importer.Parse(strings.TrimSpace(*format), f)
+	if err != nil {
+		return err
+	}
+	extraTags := parseCSV(*tags)
+
+	sess, summary, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (importSummary, error) {
+		// An import is usually a whole password manager; it only goes to a
+		// vault that seals it.
+		if !*dryRun {
+			if err := requireEncryptedVault(sess); err != nil {
+				return importSummary{}, err
+			}
+		}
+		summary := importSummary{
+			Format: strings.TrimSpace(*format),
+			DryRun: *dryRun,
+			Parsed: len(parsed.Entries),
+			ByKind: map[string]int{},
+			Errors: append([]importer.RowError(nil), parsed.Errors...),
+		}
+		existing, err := client.ListSecrets(ctx, accessToken, "")
+		if err != nil {
+			return importSummary{}, err
+		}
+		entries, duplicates := importer.Dedupe(parsed.Entries, existingFingerprints(existing))
+		summary.Duplicates = duplicates
+
+		operations := make([]dtosecret.BatchOperation, 0, len(entries))
+		queued := make([]importer.Entry, 0, len(entries))
+		for _, entry := range entries {
+			payload, err := importPayload(entry, extraTags)
+			if err != nil {
+				summary.Errors = append(summary.Errors, importer.RowError{Row: entry.Row, Title: entry.Title, Error: err.Error()})
+				continue
+			}
+			summary.ByKind[entry.Payload.Kind]++
+			operations = append(operations, dtosecret.BatchOperation{Op: dtosecret.BatchOpCreate, Secret: &payload})
+			queued = append(queued, entry)
+		}
+		summary.ToImport = len(operations)
+		if *dryRun || len(operations) == 0 {
+			return summary, nil
+		}
+
+		result, err := sendBatch(ctx, client, accessToken, dtosecret.BatchModePerItem, operations)
+		if err != nil {
+			return importSummary{}, err
+		}
+		for _, item := range result.Results {
+			if item.Status == dtosecret.BatchStatusOK {
+				summary.Imported++
+				continue
+			}
+			entry := queued[item.Index]
+			summary.Errors = append(summary.Errors, importer.RowError{Row: entry.Row, Title: entry.Title, Error: item.Error})
+		}
+		return summary, nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	return printJSON(stdout, summary)
+}
+
+// importPayload encodes an imported entry into a create request. The
+// payload is sealed with the vault key by the API client on upload.
+func importPayload(entry importer.Entry, extraTags []string) (dtosecret.SecretPayload, error) {
+	encoded, err := secretkind.Encode(entry.Payload)
+	if err != nil {
+		return dtosecret.SecretPayload{}, err
+	}
+	tags := append(append([]string(nil), entry.Tags...), extraTags...)
+	if len(tags) == 0 {
+		tags = nil
+	}
+	return dtosecret.SecretPayload{
+		Type:       entry.Payload.Kind,
+		Ciphertext: base64.StdEncoding.EncodeToString(encoded),
+		MetaOpen: models.MetaOpen{
+			Title: entry.Title,
+			Site:  entry.Site,
+			Tags:  tags,
+		},
+	}, nil
+}
+
+// existingFingerprints fingerprints the vault so re-running an import does
+// not create the same secrets again. Secrets that cannot be decoded are
+// skipped.
+func existingFingerprints(secrets []dtosecret.SecretResponse) map[string]struct{} {
+	seen := make(map[string]struct{}, len(secrets))
+	for _, secret := range secrets {
+		if fingerprint, ok := secretFingerprint(secret.Type, secret.MetaOpen, secret.Ciphertext); ok {
+			seen[fingerprint] = struct{}{}
+		}
+	}
+	return seen
+}
+
+func secretFingerprint(secretType string, meta models.MetaOpen, ciphertext string) (string, bool) {
+	payload, err := decodeSecretPayload(secretType, ciphertext)
+	if err != nil {
+		return "", false
+	}
+	return importer.Fingerprint(meta.Title, meta.Site, payload), true
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultagent"
+)
+
+const chromeExport = "name,url,username,password\n" +
+	"Mail,https://mail.example.com,alice,pw1\n" +
+	"Mail,https://mail.example.com,alice,pw1\n" +
+	"Shop,https://shop.example.com,alice,pw2\n" +
+	"Forum,https://forum.example.com,alice,pw3\n" +
+	"Broken,https://broken.example.com,alice,\n"
+
+func writeImportFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "export.csv")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write export: %v", err)
+	}
+	return path
+}
+
+func existingShopSecret(t *testing.T) dtosecret.SecretResponse {
+	t.Helper()
+	encoded, err := secretkind.Encode(secretkind.Payload{
+		Kind:  secretkind.Login,
+		Login: &secretkind.LoginData{Username: "alice", Password: "pw2", URL: "https://shop.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	return dtosecret.SecretResponse{
+		ID:         "existing",
+		Type:       secretkind.Login,
+		MetaOpen:   models.MetaOpen{Title: "Shop", Site: "https://shop.example.com"},
+		Ciphertext: base64.StdEncoding.EncodeToString(encoded),
+	}
+}
+
+func TestImportDryRunReportsWithoutUploading(t *testing.T) {
+	saveTestSession(t)
+	path := writeImportFile(t, chromeExport)
+	existing := existingShopSecret(t)
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet || req.URL.Path != "/secrets" {
+			t.Fatalf("dry run must not write: %s %s", req.Method, req.URL.Path)
+		}
+		return jsonResponse(http.StatusOK, []dtosecret.SecretResponse{existing}), nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"import", "--format", "chrome-csv", "--file", path, "--dry-run"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	var summary importSummary
+	if err := json.Unmarshal(stdout.Bytes(), &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if summary.Parsed != 4 || summary.Duplicates != 2 || summary.ToImport != 2 || summary.Imported != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if len(summary.Errors) != 1 || summary.Errors[0].Row != 6 {
+		t.Fatalf("expected the row without password to be reported: %+v", summary.Errors)
+	}
+}
+
+func TestImportUploadsInBulkAndReportsFailedRows(t *testing.T) {
+	unlockTestVault(t)
+	path := writeImportFile(t, chromeExport)
+
+	var batch dtosecret.BatchRequest
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, []dtosecret.SecretResponse{}), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/batch":
+			if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			results := make([]dtosecret.BatchItemResult, 0, len(batch.Operations))
+			for i := range batch.Operations {
+				status := dtosecret.BatchStatusOK
+				errText := ""
+				if i == 1 {
+					status = dtosecret.BatchStatusFailed
+					errText = "invalid_ciphertext"
+				}
+				results = append(results, dtosecret.BatchItemResult{Index: i, Op: dtosecret.BatchOpCreate, Status: status, Error: errText})
+			}
+			return jsonResponse(http.StatusOK, dtosecret.BatchResponse{Mode: batch.Mode, Committed: true, Results: results}), nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"import", "--format", "chrome-csv", "--file", path, "--tags", "imported"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if batch.Mode != dtosecret.BatchModePerItem || len(batch.Operations) != 3 {
+		t.Fatalf("unexpected batch: mode=%s operations=%d", batch.Mode, len(batch.Operations))
+	}
+	first := batch.Operations[0].Secret
+	if first.Type != secretkind.Login || first.MetaOpen.Title != "Mail" || len(first.MetaOpen.Tags) != 1 || first.MetaOpen.Tags[0] != "imported" {
+		t.Fatalf("unexpected secret: %+v", first)
+	}
+	for _, op := range batch.Operations {
+		sealed, err := base64.StdEncoding.DecodeString(op.Secret.Ciphertext)
+		if err != nil || !vaultagent.IsSealed(sealed) {
+			t.Fatalf("imported secrets must be sealed, got %q", op.Secret.Ciphertext)
+		}
+	}
+
+	var summary importSummary
+	if err := json.Unmarshal(stdout.Bytes(), &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if summary.Imported != 2 || len(summary.Errors) != 2 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary.Errors[1].Row != 4 || summary.Errors[1].Title != "Shop" {
+		t.Fatalf("expected failed upload to point at its row: %+v", summary.Errors[1])
+	}
+}
+
+func TestImportRefusesUnencryptedVault(t *testing.T) {
+	saveTestSession(t)
+	path := writeImportFile(t, chromeExport)
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"import", "--format", "chrome-csv", "--file", path}, &stdout, &stderr)
+	if code == 0 || !strings.Contains(stderr.String(), errVaultNotEncrypted.Error()) {
+		t.Fatalf("expected the import to be refused, code=%d stderr=%s", code, stderr.String())
+	}
+}
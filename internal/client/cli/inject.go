@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+type injectSummary struct {
+	Path       string `json:"path"`
+	References int    `json:"references"`
+}
+
+func runInject(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("inject", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	var in, out string
+	fs.StringVar(&in, "i", "", "Template file")
+	fs.StringVar(&in, "in", "", "Template file")
+	fs.StringVar(&out, "o", "", "Output file, - for stdout")
+	fs.StringVar(&out, "out", "", "Output file, - for stdout")
+	offline := fs.Bool("offline", false, offlineFlagUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	in = strings.TrimSpace(in)
+	out = strings.TrimSpace(out)
+	if in == "" {
+		return errors.New("-i is required")
+	}
+	if out == "" {
+		return errors.New("-o is required")
+	}
+	source, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	// Parse before contacting the server so syntax errors surface first.
+	resolver := &secretResolver{}
+	tmpl, err := template.New(filepath.Base(in)).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"pkeeper": resolver.resolve}).
+		Parse(string(source))
+	if err != nil {
+		return err
+	}
+
+	secrets, err := listSecrets(strings.TrimSpace(*serverURL), *offline, stderr)
+	if err != nil {
+		return err
+	}
+	resolver.secrets = secrets
+
+	// Render fully into memory: a failing reference must not leave a half
+	// written file behind.
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, nil); err != nil {
+		return err
+	}
+	if out == "-" {
+		_, err := stdout.Write(rendered.Bytes())
+		return err
+	}
+	if _, err := writePrivateFile(out, func(w io.Writer) error {
+		_, err := w.Write(rendered.Bytes())
+		return err
+	}); err != nil {
+		return err
+	}
+	return printJSON(stdout, injectSummary{Path: out, References: resolver.resolved})
+}
+
+// secretResolver backs the pkeeper template function:
+//
+//	{{ pkeeper "title-or-id" }}           primary field, e.g. a login password
+//	{{ pkeeper "title-or-id" "field" }}   a named field, e.g. "username"
+type secretResolver struct {
+	secrets  []dtosecret.SecretResponse
+	resolved int
+}
+
+func (r *secretResolver) resolve(ref string, field ...string) (string, error) {
+	if len(field) > 1 {
+		return "", errors.New("pkeeper takes a secret and at most one field")
+	}
+	secret, err := r.find(ref)
+	if err != nil {
+		return "", err
+	}
+	payload, err := decodeSecretPayload(secret.Type, secret.Ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("secret %q: %w", ref, err)
+	}
+	name := secretkind.PrimaryField(payload.Kind)
+	if len(field) == 1 {
+		name = field[0]
+	}
+	value, ok := payload.Field(name)
+	if !ok {
+		return "", fmt.Errorf("secret %q has no field %q (%s secrets have: %s)", ref, name, payload.Kind, strings.Join(secretkind.FieldNames(payload.Kind), ", "))
+	}
+	r.resolved++
+	return value, nil
+}
+
+// find matches an ID first and a title second. A title shared by several
+// secrets is an error rather than a guess.
+func (r *secretResolver) find(ref string) (dtosecret.SecretResponse, error) {
+	var matches []dtosecret.SecretResponse
+	for _, secret := range r.secrets {
+		if secret.ID == ref {
+			return secret, nil
+		}
+		if secret.MetaOpen.Title == ref {
+			matches = append(matches, secret)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return dtosecret.SecretResponse{}, fmt.Errorf("secret %q not found", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return dtosecret.SecretResponse{}, fmt.Errorf("title %q matches %d secrets, reference one by ID", ref, len(matches))
+	}
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+func installInjectSecrets(t *testing.T) {
+	t.Helper()
+	saveTestSession(t)
+	encoded, err := secretkind.Encode(secretkind.Payload{Kind: secretkind.Login, Login: &secretkind.LoginData{Username: "app", Password: "pw"}})
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	secrets := []dtosecret.SecretResponse{
+		{ID: "s-1", Type: secretkind.Login, MetaOpen: models.MetaOpen{Title: "db"}, Ciphertext: base64.StdEncoding.EncodeToString(encoded)},
+		{ID: "s-2", Type: secretkind.Note, MetaOpen: models.MetaOpen{Title: "dup"}, Ciphertext: "b25l"},
+		{ID: "s-3", Type: secretkind.Note, MetaOpen: models.MetaOpen{Title: "dup"}, Ciphertext: "dHdv"},
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, secrets), nil
+	})
+}
+
+func writeTemplate(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.tmpl")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	return path
+}
+
+func TestInjectRendersReferences(t *testing.T) {
+	installInjectSecrets(t)
+	tmpl := writeTemplate(t, "user = {{ pkeeper \"db\" \"username\" }}\npassword = {{ pkeeper \"db\" }}\nnote = {{ pkeeper \"s-3\" \"text\" }}\n")
+	out := filepath.Join(filepath.Dir(tmpl), "app.conf")
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"inject", "-i", tmpl, "-o", out}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(data) != "user = app\npassword = pw\nnote = two\n" {
+		t.Fatalf("unexpected output:\n%s", data)
+	}
+	info, err := os.Stat(out)
+	if err != nil {
+		t.Fatalf("stat output: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 permissions, got %v", info.Mode().Perm())
+	}
+}
+
+func TestInjectFailsWithLocationOfUnresolvedReference(t *testing.T) {
+	installInjectSecrets(t)
+	tests := map[string]string{
+		"a = 1\nb = {{ pkeeper \"missing\" }}\n": `app.tmpl:2:7`,
+		"{{ pkeeper \"dup\" }}":                  `matches 2 secrets`,
+		"{{ pkeeper \"db\" \"cvv\" }}":           `has no field "cvv"`,
+	}
+	for content, want := range tests {
+		tmpl := writeTemplate(t, content)
+		out := filepath.Join(filepath.Dir(tmpl), "app.conf")
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+		if code := run([]string{"inject", "-i", tmpl, "-o", out}, &stdout, &stderr); code == 0 {
+			t.Fatalf("expected %q to fail", content)
+		}
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("expected %q in error, got %s", want, stderr.String())
+		}
+		if _, err := os.Stat(out); !os.IsNotExist(err) {
+			t.Fatalf("failed render must not create the output file")
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package cli
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/internal/sitematch"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultagent"
+)
+
+const (
+	// nativeRequestLimit bounds a message from the browser. Real requests
+	// are a few hundred bytes; the cap only stops a broken length prefix
+	// from allocating gigabytes.
+	nativeRequestLimit = 1 << 20
+	// nativeResponseLimit is what Chrome and Firefox accept from a host.
+	nativeResponseLimit = 1 << 20
+
+	nativeCodeLocked      = "locked"
+	nativeCodeNoSession   = "not_signed_in"
+	nativeCodeBadRequest  = "bad_request"
+	nativeCodeWrongSite   = "wrong_site"
+	nativeCodeUnavailable = "error"
+)
+
+// nativeInput is where the browser writes framed requests.
+var nativeInput io.Reader = os.Stdin
+
+var (
+	errWrongSite        = errors.New("secret is not saved for this site")
+	errBadNativeRequest = errors.New("bad request")
+)
+
+// nativeRequest is one message from the extension. ID is echoed back so
+// the extension can pair answers with requests.
+type nativeRequest struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	URL      string `json:"url,omitempty"`
+	SecretID string `json:"secret_id,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+type nativeResponse struct {
+	ID     string        `json:"id,omitempty"`
+	OK     bool          `json:"ok"`
+	Code   string        `json:"code,omitempty"`
+	Error  string        `json:"error,omitempty"`
+	Logins []nativeLogin `json:"logins,omitempty"`
+	Fill   *nativeFill   `json:"fill,omitempty"`
+	Status *nativeStatus `json:"status,omitempty"`
+}
+
+// nativeLogin describes a candidate without its password; the extension
+// asks for one with fill once the user picks it.
+type nativeLogin struct {
+	SecretID  string `json:"secret_id"`
+	Title     string `json:"title"`
+	Username  string `json:"username,omitempty"`
+	Site      string `json:"site"`
+	ExactHost bool   `json:"exact_host"`
+}
+
+type nativeFill struct {
+	SecretID string `json:"secret_id"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password"`
+}
+
+type nativeStatus struct {
+	SignedIn  bool `json:"signed_in"`
+	Encrypted bool `json:"encrypted"`
+	Unlocked  bool `json:"unlocked"`
+}
+
+// runNativeHost answers browser native-messaging requests until the
+// browser closes stdin. Every message is a 32-bit little-endian length
+// followed by that many bytes of JSON. Browsers start the host through
+// the pkeeper-native-host binary, which also ignores their arguments.
+func runNativeHost(_ []string, stdout, stderr io.Writer) error {
+	for {
+		raw, err := readNativeMessage(nativeInput)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req nativeRequest
+		var resp nativeResponse
+		if err := json.Unmarshal(raw, &req); err != nil {
+			resp = nativeError(fmt.Errorf("decode request: %w", err))
+		} else {
+			resp = handleNativeRequest(req, stderr)
+		}
+		resp.ID = req.ID
+		if err := writeNativeMessage(stdout, resp); err != nil {
+			return err
+		}
+	}
+}
+
+func readNativeMessage(r io.Reader) ([]byte, error) {
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size > nativeRequestLimit {
+		return nil, fmt.Errorf("native message of %d bytes is over the %d byte limit", size, nativeRequestLimit)
+	}
+	raw := make([]byte, size)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("read native message: %w", err)
+	}
+	return raw, nil
+}
+
+func writeNativeMessage(w io.Writer, resp nativeResponse) error {
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	if len(raw) > nativeResponseLimit {
+		raw, _ = json.Marshal(nativeResponse{ID: resp.ID, Code: nativeCodeUnavailable, Error: "response is too large for the browser"})
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(raw))); err != nil {
+		return err
+	}
+	_, err = w.Write(raw)
+	return err
+}
+
+func nativeError(err error) nativeResponse {
+	code := nativeCodeUnavailable
+	switch {
+	case errors.Is(err, vaultagent.ErrLocked), errors.Is(err, vaultagent.ErrNoAgent):
+		code = nativeCodeLocked
+	case errors.Is(err, errNoSession):
+		code = nativeCodeNoSession
+	case errors.Is(err, errWrongSite):
+		code = nativeCodeWrongSite
+	case errors.Is(err, sitematch.ErrInvalidURL), errors.Is(err, errBadNativeRequest):
+		code = nativeCodeBadRequest
+	}
+	return nativeResponse{Code: code, Error: err.Error()}
+}
+
+func handleNativeRequest(req nativeRequest, stderr io.Writer) nativeResponse {
+	var resp nativeResponse
+	var err error
+	switch req.Type {
+	case "status":
+		resp.Status = nativeVaultStatus()
+	case "match":
+		resp.Logins, err = nativeMatch(req.URL, stderr)
+	case "fill":
+		resp.Fill, err = nativeFillLogin(req.URL, req.SecretID, stderr)
+	case "save":
+		err = nativeSaveLogin(req, stderr)
+	default:
+		return nativeResponse{Code: nativeCodeBadRequest, Error: fmt.Sprintf("unknown request type %q", req.Type)}
+	}
+	if err != nil {
+		return nativeError(err)
+	}
+	resp.OK = true
+	return resp
+}
+
+func nativeVaultStatus() *nativeStatus {
+	status := &nativeStatus{}
+	sess, err := loadSession()
+	if err != nil {
+		return status
+	}
+	status.SignedIn = HasAuthorizedTokens(sess.AccessToken, sess.RefreshToken)
+	if check, err := loadKeyCheck(sess); err == nil && len(check) > 0 {
+		status.Encrypted = true
+	}
+	if client, err := newAgentClient(); err == nil {
+		if agentStatus, err := client.Status(); err == nil {
+			status.Unlocked = agentStatus.Unlocked && agentStatus.Account == sess.UserID
+		}
+	}
+	return status
+}
+
+func nativeMatch(pageURL string, stderr io.Writer) ([]nativeLogin, error) {
+	matches, _, err := siteLogins("", pageURL, false, stderr)
+	if err != nil {
+		return nil, err
+	}
+	logins := make([]nativeLogin, 0, len(matches))
+	for _, match := range matches {
+		logins = append(logins, nativeLogin{
+			SecretID:  match.secret.ID,
+			Title:     match.secret.MetaOpen.Title,
+			Username:  match.login.Username,
+			Site:      match.secret.MetaOpen.Site,
+			ExactHost: match.level == sitematch.Host,
+		})
+	}
+	return logins, nil
+}
+
+// nativeFillLogin hands out a password only for the site it was saved
+// for, so a compromised page cannot ask for another site's login.
+func nativeFillLogin(pageURL, secretID string, stderr io.Writer) (*nativeFill, error) {
+	if strings.TrimSpace(secretID) == "" {
+		return nil, fmt.Errorf("%w: secret_id is required", errBadNativeRequest)
+	}
+	matches, _, err := siteLogins("", pageURL, false, stderr)
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range matches {
+		if match.secret.ID == secretID {
+			return &nativeFill{SecretID: secretID, Username: match.login.Username, Password: match.login.Password}, nil
+		}
+	}
+	return nil, errWrongSite
+}
+
+// nativeSaveLogin keeps a login the user submitted on a page. A login of
+// the same user on the same host gets the new password instead of a copy.
+func nativeSaveLogin(req nativeRequest, stderr io.Writer) error {
+	if req.Password == "" {
+		return fmt.Errorf("%w: password is required", errBadNativeRequest)
+	}
+	matches, page, err := siteLogins("", req.URL, false, stderr)
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		if match.login.Username != req.Username || match.level != sitematch.Host {
+			continue
+		}
+		if match.login.Password == req.Password {
+			return nil
+		}
+		login := match.login
+		login.Password = req.Password
+		payload, err := loginSecretPayload(match.secret.MetaOpen, login)
+		if err != nil {
+			return err
+		}
+		_, err = updateSecret("", match.secret.ID, payload, false, stderr)
+		return err
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = page.Host
+	}
+	payload, err := loginSecretPayload(models.MetaOpen{Title: title, Site: page.Scheme + "://" + page.Host}, secretkind.LoginData{
+		Username: req.Username,
+		Password: req.Password,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = createSecret("", payload, false, stderr)
+	return err
+}
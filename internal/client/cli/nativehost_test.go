@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+)
+
+// runNativeMessages pipes framed requests through native-host and decodes
+// the framed answers.
+func runNativeMessages(t *testing.T, requests ...nativeRequest) []nativeResponse {
+	t.Helper()
+	var input bytes.Buffer
+	for _, req := range requests {
+		raw, err := json.Marshal(req)
+		if err != nil {
+			t.Fatalf("encode request: %v", err)
+		}
+		_ = binary.Write(&input, binary.LittleEndian, uint32(len(raw)))
+		input.Write(raw)
+	}
+	prev := nativeInput
+	nativeInput = &input
+	t.Cleanup(func() { nativeInput = prev })
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	// Chrome passes the extension origin as an argument.
+	if code := run([]string{"native-host", "chrome-extension://abc/"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("native-host: exit code=%d stderr=%s", code, stderr.String())
+	}
+	var responses []nativeResponse
+	for {
+		raw, err := readNativeMessage(&stdout)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read response: %v", err)
+		}
+		var resp nativeResponse
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			t.Fatalf("decode response %s: %v", raw, err)
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) != len(requests) {
+		t.Fatalf("expected %d responses, got %d", len(requests), len(responses))
+	}
+	return responses
+}
+
+func TestNativeHostMatchesFillsAndSaves(t *testing.T) {
+	saveTestSession(t)
+	secrets := []dtosecret.SecretResponse{
+		loginSecret(t, "s-domain", "https://example.com", "bob", "domain-pass", "2026-03-03T10:00:00Z"),
+		loginSecret(t, "s-host", "accounts.example.com", "alice", "host-pass", "2026-03-01T10:00:00Z"),
+		loginSecret(t, "s-other", "https://other.org", "eve", "other-pass", "2026-03-04T10:00:00Z"),
+	}
+	var writes []string
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			return jsonResponse(http.StatusOK, secrets), nil
+		case req.Method == http.MethodPut || req.Method == http.MethodPost:
+			var payload dtosecret.SecretPayload
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+			writes = append(writes, req.Method+" "+req.URL.Path+" "+payload.MetaOpen.Site)
+			return jsonResponse(http.StatusOK, dtosecret.SecretResponse{ID: "s-new", Type: payload.Type, MetaOpen: payload.MetaOpen, Ciphertext: payload.Ciphertext}), nil
+		default:
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			return nil, nil
+		}
+	})
+
+	responses := runNativeMessages(t,
+		nativeRequest{ID: "1", Type: "match", URL: "https://accounts.example.com:443/login?next=/"},
+		nativeRequest{ID: "2", Type: "fill", URL: "https://www.example.com/", SecretID: "s-domain"},
+		nativeRequest{ID: "3", Type: "fill", URL: "https://example.com/", SecretID: "s-other"},
+		nativeRequest{ID: "4", Type: "save", URL: "https://accounts.example.com/login", Username: "alice", Password: "rotated"},
+		nativeRequest{ID: "5", Type: "save", URL: "https://shop.example.net/cart", Username: "carol", Password: "new-pass"},
+		nativeRequest{ID: "6", Type: "match", URL: "not a url"},
+		nativeRequest{ID: "7", Type: "reboot"},
+		nativeRequest{ID: "8", Type: "fill", URL: "http://example.com/", SecretID: "s-domain"},
+		nativeRequest{ID: "9", Type: "fill", URL: "https://example.com/"},
+		nativeRequest{ID: "10", Type: "save", URL: "https://example.com/", Username: "bob"},
+	)
+
+	match := responses[0]
+	if match.ID != "1" || !match.OK || len(match.Logins) != 2 {
+		t.Fatalf("unexpected match: %+v", match)
+	}
+	if match.Logins[0].SecretID != "s-host" || !match.Logins[0].ExactHost || match.Logins[1].SecretID != "s-domain" || match.Logins[1].ExactHost {
+		t.Fatalf("exact host matches must come first: %+v", match.Logins)
+	}
+	if raw, _ := json.Marshal(match); bytes.Contains(raw, []byte("pass")) {
+		t.Fatalf("match must not carry passwords: %s", raw)
+	}
+
+	if fill := responses[1]; !fill.OK || fill.Fill == nil || fill.Fill.Username != "bob" || fill.Fill.Password != "domain-pass" {
+		t.Fatalf("unexpected fill: %+v", fill)
+	}
+	if fill := responses[2]; fill.OK || fill.Code != nativeCodeWrongSite || fill.Fill != nil {
+		t.Fatalf("a login of another site must not be filled: %+v", fill)
+	}
+
+	if !responses[3].OK || !responses[4].OK {
+		t.Fatalf("unexpected save responses: %+v %+v", responses[3], responses[4])
+	}
+	if len(writes) != 2 || writes[0] != "PUT /secrets/s-host accounts.example.com" || writes[1] != "POST /secrets https://shop.example.net" {
+		t.Fatalf("unexpected writes: %v", writes)
+	}
+
+	if resp := responses[5]; resp.OK || resp.Code != nativeCodeBadRequest {
+		t.Fatalf("unexpected invalid url response: %+v", resp)
+	}
+	if resp := responses[6]; resp.OK || resp.Code != nativeCodeBadRequest || resp.ID != "7" {
+		t.Fatalf("unexpected unknown type response: %+v", resp)
+	}
+	if fill := responses[7]; fill.OK || fill.Code != nativeCodeWrongSite || fill.Fill != nil {
+		t.Fatalf("an https login must not be filled into a plain http page: %+v", fill)
+	}
+	for _, resp := range responses[8:] {
+		if resp.OK || resp.Code != nativeCodeBadRequest || !strings.HasPrefix(resp.Error, "bad request: ") {
+			t.Fatalf("a missing field must be a bad request of its own: %+v", resp)
+		}
+	}
+}
+
+func TestNativeHostReportsMissingSession(t *testing.T) {
+	t.Setenv("PKEEPER_SESSION_PATH", t.TempDir()+"/session.json")
+	responses := runNativeMessages(t,
+		nativeRequest{ID: "1", Type: "status"},
+		nativeRequest{ID: "2", Type: "match", URL: "https://example.com"},
+	)
+	if status := responses[0]; !status.OK || status.Status == nil || status.Status.SignedIn {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if resp := responses[1]; resp.OK || resp.Code != nativeCodeNoSession {
+		t.Fatalf("expected not_signed_in, got %+v", resp)
+	}
+}
@@ -0,0 +1,361 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultcache"
+	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
+)
+
+const offlineFlagUsage = "Use the offline cache instead of the server"
+
+var errCacheEmpty = errors.New("offline cache is empty, run secrets sync while online first")
+
+// cacheStore returns the offline cache of the signed-in account. It lives
+// next to the session file. Once the vault is encrypted, the cache key is
+// sealed by the agent, so the cache opens only while the vault is unlocked.
+func cacheStore(sess session) (*vaultcache.Store, error) {
+	path, err := sessionPath()
+	if err != nil {
+		return nil, err
+	}
+	store := vaultcache.NewStore(filepath.Dir(path), sess.UserID)
+	checkPath, err := keyCheckPath(sess)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(checkPath); err == nil {
+		client, err := newAgentClient()
+		if err != nil {
+			return nil, err
+		}
+		store.WithKeySealer(agentKeySealer{client: client, account: sess.UserID})
+	}
+	return store, nil
+}
+
+func loadCache() (*vaultcache.Cache, error) {
+	sess, err := loadSession()
+	if err != nil {
+		return nil, err
+	}
+	store, err := cacheStore(sess)
+	if err != nil {
+		return nil, err
+	}
+	return store.Load()
+}
+
+// updateCache writes server results through to the cache. The command
+// itself already succeeded, so a failure here is only reported.
+func updateCache(sess session, stderr io.Writer, fn func(c *vaultcache.Cache) error) {
+	store, err := cacheStore(sess)
+	if err == nil {
+		err = store.Update(fn)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "warning: offline cache not updated: %v\n", err)
+	}
+}
+
+// cachedSecrets lists the secrets of a seeded cache.
+func cachedSecrets() ([]dtosecret.SecretResponse, *vaultcache.Cache, error) {
+	cache, err := loadCache()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cache.Complete {
+		return nil, nil, errCacheEmpty
+	}
+	return cache.List(), cache, nil
+}
+
+func warnOffline(stderr io.Writer, cause error, cache *vaultcache.Cache) {
+	syncedAt := cache.Cursor
+	if syncedAt == "" {
+		syncedAt = "never"
+	}
+	_, _ = fmt.Fprintf(stderr, "warning: server unreachable (%v), using the offline cache (last change %s)\n", cause, syncedAt)
+}
+
+// listSecrets lists live secrets and refreshes the cache with them. With
+// offline set, or when the server cannot be reached, the cache answers
+// instead. Queued writes are included either way.
+func listSecrets(serverURL string, offline bool, stderr io.Writer) ([]dtosecret.SecretResponse, error) {
+	return readSecrets(serverURL, offline, true, stderr)
+}
+
+// listSecretsUncached is listSecrets for callers that must not put what they
+// read on disk, such as the SSH agent with private keys: the cache still
+// answers when offline but is never refreshed.
+func listSecretsUncached(serverURL string, offline bool, stderr io.Writer) ([]dtosecret.SecretResponse, error) {
+	return readSecrets(serverURL, offline, false, stderr)
+}
+
+func readSecrets(serverURL string, offline, writeCache bool, stderr io.Writer) ([]dtosecret.SecretResponse, error) {
+	if offline {
+		secrets, _, err := cachedSecrets()
+		return secrets, err
+	}
+	sess, secrets, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) ([]dtosecret.SecretResponse, error) {
+		return client.ListSecrets(ctx, accessToken, "")
+	})
+	if err != nil {
+		if !isTransientError(err) {
+			return nil, err
+		}
+		cached, cache, cacheErr := cachedSecrets()
+		if cacheErr != nil {
+			return nil, err
+		}
+		warnOffline(stderr, err, cache)
+		return cached, nil
+	}
+	if err := saveSession(sess); err != nil {
+		return nil, err
+	}
+	view := secrets
+	if !writeCache {
+		// Queued writes still show; the merge stays in memory.
+		if cache, err := loadCache(); err == nil {
+			cache.Replace(secrets)
+			view = cache.List()
+		}
+		return view, nil
+	}
+	updateCache(sess, stderr, func(c *vaultcache.Cache) error {
+		c.Replace(secrets)
+		view = c.List()
+		return nil
+	})
+	return view, nil
+}
+
+// queueWrite stores a write for the next sync and returns the secret as it
+// now reads locally.
+func queueWrite(op vaultcache.Op, stderr io.Writer) (dtosecret.SecretResponse, error) {
+	sess, err := loadSession()
+	if err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
+	store, err := cacheStore(sess)
+	if err != nil {
+		return dtosecret.SecretResponse{}, err
+	}
+	op.QueuedAt = time.Now().UTC()
+	var secret dtosecret.SecretResponse
+	if err := store.Update(func(c *vaultcache.Cache) error {
+		var enqueueErr error
+		secret, enqueueErr = c.Enqueue(op)
+		return enqueueErr
+	}); err != nil {
+		if errors.Is(err, vaultcache.ErrNotFound) {
+			return dtosecret.SecretResponse{}, fmt.Errorf("secret %s: %w", op.SecretID, err)
+		}
+		return dtosecret.SecretResponse{}, err
+	}
+	_, _ = fmt.Fprintln(stderr, "change queued, it is uploaded by the next secrets sync")
+	return secret, nil
+}
+
+// mustQueue reports whether a write to secretID has to wait for the queue:
+// the secret only exists locally or an earlier write to it is still queued.
+func mustQueue(secretID string) bool {
+	if vaultcache.IsLocalID(secretID) {
+		return true
+	}
+	cache, err := loadCache()
+	return err == nil && cache.HasPending(secretID)
+}
+
+// maxRebases bounds how often one flush merges a write with the server
+// state and retries it, in case the secret keeps changing meanwhile.
+const maxRebases = 2
+
+type replayResult struct {
+	secret *dtosecret.SecretResponse
+	// gone is set when a queued delete finds the secret already deleted.
+	gone bool
+	// rejected is why the server refused the write; it stays queued.
+	rejected string
+	// changed is set when the refusal is because the secret changed or
+	// disappeared on the server since the write was queued.
+	changed bool
+}
+
+// flushQueue uploads queued writes in order. A write the server rejects stays
+// queued with the reason; an unreachable server stops the flush and fails
+// the sync like any other request. Writes in conflict with the server wait
+// for pkeeper conflicts and are skipped.
+func flushQueue(serverURL string, stderr io.Writer) error {
+	sess, err := loadSession()
+	if err != nil {
+		return err
+	}
+	store, err := cacheStore(sess)
+	if err != nil {
+		return err
+	}
+	cache, err := store.Load()
+	if err != nil {
+		return err
+	}
+	conflicts := 0
+	for _, op := range cache.Pending() {
+		if op.Conflict == nil {
+			if op.Conflict, err = uploadOp(serverURL, store, op, stderr); err != nil {
+				return err
+			}
+		}
+		if op.Conflict != nil {
+			conflicts++
+		}
+	}
+	if conflicts > 0 {
+		_, _ = fmt.Fprintf(stderr, "warning: %d queued change(s) conflict with the server, run pkeeper conflicts\n", conflicts)
+	}
+	return nil
+}
+
+// uploadOp uploads one queued write. When the secret changed on the server
+// since the write was queued, it is merged with the server state and
+// uploaded again; overlapping changes are returned as a conflict.
+func uploadOp(serverURL string, store *vaultcache.Store, op vaultcache.Op, stderr io.Writer) (*vaultcache.Conflict, error) {
+	for attempt := 0; ; attempt++ {
+		refreshed, result, err := runAuthorizedRequestWithKey(serverURL, op.ID, func(ctx context.Context, client *api.API, accessToken string, sess session) (replayResult, error) {
+			return replayOp(ctx, client, accessToken, op)
+		})
+		switch {
+		case err == nil:
+			if err := saveSession(refreshed); err != nil {
+				return nil, err
+			}
+		case isRejection(err):
+			result = replayResult{rejected: err.Error()}
+		default:
+			return nil, err
+		}
+
+		if result.changed && attempt < maxRebases {
+			theirs, err := fetchServerSecret(serverURL, op.SecretID)
+			if err != nil {
+				return nil, err
+			}
+			var next *vaultcache.Op
+			var conflict *vaultcache.Conflict
+			if err := store.Update(func(c *vaultcache.Cache) error {
+				next = c.Reconcile(op.ID, theirs, time.Now().UTC())
+				if i := slices.IndexFunc(c.Queue, func(queued vaultcache.Op) bool { return queued.ID == op.ID }); i >= 0 {
+					conflict = c.Queue[i].Conflict
+				}
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+			if next == nil {
+				return conflict, nil
+			}
+			op = *next
+			continue
+		}
+
+		if result.rejected != "" {
+			_, _ = fmt.Fprintf(stderr, "warning: queued %s of %s rejected: %s\n", op.Kind, op.SecretID, result.rejected)
+		}
+		return nil, store.Update(func(c *vaultcache.Cache) error {
+			switch {
+			case result.rejected != "":
+				c.Fail(op.ID, result.rejected)
+				return nil
+			case result.gone:
+				c.Remove(op.SecretID)
+			case result.secret != nil:
+				c.Put(*result.secret)
+			}
+			c.Done(op.ID)
+			return nil
+		})
+	}
+}
+
+// fetchServerSecret reads the current server state of a secret for a merge;
+// nil means it is deleted.
+func fetchServerSecret(serverURL, secretID string) (*dtosecret.SecretResponse, error) {
+	refreshed, secret, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
+		return client.GetSecret(ctx, accessToken, secretID)
+	})
+	var httpErr *apiclient.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := saveSession(refreshed); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// isRejection reports whether the server refused a request for good, as
+// opposed to being unreachable or the session having expired.
+func isRejection(err error) bool {
+	var httpErr *apiclient.HTTPError
+	return errors.As(err, &httpErr) &&
+		httpErr.StatusCode >= http.StatusBadRequest &&
+		httpErr.StatusCode < http.StatusInternalServerError &&
+		httpErr.StatusCode != http.StatusUnauthorized
+}
+
+// replayOp uploads one queued write. Creates go through the create endpoint
+// under the key of the command that queued them, so a create that reached
+// the server before the connection dropped is not applied twice. Updates and
+// deletes go through the batch endpoint, which checks the base version.
+func replayOp(ctx context.Context, client *api.API, accessToken string, op vaultcache.Op) (replayResult, error) {
+	if op.Kind == dtosecret.BatchOpCreate {
+		if op.Secret == nil {
+			return replayResult{rejected: "queued create has no secret"}, nil
+		}
+		secret, err := client.CreateSecret(ctx, accessToken, *op.Secret)
+		if err != nil {
+			return replayResult{}, err
+		}
+		return replayResult{secret: &secret}, nil
+	}
+	resp, err := client.BatchSecrets(ctx, accessToken, dtosecret.BatchRequest{
+		Mode: dtosecret.BatchModePerItem,
+		Operations: []dtosecret.BatchOperation{{
+			Op:              op.Kind,
+			ID:              op.SecretID,
+			ExpectedVersion: op.BaseVersion,
+			Secret:          op.Secret,
+		}},
+	})
+	if err != nil {
+		return replayResult{}, err
+	}
+	if len(resp.Results) != 1 {
+		return replayResult{}, fmt.Errorf("batch returned %d results for one operation", len(resp.Results))
+	}
+	item := resp.Results[0]
+	switch {
+	case item.Status == dtosecret.BatchStatusOK:
+		return replayResult{secret: item.Secret}, nil
+	case op.Kind == dtosecret.BatchOpDelete && item.Error == "not_found":
+		return replayResult{gone: true}, nil
+	case item.Error == "version_conflict" || item.Error == "not_found":
+		return replayResult{rejected: item.Error, changed: true}, nil
+	default:
+		return replayResult{rejected: item.Error}, nil
+	}
+}
@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+)
+
+func offlineSecrets() []dtosecret.SecretResponse {
+	return []dtosecret.SecretResponse{
+		{ID: "s-1", Type: "note", MetaOpen: models.MetaOpen{Title: "wifi"}, Ciphertext: "b25l", Version: 2, UpdatedAt: "2026-03-01T10:00:00Z"},
+		{ID: "s-2", Type: "note", MetaOpen: models.MetaOpen{Title: "door"}, Ciphertext: "dHdv", Version: 5, UpdatedAt: "2026-03-01T11:00:00Z"},
+	}
+}
+
+// seedOfflineCache lists the secrets once while online, which fills the
+// cache, and then makes the server unreachable.
+func seedOfflineCache(t *testing.T) {
+	t.Helper()
+	saveTestSession(t)
+	prevDelay := retryDelay
+	retryDelay = 0
+	t.Cleanup(func() { retryDelay = prevDelay })
+
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, offlineSecrets()), nil
+	})
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "list"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("seed: exit code=%d stderr=%s", code, stderr.String())
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("dial tcp: connection refused")
+	})
+}
+
+func decodeSecretList(t *testing.T, raw []byte) []dtosecret.SecretResponse {
+	t.Helper()
+	var secrets []dtosecret.SecretResponse
+	if err := json.Unmarshal(raw, &secrets); err != nil {
+		t.Fatalf("decode list: %v\n%s", err, raw)
+	}
+	return secrets
+}
+
+func TestReadsFallBackToOfflineCache(t *testing.T) {
+	seedOfflineCache(t)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "list"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if got := decodeSecretList(t, stdout.Bytes()); len(got) != 2 {
+		t.Fatalf("expected the cached secrets, got %+v", got)
+	}
+	if !strings.Contains(stderr.String(), "offline cache") {
+		t.Fatalf("expected a warning about the fallback, got %q", stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "get", "--id", "s-2", "--offline"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"door"`) {
+		t.Fatalf("unexpected secret: %s", stdout.String())
+	}
+	if code := run([]string{"secrets", "get", "--id", "missing", "--offline"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected an unknown secret to fail")
+	}
+}
+
+func TestOfflineReadWithoutCacheFails(t *testing.T) {
+	saveTestSession(t)
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "list", "--offline"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected an empty cache to fail")
+	}
+	if !strings.Contains(stderr.String(), "run secrets sync") {
+		t.Fatalf("unexpected error: %s", stderr.String())
+	}
+}
+
+func TestOfflineWritesAreQueuedAndUploadedBySync(t *testing.T) {
+	seedOfflineCache(t)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	// The server is down: the create is queued under the key it was sent with.
+	if code := run([]string{"secrets", "create", "--type", "note", "--ciphertext", "bmV3", "--title", "new"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("create: exit code=%d stderr=%s", code, stderr.String())
+	}
+	var created dtosecret.SecretResponse
+	if err := json.Unmarshal(stdout.Bytes(), &created); err != nil || !strings.HasPrefix(created.ID, "local-") {
+		t.Fatalf("expected a local secret, got %s (%v)", stdout.String(), err)
+	}
+	for _, args := range [][]string{
+		{"secrets", "update", "--offline", "--id", "s-1", "--type", "note", "--ciphertext", "ZWRpdA==", "--title", "wifi"},
+		{"secrets", "delete", "--offline", "--id", "s-2"},
+	} {
+		if code := run(args, &stdout, &stderr); code != 0 {
+			t.Fatalf("%v: exit code=%d stderr=%s", args, code, stderr.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "list", "--offline"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("list: exit code=%d stderr=%s", code, stderr.String())
+	}
+	ciphertexts := map[string]string{}
+	for _, secret := range decodeSecretList(t, stdout.Bytes()) {
+		ciphertexts[secret.ID] = secret.Ciphertext
+	}
+	if len(ciphertexts) != 2 || ciphertexts["s-1"] != "ZWRpdA==" || ciphertexts[created.ID] != "bmV3" {
+		t.Fatalf("queued writes must show in the offline list: %v", ciphertexts)
+	}
+
+	createKey := strings.TrimPrefix(created.ID, "local-")
+	var uploaded []string
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets":
+			if req.Header.Get("Idempotency-Key") != createKey {
+				t.Fatalf("queued create must reuse its key, got %q", req.Header.Get("Idempotency-Key"))
+			}
+			uploaded = append(uploaded, "create")
+			return jsonResponse(http.StatusCreated, dtosecret.SecretResponse{ID: "s-3", Type: "note", MetaOpen: models.MetaOpen{Title: "new"}, Ciphertext: "bmV3", Version: 1, UpdatedAt: "2026-03-02T09:00:00Z"}), nil
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/batch":
+			var batch dtosecret.BatchRequest
+			if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			op := batch.Operations[0]
+			uploaded = append(uploaded, op.Op)
+			if op.Op == dtosecret.BatchOpUpdate {
+				if op.ExpectedVersion != 2 {
+					t.Fatalf("expected the base version, got %d", op.ExpectedVersion)
+				}
+				return jsonResponse(http.StatusOK, dtosecret.BatchResponse{Results: []dtosecret.BatchItemResult{{Op: op.Op, ID: op.ID, Status: dtosecret.BatchStatusFailed, Error: "version_conflict"}}}), nil
+			}
+			deleted := dtosecret.SecretResponse{ID: op.ID, Version: 5, UpdatedAt: "2026-03-02T09:00:01Z", DeletedAt: "2026-03-02T09:00:01Z"}
+			return jsonResponse(http.StatusOK, dtosecret.BatchResponse{Results: []dtosecret.BatchItemResult{{Op: op.Op, ID: op.ID, Status: dtosecret.BatchStatusOK, Secret: &deleted}}}), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets/s-1":
+			uploaded = append(uploaded, "get")
+			return jsonResponse(http.StatusOK, dtosecret.SecretResponse{ID: "s-1", Type: "note", MetaOpen: models.MetaOpen{Title: "wifi"}, Ciphertext: "dGhlaXJz", Version: 3, UpdatedAt: "2026-03-02T08:00:00Z"}), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			if req.URL.Query().Get("include_deleted") != "true" {
+				t.Fatalf("sync must ask for deletions: %s", req.URL)
+			}
+			return jsonResponse(http.StatusOK, []dtosecret.SecretResponse{}), nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL)
+		return nil, nil
+	})
+
+	stderr.Reset()
+	if code := run([]string{"secrets", "sync", "--once"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("sync: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if strings.Join(uploaded, ",") != "create,update,get,delete" {
+		t.Fatalf("unexpected upload order: %v", uploaded)
+	}
+	if !strings.Contains(stderr.String(), "1 queued change(s) conflict") {
+		t.Fatalf("expected the conflicting update to be reported: %q", stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "pending"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("pending: exit code=%d stderr=%s", code, stderr.String())
+	}
+	var pending []pendingWrite
+	if err := json.Unmarshal(stdout.Bytes(), &pending); err != nil {
+		t.Fatalf("decode pending: %v", err)
+	}
+	if len(pending) != 1 || pending[0].SecretID != "s-1" || strings.Join(pending[0].Conflict, ",") != "payload" {
+		t.Fatalf("only the conflicting update must stay queued: %+v", pending)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "list", "--offline"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("list: exit code=%d stderr=%s", code, stderr.String())
+	}
+	ids := []string{}
+	for _, secret := range decodeSecretList(t, stdout.Bytes()) {
+		ids = append(ids, secret.ID)
+	}
+	slices.Sort(ids)
+	if strings.Join(ids, ",") != "s-1,s-3" {
+		t.Fatalf("unexpected cache after sync: %v", ids)
+	}
+}
+
+func TestSyncDropsSecretsDeletedElsewhere(t *testing.T) {
+	seedOfflineCache(t)
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("since") != "2026-03-01T11:00:00Z" {
+			t.Fatalf("expected the cache cursor, got %s", req.URL)
+		}
+		return jsonResponse(http.StatusOK, []dtosecret.SecretResponse{
+			{ID: "s-1", Version: 2, UpdatedAt: "2026-03-01T12:00:00Z", DeletedAt: "2026-03-01T12:00:00Z"},
+		}), nil
+	})
+	sess, err := loadSession()
+	if err != nil {
+		t.Fatalf("load session: %v", err)
+	}
+	sess.LastSyncAt = "2026-03-01T11:00:00Z"
+	if err := saveSession(sess); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "sync", "--once"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("sync: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if got := decodeSecretList(t, stdout.Bytes()); len(got) != 0 {
+		t.Fatalf("deleted secrets must not be printed as changes: %+v", got)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "list", "--offline"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("list: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if got := decodeSecretList(t, stdout.Bytes()); len(got) != 1 || got[0].ID != "s-2" {
+		t.Fatalf("expected the deletion in the cache, got %+v", got)
+	}
+}
@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	profileEnv     = "PKEEPER_PROFILE"
+	defaultProfile = "default"
+	// profileFile names the profile pkeeper profile use picked.
+	profileFile = "profile"
+	profilesDir = "profiles"
+)
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+var errDefaultProfile = errors.New("the default profile cannot be removed")
+
+var (
+	selectedMu sync.Mutex
+	// selectedProfile is set by --profile for one command, or for the rest
+	// of the process by SelectProfile.
+	selectedProfile string
+)
+
+func validProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// activeProfile picks the profile in order: --profile, $PKEEPER_PROFILE,
+// the one chosen with pkeeper profile use, the default profile.
+func activeProfile() (string, error) {
+	selectedMu.Lock()
+	selected := selectedProfile
+	selectedMu.Unlock()
+	if selected != "" {
+		return selected, nil
+	}
+	if name := strings.TrimSpace(os.Getenv(profileEnv)); name != "" {
+		if err := validProfileName(name); err != nil {
+			return "", fmt.Errorf("$%s: %w", profileEnv, err)
+		}
+		return name, nil
+	}
+	base, err := configBaseDir()
+	if err != nil {
+		return "", err
+	}
+	raw, err := os.ReadFile(filepath.Join(base, profileFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultProfile, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(string(raw))
+	if validProfileName(name) != nil {
+		return defaultProfile, nil
+	}
+	return name, nil
+}
+
+// profileDir is where a profile keeps its session, cache and sockets. The
+// default profile lives in the base directory, where a single session
+// was kept before profiles existed.
+func profileDir(profile string) (string, error) {
+	base, err := configBaseDir()
+	if err != nil {
+		return "", err
+	}
+	if profile == defaultProfile {
+		return base, nil
+	}
+	return filepath.Join(base, profilesDir, profile), nil
+}
+
+// withProfile runs fn with the profile chosen by --profile and restores the
+// previous choice afterwards.
+func withProfile(name string, fn func() error) error {
+	if err := validProfileName(name); err != nil {
+		return err
+	}
+	selectedMu.Lock()
+	previous := selectedProfile
+	selectedProfile = name
+	selectedMu.Unlock()
+	defer func() {
+		selectedMu.Lock()
+		selectedProfile = previous
+		selectedMu.Unlock()
+	}()
+	return fn()
+}
+
+// splitProfileFlag takes a leading --profile NAME or --profile=NAME off args.
+func splitProfileFlag(args []string) (string, []string, bool, error) {
+	if len(args) == 0 {
+		return "", args, false, nil
+	}
+	if value, ok := strings.CutPrefix(args[0], "--profile="); ok {
+		return value, args[1:], true, nil
+	}
+	if args[0] != "--profile" {
+		return "", args, false, nil
+	}
+	if len(args) < 2 {
+		return "", nil, false, errors.New("--profile needs a name")
+	}
+	return args[1], args[2:], true, nil
+}
+
+// SelectProfile switches the profile for the rest of the process and makes
+// it the one later commands use, as pkeeper profile use does.
+func SelectProfile(name string) error {
+	if err := useProfile(name); err != nil {
+		return err
+	}
+	selectedMu.Lock()
+	selectedProfile = name
+	selectedMu.Unlock()
+	return nil
+}
+
+// ActiveProfile reports the profile commands use now.
+func ActiveProfile() string {
+	name, err := activeProfile()
+	if err != nil {
+		return defaultProfile
+	}
+	return name
+}
+
+// Profiles lists the default profile and every named one.
+func Profiles() ([]string, error) {
+	base, err := configBaseDir()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{defaultProfile}
+	entries, err := os.ReadDir(filepath.Join(base, profilesDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && validProfileName(entry.Name()) == nil && entry.Name() != defaultProfile {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names[1:])
+	return names, nil
+}
+
+func useProfile(name string) error {
+	if err := validProfileName(name); err != nil {
+		return err
+	}
+	dir, err := profileDir(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	base, err := configBaseDir()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(base, profileFile), []byte(name+"\n"), 0o600)
+}
+
+func runProfile(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("profile subcommand is required: list, use or remove")
+	}
+	switch args[0] {
+	case "list":
+		return runProfileList(args[1:], stdout)
+	case "use":
+		return runProfileUse(args[1:], stdout, stderr)
+	case "remove":
+		return runProfileRemove(args[1:], stdout)
+	default:
+		return fmt.Errorf("unknown profile command: %s", args[0])
+	}
+}
+
+type profileInfo struct {
+	Name      string `json:"name"`
+	Active    bool   `json:"active"`
+	ServerURL string `json:"server_url,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	SignedIn  bool   `json:"signed_in"`
+}
+
+func runProfileList(args []string, stdout io.Writer) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected profile list arguments: %s", strings.Join(args, " "))
+	}
+	active, err := activeProfile()
+	if err != nil {
+		return err
+	}
+	names, err := Profiles()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(names, active) {
+		names = append(names, active)
+	}
+	infos := make([]profileInfo, 0, len(names))
+	for _, name := range names {
+		info := profileInfo{Name: name, Active: name == active}
+		if sess, err := loadProfileSession(name); err == nil {
+			info.ServerURL = sess.Pinned.ServerURL
+			if info.ServerURL == "" {
+				info.ServerURL = sess.ServerURL
+			}
+			info.UserID = sess.UserID
+			info.SignedIn = HasAuthorizedTokens(sess.AccessToken, sess.RefreshToken)
+		} else if !errors.Is(err, errNoSession) {
+			return err
+		}
+		infos = append(infos, info)
+	}
+	return printJSON(stdout, infos)
+}
+
+// runProfileUse makes a profile the active one, creating it if needed.
+// --server pins the profile to a server, over SERVER_URL.
+func runProfileUse(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("profile use", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL to pin for the profile")
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("profile name is required")
+	}
+	name := strings.TrimSpace(args[0])
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected profile use arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if err := useProfile(name); err != nil {
+		return err
+	}
+	if pinned := strings.TrimSpace(*serverURL); pinned != "" {
+		err := withProfile(name, func() error {
+			sess, err := loadSession()
+			if err != nil && !errors.Is(err, errNoSession) {
+				return err
+			}
+			sess.Pinned.ServerURL = pinned
+			return saveSession(sess)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if env := strings.TrimSpace(os.Getenv(profileEnv)); env != "" && env != name {
+		_, _ = fmt.Fprintf(stderr, "warning: $%s=%s still selects another profile in this shell\n", profileEnv, env)
+	}
+	_, err := fmt.Fprintf(stdout, "using profile %s\n", name)
+	return err
+}
+
+// runProfileRemove deletes a profile with its tokens and offline cache.
+// Removing the active profile switches back to the default one.
+func runProfileRemove(args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("profile remove takes one profile name")
+	}
+	name := strings.TrimSpace(args[0])
+	if err := validProfileName(name); err != nil {
+		return err
+	}
+	if name == defaultProfile {
+		return errDefaultProfile
+	}
+	dir, err := profileDir(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("profile %s does not exist", name)
+		}
+		return err
+	}
+	active, err := activeProfile()
+	if err != nil {
+		return err
+	}
+	// The profile's vault agent listens inside its directory; stop it
+	// rather than leave it holding the key. A shared agent on
+	// $PKEEPER_AGENT_SOCK is left alone.
+	if os.Getenv(agentSocketEnv) == "" {
+		_ = withProfile(name, func() error {
+			client, err := newAgentClient()
+			if err != nil {
+				return err
+			}
+			return client.Stop()
+		})
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if active == name {
+		if err := useProfile(defaultProfile); err != nil {
+			return err
+		}
+		selectedMu.Lock()
+		if selectedProfile == name {
+			selectedProfile = ""
+		}
+		selectedMu.Unlock()
+	}
+	_, err = fmt.Fprintf(stdout, "profile %s removed\n", name)
+	return err
+}
+
+func loadProfileSession(name string) (session, error) {
+	var sess session
+	err := withProfile(name, func() error {
+		var err error
+		sess, err = loadSession()
+		return err
+	})
+	return sess, err
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func decodeProfiles(t *testing.T, raw []byte) map[string]profileInfo {
+	t.Helper()
+	var infos []profileInfo
+	if err := json.Unmarshal(raw, &infos); err != nil {
+		t.Fatalf("decode profiles %s: %v", raw, err)
+	}
+	out := make(map[string]profileInfo, len(infos))
+	for _, info := range infos {
+		out[info.Name] = info
+	}
+	return out
+}
+
+func TestProfilesKeepSeparateSessions(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(dir, "session.json"))
+	t.Setenv(profileEnv, "")
+	t.Setenv("SERVER_URL", "http://config.test")
+	if err := saveSession(session{ServerURL: "http://prod.test", UserID: "u-prod", AccessToken: "a", RefreshToken: "r", LastSyncAt: "2026-03-01T10:00:00Z"}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+
+	var hosts []string
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Host)
+		return jsonResponse(http.StatusOK, map[string]string{
+			"user_id":       "u-staging",
+			"access_token":  "staging-access",
+			"refresh_token": "staging-refresh",
+		}), nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"profile", "use", "staging", "--server", "http://staging.test"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("profile use: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if code := run([]string{"signin", "--login", "alice", "--password", "secret-password"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("signin: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if len(hosts) != 1 || hosts[0] != "staging.test" {
+		t.Fatalf("the pinned server must win over SERVER_URL, got %v", hosts)
+	}
+
+	staging, err := loadSession()
+	if err != nil {
+		t.Fatalf("load staging session: %v", err)
+	}
+	if staging.UserID != "u-staging" || staging.Pinned.ServerURL != "http://staging.test" || staging.LastSyncAt != "" {
+		t.Fatalf("unexpected staging session: %+v", staging)
+	}
+	if _, err := os.Stat(filepath.Join(dir, profilesDir, "staging", "session.json")); err != nil {
+		t.Fatalf("expected the profile session in its own directory: %v", err)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--profile", "default", "profile", "list"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("profile list: exit code=%d stderr=%s", code, stderr.String())
+	}
+	profiles := decodeProfiles(t, stdout.Bytes())
+	if len(profiles) != 2 || !profiles["default"].Active || profiles["staging"].Active {
+		t.Fatalf("--profile must select the profile for one command: %+v", profiles)
+	}
+	if profiles["default"].UserID != "u-prod" || profiles["staging"].ServerURL != "http://staging.test" || !profiles["staging"].SignedIn {
+		t.Fatalf("unexpected profiles: %+v", profiles)
+	}
+	if ActiveProfile() != "staging" {
+		t.Fatalf("--profile must not change the chosen profile, got %s", ActiveProfile())
+	}
+
+	t.Setenv(profileEnv, "default")
+	prod, err := loadSession()
+	if err != nil {
+		t.Fatalf("load default session: %v", err)
+	}
+	if prod.UserID != "u-prod" || prod.LastSyncAt != "2026-03-01T10:00:00Z" {
+		t.Fatalf("the default profile must keep its session and sync cursor: %+v", prod)
+	}
+	t.Setenv(profileEnv, "")
+
+	stderr.Reset()
+	if code := run([]string{"profile", "remove", "default"}, &stdout, &stderr); code == 0 || !strings.Contains(stderr.String(), errDefaultProfile.Error()) {
+		t.Fatalf("expected the default profile to be kept, code=%d stderr=%s", code, stderr.String())
+	}
+	if code := run([]string{"profile", "remove", "staging"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("profile remove: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if ActiveProfile() != defaultProfile {
+		t.Fatalf("removing the active profile must switch back, got %s", ActiveProfile())
+	}
+	if _, err := os.Stat(filepath.Join(dir, profilesDir, "staging")); !os.IsNotExist(err) {
+		t.Fatalf("expected the profile directory to be removed: %v", err)
+	}
+}
+
+func TestProfileNamesAreValidated(t *testing.T) {
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	for _, args := range [][]string{
+		{"profile", "use", "../escape"},
+		{"--profile", "a/b", "profile", "list"},
+		{"--profile"},
+	} {
+		stderr.Reset()
+		if code := run(args, &stdout, &stderr); code == 0 {
+			t.Fatalf("expected %v to be refused", args)
+		}
+	}
+
+	t.Setenv(profileEnv, "bad name")
+	if code := run([]string{"profile", "list"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected an invalid $PKEEPER_PROFILE to be refused")
+	}
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
+)
+
+const requestAttempts = 3
+
+var (
+	retryDelay = 300 * time.Millisecond
+	// maxRetryAfter caps how long a server-sent Retry-After may stall a retry.
+	maxRetryAfter = 5 * time.Second
+)
+
+// retryTransient repeats fn while it fails with a transport error, a gateway
+// status or a conflict the server asked to retry later, such as an earlier
+// attempt with the same Idempotency-Key that is still running. Mutating calls
+// are safe to repeat because every attempt carries the same Idempotency-Key.
+func retryTransient(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt == requestAttempts || !isTransientError(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryWait(err, attempt)):
+		}
+	}
+}
+
+func retryWait(err error, attempt int) time.Duration {
+	wait := time.Duration(attempt) * retryDelay
+	var httpErr *apiclient.HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > wait {
+		wait = min(httpErr.RetryAfter, maxRetryAfter)
+	}
+	return wait
+}
+
+func isTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var httpErr *apiclient.HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		case http.StatusConflict:
+			// Plain conflicts such as a stale secret version are final.
+			return httpErr.RetryAfter > 0
+		}
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
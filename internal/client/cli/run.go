@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/7StaSH7/practicum-diploma/internal/exporter"
+)
+
+// forwardedSignals reach the child instead of stopping pkeeper, so the
+// child decides how to shut down and its exit code is what the caller sees.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+const (
+	maskReplacement = "********"
+	// minMaskedLength leaves very short values such as "1" or "yes"
+	// unmasked; hiding them would garble ordinary output.
+	minMaskedLength = 4
+)
+
+// exitCodeError carries the exit code of a child process through run
+// without printing an error of its own.
+type exitCodeError struct {
+	code int
+}
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func runRun(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	tags := fs.String("tag", "", "Comma-separated tags a secret must have to be injected")
+	mask := fs.Bool("mask", false, "Replace secret values in the command output")
+	offline := fs.Bool("offline", false, offlineFlagUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	command := fs.Args()
+	if len(command) == 0 {
+		return errors.New("command is required: pkeeper run --tag TAG -- COMMAND [ARGS...]")
+	}
+	selected := parseCSV(*tags)
+	if len(selected) == 0 {
+		return errors.New("--tag is required")
+	}
+
+	secrets, err := listSecrets(strings.TrimSpace(*serverURL), *offline, stderr)
+	if err != nil {
+		return err
+	}
+	vars, _, err := collectVariables(secrets, selected)
+	if err != nil {
+		return err
+	}
+	vars, err = exporter.Sorted(vars)
+	if err != nil {
+		return err
+	}
+
+	// Values only ever live in the child's environment; nothing is written
+	// to disk.
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = os.Environ()
+	for _, v := range vars {
+		cmd.Env = append(cmd.Env, v.Key+"="+v.Value)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if *mask {
+		values := make([]string, 0, len(vars))
+		for _, v := range vars {
+			values = append(values, v.Value)
+		}
+		outMask := newMaskWriter(stdout, values)
+		errMask := newMaskWriter(stderr, values)
+		defer outMask.Flush()
+		defer errMask.Flush()
+		cmd.Stdout = outMask
+		cmd.Stderr = errMask
+	}
+	return runChild(cmd)
+}
+
+// runChild starts cmd, forwards signals to it until it exits and turns a
+// non-zero exit into an exitCodeError.
+func runChild(cmd *exec.Cmd) error {
+	signals := make(chan os.Signal, 4)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	code := exitErr.ExitCode()
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		// Shells report a child killed by a signal as 128+signal.
+		code = 128 + int(status.Signal())
+	}
+	return exitCodeError{code: code}
+}
+
+// maskWriter replaces secret values in a byte stream. Output that could be
+// the start of a value is held back until the next write shows whether it
+// is one, so values split across writes are still masked.
+type maskWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	values  [][]byte
+	pending []byte
+}
+
+func newMaskWriter(w io.Writer, values []string) *maskWriter {
+	masked := make([][]byte, 0, len(values))
+	for _, value := range values {
+		if len(value) >= minMaskedLength && !slices.ContainsFunc(masked, func(v []byte) bool { return string(v) == value }) {
+			masked = append(masked, []byte(value))
+		}
+	}
+	// Longest first, so a value wins over a shorter one it contains.
+	slices.SortFunc(masked, func(a, b []byte) int { return len(b) - len(a) })
+	return &maskWriter{w: w, values: masked}
+}
+
+func (m *maskWriter) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = append(m.pending, p...)
+	if err := m.emit(false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes whatever was held back once the stream has ended.
+func (m *maskWriter) Flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.emit(true)
+}
+
+// emit writes pending up to the first position where a value might start
+// but has not fully arrived yet, or all of it at the end of the stream.
+func (m *maskWriter) emit(final bool) error {
+	var out bytes.Buffer
+	i := 0
+scan:
+	for i < len(m.pending) {
+		rest := m.pending[i:]
+		for _, value := range m.values {
+			if !final && len(rest) < len(value) && bytes.HasPrefix(value, rest) {
+				break scan
+			}
+		}
+		matched := false
+		for _, value := range m.values {
+			if bytes.HasPrefix(rest, value) {
+				out.WriteString(maskReplacement)
+				i += len(value)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out.WriteByte(rest[0])
+			i++
+		}
+	}
+	m.pending = append(m.pending[:0], m.pending[i:]...)
+	if out.Len() == 0 {
+		return nil
+	}
+	_, err := m.w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+)
+
+// TestRunHelperProcess is the child started by the run tests. It prints the
+// injected variable and exits with code 3.
+func TestRunHelperProcess(t *testing.T) {
+	if os.Getenv("PKEEPER_RUN_HELPER") != "1" {
+		return
+	}
+	fmt.Printf("token is %s\n", os.Getenv("API_TOKEN"))
+	fmt.Fprintln(os.Stderr, "stderr "+os.Getenv("API_TOKEN"))
+	// Secrets outside the selected tag must not be injected.
+	if _, ok := os.LookupEnv("STAGING_TOKEN"); ok {
+		os.Exit(9)
+	}
+	os.Exit(3)
+}
+
+func installRunSecrets(t *testing.T) {
+	t.Helper()
+	saveTestSession(t)
+	secrets := []dtosecret.SecretResponse{
+		{ID: "s-1", Type: "note", MetaOpen: models.MetaOpen{Title: "api token", Tags: []string{"service-x"}}, Ciphertext: "dG9wLXNlY3JldA=="},
+		{ID: "s-2", Type: "note", MetaOpen: models.MetaOpen{Title: "staging token", Tags: []string{"staging"}}, Ciphertext: "b3RoZXI="},
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet || req.URL.Path != "/secrets" {
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		}
+		return jsonResponse(http.StatusOK, secrets), nil
+	})
+	t.Setenv("PKEEPER_RUN_HELPER", "1")
+}
+
+func TestRunInjectsSecretsAndPropagatesExitCode(t *testing.T) {
+	installRunSecrets(t)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"run", "--tag", "service-x", "--", os.Args[0], "-test.run=^TestRunHelperProcess$"}, &stdout, &stderr)
+	if code != 3 {
+		t.Fatalf("expected the child exit code, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "token is top-secret\n") {
+		t.Fatalf("expected the secret in the child environment: %q", stdout.String())
+	}
+	if strings.Contains(stderr.String(), "error:") {
+		t.Fatalf("a failing child must not be reported as a pkeeper error: %q", stderr.String())
+	}
+}
+
+func TestRunMasksSecretValuesInOutput(t *testing.T) {
+	installRunSecrets(t)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"run", "--tag", "service-x", "--mask", "--", os.Args[0], "-test.run=^TestRunHelperProcess$"}, &stdout, &stderr)
+	if code != 3 {
+		t.Fatalf("expected the child exit code, got %d stderr=%s", code, stderr.String())
+	}
+	if strings.Contains(stdout.String()+stderr.String(), "top-secret") {
+		t.Fatalf("secret leaked into output: %q %q", stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "token is ********\n") || !strings.Contains(stderr.String(), "stderr ********\n") {
+		t.Fatalf("expected masked output: %q %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestRunRequiresTagAndCommand(t *testing.T) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"run", "--tag", "service-x"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected a missing command to fail, got %d", code)
+	}
+	if code := run([]string{"run", "--", "true"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected a missing tag to fail, got %d", code)
+	}
+}
+
+func TestMaskWriterHandlesSplitAndOverlappingValues(t *testing.T) {
+	var out bytes.Buffer
+	w := newMaskWriter(&out, []string{"abcd", "cdXYZ", "hunter2", "ab"})
+	for _, chunk := range []string{"pass=hun", "ter2; ab", "cd; cdX", "YZ; abc"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	w.Flush()
+	want := "pass=********; ********; ********; abc"
+	if out.String() != want {
+		t.Fatalf("unexpected output: %q, want %q", out.String(), want)
+	}
+}
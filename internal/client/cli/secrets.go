@@ -11,31 +11,57 @@ import (
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultcache"
 )
 
-func runSecrets(args []string, stdout io.Writer) error {
+func runSecrets(args []string, stdout, stderr io.Writer) error {
 	if len(args) == 0 {
-		return errors.New("usage: secrets <list|get|create|update|delete|sync>")
+		return errors.New("usage: secrets <list|get|create|update|delete|sync|pending|history|restore|batch|attach|attachments|download|detach|totp|match|totp-import>")
 	}
 	switch args[0] {
 	case "list":
-		return runSecretsList(args[1:], stdout, false)
+		return runSecretsList(args[1:], stdout, stderr)
 	case "sync":
-		return runSecretsSync(args[1:], stdout)
+		return runSecretsSync(args[1:], stdout, stderr)
+	case "pending":
+		return runSecretsPending(args[1:], stdout)
 	case "get":
-		return runSecretsGet(args[1:], stdout)
+		return runSecretsGet(args[1:], stdout, stderr)
 	case "create":
-		return runSecretsCreate(args[1:], stdout)
+		return runSecretsCreate(args[1:], stdout, stderr)
 	case "update":
-		return runSecretsUpdate(args[1:], stdout)
+		return runSecretsUpdate(args[1:], stdout, stderr)
 	case "delete":
-		return runSecretsDelete(args[1:], stdout)
+		return runSecretsDelete(args[1:], stdout, stderr)
+	case "history":
+		return runSecretsHistory(args[1:], stdout)
+	case "restore":
+		return runSecretsRestore(args[1:], stdout)
+	case "batch":
+		return runSecretsBatch(args[1:], stdout)
+	case "attach":
+		return runSecretsAttach(args[1:], stdout)
+	case "attachments":
+		return runSecretsAttachments(args[1:], stdout)
+	case "download":
+		return runSecretsDownload(args[1:], stdout)
+	case "detach":
+		return runSecretsDetach(args[1:], stdout)
+	case "totp":
+		return runSecretsTOTP(args[1:], stdout)
+	case "match":
+		return runSecretsMatch(args[1:], stdout, stderr)
+	case "totp-import":
+		return runSecretsTOTPImport(args[1:], stdout)
 	default:
 		return fmt.Errorf("unknown secrets command: %s", args[0])
 	}
 }
 
-func runSecretsSync(args []string, stdout io.Writer) error {
+func runSecretsSync(args []string, stdout, stderr io.Writer) error {
 	fs := flag.NewFlagSet("secrets sync", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	serverURL := fs.String("server", "", "Server base URL")
@@ -68,15 +94,11 @@ func runSecretsSync(args []string, stdout io.Writer) error {
 		}
 	}
 
-	syncArgs := make([]string, 0, 4)
-	if trimmedServerURL != "" {
-		syncArgs = append(syncArgs, "--server", trimmedServerURL)
-	}
-	if *once && trimmedSince != "" {
-		syncArgs = append(syncArgs, "--since", trimmedSince)
+	onceSince := ""
+	if *once {
+		onceSince = trimmedSince
 	}
-
-	if err := runSecretsList(syncArgs, stdout, true); err != nil {
+	if err := syncSecrets(trimmedServerURL, onceSince, stdout, stderr); err != nil {
 		return err
 	}
 	if *once {
@@ -85,17 +107,151 @@ func runSecretsSync(args []string, stdout io.Writer) error {
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
-	ticker := time.NewTicker(syncInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-ticker.C:
-			if err := runSecretsList(syncArgs, stdout, true); err != nil {
-				return err
-			}
+	return watchAndSync(ctx, trimmedServerURL, func() error {
+		return syncSecrets(trimmedServerURL, "", stdout, stderr)
+	})
+}
+
+// syncSecrets uploads queued writes, brings the offline cache up to date and
+// prints the secrets changed since the last sync, or since the given time.
+func syncSecrets(serverURL, since string, stdout, stderr io.Writer) error {
+	if err := flushQueue(serverURL, stderr); err != nil {
+		return err
+	}
+	sess, err := loadSession()
+	if err != nil {
+		return err
+	}
+	store, err := cacheStore(sess)
+	if err != nil {
+		return err
+	}
+	cache, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if !cache.Complete {
+		if err := seedCache(serverURL, store); err != nil {
+			return err
+		}
+		if cache, err = store.Load(); err != nil {
+			return err
+		}
+	}
+
+	// One request serves both cursors: the cache may lag behind the session
+	// after a sync with --since, or the other way round.
+	fetchSince := ""
+	sess, changes, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) ([]dtosecret.SecretResponse, error) {
+		if since == "" {
+			since = sess.LastSyncAt
+		}
+		fetchSince = earlierTime(cache.Cursor, since)
+		return client.ListSecretChanges(ctx, accessToken, fetchSince)
+	})
+	if err != nil {
+		return err
+	}
+	if err := store.Update(func(c *vaultcache.Cache) error {
+		c.Apply(changes)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if fetchSince != since {
+		changes = changedSince(changes, since)
+	}
+	if latest, ok := findLatestUpdatedAt(changes); ok {
+		sess.LastSyncAt = latest
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	live := make([]dtosecret.SecretResponse, 0, len(changes))
+	for _, secret := range changes {
+		if secret.DeletedAt == "" {
+			live = append(live, secret)
+		}
+	}
+	return printJSON(stdout, live)
+}
+
+// seedCache fills an empty cache with a full listing, so deletions the
+// change feed no longer carries cannot linger in it.
+func seedCache(serverURL string, store *vaultcache.Store) error {
+	sess, secrets, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) ([]dtosecret.SecretResponse, error) {
+		return client.ListSecrets(ctx, accessToken, "")
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	return store.Update(func(c *vaultcache.Cache) error {
+		c.Replace(secrets)
+		return nil
+	})
+}
+
+// earlierTime returns the earlier of two RFC3339 times; empty means the
+// beginning of time.
+func earlierTime(a, b string) string {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	switch {
+	case errA != nil || errB != nil:
+		return ""
+	case ta.Before(tb):
+		return a
+	default:
+		return b
+	}
+}
+
+type pendingWrite struct {
+	ID          string `json:"id"`
+	Op          string `json:"op"`
+	SecretID    string `json:"secret_id"`
+	Title       string `json:"title,omitempty"`
+	BaseVersion int64  `json:"base_version,omitempty"`
+	QueuedAt    string `json:"queued_at"`
+	LastError   string `json:"last_error,omitempty"`
+	// Conflict lists the fields that wait for pkeeper conflicts resolve.
+	Conflict []string `json:"conflict,omitempty"`
+}
+
+// runSecretsPending lists writes waiting in the offline queue.
+func runSecretsPending(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("secrets pending", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cache, err := loadCache()
+	if err != nil {
+		return err
+	}
+	pending := make([]pendingWrite, 0, len(cache.Queue))
+	for _, op := range cache.Pending() {
+		item := pendingWrite{
+			ID:          op.ID,
+			Op:          op.Kind,
+			SecretID:    op.SecretID,
+			BaseVersion: op.BaseVersion,
+			QueuedAt:    op.QueuedAt.UTC().Format(time.RFC3339),
+			LastError:   op.LastError,
+		}
+		if op.Conflict != nil {
+			item.Conflict = op.Conflict.Fields
+		}
+		if op.Secret != nil {
+			item.Title = op.Secret.MetaOpen.Title
+		} else if secret, ok := cache.Secrets[op.SecretID]; ok {
+			item.Title = secret.MetaOpen.Title
 		}
+		pending = append(pending, item)
 	}
+	return printJSON(stdout, pending)
 }
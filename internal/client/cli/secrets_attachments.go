@@ -0,0 +1,298 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtoattachment "github.com/7StaSH7/practicum-diploma/internal/dto/attachment"
+	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
+)
+
+// attachmentChunkSize keeps each upload request well below the server limit
+// so a dropped connection costs at most one chunk.
+var attachmentChunkSize int64 = 1 << 20
+
+type attachmentDownload struct {
+	dtoattachment.AttachmentResponse
+	Path string `json:"path"`
+}
+
+func runSecretsAttach(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("secrets attach", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	secretID := fs.String("id", "", "Secret ID")
+	filePath := fs.String("file", "", "File to attach")
+	name := fs.String("name", "", "Attachment name, defaults to the file name")
+	resume := fs.String("resume", "", "Attachment ID of an interrupted upload")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	trimmedID := strings.TrimSpace(*secretID)
+	attachmentID := strings.TrimSpace(*resume)
+	path := strings.TrimSpace(*filePath)
+	if trimmedID == "" && attachmentID == "" {
+		return errors.New("--id is required")
+	}
+	if path == "" {
+		return errors.New("--file is required")
+	}
+	attachmentName := strings.TrimSpace(*name)
+	if attachmentName == "" {
+		attachmentName = filepath.Base(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return errors.New("cannot attach an empty file")
+	}
+
+	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (dtoattachment.AttachmentResponse, error) {
+		// A retried or refreshed request resumes the attachment created by
+		// the first attempt instead of starting a new one.
+		var current dtoattachment.AttachmentResponse
+		var requestErr error
+		if attachmentID == "" {
+			stored := client.AttachmentLayout("", info.Size(), attachmentChunkSize).StoredSize()
+			current, requestErr = client.CreateAttachment(ctx, accessToken, trimmedID, attachmentName, stored)
+			if requestErr != nil {
+				return dtoattachment.AttachmentResponse{}, requestErr
+			}
+			attachmentID = current.ID
+		} else {
+			current, requestErr = client.GetAttachment(ctx, accessToken, attachmentID)
+			if requestErr != nil {
+				return dtoattachment.AttachmentResponse{}, requestErr
+			}
+		}
+		return uploadAttachment(ctx, client, accessToken, file, info.Size(), current)
+	})
+	if err != nil {
+		if attachmentID != "" {
+			return fmt.Errorf("%w (resume with --resume %s)", err, attachmentID)
+		}
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	return printJSON(stdout, result)
+}
+
+// uploadAttachment sends the rest of content, a file of size bytes, from
+// the offset the server reports. Once the vault is encrypted every chunk is
+// sealed before it leaves. A 409 means the server holds a different offset,
+// e.g. because a chunk was stored but its response was lost, so the offset
+// is re-read. content is read front to back; only a seekable one can go
+// back.
+func uploadAttachment(ctx context.Context, client *api.API, accessToken string, content io.Reader, size int64, current dtoattachment.AttachmentResponse) (dtoattachment.AttachmentResponse, error) {
+	layout := client.AttachmentLayout(current.ID, size, attachmentChunkSize)
+	if current.Size != layout.StoredSize() {
+		return dtoattachment.AttachmentResponse{}, fmt.Errorf("file of %d bytes does not match the attachment of %d bytes", size, current.Size)
+	}
+	source := attachmentSource{r: content}
+	buf := make([]byte, attachmentChunkSize)
+	for !current.Complete {
+		stored := current.Received
+		offset, err := layout.FileOffset(stored)
+		if err != nil {
+			return dtoattachment.AttachmentResponse{}, err
+		}
+		n, err := source.readAt(buf[:min(attachmentChunkSize, size-offset)], offset)
+		if err != nil {
+			return dtoattachment.AttachmentResponse{}, err
+		}
+		if n == 0 {
+			return dtoattachment.AttachmentResponse{}, errors.New("file is shorter than the attachment")
+		}
+		// Each chunk is its own request, so it gets its own key.
+		chunkCtx := ctx
+		if key, ok := apiclient.IdempotencyKey(ctx); ok {
+			chunkCtx = apiclient.WithIdempotencyKey(ctx, fmt.Sprintf("%s-%d", key, stored))
+		}
+		chunk, err := client.SealAttachmentChunk(chunkCtx, layout, offset, buf[:n])
+		if err != nil {
+			return dtoattachment.AttachmentResponse{}, err
+		}
+		next, err := client.UploadAttachmentChunk(chunkCtx, accessToken, current.ID, stored, chunk)
+		if api.IsHTTPStatus(err, http.StatusConflict) {
+			next, err = client.GetAttachment(ctx, accessToken, current.ID)
+			if err == nil && !next.Complete && next.Received == stored {
+				return dtoattachment.AttachmentResponse{}, errors.New("server rejected the upload offset")
+			}
+		}
+		if err != nil {
+			return dtoattachment.AttachmentResponse{}, err
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// attachmentSource reads upload chunks at the offsets the server asks for,
+// so a stream such as an archive entry never has to be held in memory.
+type attachmentSource struct {
+	r   io.Reader
+	pos int64
+}
+
+// readAt fills as much of buf as content holds from offset on. Streams skip
+// forward over what the server already has and cannot go back.
+func (s *attachmentSource) readAt(buf []byte, offset int64) (int, error) {
+	if seeker, ok := s.r.(io.Seeker); ok {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+		s.pos = offset
+	}
+	if offset < s.pos {
+		return 0, fmt.Errorf("cannot go back to offset %d of a streamed upload", offset)
+	}
+	if _, err := io.CopyN(io.Discard, s.r, offset-s.pos); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	n, err := io.ReadFull(s.r, buf)
+	s.pos = offset + int64(n)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	return n, err
+}
+
+func runSecretsAttachments(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("secrets attachments", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	secretID := fs.String("id", "", "Secret ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	trimmedID := strings.TrimSpace(*secretID)
+	if trimmedID == "" {
+		return errors.New("--id is required")
+	}
+
+	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) ([]dtoattachment.AttachmentResponse, error) {
+		return client.ListAttachments(ctx, accessToken, trimmedID)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	if result == nil {
+		result = []dtoattachment.AttachmentResponse{}
+	}
+	return printJSON(stdout, result)
+}
+
+func runSecretsDownload(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("secrets download", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	attachmentID := fs.String("attachment", "", "Attachment ID")
+	out := fs.String("out", "", "Output file, defaults to the attachment name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	trimmedID := strings.TrimSpace(*attachmentID)
+	if trimmedID == "" {
+		return errors.New("--attachment is required")
+	}
+
+	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (attachmentDownload, error) {
+		meta, requestErr := client.GetAttachment(ctx, accessToken, trimmedID)
+		if requestErr != nil {
+			return attachmentDownload{}, requestErr
+		}
+		path := strings.TrimSpace(*out)
+		if path == "" {
+			path = filepath.Base(meta.Name)
+		}
+		if requestErr := downloadAttachment(ctx, client, accessToken, meta, path); requestErr != nil {
+			return attachmentDownload{}, requestErr
+		}
+		return attachmentDownload{AttachmentResponse: meta, Path: path}, nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	return printJSON(stdout, result)
+}
+
+// downloadAttachment writes into a temporary file next to path and renames
+// it into place, so an interrupted download never leaves a truncated file.
+func downloadAttachment(ctx context.Context, client *api.API, accessToken string, meta dtoattachment.AttachmentResponse, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.part")
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := client.DownloadAttachment(ctx, accessToken, meta, tmp); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+func runSecretsDetach(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("secrets detach", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	attachmentID := fs.String("attachment", "", "Attachment ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	trimmedID := strings.TrimSpace(*attachmentID)
+	if trimmedID == "" {
+		return errors.New("--attachment is required")
+	}
+
+	sess, _, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (struct{}, error) {
+		return struct{}{}, client.DeleteAttachment(ctx, accessToken, trimmedID)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "attachment %s deleted\n", trimmedID)
+	return err
+}
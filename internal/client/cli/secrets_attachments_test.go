@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	dtoattachment "github.com/7StaSH7/practicum-diploma/internal/dto/attachment"
+)
+
+func saveTestSession(t *testing.T) {
+	t.Helper()
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(t.TempDir(), "session.json"))
+	if err := saveSession(session{
+		ServerURL:    "http://example.test",
+		UserID:       "u-1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+	}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+}
+
+func TestSecretsAttachUploadsInChunksAndResyncsOffset(t *testing.T) {
+	saveTestSession(t)
+	prevChunk := attachmentChunkSize
+	attachmentChunkSize = 4
+	t.Cleanup(func() { attachmentChunkSize = prevChunk })
+
+	path := filepath.Join(t.TempDir(), "key.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	var stored []byte
+	conflictSent := false
+	state := func() dtoattachment.AttachmentResponse {
+		return dtoattachment.AttachmentResponse{
+			ID:       "a-1",
+			Name:     "key.bin",
+			Size:     10,
+			Received: int64(len(stored)),
+			Complete: len(stored) == 10,
+		}
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/s-1/attachments":
+			var payload dtoattachment.CreateAttachmentRequest
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode create: %v", err)
+			}
+			if payload.Name != "key.bin" || payload.Size != 10 {
+				t.Fatalf("unexpected create payload: %+v", payload)
+			}
+			return jsonResponse(http.StatusCreated, state()), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/attachments/a-1":
+			return jsonResponse(http.StatusOK, state()), nil
+		case req.Method == http.MethodPut && req.URL.Path == "/attachments/a-1/content":
+			offset, _ := strconv.Atoi(req.Header.Get("Upload-Offset"))
+			body, _ := io.ReadAll(req.Body)
+			if offset == 4 && !conflictSent {
+				// The first chunk landed twice: the server is already ahead.
+				conflictSent = true
+				stored = append(stored, body...)
+				return jsonResponse(http.StatusConflict, nil), nil
+			}
+			if offset != len(stored) {
+				return jsonResponse(http.StatusConflict, nil), nil
+			}
+			stored = append(stored, body...)
+			return jsonResponse(http.StatusOK, state()), nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"secrets", "attach", "--id", "s-1", "--file", path}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if string(stored) != "0123456789" {
+		t.Fatalf("unexpected stored bytes: %q", stored)
+	}
+	var result dtoattachment.AttachmentResponse
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if !result.Complete {
+		t.Fatalf("expected completed attachment, got %+v", result)
+	}
+}
+
+func TestSecretsDownloadWritesFile(t *testing.T) {
+	saveTestSession(t)
+	out := filepath.Join(t.TempDir(), "restored.bin")
+
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/attachments/a-1":
+			return jsonResponse(http.StatusOK, dtoattachment.AttachmentResponse{ID: "a-1", Name: "key.bin", Size: 5, Received: 5, Complete: true}), nil
+		case "/attachments/a-1/content":
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     make(http.Header),
+				Body:       io.NopCloser(bytes.NewReader([]byte("bytes"))),
+			}, nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"secrets", "download", "--attachment", "a-1", "--out", out}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(data) != "bytes" {
+		t.Fatalf("unexpected file content: %q", data)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(out))
+	if len(entries) != 1 {
+		t.Fatalf("expected no leftover temp files, got %d entries", len(entries))
+	}
+}
+
+func TestSecretsDownloadRejectsTruncatedBody(t *testing.T) {
+	saveTestSession(t)
+	out := filepath.Join(t.TempDir(), "restored.bin")
+
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/attachments/a-1" {
+			return jsonResponse(http.StatusOK, dtoattachment.AttachmentResponse{ID: "a-1", Name: "key.bin", Size: 10, Received: 10, Complete: true}), nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(bytes.NewReader([]byte("short"))),
+		}, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"secrets", "download", "--attachment", "a-1", "--out", out}, &stdout, &stderr)
+	if code == 0 {
+		t.Fatal("expected failure for truncated download")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("expected no output file, got err=%v", err)
+	}
+}
+
+func TestSecretsAttachSealsChunksOfEncryptedVault(t *testing.T) {
+	unlockTestVault(t)
+	prevChunk := attachmentChunkSize
+	attachmentChunkSize = 4
+	t.Cleanup(func() { attachmentChunkSize = prevChunk })
+
+	content := []byte("0123456789")
+	path := filepath.Join(t.TempDir(), "key.bin")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	var stored []byte
+	var size int64
+	state := func(id string) dtoattachment.AttachmentResponse {
+		received := int64(len(stored))
+		return dtoattachment.AttachmentResponse{ID: id, Name: "key.bin", Size: size, Received: received, Complete: received == size}
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets/s-1/attachments":
+			var payload dtoattachment.CreateAttachmentRequest
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode create: %v", err)
+			}
+			size = payload.Size
+			return jsonResponse(http.StatusCreated, state("a-1")), nil
+		case req.Method == http.MethodGet && (req.URL.Path == "/attachments/a-1" || req.URL.Path == "/attachments/a-2"):
+			return jsonResponse(http.StatusOK, state(filepath.Base(req.URL.Path))), nil
+		case req.Method == http.MethodPut && req.URL.Path == "/attachments/a-1/content":
+			if req.Header.Get("Upload-Offset") != strconv.Itoa(len(stored)) {
+				return jsonResponse(http.StatusConflict, nil), nil
+			}
+			body, _ := io.ReadAll(req.Body)
+			stored = append(stored, body...)
+			return jsonResponse(http.StatusOK, state("a-1")), nil
+		case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/content"):
+			return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(bytes.NewReader(stored))}, nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "attach", "--id", "s-1", "--file", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("attach: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if int64(len(stored)) != size || size <= int64(len(content)) {
+		t.Fatalf("expected the declared size to be the sealed size: declared=%d stored=%d", size, len(stored))
+	}
+	for _, part := range []string{"0123", "4567", "89"} {
+		if bytes.Contains(stored, []byte(part)) {
+			t.Fatalf("the server must not get file bytes, found %q in %q", part, stored)
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "restored.bin")
+	if code := run([]string{"secrets", "download", "--attachment", "a-1", "--out", out}, &stdout, &stderr); code != 0 {
+		t.Fatalf("download: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if data, err := os.ReadFile(out); err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("unexpected download: %q %v", data, err)
+	}
+
+	// Sealed chunks only open as the attachment they were uploaded to.
+	stderr.Reset()
+	if code := run([]string{"secrets", "download", "--attachment", "a-2", "--out", out + ".moved"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected chunks of another attachment to be refused")
+	}
+	stored[len(stored)-1] ^= 1
+	if code := run([]string{"secrets", "download", "--attachment", "a-1", "--out", out + ".damaged"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected a damaged chunk to be refused")
+	}
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
+)
+
+// maxBatchOperations mirrors the server limit so large files are split into
+// several requests instead of being rejected.
+const maxBatchOperations = 500
+
+var batchInput io.Reader = os.Stdin
+
+func runSecretsBatch(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("secrets batch", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	file := fs.String("file", "", "JSON file with operations, - for stdin")
+	perItem := fs.Bool("per-item", false, "Commit every operation on its own")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path := strings.TrimSpace(*file)
+	if path == "" {
+		return errors.New("--file is required")
+	}
+	operations, err := readBatchOperations(path)
+	if err != nil {
+		return err
+	}
+	if len(operations) == 0 {
+		return errors.New("batch file has no operations")
+	}
+	mode := dtosecret.BatchModeAtomic
+	if *perItem {
+		mode = dtosecret.BatchModePerItem
+	}
+	if mode == dtosecret.BatchModeAtomic && len(operations) > maxBatchOperations {
+		return fmt.Errorf("atomic batch is limited to %d operations, use --per-item", maxBatchOperations)
+	}
+
+	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.BatchResponse, error) {
+		return sendBatch(ctx, client, accessToken, mode, operations)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	return printJSON(stdout, result)
+}
+
+// sendBatch submits operations in chunks the server accepts and merges the
+// per-item results back into one response with indexes from the input.
+func sendBatch(ctx context.Context, client *api.API, accessToken, mode string, operations []dtosecret.BatchOperation) (dtosecret.BatchResponse, error) {
+	merged := dtosecret.BatchResponse{
+		Mode:    mode,
+		Results: make([]dtosecret.BatchItemResult, 0, len(operations)),
+	}
+	for start := 0; start < len(operations); start += maxBatchOperations {
+		end := min(start+maxBatchOperations, len(operations))
+		chunkCtx := ctx
+		if key, ok := apiclient.IdempotencyKey(ctx); ok && start > 0 {
+			chunkCtx = apiclient.WithIdempotencyKey(ctx, fmt.Sprintf("%s-%d", key, start))
+		}
+		resp, err := client.BatchSecrets(chunkCtx, accessToken, dtosecret.BatchRequest{
+			Mode:       mode,
+			Operations: operations[start:end],
+		})
+		if err != nil {
+			return dtosecret.BatchResponse{}, err
+		}
+		merged.Committed = merged.Committed || resp.Committed
+		for _, item := range resp.Results {
+			item.Index += start
+			merged.Results = append(merged.Results, item)
+		}
+	}
+	return merged, nil
+}
+
+// readBatchOperations accepts either a full batch request object or a bare
+// array of operations.
+func readBatchOperations(path string) ([]dtosecret.BatchOperation, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(batchInput)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var operations []dtosecret.BatchOperation
+		if err := json.Unmarshal(data, &operations); err != nil {
+			return nil, fmt.Errorf("parse batch file: %w", err)
+		}
+		return operations, nil
+	}
+	var req dtosecret.BatchRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("parse batch file: %w", err)
+	}
+	return req.Operations, nil
+}
@@ -6,112 +6,244 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/7StaSH7/practicum-diploma/internal/api"
 	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultcache"
+	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
+	"github.com/google/uuid"
 )
 
-func runSecretsList(args []string, stdout io.Writer, updateLastSync bool) error {
+func runSecretsList(args []string, stdout, stderr io.Writer) error {
 	fs := flag.NewFlagSet("secrets list", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	serverURL := fs.String("server", "", "Server base URL")
 	since := fs.String("since", "", "RFC3339 timestamp")
+	offline := fs.Bool("offline", false, offlineFlagUsage)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	effectiveSince := strings.TrimSpace(*since)
-	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) ([]dtosecret.SecretResponse, error) {
-		if effectiveSince == "" && updateLastSync {
-			effectiveSince = sess.LastSyncAt
+	trimmedSince := strings.TrimSpace(*since)
+	if trimmedSince == "" {
+		result, err := listSecrets(strings.TrimSpace(*serverURL), *offline, stderr)
+		if err != nil {
+			return err
+		}
+		return printJSON(stdout, result)
+	}
+	if *offline {
+		cached, _, err := cachedSecrets()
+		if err != nil {
+			return err
 		}
-		secrets, requestErr := client.ListSecrets(ctx, accessToken, effectiveSince)
-		return secrets, requestErr
+		return printJSON(stdout, changedSince(cached, trimmedSince))
+	}
+
+	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) ([]dtosecret.SecretResponse, error) {
+		return client.ListSecrets(ctx, accessToken, trimmedSince)
 	})
 	if err != nil {
 		return err
 	}
-
-	if updateLastSync && len(result) > 0 {
-		if latest, ok := findLatestUpdatedAt(result); ok {
-			sess.LastSyncAt = latest
-		}
-	}
 	if err := saveSession(sess); err != nil {
 		return err
 	}
 	return printJSON(stdout, result)
 }
 
-func runSecretsGet(args []string, stdout io.Writer) error {
+// changedSince keeps secrets updated after since, like the server filter.
+func changedSince(secrets []dtosecret.SecretResponse, since string) []dtosecret.SecretResponse {
+	threshold, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return secrets
+	}
+	out := make([]dtosecret.SecretResponse, 0, len(secrets))
+	for _, secret := range secrets {
+		if updated, err := time.Parse(time.RFC3339, secret.UpdatedAt); err == nil && updated.After(threshold) {
+			out = append(out, secret)
+		}
+	}
+	return out
+}
+
+func runSecretsGet(args []string, stdout, stderr io.Writer) error {
 	fs := flag.NewFlagSet("secrets get", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	serverURL := fs.String("server", "", "Server base URL")
 	secretID := fs.String("id", "", "Secret ID")
+	offline := fs.Bool("offline", false, offlineFlagUsage)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
-	if strings.TrimSpace(*secretID) == "" {
+	trimmedID := strings.TrimSpace(*secretID)
+	if trimmedID == "" {
 		return errors.New("--id is required")
 	}
 
+	if *offline || mustQueue(trimmedID) {
+		// Secrets with queued writes read as they will be after upload.
+		cache, err := loadCache()
+		if err != nil {
+			return err
+		}
+		secret, ok := cache.Get(trimmedID)
+		if !ok {
+			return fmt.Errorf("secret %s: %w", trimmedID, vaultcache.ErrNotFound)
+		}
+		return printJSON(stdout, secret)
+	}
+
 	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
-		secret, requestErr := client.GetSecret(ctx, accessToken, strings.TrimSpace(*secretID))
+		secret, requestErr := client.GetSecret(ctx, accessToken, trimmedID)
 		return secret, requestErr
 	})
 	if err != nil {
-		return err
+		if !isTransientError(err) {
+			return err
+		}
+		cache, cacheErr := loadCache()
+		if cacheErr != nil {
+			return err
+		}
+		secret, ok := cache.Get(trimmedID)
+		if !ok {
+			return err
+		}
+		warnOffline(stderr, err, cache)
+		return printJSON(stdout, secret)
 	}
 	if err := saveSession(sess); err != nil {
 		return err
 	}
+	updateCache(sess, stderr, func(c *vaultcache.Cache) error {
+		c.Put(result)
+		return nil
+	})
 	return printJSON(stdout, result)
 }
 
-func runSecretsCreate(args []string, stdout io.Writer) error {
-	payload, serverURL, _, err := parseSecretWriteFlags("secrets create", args, false)
+func runSecretsCreate(args []string, stdout, stderr io.Writer) error {
+	w, err := parseSecretWriteFlags("secrets create", args, false)
 	if err != nil {
 		return err
 	}
-
-	sess, result, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
-		secret, requestErr := client.CreateSecret(ctx, accessToken, payload)
-		return secret, requestErr
-	})
+	result, err := createSecret(w.serverURL, w.payload, w.offline, stderr)
 	if err != nil {
 		return err
 	}
-	if err := saveSession(sess); err != nil {
-		return err
+	return printSecretWrite(stdout, w, result)
+}
+
+// generatedSecretOutput is a written secret with the password generated for
+// it. Unless --show was given, neither the password nor the payload holding
+// it is printed.
+type generatedSecretOutput struct {
+	dtosecret.SecretResponse
+	Generated generateOutput `json:"generated"`
+}
+
+func printSecretWrite(stdout io.Writer, w secretWrite, result dtosecret.SecretResponse) error {
+	if w.generated == nil {
+		return printJSON(stdout, result)
 	}
-	return printJSON(stdout, result)
+	out := generatedSecretOutput{SecretResponse: result, Generated: *w.generated}
+	if !w.show {
+		out.Ciphertext = ""
+		out.Generated.Value = ""
+	}
+	return printJSON(stdout, out)
 }
 
-func runSecretsUpdate(args []string, stdout io.Writer) error {
-	payload, serverURL, secretID, err := parseSecretWriteFlags("secrets update", args, true)
-	if err != nil {
-		return err
+// createSecret uploads a new secret, or queues it when offline is set or
+// the server cannot be reached.
+func createSecret(serverURL string, payload dtosecret.SecretPayload, offline bool, stderr io.Writer) (dtosecret.SecretResponse, error) {
+	// A create queued after a dropped connection is uploaded under the same
+	// key, so the server recognises it if the first attempt got through.
+	key := uuid.NewString()
+	if !offline {
+		sess, result, err := runAuthorizedRequestWithKey(serverURL, key, func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
+			secret, requestErr := client.CreateSecret(ctx, accessToken, payload)
+			return secret, requestErr
+		})
+		if err == nil {
+			if err := saveSession(sess); err != nil {
+				return dtosecret.SecretResponse{}, err
+			}
+			updateCache(sess, stderr, func(c *vaultcache.Cache) error {
+				c.Put(result)
+				return nil
+			})
+			return result, nil
+		}
+		if !isTransientError(err) {
+			return dtosecret.SecretResponse{}, err
+		}
+		_, _ = fmt.Fprintf(stderr, "warning: server unreachable (%v)\n", err)
 	}
+	return queueWrite(vaultcache.Op{ID: key, Kind: dtosecret.BatchOpCreate, Secret: &payload}, stderr)
+}
 
-	sess, result, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
-		secret, requestErr := client.UpdateSecret(ctx, accessToken, secretID, payload)
-		return secret, requestErr
-	})
+func runSecretsUpdate(args []string, stdout, stderr io.Writer) error {
+	w, err := parseSecretWriteFlags("secrets update", args, true)
 	if err != nil {
 		return err
 	}
-	if err := saveSession(sess); err != nil {
+	if w.payload.Ciphertext == "" {
+		// A rotation: the new password goes into the current login.
+		secrets, err := listSecrets(w.serverURL, w.offline, stderr)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(secrets, func(secret dtosecret.SecretResponse) bool { return secret.ID == w.secretID })
+		if i < 0 {
+			return fmt.Errorf("secret %s not found", w.secretID)
+		}
+		if err := w.rebase(secrets[i]); err != nil {
+			return err
+		}
+	}
+	result, err := updateSecret(w.serverURL, w.secretID, w.payload, w.offline, stderr)
+	if err != nil {
 		return err
 	}
-	return printJSON(stdout, result)
+	return printSecretWrite(stdout, w, result)
 }
 
-func runSecretsDelete(args []string, stdout io.Writer) error {
+// updateSecret uploads a change, or queues it like createSecret.
+func updateSecret(serverURL, secretID string, payload dtosecret.SecretPayload, offline bool, stderr io.Writer) (dtosecret.SecretResponse, error) {
+	if !offline && !mustQueue(secretID) {
+		sess, result, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
+			secret, requestErr := client.UpdateSecret(ctx, accessToken, secretID, payload)
+			return secret, requestErr
+		})
+		if err == nil {
+			if err := saveSession(sess); err != nil {
+				return dtosecret.SecretResponse{}, err
+			}
+			updateCache(sess, stderr, func(c *vaultcache.Cache) error {
+				c.Put(result)
+				return nil
+			})
+			return result, nil
+		}
+		if !isTransientError(err) {
+			return dtosecret.SecretResponse{}, err
+		}
+		_, _ = fmt.Fprintf(stderr, "warning: server unreachable (%v)\n", err)
+	}
+	return queueWrite(vaultcache.Op{ID: uuid.NewString(), Kind: dtosecret.BatchOpUpdate, SecretID: secretID, Secret: &payload}, stderr)
+}
+
+func runSecretsDelete(args []string, stdout, stderr io.Writer) error {
 	fs := flag.NewFlagSet("secrets delete", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	serverURL := fs.String("server", "", "Server base URL")
 	secretID := fs.String("id", "", "Secret ID")
+	offline := fs.Bool("offline", false, offlineFlagUsage)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -120,36 +252,73 @@ func runSecretsDelete(args []string, stdout io.Writer) error {
 		return errors.New("--id is required")
 	}
 
-	sess, _, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (struct{}, error) {
-		requestErr := client.DeleteSecret(ctx, accessToken, trimmedID)
-		return struct{}{}, requestErr
-	})
+	queued, err := deleteSecret(strings.TrimSpace(*serverURL), trimmedID, *offline, stderr)
 	if err != nil {
 		return err
 	}
-	if err := saveSession(sess); err != nil {
+	if queued {
+		_, err = fmt.Fprintf(stdout, "secret %s queued for deletion\n", trimmedID)
 		return err
 	}
-	_, err = fmt.Fprintf(stdout, "secret %s deleted\n", trimmedID)
+	_, err = fmt.Fprintf(stdout, "secret %s moved to trash\n", trimmedID)
 	return err
 }
 
+// deleteSecret moves a secret to the trash, or queues the deletion like
+// createSecret. It reports whether the deletion was queued.
+func deleteSecret(serverURL, secretID string, offline bool, stderr io.Writer) (bool, error) {
+	if !offline && !mustQueue(secretID) {
+		sess, _, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) (struct{}, error) {
+			requestErr := client.DeleteSecret(ctx, accessToken, secretID)
+			return struct{}{}, requestErr
+		})
+		if err == nil {
+			if err := saveSession(sess); err != nil {
+				return false, err
+			}
+			updateCache(sess, stderr, func(c *vaultcache.Cache) error {
+				c.Remove(secretID)
+				return nil
+			})
+			return false, nil
+		}
+		if !isTransientError(err) {
+			return false, err
+		}
+		_, _ = fmt.Fprintf(stderr, "warning: server unreachable (%v)\n", err)
+	}
+	if _, err := queueWrite(vaultcache.Op{ID: uuid.NewString(), Kind: dtosecret.BatchOpDelete, SecretID: secretID}, stderr); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func runAuthorizedRequest[T any](overrideURL string, request func(ctx context.Context, client *api.API, accessToken string, sess session) (T, error)) (session, T, error) {
+	return runAuthorizedRequestWithKey(overrideURL, uuid.NewString(), request)
+}
+
+// runAuthorizedRequestWithKey is runAuthorizedRequest with a caller chosen
+// Idempotency-Key, for writes that may be repeated by a later command.
+func runAuthorizedRequestWithKey[T any](overrideURL, key string, request func(ctx context.Context, client *api.API, accessToken string, sess session) (T, error)) (session, T, error) {
 	var zero T
 	sess, client, err := loadSessionAndClient(strings.TrimSpace(overrideURL))
 	if err != nil {
 		return session{}, zero, err
 	}
 
-	ctx := context.Background()
+	// One key per command: retries below and the replay after a token
+	// refresh are recognised by the server instead of being applied twice.
+	ctx := apiclient.WithIdempotencyKey(context.Background(), key)
 	var out T
 	sess, err = withAutoRefresh(sess, client, func(accessToken string) error {
-		result, requestErr := request(ctx, client, accessToken, sess)
-		if requestErr != nil {
-			return requestErr
-		}
-		out = result
-		return nil
+		return retryTransient(ctx, func() error {
+			result, requestErr := request(ctx, client, accessToken, sess)
+			if requestErr != nil {
+				return requestErr
+			}
+			out = result
+			return nil
+		})
 	})
 	if err != nil {
 		return session{}, zero, err
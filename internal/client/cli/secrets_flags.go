@@ -1,19 +1,41 @@
 package cli
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"time"
 
 	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/exporter"
 	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/internal/sitematch"
 )
 
-func parseSecretWriteFlags(name string, args []string, includeID bool) (dtosecret.SecretPayload, string, string, error) {
+// secretWrite is a parsed secrets create or update.
+type secretWrite struct {
+	payload   dtosecret.SecretPayload
+	serverURL string
+	secretID  string
+	offline   bool
+	// generated is the password --generate or --passphrase put in the
+	// login. Without --ciphertext an update sets it on the current login,
+	// so payload.Ciphertext is left empty.
+	generated *generateOutput
+	show      bool
+	// username and explicit are kept for that update: meta flags left out
+	// keep their current values.
+	username string
+	explicit map[string]bool
+}
+
+func parseSecretWriteFlags(name string, args []string, includeID bool) (secretWrite, error) {
 	fs := flag.NewFlagSet(name, flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	serverURL := fs.String("server", "", "Server base URL")
@@ -22,36 +44,151 @@ func parseSecretWriteFlags(name string, args []string, includeID bool) (dtosecre
 	title := fs.String("title", "", "Meta title")
 	tags := fs.String("tags", "", "Comma-separated tags")
 	site := fs.String("site", "", "Meta site")
+	match := fs.String("match", "", "How --site is matched: domain, host or regex")
+	keys := fs.String("keys", "", "Comma-separated field=VARIABLE names for plaintext exports")
+	offline := fs.Bool("offline", false, "Queue the change for the next sync instead of sending it")
+	generate := fs.Bool("generate", false, "Generate the login password")
+	generator := addGeneratorFlags(fs)
+	username := fs.String("username", "", "Login username for a generated password")
+	show := fs.Bool("show", false, "Print the generated password")
 	var id *string
 	if includeID {
 		id = fs.String("id", "", "Secret ID")
 	}
 	if err := fs.Parse(args); err != nil {
-		return dtosecret.SecretPayload{}, "", "", err
+		return secretWrite{}, err
+	}
+	generating := *generate || *generator.passphrase
+	kind := strings.TrimSpace(*secretType)
+	if kind == "" && generating {
+		kind = secretkind.Login
+	}
+	if kind == "" {
+		return secretWrite{}, errors.New("--type is required")
+	}
+	if !secretkind.Registered(kind) {
+		return secretWrite{}, fmt.Errorf("unknown --type %q (expected one of: %s)", kind, strings.Join(secretkind.Kinds(), ", "))
+	}
+	if generating && kind != secretkind.Login {
+		return secretWrite{}, fmt.Errorf("--generate and --passphrase only fill %s secrets", secretkind.Login)
 	}
-	if strings.TrimSpace(*secretType) == "" {
-		return dtosecret.SecretPayload{}, "", "", errors.New("--type is required")
+	if !generating && (*show || strings.TrimSpace(*username) != "") {
+		return secretWrite{}, errors.New("--show and --username need --generate or --passphrase")
 	}
-	if strings.TrimSpace(*ciphertext) == "" {
-		return dtosecret.SecretPayload{}, "", "", errors.New("--ciphertext is required")
+	encoded := strings.TrimSpace(*ciphertext)
+	if encoded == "" && !generating {
+		return secretWrite{}, errors.New("--ciphertext is required")
+	}
+	keyMapping, err := parseKeyMapping(*keys, kind)
+	if err != nil {
+		return secretWrite{}, err
+	}
+	matchRule := strings.TrimSpace(*match)
+	if matchRule != "" {
+		if strings.TrimSpace(*site) == "" {
+			return secretWrite{}, errors.New("--match needs --site")
+		}
+		if err := sitematch.ValidateRule(matchRule, strings.TrimSpace(*site)); err != nil {
+			return secretWrite{}, fmt.Errorf("invalid --match: %w", err)
+		}
 	}
-	payload := dtosecret.SecretPayload{
-		Type:       strings.TrimSpace(*secretType),
-		Ciphertext: strings.TrimSpace(*ciphertext),
-		MetaOpen: models.MetaOpen{
-			Title: strings.TrimSpace(*title),
-			Site:  strings.TrimSpace(*site),
-			Tags:  parseCSV(*tags),
+	w := secretWrite{
+		payload: dtosecret.SecretPayload{
+			Type:       kind,
+			Ciphertext: encoded,
+			MetaOpen: models.MetaOpen{
+				Title: strings.TrimSpace(*title),
+				Site:  strings.TrimSpace(*site),
+				Match: matchRule,
+				Tags:  parseCSV(*tags),
+				Keys:  keyMapping,
+			},
 		},
+		serverURL: strings.TrimSpace(*serverURL),
+		offline:   *offline,
+		show:      *show,
 	}
-	secretID := ""
 	if includeID {
-		secretID = strings.TrimSpace(*id)
-		if secretID == "" {
-			return dtosecret.SecretPayload{}, "", "", errors.New("--id is required")
+		w.secretID = strings.TrimSpace(*id)
+		if w.secretID == "" {
+			return secretWrite{}, errors.New("--id is required")
 		}
 	}
-	return payload, strings.TrimSpace(*serverURL), secretID, nil
+	if generating {
+		generated, err := generator.generate()
+		if err != nil {
+			return secretWrite{}, err
+		}
+		w.generated = &generated
+		// An update without --ciphertext rotates the current login, which
+		// is only known once the secret is read.
+		if encoded != "" || !includeID {
+			var login secretkind.LoginData
+			if encoded != "" {
+				payload, err := decodeSecretPayload(kind, encoded)
+				if err == nil && payload.Login == nil {
+					err = errors.New("no login payload")
+				}
+				if err != nil {
+					return secretWrite{}, fmt.Errorf("invalid --ciphertext: %w", err)
+				}
+				login = *payload.Login
+			}
+			if err := w.setLogin(login, strings.TrimSpace(*username)); err != nil {
+				return secretWrite{}, err
+			}
+		} else {
+			w.username = strings.TrimSpace(*username)
+			w.explicit = map[string]bool{}
+			fs.Visit(func(f *flag.Flag) { w.explicit[f.Name] = true })
+		}
+	}
+	return w, nil
+}
+
+// rebase completes a password rotation from the current secret: the login
+// keeps its other fields and meta flags left out keep their values.
+func (w *secretWrite) rebase(current dtosecret.SecretResponse) error {
+	if current.Type != secretkind.Login {
+		return fmt.Errorf("secret %s is a %s secret, --generate only fills %s secrets", current.ID, current.Type, secretkind.Login)
+	}
+	payload, err := decodeSecretPayload(current.Type, current.Ciphertext)
+	if err == nil && payload.Login == nil {
+		err = errors.New("no login payload")
+	}
+	if err != nil {
+		return fmt.Errorf("secret %s: %w", current.ID, err)
+	}
+	meta := current.MetaOpen
+	given := w.payload.MetaOpen
+	if w.explicit["title"] {
+		meta.Title = given.Title
+	}
+	if w.explicit["tags"] {
+		meta.Tags = given.Tags
+	}
+	if w.explicit["site"] {
+		meta.Site, meta.Match = given.Site, given.Match
+	}
+	if w.explicit["keys"] {
+		meta.Keys = given.Keys
+	}
+	w.payload.MetaOpen = meta
+	return w.setLogin(*payload.Login, w.username)
+}
+
+// setLogin stores login with the generated password in the payload.
+func (w *secretWrite) setLogin(login secretkind.LoginData, username string) error {
+	if username != "" {
+		login.Username = username
+	}
+	login.Password = w.generated.Value
+	payload, err := loginSecretPayload(w.payload.MetaOpen, login)
+	if err != nil {
+		return err
+	}
+	w.payload.Ciphertext = payload.Ciphertext
+	return nil
 }
 
 func parseCSV(raw string) []string {
@@ -72,6 +209,45 @@ func parseCSV(raw string) []string {
 	return tags
 }
 
+// parseKeyMapping reads "password=DB_PASSWORD,username=DB_USER" and checks
+// the fields against the secret kind.
+func parseKeyMapping(raw, kind string) (map[string]string, error) {
+	pairs := parseCSV(raw)
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	fields := secretkind.FieldNames(kind)
+	mapping := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		field, key, ok := strings.Cut(pair, "=")
+		field = strings.TrimSpace(field)
+		key = strings.TrimSpace(key)
+		if !ok || field == "" || key == "" {
+			return nil, fmt.Errorf("invalid --keys entry %q, expected field=NAME", pair)
+		}
+		if !slices.Contains(fields, field) {
+			return nil, fmt.Errorf("unknown field %q for %s secrets (expected one of: %s)", field, kind, strings.Join(fields, ", "))
+		}
+		if !exporter.ValidKey(key) {
+			return nil, fmt.Errorf("invalid variable name %q", key)
+		}
+		mapping[field] = key
+	}
+	return mapping, nil
+}
+
+// decodeSecretPayload decrypts a secret into its typed payload.
+func decodeSecretPayload(secretType, ciphertext string) (secretkind.Payload, error) {
+	if !secretkind.Registered(secretType) {
+		return secretkind.Payload{}, fmt.Errorf("%w: %q", secretkind.ErrUnknownKind, secretType)
+	}
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return secretkind.Payload{}, errors.New("secret ciphertext is not valid base64")
+	}
+	return secretkind.Decode(secretType, data)
+}
+
 func findLatestUpdatedAt(secrets []dtosecret.SecretResponse) (string, bool) {
 	var latest time.Time
 	var found bool
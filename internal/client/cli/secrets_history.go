@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+)
+
+func runSecretsHistory(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("secrets history", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	secretID := fs.String("id", "", "Secret ID")
+	version := fs.Int64("version", 0, "Show a single archived version")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	trimmedID := strings.TrimSpace(*secretID)
+	if trimmedID == "" {
+		return errors.New("--id is required")
+	}
+	if *version < 0 {
+		return errors.New("--version must be positive")
+	}
+
+	if *version > 0 {
+		sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretVersionResponse, error) {
+			return client.GetSecretVersion(ctx, accessToken, trimmedID, *version)
+		})
+		if err != nil {
+			return err
+		}
+		if err := saveSession(sess); err != nil {
+			return err
+		}
+		return printJSON(stdout, result)
+	}
+
+	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) ([]dtosecret.SecretVersionResponse, error) {
+		return client.ListSecretVersions(ctx, accessToken, trimmedID)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	if result == nil {
+		result = []dtosecret.SecretVersionResponse{}
+	}
+	return printJSON(stdout, result)
+}
+
+func runSecretsRestore(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("secrets restore", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	secretID := fs.String("id", "", "Secret ID")
+	version := fs.Int64("version", 0, "Version to restore")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	trimmedID := strings.TrimSpace(*secretID)
+	if trimmedID == "" {
+		return errors.New("--id is required")
+	}
+	if *version < 1 {
+		return errors.New("--version is required")
+	}
+
+	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
+		return client.RestoreSecret(ctx, accessToken, trimmedID, *version)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	return printJSON(stdout, result)
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/internal/sitematch"
+)
+
+var errNoSiteLogin = errors.New("no login matches the url")
+
+// siteLogin is a login matching a page and how closely it does.
+type siteLogin struct {
+	matchedLogin
+	level sitematch.Level
+}
+
+// rankSiteLogins returns the logins whose site matches page under their
+// match rule: exact host matches first, then the most recently updated.
+func rankSiteLogins(secrets []dtosecret.SecretResponse, page sitematch.Target) []siteLogin {
+	var matches []siteLogin
+	for _, secret := range secrets {
+		if secret.Type != secretkind.Login {
+			continue
+		}
+		level := sitematch.MatchRule(secret.MetaOpen.Site, secret.MetaOpen.Match, page)
+		if level == sitematch.None {
+			continue
+		}
+		payload, err := decodeSecretPayload(secret.Type, secret.Ciphertext)
+		if err != nil || payload.Login == nil {
+			continue
+		}
+		matches = append(matches, siteLogin{matchedLogin: matchedLogin{secret: secret, login: *payload.Login}, level: level})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].level != matches[j].level {
+			return matches[i].level > matches[j].level
+		}
+		return matches[i].secret.UpdatedAt > matches[j].secret.UpdatedAt
+	})
+	return matches
+}
+
+// siteLogins lists the vault and ranks its logins for pageURL.
+func siteLogins(serverURL, pageURL string, offline bool, stderr io.Writer) ([]siteLogin, sitematch.Target, error) {
+	page, err := sitematch.Parse(pageURL)
+	if err != nil {
+		return nil, sitematch.Target{}, err
+	}
+	secrets, err := listSecrets(serverURL, offline, stderr)
+	if err != nil {
+		return nil, sitematch.Target{}, err
+	}
+	return rankSiteLogins(secrets, page), page, nil
+}
+
+// runSecretsMatch prints the logins saved for a URL, best match first.
+// With --field only that field of the best match is printed, for scripts.
+func runSecretsMatch(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("secrets match", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	pageURL := fs.String("url", "", "Page URL")
+	field := fs.String("field", "", "Print only this field of the best match")
+	offline := fs.Bool("offline", false, offlineFlagUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*pageURL) == "" {
+		return errors.New("--url is required")
+	}
+
+	matches, _, err := siteLogins(strings.TrimSpace(*serverURL), *pageURL, *offline, stderr)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(*field)
+	if name == "" {
+		secrets := make([]dtosecret.SecretResponse, 0, len(matches))
+		for _, match := range matches {
+			secrets = append(secrets, match.secret)
+		}
+		return printJSON(stdout, secrets)
+	}
+
+	if len(matches) == 0 {
+		return errNoSiteLogin
+	}
+	payload := secretkind.Payload{Kind: secretkind.Login, Login: &matches[0].login}
+	value, ok := payload.Field(name)
+	if !ok {
+		return fmt.Errorf("unknown field %q (login secrets have: %s)", name, strings.Join(secretkind.FieldNames(secretkind.Login), ", "))
+	}
+	_, err = fmt.Fprintln(stdout, value)
+	return err
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/sitematch"
+)
+
+func TestSecretsMatchRanksLoginsForURL(t *testing.T) {
+	saveTestSession(t)
+	hostOnly := loginSecret(t, "s-host-only", "example.com", "root", "host-only-pass", "2026-03-05T10:00:00Z")
+	hostOnly.MetaOpen.Match = sitematch.RuleHost
+	regex := loginSecret(t, "s-regex", `https://login\.example\.com`, "sso", "regex-pass", "2026-03-06T10:00:00Z")
+	regex.MetaOpen.Match = sitematch.RuleRegex
+	secrets := []dtosecret.SecretResponse{
+		loginSecret(t, "s-domain", "https://www.example.com:8443", "bob", "domain-pass", "2026-03-03T10:00:00Z"),
+		loginSecret(t, "s-host", "login.example.com", "alice", "host-pass", "2026-03-01T10:00:00Z"),
+		loginSecret(t, "s-suffix", "https://example.com.evil.io", "eve", "evil-pass", "2026-03-04T10:00:00Z"),
+		hostOnly,
+		regex,
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodGet && req.URL.Path == "/secrets" {
+			return jsonResponse(http.StatusOK, secrets), nil
+		}
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "match", "--url", "https://WWW.Login.Example.com:8080/path"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("match: exit code=%d stderr=%s", code, stderr.String())
+	}
+	var ids []string
+	for _, secret := range decodeSecretList(t, stdout.Bytes()) {
+		ids = append(ids, secret.ID)
+	}
+	if strings.Join(ids, ",") != "s-host,s-domain" {
+		t.Fatalf("expected the exact host before the base domain, got %v", ids)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "match", "--url", "https://login.example.com/sso/start", "--field", "password"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("match field: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if stdout.String() != "regex-pass\n" {
+		t.Fatalf("expected the newest exact match, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"secrets", "match", "--url", "example.com", "--field", "username"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("match host rule: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if stdout.String() != "root\n" {
+		t.Fatalf("unexpected username: %q", stdout.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"secrets", "match", "--url", "https://example.org", "--field", "password"}, &stdout, &stderr); code == 0 || !strings.Contains(stderr.String(), errNoSiteLogin.Error()) {
+		t.Fatalf("expected no match, code=%d stderr=%s", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"secrets", "match", "--url", "example.com", "--field", "cvv"}, &stdout, &stderr); code == 0 || !strings.Contains(stderr.String(), "unknown field") {
+		t.Fatalf("expected an unknown field error, code=%d stderr=%s", code, stderr.String())
+	}
+}
+
+func TestSecretWriteFlagsValidateMatchRule(t *testing.T) {
+	saveTestSession(t)
+	var created dtosecret.SecretPayload
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if err := json.NewDecoder(req.Body).Decode(&created); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		return jsonResponse(http.StatusCreated, dtosecret.SecretResponse{ID: "s-1", Type: created.Type, MetaOpen: created.MetaOpen, Ciphertext: created.Ciphertext}), nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	for _, args := range [][]string{
+		{"--match", "host"},
+		{"--site", "example.com", "--match", "exact"},
+		{"--site", "(", "--match", "regex"},
+	} {
+		stderr.Reset()
+		full := append([]string{"secrets", "create", "--type", "note", "--ciphertext", "bm90ZQ=="}, args...)
+		if code := run(full, &stdout, &stderr); code == 0 {
+			t.Fatalf("expected %v to be refused", args)
+		}
+	}
+
+	if code := run([]string{"secrets", "create", "--type", "note", "--ciphertext", "bm90ZQ==", "--site", "https://example.com", "--match", "host"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("create: exit code=%d stderr=%s", code, stderr.String())
+	}
+	if created.MetaOpen.Match != sitematch.RuleHost {
+		t.Fatalf("match rule not sent: %+v", created.MetaOpen)
+	}
+}
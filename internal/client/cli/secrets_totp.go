@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/internal/totp"
+)
+
+var totpNow = time.Now
+
+type totpCodeOutput struct {
+	SecretID  string `json:"secret_id"`
+	Issuer    string `json:"issuer,omitempty"`
+	Account   string `json:"account,omitempty"`
+	Code      string `json:"code"`
+	Remaining int    `json:"remaining"`
+	Period    int    `json:"period"`
+}
+
+func runSecretsTOTP(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("secrets totp", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	secretID := fs.String("id", "", "Secret ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	trimmedID := strings.TrimSpace(*secretID)
+	if trimmedID == "" {
+		return errors.New("--id is required")
+	}
+
+	sess, secret, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
+		return client.GetSecret(ctx, accessToken, trimmedID)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+
+	key, err := secretTOTPKey(secret)
+	if err != nil {
+		return err
+	}
+	now := totpNow()
+	return printJSON(stdout, totpCodeOutput{
+		SecretID:  secret.ID,
+		Issuer:    key.Issuer,
+		Account:   key.Account,
+		Code:      key.Code(now),
+		Remaining: int(key.Remaining(now) / time.Second),
+		Period:    key.Period,
+	})
+}
+
+func runSecretsTOTPImport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("secrets totp-import", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	uri := fs.String("uri", "", "otpauth:// URI")
+	title := fs.String("title", "", "Meta title")
+	tags := fs.String("tags", "", "Comma-separated tags")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*uri) == "" {
+		return errors.New("--uri is required")
+	}
+	key, err := totp.ParseURI(*uri)
+	if err != nil {
+		return err
+	}
+	encoded, err := secretkind.Encode(secretkind.Payload{
+		Kind: secretkind.TOTP,
+		TOTP: &secretkind.TOTPData{URI: strings.TrimSpace(*uri)},
+	})
+	if err != nil {
+		return err
+	}
+
+	metaTitle := strings.TrimSpace(*title)
+	if metaTitle == "" {
+		metaTitle = totpLabel(key)
+	}
+	payload := dtosecret.SecretPayload{
+		Type:       secretkind.TOTP,
+		Ciphertext: base64.StdEncoding.EncodeToString(encoded),
+		MetaOpen: models.MetaOpen{
+			Title: metaTitle,
+			Tags:  parseCSV(*tags),
+		},
+	}
+
+	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
+		return client.CreateSecret(ctx, accessToken, payload)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	return printJSON(stdout, result)
+}
+
+func secretTOTPKey(secret dtosecret.SecretResponse) (totp.Key, error) {
+	if secret.Type != secretkind.TOTP {
+		return totp.Key{}, fmt.Errorf("secret %s is a %s secret, not totp", secret.ID, secret.Type)
+	}
+	payload, err := decodeSecretPayload(secret.Type, secret.Ciphertext)
+	if err != nil {
+		return totp.Key{}, err
+	}
+	if payload.TOTP == nil {
+		return totp.Key{}, fmt.Errorf("secret %s has no totp payload", secret.ID)
+	}
+	return totp.ParseURI(payload.TOTP.URI)
+}
+
+func totpLabel(key totp.Key) string {
+	if key.Issuer == "" {
+		return key.Account
+	}
+	if key.Account == "" {
+		return key.Issuer
+	}
+	return key.Issuer + ":" + key.Account
+}
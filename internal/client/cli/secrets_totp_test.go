@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+// otpauth URI for the RFC 6238 SHA1 seed "12345678901234567890".
+const testTOTPURI = "otpauth://totp/ACME:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8"
+
+func TestSecretsTOTPPrintsCurrentCode(t *testing.T) {
+	saveTestSession(t)
+	prevNow := totpNow
+	totpNow = func() time.Time { return time.Unix(1111111109, 0) }
+	t.Cleanup(func() { totpNow = prevNow })
+
+	encoded, err := secretkind.Encode(secretkind.Payload{Kind: secretkind.TOTP, TOTP: &secretkind.TOTPData{URI: testTOTPURI}})
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/secrets/s-1" {
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		}
+		return jsonResponse(http.StatusOK, dtosecret.SecretResponse{
+			ID:         "s-1",
+			Type:       secretkind.TOTP,
+			Ciphertext: base64.StdEncoding.EncodeToString(encoded),
+		}), nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "totp", "--id", "s-1"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	var out totpCodeOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if out.Code != "07081804" || out.Remaining != 1 || out.Period != 30 || out.Issuer != "ACME" {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+}
+
+func TestSecretsTOTPImportCreatesTOTPSecret(t *testing.T) {
+	saveTestSession(t)
+
+	var created dtosecret.SecretPayload
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodPost || req.URL.Path != "/secrets" {
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+		}
+		if err := json.NewDecoder(req.Body).Decode(&created); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		return jsonResponse(http.StatusCreated, dtosecret.SecretResponse{ID: "s-2", Type: created.Type}), nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "totp-import", "--uri", testTOTPURI}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if created.Type != secretkind.TOTP || created.MetaOpen.Title != "ACME:alice" {
+		t.Fatalf("unexpected payload: %+v", created)
+	}
+	data, err := base64.StdEncoding.DecodeString(created.Ciphertext)
+	if err != nil {
+		t.Fatalf("decode ciphertext: %v", err)
+	}
+	payload, err := secretkind.Decode(secretkind.TOTP, data)
+	if err != nil || payload.TOTP == nil || payload.TOTP.URI != testTOTPURI {
+		t.Fatalf("unexpected stored payload: %+v err=%v", payload, err)
+	}
+}
+
+func TestSecretsTOTPImportRejectsInvalidURI(t *testing.T) {
+	saveTestSession(t)
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request to %s", req.URL)
+		return nil, nil
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"secrets", "totp-import", "--uri", "otpauth://hotp/alice?secret=GEZDGNBV"}, &stdout, &stderr); code == 0 {
+		t.Fatal("expected non-zero exit code")
+	}
+}
@@ -3,20 +3,50 @@ package cli
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/sessionseal"
 )
 
-func sessionPath() (string, error) {
-	if override := os.Getenv("PKEEPER_SESSION_PATH"); override != "" {
-		return override, nil
+const sessionPathEnv = "PKEEPER_SESSION_PATH"
+
+// configBaseDir holds the default profile's session and the other
+// profiles. With $PKEEPER_SESSION_PATH set it is the directory of that file.
+func configBaseDir() (string, error) {
+	if override := os.Getenv(sessionPathEnv); override != "" {
+		return filepath.Dir(override), nil
 	}
 	configDir, err := os.UserConfigDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(configDir, "pkeeper", "session.json"), nil
+	return filepath.Join(configDir, "pkeeper"), nil
+}
+
+// sessionPath is the session file of the active profile. Files kept next to
+// it, such as the offline cache and the agent socket, are per profile too.
+func sessionPath() (string, error) {
+	profile, err := activeProfile()
+	if err != nil {
+		return "", err
+	}
+	return profileSessionPath(profile)
+}
+
+func profileSessionPath(profile string) (string, error) {
+	if profile == defaultProfile {
+		if override := os.Getenv(sessionPathEnv); override != "" {
+			return override, nil
+		}
+	}
+	dir, err := profileDir(profile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "session.json"), nil
 }
 
 func loadSession() (session, error) {
@@ -31,10 +61,30 @@ func loadSession() (session, error) {
 		}
 		return session{}, err
 	}
+	if !sessionseal.IsSealed(raw) {
+		return migrateSession(raw)
+	}
+	plain, err := sessionseal.Open(sessionKeyProvider, raw)
+	if err != nil {
+		return session{}, fmt.Errorf("read session %s: %w", path, err)
+	}
+	var sess session
+	if err := json.Unmarshal(plain, &sess); err != nil {
+		return session{}, err
+	}
+	return sess, nil
+}
+
+// migrateSession seals a session written as plain JSON before sessions
+// were encrypted.
+func migrateSession(raw []byte) (session, error) {
 	var sess session
 	if err := json.Unmarshal(raw, &sess); err != nil {
 		return session{}, err
 	}
+	if err := saveSession(sess); err != nil {
+		return session{}, fmt.Errorf("encrypt the session file: %w (set $%s=file to keep the key in a file)", err, sessionKeyEnv)
+	}
 	return sess, nil
 }
 
@@ -46,11 +96,36 @@ func saveSession(sess session) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
 		return err
 	}
-	encoded, err := json.MarshalIndent(sess, "", "  ")
+	encoded, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	provider, err := sealingProvider()
+	if err != nil {
+		return err
+	}
+	sealed, err := sessionseal.Seal(provider, encoded)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, sealed)
+}
+
+// writeFileAtomic replaces path so a reader never sees half a session.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".session-*.tmp")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, encoded, 0o600)
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 func AuthorizedSession() (bool, error) {
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/7StaSH7/practicum-diploma/internal/sessionseal"
+)
+
+// TestMain keeps the session key in a file so tests never reach the
+// developer's keyring.
+func TestMain(m *testing.M) {
+	_ = os.Setenv(sessionKeyEnv, sessionseal.FileProviderName)
+	os.Exit(m.Run())
+}
+
+func TestLoadSessionSealsLegacyPlainFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.json")
+	t.Setenv(sessionPathEnv, path)
+	legacy := `{"server_url":"http://example.test","user_id":"u-1","access_token":"access","refresh_token":"refresh-secret"}`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatalf("write legacy session: %v", err)
+	}
+
+	sess, err := loadSession()
+	if err != nil {
+		t.Fatalf("load legacy session: %v", err)
+	}
+	if sess.UserID != "u-1" || sess.RefreshToken != "refresh-secret" {
+		t.Fatalf("unexpected session: %+v", sess)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read session: %v", err)
+	}
+	if !sessionseal.IsSealed(raw) || bytes.Contains(raw, []byte("refresh-secret")) {
+		t.Fatalf("session file was not sealed: %q", raw)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat session: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("session mode = %v, want 0600", info.Mode().Perm())
+	}
+	keyInfo, err := os.Stat(filepath.Join(dir, "session.key"))
+	if err != nil {
+		t.Fatalf("stat key file: %v", err)
+	}
+	if keyInfo.Mode().Perm() != 0o600 {
+		t.Fatalf("key file mode = %v, want 0600", keyInfo.Mode().Perm())
+	}
+
+	again, err := loadSession()
+	if err != nil {
+		t.Fatalf("reload sealed session: %v", err)
+	}
+	if again.RefreshToken != "refresh-secret" {
+		t.Fatalf("unexpected reloaded session: %+v", again)
+	}
+}
+
+func TestAutoSessionKeyFallsBackToFileWithoutKeyring(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(sessionPathEnv, filepath.Join(dir, "session.json"))
+	t.Setenv(sessionKeyEnv, sessionKeyAuto)
+	t.Setenv(sessionPassphraseEnv, "")
+	prev := keyringAvailable
+	keyringAvailable = func() bool { return false }
+	t.Cleanup(func() { keyringAvailable = prev })
+
+	provider, err := sealingProvider()
+	if err != nil {
+		t.Fatalf("sealing provider: %v", err)
+	}
+	if provider.Name() != sessionseal.FileProviderName {
+		t.Fatalf("provider = %q, want %q", provider.Name(), sessionseal.FileProviderName)
+	}
+	if err := saveSession(session{UserID: "u-1", RefreshToken: "refresh"}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	sess, err := loadSession()
+	if err != nil || sess.UserID != "u-1" {
+		t.Fatalf("load session = %+v, %v", sess, err)
+	}
+
+	// A keyring provider is refused rather than silently replaced.
+	t.Setenv(sessionKeyEnv, sessionseal.KeyringProviderName)
+	if _, err := sealingProvider(); err == nil {
+		t.Fatal("expected an error for the keyring without a Secret Service")
+	}
+}
+
+func TestPassphraseSessionKey(t *testing.T) {
+	t.Setenv(sessionPathEnv, filepath.Join(t.TempDir(), "session.json"))
+	t.Setenv(sessionKeyEnv, sessionseal.PassphraseProviderName)
+	t.Setenv(sessionPassphraseEnv, "correct horse")
+
+	if err := saveSession(session{UserID: "u-1", RefreshToken: "refresh"}); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	if sess, err := loadSession(); err != nil || sess.UserID != "u-1" {
+		t.Fatalf("load session = %+v, %v", sess, err)
+	}
+
+	// The provider is taken from the file, whatever would seal new ones.
+	t.Setenv(sessionKeyEnv, sessionseal.FileProviderName)
+	t.Setenv(sessionPassphraseEnv, "battery staple")
+	if _, err := loadSession(); err == nil {
+		t.Fatal("expected the wrong passphrase to fail")
+	}
+	t.Setenv(sessionPassphraseEnv, "")
+	if _, err := loadSession(); err == nil {
+		t.Fatal("expected a missing passphrase to fail")
+	}
+}
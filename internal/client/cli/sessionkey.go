@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/7StaSH7/practicum-diploma/internal/sessionseal"
+)
+
+const (
+	// sessionKeyEnv picks where the session key lives: auto, keyring,
+	// passphrase or file.
+	sessionKeyEnv        = "PKEEPER_SESSION_KEY"
+	sessionPassphraseEnv = "PKEEPER_SESSION_PASSPHRASE"
+	sessionKeyFileEnv    = "PKEEPER_SESSION_KEY_FILE"
+
+	sessionKeyAuto = "auto"
+)
+
+// keyringAvailable reports whether the desktop keyring can hold the key.
+// Tests replace it.
+var keyringAvailable = sessionseal.KeyringAvailable
+
+var (
+	passphraseMu sync.Mutex
+	// passphraseProvider is reused for the same passphrase so a command
+	// derives the key once.
+	passphraseProvider *sessionseal.Passphrase
+	passphraseSource   string
+)
+
+// sealingProvider picks the provider new session files are sealed with.
+// auto takes the keyring when there is one, then a passphrase from
+// $PKEEPER_SESSION_PASSPHRASE, then a key file.
+func sealingProvider() (sessionseal.Provider, error) {
+	choice := strings.TrimSpace(os.Getenv(sessionKeyEnv))
+	if choice != "" && choice != sessionKeyAuto {
+		return sessionKeyProvider(choice)
+	}
+	switch {
+	case keyringAvailable():
+		return sessionKeyProvider(sessionseal.KeyringProviderName)
+	case os.Getenv(sessionPassphraseEnv) != "":
+		return sessionKeyProvider(sessionseal.PassphraseProviderName)
+	default:
+		return sessionKeyProvider(sessionseal.FileProviderName)
+	}
+}
+
+// sessionKeyProvider returns the provider a session file names, whatever
+// would be picked for new files.
+func sessionKeyProvider(name string) (sessionseal.Provider, error) {
+	switch name {
+	case sessionseal.FileProviderName:
+		path, err := sessionKeyFilePath()
+		if err != nil {
+			return nil, err
+		}
+		return sessionseal.FileKey{Path: path}, nil
+	case sessionseal.KeyringProviderName:
+		if !keyringAvailable() {
+			return nil, fmt.Errorf("the session key is in the keyring, which needs secret-tool and a D-Bus session")
+		}
+		base, err := configBaseDir()
+		if err != nil {
+			return nil, err
+		}
+		return sessionseal.Keyring{Account: base}, nil
+	case sessionseal.PassphraseProviderName:
+		return sessionPassphrase()
+	default:
+		return nil, fmt.Errorf("unknown session key provider %q (expected %s, %s, %s or %s)", name,
+			sessionKeyAuto, sessionseal.KeyringProviderName, sessionseal.PassphraseProviderName, sessionseal.FileProviderName)
+	}
+}
+
+func sessionPassphrase() (sessionseal.Provider, error) {
+	passphrase := os.Getenv(sessionPassphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("the session is sealed with a passphrase; set $%s", sessionPassphraseEnv)
+	}
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	if passphraseProvider == nil || passphraseSource != passphrase {
+		provider, err := sessionseal.NewPassphrase([]byte(passphrase))
+		if err != nil {
+			return nil, err
+		}
+		passphraseProvider, passphraseSource = provider, passphrase
+	}
+	return passphraseProvider, nil
+}
+
+// sessionKeyFilePath is $PKEEPER_SESSION_KEY_FILE or session.key in the
+// configuration directory, shared by all profiles.
+func sessionKeyFilePath() (string, error) {
+	if override := os.Getenv(sessionKeyFileEnv); override != "" {
+		return override, nil
+	}
+	base, err := configBaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "session.key"), nil
+}
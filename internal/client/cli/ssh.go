@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/internal/sshvault"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultagent"
+)
+
+// confirmKeyUse asks whether a vault key may sign.
+var confirmKeyUse = func(comment string) bool {
+	return confirmPrompt(fmt.Sprintf("Allow use of SSH key %q from the vault?", comment))
+}
+
+func sshAgentSocketPath() (string, error) {
+	path, err := sessionPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "ssh-agent.sock"), nil
+}
+
+// runSSHAgent serves the vault's SSH keys over the OpenSSH agent protocol
+// until interrupted. Keys are read from the vault on every listing and
+// only ever held in memory.
+func runSSHAgent(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("ssh-agent", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	socket := fs.String("socket", "", "Agent socket path")
+	tags := fs.String("tag", "", "Comma-separated tags a key must have to be offered")
+	confirm := fs.Bool("confirm", false, "Ask before every signature")
+	offline := fs.Bool("offline", false, offlineFlagUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path := strings.TrimSpace(*socket)
+	if path == "" {
+		var err error
+		if path, err = sshAgentSocketPath(); err != nil {
+			return err
+		}
+	}
+
+	ln, err := vaultagent.Listen(path)
+	if errors.Is(err, vaultagent.ErrRunning) {
+		return fmt.Errorf("an ssh agent already listens on %s", path)
+	}
+	if err != nil {
+		return err
+	}
+	var confirmFn sshvault.Confirm
+	if *confirm {
+		confirmFn = confirmKeyUse
+	}
+	keyring := sshvault.NewKeyring(vaultSSHKeys(strings.TrimSpace(*serverURL), parseCSV(*tags), *offline, stderr), confirmFn)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+	_, _ = fmt.Fprintf(stdout, "SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", path)
+	return sshvault.Serve(ln, keyring, vaultagent.SameUser)
+}
+
+// vaultSSHKeys lists the ssh_key secrets carrying all of tags. A key that
+// cannot be parsed is skipped with a warning so one bad secret does not
+// hide the others. The listing bypasses the offline cache refresh, so the
+// private keys it fetches are never written out.
+func vaultSSHKeys(serverURL string, tags []string, offline bool, stderr io.Writer) sshvault.Source {
+	return func() ([]sshvault.Key, error) {
+		secrets, err := listSecretsUncached(serverURL, offline, stderr)
+		if err != nil {
+			return nil, err
+		}
+		var keys []sshvault.Key
+		for _, secret := range secrets {
+			if secret.Type != secretkind.SSHKey || !hasAllTags(secret.MetaOpen.Tags, tags) {
+				continue
+			}
+			payload, err := decodeSecretPayload(secret.Type, secret.Ciphertext)
+			if err == nil && payload.SSHKey == nil {
+				err = errors.New("no ssh key payload")
+			}
+			if err != nil {
+				_, _ = fmt.Fprintf(stderr, "warning: ssh key %s skipped: %v\n", secret.ID, err)
+				continue
+			}
+			signer, err := sshvault.ParseKey(*payload.SSHKey)
+			if err != nil {
+				_, _ = fmt.Fprintf(stderr, "warning: ssh key %s skipped: %v\n", secret.ID, err)
+				continue
+			}
+			comment := secret.MetaOpen.Title
+			if comment == "" {
+				comment = secret.ID
+			}
+			keys = append(keys, sshvault.Key{Comment: comment, Signer: signer})
+		}
+		return keys, nil
+	}
+}
+
+// runSSHKeygen generates an ed25519 key straight into the vault and prints
+// its public key for authorized_keys or a deploy key form.
+func runSSHKeygen(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("ssh-keygen", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	title := fs.String("title", "", "Meta title")
+	comment := fs.String("comment", "", "Key comment, defaults to the title")
+	tags := fs.String("tags", "", "Comma-separated tags")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	metaTitle := strings.TrimSpace(*title)
+	if metaTitle == "" {
+		return errors.New("--title is required")
+	}
+	keyComment := strings.TrimSpace(*comment)
+	if keyComment == "" {
+		keyComment = metaTitle
+	}
+
+	data, err := sshvault.Generate(keyComment)
+	if err != nil {
+		return err
+	}
+	encoded, err := secretkind.Encode(secretkind.Payload{Kind: secretkind.SSHKey, SSHKey: &data})
+	if err != nil {
+		return err
+	}
+	payload := dtosecret.SecretPayload{
+		Type:       secretkind.SSHKey,
+		Ciphertext: base64.StdEncoding.EncodeToString(encoded),
+		MetaOpen: models.MetaOpen{
+			Title: metaTitle,
+			Tags:  parseCSV(*tags),
+		},
+	}
+	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
+		return client.CreateSecret(ctx, accessToken, payload)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(stderr, "saved as secret %s\n", result.ID)
+	_, err = fmt.Fprintln(stdout, data.PublicKey)
+	return err
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHKeygenStoresKeyAndAgentOffersIt(t *testing.T) {
+	saveTestSession(t)
+	var stored []dtosecret.SecretResponse
+	installMockHTTPClient(t, func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/secrets":
+			var payload dtosecret.SecretPayload
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+			secret := dtosecret.SecretResponse{ID: "s-1", Type: payload.Type, MetaOpen: payload.MetaOpen, Ciphertext: payload.Ciphertext, Version: 1, UpdatedAt: "2026-03-01T10:00:00Z"}
+			stored = append(stored, secret)
+			return jsonResponse(http.StatusCreated, secret), nil
+		case req.Method == http.MethodGet && req.URL.Path == "/secrets":
+			broken := base64.StdEncoding.EncodeToString([]byte(`{"schema":1,"kind":"ssh_key","ssh_key":{"private_key":"nope"}}`))
+			return jsonResponse(http.StatusOK, append(stored,
+				dtosecret.SecretResponse{ID: "s-2", Type: secretkind.SSHKey, MetaOpen: models.MetaOpen{Title: "broken", Tags: []string{"deploy"}}, Ciphertext: broken},
+				dtosecret.SecretResponse{ID: "s-3", Type: secretkind.Note, MetaOpen: models.MetaOpen{Title: "note", Tags: []string{"deploy"}}, Ciphertext: "b25l"},
+			)), nil
+		default:
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			return nil, nil
+		}
+	})
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if code := run([]string{"ssh-keygen", "--title", "ci deploy", "--tags", "deploy"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey(stdout.Bytes())
+	if err != nil || comment != "ci deploy" || pub.Type() != ssh.KeyAlgoED25519 {
+		t.Fatalf("expected an ed25519 authorized_keys line, got %q (%v)", stdout.String(), err)
+	}
+	if len(stored) != 1 || stored[0].Type != secretkind.SSHKey {
+		t.Fatalf("expected one ssh_key secret, got %+v", stored)
+	}
+
+	stderr.Reset()
+	keys, err := vaultSSHKeys("", []string{"deploy"}, false, &stderr)()
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if len(keys) != 1 || keys[0].Comment != "ci deploy" {
+		t.Fatalf("expected the generated key only, got %+v", keys)
+	}
+	if !bytes.Equal(keys[0].Signer.PublicKey().Marshal(), pub.Marshal()) {
+		t.Fatal("the agent must offer the key that was printed")
+	}
+	if !strings.Contains(stderr.String(), "ssh key s-2 skipped") {
+		t.Fatalf("expected a warning about the broken key, got %q", stderr.String())
+	}
+	if keys, _ := vaultSSHKeys("", []string{"personal"}, false, &stderr)(); len(keys) != 0 {
+		t.Fatalf("keys without the tag must not be offered: %+v", keys)
+	}
+	path, err := sessionPath()
+	if err != nil {
+		t.Fatalf("session path: %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "vault-*.cache")); len(matches) != 0 {
+		t.Fatalf("the key source must not write the offline cache: %v", matches)
+	}
+}
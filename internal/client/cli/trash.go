@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+)
+
+func runTrash(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: trash <list|restore|delete>")
+	}
+	switch args[0] {
+	case "list":
+		return runTrashList(args[1:], stdout)
+	case "restore":
+		return runTrashRestore(args[1:], stdout)
+	case "delete":
+		return runTrashDelete(args[1:], stdout)
+	default:
+		return fmt.Errorf("unknown trash command: %s", args[0])
+	}
+}
+
+func runTrashList(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("trash list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sess, result, err := runAuthorizedRequest(strings.TrimSpace(*serverURL), func(ctx context.Context, client *api.API, accessToken string, sess session) ([]dtosecret.SecretResponse, error) {
+		return client.ListTrash(ctx, accessToken)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	if result == nil {
+		result = []dtosecret.SecretResponse{}
+	}
+	return printJSON(stdout, result)
+}
+
+func runTrashRestore(args []string, stdout io.Writer) error {
+	serverURL, secretID, err := parseTrashItemFlags("trash restore", args)
+	if err != nil {
+		return err
+	}
+
+	sess, result, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) (dtosecret.SecretResponse, error) {
+		return client.RestoreFromTrash(ctx, accessToken, secretID)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	return printJSON(stdout, result)
+}
+
+func runTrashDelete(args []string, stdout io.Writer) error {
+	serverURL, secretID, err := parseTrashItemFlags("trash delete", args)
+	if err != nil {
+		return err
+	}
+
+	sess, _, err := runAuthorizedRequest(serverURL, func(ctx context.Context, client *api.API, accessToken string, sess session) (struct{}, error) {
+		return struct{}{}, client.PurgeSecret(ctx, accessToken, secretID)
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "secret %s permanently deleted\n", secretID)
+	return err
+}
+
+func parseTrashItemFlags(name string, args []string) (string, string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serverURL := fs.String("server", "", "Server base URL")
+	secretID := fs.String("id", "", "Secret ID")
+	if err := fs.Parse(args); err != nil {
+		return "", "", err
+	}
+	trimmedID := strings.TrimSpace(*secretID)
+	if trimmedID == "" {
+		return "", "", errors.New("--id is required")
+	}
+	return strings.TrimSpace(*serverURL), trimmedID, nil
+}
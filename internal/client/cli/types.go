@@ -22,4 +22,11 @@ type session struct {
 	RefreshToken string `json:"refresh_token"`
 	KDFSalt      string `json:"kdf_salt"`
 	LastSyncAt   string `json:"last_sync_at,omitempty"`
+	// Pinned holds settings chosen for the profile, which win over the
+	// global configuration.
+	Pinned profileSettings `json:"pinned,omitzero"`
+}
+
+type profileSettings struct {
+	ServerURL string `json:"server_url,omitempty"`
 }
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	dtoattachment "github.com/7StaSH7/practicum-diploma/internal/dto/attachment"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultagent"
+	"github.com/7StaSH7/practicum-diploma/pkg/apiclient"
+)
+
+// sealRounds bounds how often secrets changed by another device while the
+// vault is being sealed are listed and sealed again.
+const sealRounds = 3
+
+// sealVault re-uploads every secret and attachment the server still stores
+// as it is, sealed with the key the agent just unlocked. It runs on the
+// first unlock before the key check is saved, so a vault is only taken as
+// encrypted once nothing readable is left, and an interrupted run is picked
+// up by the next unlock.
+//
+// Trashed secrets cannot be written and keep their content until they are
+// restored or purged; history versions keep theirs until pruned.
+func sealVault(overrideURL string, agent *vaultagent.Client) error {
+	sess, _, err := runAuthorizedRequest(overrideURL, func(ctx context.Context, _ *api.API, accessToken string, sess session) (struct{}, error) {
+		raw := api.New(sess.ServerURL, apiHTTPClientFactory())
+		sealing := api.New(sess.ServerURL, apiHTTPClientFactory()).
+			WithCipher(agentCipher{client: agent, account: sess.UserID, required: true})
+		if err := sealSecrets(ctx, raw, sealing, accessToken); err != nil {
+			return struct{}{}, err
+		}
+		return struct{}{}, sealAttachments(ctx, raw, sealing, accessToken)
+	})
+	if err != nil {
+		return err
+	}
+	return saveSession(sess)
+}
+
+// sealSecrets updates every plain secret with its own content, which the
+// sealing client seals on the way. Each update expects the listed version,
+// so an edit made meanwhile is never overwritten; such secrets are listed
+// and sealed again.
+func sealSecrets(ctx context.Context, raw, sealing *api.API, accessToken string) error {
+	for round := 0; ; round++ {
+		secrets, err := raw.ListSecrets(ctx, accessToken, "")
+		if err != nil {
+			return err
+		}
+		operations := plainSecretUpdates(secrets)
+		if len(operations) == 0 {
+			return nil
+		}
+		if round == sealRounds {
+			return errors.New("secrets kept changing while the vault was sealed, unlock again")
+		}
+		result, err := sendBatch(sealKey(ctx, operations), sealing, accessToken, dtosecret.BatchModePerItem, operations)
+		if err != nil {
+			return err
+		}
+		for _, item := range result.Results {
+			if item.Status == dtosecret.BatchStatusOK || item.Error == "version_conflict" || item.Error == "not_found" {
+				continue
+			}
+			return fmt.Errorf("seal secret %s: %s", operations[item.Index].ID, item.Error)
+		}
+	}
+}
+
+func plainSecretUpdates(secrets []dtosecret.SecretResponse) []dtosecret.BatchOperation {
+	var operations []dtosecret.BatchOperation
+	for _, secret := range secrets {
+		data, err := base64.StdEncoding.DecodeString(secret.Ciphertext)
+		if err != nil || vaultagent.IsSealed(data) {
+			continue
+		}
+		operations = append(operations, dtosecret.BatchOperation{
+			Op:              dtosecret.BatchOpUpdate,
+			ID:              secret.ID,
+			ExpectedVersion: secret.Version,
+			Secret: &dtosecret.SecretPayload{
+				Type:       secret.Type,
+				MetaOpen:   secret.MetaOpen,
+				Ciphertext: secret.Ciphertext,
+			},
+		})
+	}
+	return operations
+}
+
+// sealKey derives the idempotency key of a round from the secrets and
+// versions it updates: a retried round replays, a new listing gets its own.
+func sealKey(ctx context.Context, operations []dtosecret.BatchOperation) context.Context {
+	key, ok := apiclient.IdempotencyKey(ctx)
+	if !ok {
+		return ctx
+	}
+	h := sha256.New()
+	for _, op := range operations {
+		_, _ = fmt.Fprintf(h, "%s:%d\n", op.ID, op.ExpectedVersion)
+	}
+	return apiclient.WithIdempotencyKey(ctx, key+"-seal-"+hex.EncodeToString(h.Sum(nil)[:8]))
+}
+
+// sealAttachments replaces every complete plain attachment with a sealed
+// copy. Stored content cannot be rewritten in place, so the copy is
+// uploaded as a new attachment of the same secret before the plain one is
+// deleted. Unfinished uploads cannot be resumed once the vault is encrypted
+// and are left to be deleted.
+func sealAttachments(ctx context.Context, raw, sealing *api.API, accessToken string) error {
+	secrets, err := raw.ListSecrets(ctx, accessToken, "")
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets {
+		attachments, err := raw.ListAttachments(ctx, accessToken, secret.ID)
+		if err != nil {
+			return err
+		}
+		for _, attachment := range attachments {
+			if !attachment.Complete {
+				continue
+			}
+			sealed, err := raw.AttachmentSealed(ctx, accessToken, attachment)
+			if err != nil {
+				return err
+			}
+			if sealed {
+				continue
+			}
+			if err := sealAttachment(ctx, raw, sealing, accessToken, attachment); err != nil {
+				return fmt.Errorf("seal attachment %s: %w", attachment.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func sealAttachment(ctx context.Context, raw, sealing *api.API, accessToken string, plain dtoattachment.AttachmentResponse) error {
+	tmp, err := os.CreateTemp("", "pkeeper-seal-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	size, err := raw.DownloadAttachment(ctx, accessToken, plain, tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// A retried run creates and fills the same copy under the same key.
+	if key, ok := apiclient.IdempotencyKey(ctx); ok {
+		ctx = apiclient.WithIdempotencyKey(ctx, key+"-seal-att-"+plain.ID+"-"+strconv.FormatInt(plain.Size, 10))
+	}
+	stored := sealing.AttachmentLayout("", size, attachmentChunkSize).StoredSize()
+	current, err := sealing.CreateAttachment(ctx, accessToken, plain.SecretID, plain.Name, stored)
+	if err != nil {
+		return err
+	}
+	if _, err := uploadAttachment(ctx, sealing, accessToken, tmp, size, current); err != nil {
+		return err
+	}
+	return sealing.DeleteAttachment(ctx, accessToken, plain.ID)
+}
@@ -6,10 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/7StaSH7/practicum-diploma/internal/client/cli"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
 	"github.com/7StaSH7/practicum-diploma/internal/version"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -51,7 +53,7 @@ func runTUIAction(actionID string, values map[string]string) (string, error) {
 		})
 		return output, err
 	case "search":
-		output, err := executeCLI([]string{"secrets", "list"})
+		output, err := listSecretsOutput()
 		if err != nil {
 			return "", err
 		}
@@ -63,9 +65,8 @@ func runTUIAction(actionID string, values map[string]string) (string, error) {
 		sortSecretsByRecent(filtered)
 		return renderSecretList(filtered, "Результаты поиска"), nil
 	case "create":
-		ciphertext := encodeSecretData(values["data"])
 		args := []string{}
-		args = append(args, "--type", defaultSecretType, "--ciphertext", ciphertext)
+		args = append(args, "--type", values["type"], "--ciphertext", values["ciphertext"])
 		args = appendOptionalFlag(args, "--title", values["title"])
 		args = appendOptionalFlag(args, "--tags", values["tags"])
 		args = appendOptionalFlag(args, "--site", values["site"])
@@ -83,12 +84,13 @@ func runTUIAction(actionID string, values map[string]string) (string, error) {
 		}
 
 		ciphertext := snapshot.Ciphertext
-		if data := strings.TrimSpace(values["data"]); data != "" {
-			ciphertext = encodeSecretData(data)
-		}
 		secretType := snapshot.Type
-		if strings.TrimSpace(secretType) == "" {
-			secretType = defaultSecretType
+		if strings.TrimSpace(values["ciphertext"]) != "" {
+			ciphertext = values["ciphertext"]
+			secretType = values["type"]
+		}
+		if !secretkind.Registered(secretType) {
+			secretType = secretkind.Note
 		}
 		title := resolveUpdateInput(values["title"], snapshot.Title)
 		tags := resolveUpdateInput(values["tags"], strings.Join(snapshot.Tags, ","))
@@ -96,6 +98,16 @@ func runTUIAction(actionID string, values map[string]string) (string, error) {
 		args := []string{}
 		args = append(args, "--id", values["id"], "--type", secretType, "--ciphertext", ciphertext)
 		args = append(args, "--title", title, "--tags", tags, "--site", site)
+		// The form does not edit export keys; keep the ones already set
+		// unless the kind changed and they may no longer apply.
+		if secretType == snapshot.Type {
+			args = appendOptionalFlag(args, "--keys", formatKeyMapping(snapshot.Keys))
+		}
+		// Likewise the match rule, which only makes sense for the site it
+		// was set with.
+		if site == snapshot.Site {
+			args = appendOptionalFlag(args, "--match", snapshot.Match)
+		}
 		output, err := executeCLI(append([]string{"secrets", "update"}, args...))
 		if err != nil {
 			return "", err
@@ -112,9 +124,78 @@ func runTUIAction(actionID string, values map[string]string) (string, error) {
 		}
 		revalidated := revalidateSecretsAfterMutation("delete", strings.TrimSpace(values["id"]), false)
 		return appendRevalidationOutput(output, revalidated), nil
+	case "restore":
+		output, err := executeCLI([]string{
+			"secrets", "restore",
+			"--id", values["id"],
+			"--version", values["version"],
+		})
+		if err != nil {
+			return "", err
+		}
+		formatted := formatSecretOutput(output)
+		revalidated := revalidateSecretsAfterMutation("restore", strings.TrimSpace(values["id"]), true)
+		return appendRevalidationOutput(formatted, revalidated), nil
+	case "trash_restore":
+		output, err := executeCLI([]string{"trash", "restore", "--id", values["id"]})
+		if err != nil {
+			return "", err
+		}
+		formatted := formatSecretOutput(output)
+		revalidated := revalidateSecretsAfterMutation("trash_restore", strings.TrimSpace(values["id"]), true)
+		return appendRevalidationOutput(formatted, revalidated), nil
+	case "trash_delete":
+		return executeCLI([]string{"trash", "delete", "--id", values["id"]})
+	case "profile":
+		name := strings.TrimSpace(values["name"])
+		if err := cli.SelectProfile(name); err != nil {
+			return "", err
+		}
+		profiles, err := cli.Profiles()
+		if err != nil {
+			return "", err
+		}
+		return "Активный профиль: " + name + "\nПрофили: " + strings.Join(profiles, ", "), nil
+	case "unlock":
+		locksAt, err := cli.UnlockVault([]byte(values["password"]))
+		if err != nil {
+			return "", err
+		}
+		return "Хранилище разблокировано до " + locksAt.Local().Format("15:04"), nil
+	case "lock":
+		if err := cli.LockVault(); err != nil {
+			return "", err
+		}
+		return "Хранилище заблокировано", nil
+	case "conflict_resolve":
+		output, err := executeCLI([]string{"conflicts", "resolve", "--id", values["id"], "--keep", values["keep"]})
+		if err != nil {
+			return "", err
+		}
+		return formatConflictDecision(output), nil
+	case "attach":
+		args := []string{"secrets", "attach", "--id", values["id"], "--file", values["file"]}
+		args = appendOptionalFlag(args, "--name", values["name"])
+		output, err := executeCLI(args)
+		if err != nil {
+			return "", err
+		}
+		return formatAttachmentOutput(output, false), nil
+	case "download":
+		args := []string{"secrets", "download", "--attachment", values["attachment"]}
+		args = appendOptionalFlag(args, "--out", values["out"])
+		output, err := executeCLI(args)
+		if err != nil {
+			return "", err
+		}
+		return formatAttachmentOutput(output, true), nil
+	case "detach":
+		return executeCLI([]string{"secrets", "detach", "--attachment", values["attachment"]})
 	case "auto_sync":
-		_, err := executeCLI([]string{"secrets", "sync", "--once"})
-		return "", err
+		if _, err := executeCLI([]string{"secrets", "sync", "--once"}); err != nil {
+			return "", err
+		}
+		return conflictsNotice(), nil
 	case "version":
 		return version.Info(), nil
 	default:
@@ -122,6 +203,20 @@ func runTUIAction(actionID string, values map[string]string) (string, error) {
 	}
 }
 
+func loadTrashCmd() tea.Cmd {
+	return func() tea.Msg {
+		output, err := executeCLI([]string{"trash", "list"})
+		if err != nil {
+			return trashLoadedMsg{Err: err}
+		}
+		items, parseErr := parseSecretListOutput(output)
+		if parseErr != nil {
+			return trashLoadedMsg{Err: errors.New("не удалось прочитать содержимое корзины")}
+		}
+		return trashLoadedMsg{Items: items}
+	}
+}
+
 func encodeSecretData(data string) string {
 	return base64.StdEncoding.EncodeToString([]byte(data))
 }
@@ -132,6 +227,8 @@ type secretSnapshot struct {
 	Title      string
 	Tags       []string
 	Site       string
+	Match      string
+	Keys       map[string]string
 }
 
 func loadSecretSnapshot(secretID string) (secretSnapshot, error) {
@@ -148,9 +245,11 @@ func loadSecretSnapshot(secretID string) (secretSnapshot, error) {
 		Type       string `json:"type"`
 		Ciphertext string `json:"ciphertext"`
 		MetaOpen   struct {
-			Title string   `json:"title"`
-			Tags  []string `json:"tags"`
-			Site  string   `json:"site"`
+			Title string            `json:"title"`
+			Tags  []string          `json:"tags"`
+			Site  string            `json:"site"`
+			Match string            `json:"match"`
+			Keys  map[string]string `json:"keys"`
 		} `json:"meta_open"`
 	}
 	if err := json.Unmarshal([]byte(output), &payload); err != nil {
@@ -163,9 +262,21 @@ func loadSecretSnapshot(secretID string) (secretSnapshot, error) {
 		Title:      strings.TrimSpace(payload.MetaOpen.Title),
 		Tags:       payload.MetaOpen.Tags,
 		Site:       strings.TrimSpace(payload.MetaOpen.Site),
+		Match:      strings.TrimSpace(payload.MetaOpen.Match),
+		Keys:       payload.MetaOpen.Keys,
 	}, nil
 }
 
+// formatKeyMapping renders export keys in the --keys flag syntax.
+func formatKeyMapping(mapping map[string]string) string {
+	pairs := make([]string, 0, len(mapping))
+	for field, key := range mapping {
+		pairs = append(pairs, field+"="+key)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
 func resolveUpdateInput(input, current string) string {
 	value := strings.TrimSpace(input)
 	if value == "-" {
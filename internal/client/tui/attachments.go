@@ -0,0 +1,78 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type attachmentItem struct {
+	ID        string `json:"id"`
+	SecretID  string `json:"secret_id"`
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	Received  int64  `json:"received"`
+	Complete  bool   `json:"complete"`
+	CreatedAt string `json:"created_at"`
+	Path      string `json:"path,omitempty"`
+}
+
+func loadAttachmentsCmd(secretID string) tea.Cmd {
+	trimmedID := strings.TrimSpace(secretID)
+	return func() tea.Msg {
+		items, err := loadAttachments(trimmedID)
+		return attachmentsLoadedMsg{Items: items, Err: err}
+	}
+}
+
+func loadAttachments(secretID string) ([]attachmentItem, error) {
+	if secretID == "" {
+		return nil, errors.New("пустой ID секрета")
+	}
+	output, err := executeCLI([]string{"secrets", "attachments", "--id", secretID})
+	if err != nil {
+		return nil, err
+	}
+	var items []attachmentItem
+	if err := json.Unmarshal([]byte(output), &items); err != nil {
+		return nil, errors.New("не удалось прочитать список вложений")
+	}
+	return items, nil
+}
+
+func formatAttachmentOutput(output string, saved bool) string {
+	var item attachmentItem
+	if err := json.Unmarshal([]byte(output), &item); err != nil {
+		return output
+	}
+	if saved {
+		return fmt.Sprintf("Вложение сохранено: %s (%s)", item.Path, formatByteSize(item.Size))
+	}
+	return fmt.Sprintf("Файл прикреплен: %s (%s)", item.Name, formatByteSize(item.Size))
+}
+
+func attachmentLine(item attachmentItem) string {
+	line := item.Name + " | " + formatByteSize(item.Size)
+	if !item.Complete {
+		line += fmt.Sprintf(" | загружено %s", formatByteSize(item.Received))
+	}
+	return line
+}
+
+func formatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d Б", size)
+	}
+	value := float64(size) / unit
+	for _, suffix := range []string{"КБ", "МБ"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f ГБ", value)
+}
@@ -0,0 +1,118 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// conflictItem is a queued offline write that overlaps a server change, as
+// printed by pkeeper conflicts.
+type conflictItem struct {
+	OpID     string   `json:"op_id"`
+	Op       string   `json:"op"`
+	SecretID string   `json:"secret_id"`
+	Title    string   `json:"title"`
+	Fields   []string `json:"fields"`
+	// Mine is nil for a queued delete, Theirs when the server deleted it.
+	Mine       *secretOutputItem `json:"mine"`
+	Theirs     *secretOutputItem `json:"theirs"`
+	DetectedAt string            `json:"detected_at"`
+}
+
+var conflictFieldLabels = map[string]string{
+	"payload": "данные",
+	"title":   "заголовок",
+	"site":    "сайт",
+	"keys":    "ключи экспорта",
+	"tags":    "теги",
+	"deleted": "удаление",
+}
+
+var conflictChoiceLabels = map[string]string{
+	"mine":   "оставлена ваша версия",
+	"theirs": "оставлена версия с сервера",
+	"both":   "сохранены обе версии",
+}
+
+func loadConflictsCmd() tea.Cmd {
+	return func() tea.Msg {
+		items, err := loadConflicts()
+		return conflictsLoadedMsg{Items: items, Err: err}
+	}
+}
+
+func loadConflicts() ([]conflictItem, error) {
+	output, err := executeCLI([]string{"conflicts", "list"})
+	if err != nil {
+		return nil, err
+	}
+	var items []conflictItem
+	if err := json.Unmarshal([]byte(output), &items); err != nil {
+		return nil, errors.New("не удалось прочитать список конфликтов")
+	}
+	return items, nil
+}
+
+// conflictsNotice tells about conflicts left after a background sync; it is
+// empty when there are none or they cannot be read.
+func conflictsNotice() string {
+	items, err := loadConflicts()
+	if err != nil || len(items) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Офлайн-изменения конфликтуют с сервером: %d. Откройте «Конфликты»", len(items))
+}
+
+func formatConflictDecision(output string) string {
+	var decision struct {
+		SecretID string `json:"secret_id"`
+		Choice   string `json:"choice"`
+	}
+	if err := json.Unmarshal([]byte(output), &decision); err != nil {
+		return output
+	}
+	label, ok := conflictChoiceLabels[decision.Choice]
+	if !ok {
+		label = decision.Choice
+	}
+	return "Конфликт " + decision.SecretID + ": " + label + ". Изменения отправятся при следующей синхронизации"
+}
+
+func conflictDisplayTitle(item conflictItem) string {
+	if strings.TrimSpace(item.Title) != "" {
+		return item.Title
+	}
+	return item.SecretID
+}
+
+func conflictFieldsText(fields []string) string {
+	labels := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if label, ok := conflictFieldLabels[field]; ok {
+			labels = append(labels, label)
+		} else {
+			labels = append(labels, field)
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// renderConflictDiff shows how the local version differs from the server one.
+func renderConflictDiff(item conflictItem) string {
+	switch {
+	case item.Theirs == nil:
+		return "На сервере секрет удален, у вас он изменен"
+	case item.Mine == nil:
+		return "Вы удалили секрет, а на сервере он изменен"
+	}
+	theirs := secretVersionItem{
+		Type:       item.Theirs.Type,
+		MetaOpen:   item.Theirs.MetaOpen,
+		Ciphertext: item.Theirs.Ciphertext,
+	}
+	return "Сервер -> ваша версия:\n" + renderSecretVersionDiff(theirs, *item.Mine)
+}
@@ -0,0 +1,96 @@
+package tui
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/7StaSH7/practicum-diploma/internal/api"
+	"github.com/7StaSH7/practicum-diploma/internal/client/cli"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// eventStream is a running subscription to the server event stream. While one
+// is open, periodic polling is paused and syncs are driven by events.
+type eventStream struct {
+	changes chan struct{}
+	done    chan error
+	// cancel ends the subscription, e.g. when the profile is switched.
+	cancel context.CancelFunc
+}
+
+type eventStreamOpenedMsg struct {
+	stream *eventStream
+}
+
+type secretsChangedMsg struct {
+	stream *eventStream
+}
+
+type eventStreamClosedMsg struct {
+	stream *eventStream
+	Err    error
+}
+
+func startEventStreamCmd() tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := &eventStream{
+			changes: make(chan struct{}, 1),
+			done:    make(chan error, 1),
+			cancel:  cancel,
+		}
+		go func() {
+			stream.done <- cli.WatchChanges(ctx, "", stream.changes)
+		}()
+		return eventStreamOpenedMsg{stream: stream}
+	}
+}
+
+func waitEventCmd(stream *eventStream) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case <-stream.changes:
+			return secretsChangedMsg{stream: stream}
+		case err := <-stream.done:
+			return eventStreamClosedMsg{stream: stream, Err: err}
+		}
+	}
+}
+
+func (m tuiModel) handleEventStreamOpened(msg eventStreamOpenedMsg) (tea.Model, tea.Cmd) {
+	m.stream = msg.stream
+	return m, waitEventCmd(msg.stream)
+}
+
+func (m tuiModel) handleSecretsChanged(msg secretsChangedMsg) (tea.Model, tea.Cmd) {
+	if msg.stream != m.stream {
+		return m, nil
+	}
+	if m.syncInFlight {
+		m.syncPending = true
+		return m, waitEventCmd(m.stream)
+	}
+	m.syncInFlight = true
+	return m, tea.Batch(waitEventCmd(m.stream), runTUIActionCmd("auto_sync", map[string]string{}))
+}
+
+func (m tuiModel) handleEventStreamClosed(msg eventStreamClosedMsg) (tea.Model, tea.Cmd) {
+	if msg.stream != m.stream {
+		return m, nil
+	}
+	m.stream = nil
+	if api.IsHTTPStatus(msg.Err, http.StatusNotFound) {
+		m.streamUnsupported = true
+	}
+	return m, nil
+}
+
+// stopEventStream drops the open subscription. Its closing message no longer
+// matches m.stream and is ignored.
+func (m *tuiModel) stopEventStream() {
+	if m.stream != nil && m.stream.cancel != nil {
+		m.stream.cancel()
+	}
+	m.stream = nil
+	m.streamUnsupported = false
+}
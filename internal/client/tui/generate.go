@@ -0,0 +1,52 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/7StaSH7/practicum-diploma/internal/passgen"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var strengthTitles = map[string]string{
+	"weak":        "слабый",
+	"fair":        "средний",
+	"strong":      "надежный",
+	"very strong": "очень надежный",
+}
+
+// generateFieldValue fills the current field with a generated password or
+// passphrase. The value stays masked until the user reveals it.
+func (m tuiModel) generateFieldValue(passphrase bool) (tea.Model, tea.Cmd) {
+	field := m.currentAction.Fields[m.fieldIndex]
+	if !field.Generate {
+		m.status = "[INFO] Для этого поля генератор недоступен"
+		return m, nil
+	}
+
+	var (
+		value   string
+		entropy float64
+		err     error
+		what    = "Пароль сгенерирован"
+	)
+	if passphrase {
+		policy := passgen.DefaultPassphrasePolicy()
+		value, err = policy.Generate()
+		entropy = policy.Entropy()
+		what = "Парольная фраза сгенерирована"
+	} else {
+		policy := passgen.DefaultPolicy()
+		value, err = policy.Generate()
+		entropy = policy.Entropy()
+	}
+	if err != nil {
+		m.status = "[ERR] " + err.Error()
+		return m, nil
+	}
+
+	m.input = value
+	m.revealInput = false
+	m.status = fmt.Sprintf("[OK] %s: %d символов, ~%.0f бит (%s). Ctrl+O показать",
+		what, len(value), entropy, strengthTitles[passgen.Strength(entropy)])
+	return m, nil
+}
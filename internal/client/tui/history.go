@@ -0,0 +1,145 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type secretVersionItem struct {
+	SecretID   string           `json:"secret_id"`
+	Version    int64            `json:"version"`
+	Type       string           `json:"type"`
+	MetaOpen   secretOutputMeta `json:"meta_open"`
+	Ciphertext string           `json:"ciphertext"`
+	UpdatedAt  string           `json:"updated_at"`
+	ArchivedAt string           `json:"archived_at"`
+}
+
+func loadSecretHistoryCmd(secretID string) tea.Cmd {
+	trimmedID := strings.TrimSpace(secretID)
+	return func() tea.Msg {
+		items, err := loadSecretHistory(trimmedID)
+		return secretHistoryLoadedMsg{Items: items, Err: err}
+	}
+}
+
+func loadSecretHistory(secretID string) ([]secretVersionItem, error) {
+	if secretID == "" {
+		return nil, errors.New("пустой ID секрета")
+	}
+	output, err := executeCLI([]string{"secrets", "history", "--id", secretID})
+	if err != nil {
+		return nil, err
+	}
+	items, err := parseSecretHistoryOutput(output)
+	if err != nil {
+		return nil, errors.New("не удалось прочитать историю версий")
+	}
+	return items, nil
+}
+
+func parseSecretHistoryOutput(output string) ([]secretVersionItem, error) {
+	var items []secretVersionItem
+	if err := json.Unmarshal([]byte(output), &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func renderSecretVersionDiff(old secretVersionItem, current secretOutputItem) string {
+	var b strings.Builder
+	writeFieldChange(&b, "Заголовок", old.MetaOpen.Title, current.MetaOpen.Title)
+	writeFieldChange(&b, "Теги", strings.Join(old.MetaOpen.Tags, ", "), strings.Join(current.MetaOpen.Tags, ", "))
+	writeFieldChange(&b, "Сайт", old.MetaOpen.Site, current.MetaOpen.Site)
+	writeFieldChange(&b, "Тип", old.Type, current.Type)
+
+	oldPayload, oldErr := decodeSecretPayload(old.Type, old.Ciphertext)
+	currentPayload, currentErr := decodeSecretPayload(current.Type, current.Ciphertext)
+	if oldErr != nil || currentErr != nil {
+		b.WriteString("Данные: (данные недоступны)")
+		return b.String()
+	}
+	if oldPayload.Note == nil || currentPayload.Note == nil {
+		changes := payloadChanges(oldPayload, currentPayload)
+		if len(changes) == 0 {
+			b.WriteString("Данные: без изменений")
+			return b.String()
+		}
+		b.WriteString("Данные:")
+		for _, change := range changes {
+			b.WriteString("\n  ")
+			b.WriteString(change)
+		}
+		return b.String()
+	}
+
+	oldText := oldPayload.Note.Text
+	currentText := currentPayload.Note.Text
+	if oldText == currentText {
+		b.WriteString("Данные: без изменений")
+		return b.String()
+	}
+	b.WriteString("Данные:")
+	for _, line := range diffLines(oldText, currentText) {
+		b.WriteString("\n")
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func writeFieldChange(b *strings.Builder, label, old, current string) {
+	oldValue := strings.TrimSpace(old)
+	currentValue := strings.TrimSpace(current)
+	if oldValue == currentValue {
+		return
+	}
+	b.WriteString(label + ": " + fallbackText(oldValue) + " -> " + fallbackText(currentValue) + "\n")
+}
+
+// diffLines renders a line diff from old to current based on the longest
+// common subsequence: removed lines start with "- ", added ones with "+ ".
+func diffLines(old, current string) []string {
+	left := strings.Split(old, "\n")
+	right := strings.Split(current, "\n")
+
+	lcs := make([][]int, len(left)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(right)+1)
+	}
+	for i := len(left) - 1; i >= 0; i-- {
+		for j := len(right) - 1; j >= 0; j-- {
+			if left[i] == right[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = maxInt(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	out := make([]string, 0, len(left)+len(right))
+	i, j := 0, 0
+	for i < len(left) && j < len(right) {
+		switch {
+		case left[i] == right[j]:
+			out = append(out, "  "+left[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+left[i])
+			i++
+		default:
+			out = append(out, "+ "+right[j])
+			j++
+		}
+	}
+	for ; i < len(left); i++ {
+		out = append(out, "- "+left[i])
+	}
+	for ; j < len(right); j++ {
+		out = append(out, "+ "+right[j])
+	}
+	return out
+}
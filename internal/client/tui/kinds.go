@@ -0,0 +1,443 @@
+package tui
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/internal/totp"
+)
+
+const actionCreateKind = "create_kind"
+const fieldKind = "kind"
+
+// maxBinarySecretSize caps files stored inline in a binary secret; larger
+// files belong in attachments.
+const maxBinarySecretSize = 64 << 10
+
+var kindTitles = map[string]string{
+	secretkind.Login:    "Логин",
+	secretkind.Card:     "Банковская карта",
+	secretkind.Note:     "Заметка",
+	secretkind.Binary:   "Файл",
+	secretkind.SSHKey:   "SSH-ключ",
+	secretkind.APIToken: "API-токен",
+	secretkind.TOTP:     "Код 2FA",
+}
+
+// payloadField is one rendered line of a decoded payload. Sensitive values
+// are masked in lists and only reported as changed in diffs.
+type payloadField struct {
+	Label     string
+	Value     string
+	Sensitive bool
+}
+
+func kindTitle(kind string) string {
+	if title, ok := kindTitles[kind]; ok {
+		return title
+	}
+	return kind
+}
+
+func kindHint() string {
+	kinds := secretkind.Kinds()
+	parts := make([]string, 0, len(kinds))
+	for i, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%d %s", i+1, strings.ToLower(kindTitle(kind))))
+	}
+	return strings.Join(parts, ", ") + "; пусто = заметка"
+}
+
+// parseKindInput accepts a kind by its menu number or name. Empty input means
+// a note.
+func parseKindInput(value string) (string, error) {
+	trimmed := strings.ToLower(strings.TrimSpace(value))
+	if trimmed == "" {
+		return secretkind.Note, nil
+	}
+	kinds := secretkind.Kinds()
+	if idx, err := strconv.Atoi(trimmed); err == nil {
+		if idx >= 1 && idx <= len(kinds) {
+			return kinds[idx-1], nil
+		}
+		return "", fmt.Errorf("тип должен быть от 1 до %d", len(kinds))
+	}
+	if secretkind.Registered(trimmed) {
+		return trimmed, nil
+	}
+	return "", errors.New("неизвестный тип секрета: " + value)
+}
+
+// kindFields returns the form fields for a kind. Update forms never require
+// a field: blank input keeps the current value.
+func kindFields(kind string, update bool) []tuiField {
+	var fields []tuiField
+	switch kind {
+	case secretkind.Login:
+		fields = []tuiField{
+			{Key: "username", Label: "Имя пользователя", Hint: "Например: alice@example.com", Required: true},
+			{Key: "password", Label: "Пароль", Hint: "Ctrl+R сгенерировать пароль, Ctrl+E фразу", Required: true, Secret: true, Generate: true},
+			{Key: "url", Label: "Адрес входа", Hint: "Например: https://example.com/login"},
+		}
+	case secretkind.Card:
+		fields = []tuiField{
+			{Key: "number", Label: "Номер карты", Hint: "Можно с пробелами: 4111 1111 1111 1111", Required: true},
+			{Key: "holder", Label: "Держатель", Hint: "Как на карте: IVAN IVANOV", Required: true},
+			{Key: "expiry", Label: "Срок действия (ММ/ГГ)", Hint: "Например: 08/29", Required: true},
+			{Key: "cvv", Label: "CVV", Hint: "3 или 4 цифры", Required: true, Secret: true},
+		}
+	case secretkind.Binary:
+		fields = []tuiField{
+			{Key: "file", Label: "Путь к файлу", Hint: "До 64 КиБ, большие файлы прикрепляйте как вложения", Required: true},
+		}
+	case secretkind.SSHKey:
+		fields = []tuiField{
+			{Key: "file", Label: "Файл приватного ключа", Hint: "Например: /home/alice/.ssh/id_ed25519", Required: true},
+			{Key: "public_key", Label: "Публичный ключ", Hint: "Строка из .pub файла"},
+			{Key: "passphrase", Label: "Парольная фраза", Hint: "Ctrl+E сгенерировать фразу", Secret: true, Generate: true},
+		}
+	case secretkind.APIToken:
+		fields = []tuiField{
+			{Key: "token", Label: "Токен", Required: true, Secret: true},
+			{Key: "service", Label: "Сервис", Hint: "Например: GitHub"},
+			{Key: "expires", Label: "Истекает (ГГГГ-ММ-ДД)", Hint: "Например: 2027-01-31"},
+		}
+	case secretkind.TOTP:
+		fields = []tuiField{
+			{Key: "uri", Label: "otpauth:// URI", Hint: "Из QR-кода: otpauth://totp/ACME:alice?secret=...", Required: true, Secret: true},
+		}
+	default:
+		fields = []tuiField{
+			{Key: "text", Label: "Текст", Hint: "Любой текст, который хотите сохранить", Required: true},
+		}
+	}
+	if update {
+		for i := range fields {
+			fields[i].Required = false
+			fields[i].Hint = "Пусто = оставить текущее значение"
+		}
+	}
+	return fields
+}
+
+// kindValuesChanged reports whether an update form touched the payload of
+// kind, as opposed to only its metadata.
+func kindValuesChanged(kind string, values map[string]string) bool {
+	for _, field := range kindFields(kind, true) {
+		if strings.TrimSpace(values[field.Key]) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func createKindAction(kind string) tuiAction {
+	return tuiAction{
+		ID:          actionCreateKind,
+		Title:       "Создать Секрет",
+		Description: "Данные секрета: " + kindTitle(kind),
+		Fields:      kindFields(kind, false),
+	}
+}
+
+func updateKindAction(kind string) tuiAction {
+	fields := kindFields(kind, true)
+	fields = append(fields, updateSelectedAction.Fields...)
+	return tuiAction{
+		ID:          updateSelectedAction.ID,
+		Title:       updateSelectedAction.Title,
+		Description: updateSelectedAction.Description + " (" + kindTitle(kind) + ")",
+		Fields:      fields,
+	}
+}
+
+// secretKind returns the kind the TUI edits a secret as. Secrets stored
+// before kinds existed are edited as notes.
+func secretKind(item secretOutputItem) string {
+	if secretkind.Registered(item.Type) {
+		return item.Type
+	}
+	return secretkind.Note
+}
+
+func decodeSecretPayload(kind, ciphertext string) (secretkind.Payload, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(ciphertext))
+	if err != nil {
+		return secretkind.Payload{}, err
+	}
+	if !secretkind.Registered(kind) {
+		kind = secretkind.Note
+	}
+	return secretkind.Decode(kind, data)
+}
+
+func encodeSecretPayload(p secretkind.Payload) (string, error) {
+	encoded, err := secretkind.Encode(p)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+// buildPayload assembles a payload of kind from form values. When current is
+// set, blank values keep the current data.
+func buildPayload(kind string, values map[string]string, current *secretkind.Payload) (secretkind.Payload, error) {
+	value := func(key, existing string) string {
+		if v := strings.TrimSpace(values[key]); v != "" {
+			return v
+		}
+		return existing
+	}
+	p := secretkind.Payload{Kind: kind}
+	if current != nil && current.Kind == kind {
+		p = *current
+	}
+
+	switch kind {
+	case secretkind.Login:
+		d := secretkind.LoginData{}
+		if p.Login != nil {
+			d = *p.Login
+		}
+		d.Username = value("username", d.Username)
+		d.Password = value("password", d.Password)
+		d.URL = value("url", d.URL)
+		p.Login = &d
+	case secretkind.Card:
+		d := secretkind.CardData{}
+		if p.Card != nil {
+			d = *p.Card
+		}
+		d.Number = secretkind.NormalizeCardNumber(value("number", d.Number))
+		d.Holder = value("holder", d.Holder)
+		d.Expiry = value("expiry", d.Expiry)
+		d.CVV = value("cvv", d.CVV)
+		p.Card = &d
+	case secretkind.Binary:
+		d := secretkind.BinaryData{}
+		if p.Binary != nil {
+			d = *p.Binary
+		}
+		if path := strings.TrimSpace(values["file"]); path != "" {
+			data, err := readKindFile(path, maxBinarySecretSize)
+			if err != nil {
+				return secretkind.Payload{}, err
+			}
+			d = secretkind.BinaryData{Name: filepath.Base(path), Data: data}
+		}
+		p.Binary = &d
+	case secretkind.SSHKey:
+		d := secretkind.SSHKeyData{}
+		if p.SSHKey != nil {
+			d = *p.SSHKey
+		}
+		if path := strings.TrimSpace(values["file"]); path != "" {
+			data, err := readKindFile(path, maxBinarySecretSize)
+			if err != nil {
+				return secretkind.Payload{}, err
+			}
+			d.PrivateKey = string(data)
+		}
+		d.PublicKey = value("public_key", d.PublicKey)
+		d.Passphrase = value("passphrase", d.Passphrase)
+		p.SSHKey = &d
+	case secretkind.APIToken:
+		d := secretkind.APITokenData{}
+		if p.APIToken != nil {
+			d = *p.APIToken
+		}
+		d.Token = value("token", d.Token)
+		d.Service = value("service", d.Service)
+		d.ExpiresAt = value("expires", d.ExpiresAt)
+		p.APIToken = &d
+	case secretkind.TOTP:
+		d := secretkind.TOTPData{}
+		if p.TOTP != nil {
+			d = *p.TOTP
+		}
+		d.URI = value("uri", d.URI)
+		p.TOTP = &d
+	default:
+		d := secretkind.NoteData{}
+		if p.Note != nil {
+			d = *p.Note
+		}
+		d.Text = value("text", d.Text)
+		p.Note = &d
+	}
+	if err := p.Validate(); err != nil {
+		return secretkind.Payload{}, err
+	}
+	return p, nil
+}
+
+func readKindFile(path string, limit int64) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.New("файл не найден: " + path)
+	}
+	if info.Size() > limit {
+		return nil, fmt.Errorf("файл больше %s, прикрепите его как вложение", formatByteSize(limit))
+	}
+	return os.ReadFile(path)
+}
+
+// payloadFields lists the decoded fields of p in display order.
+func payloadFields(p secretkind.Payload) []payloadField {
+	switch {
+	case p.Login != nil:
+		return []payloadField{
+			{Label: "Пользователь", Value: p.Login.Username},
+			{Label: "Пароль", Value: p.Login.Password, Sensitive: true},
+			{Label: "Адрес", Value: p.Login.URL},
+			{Label: "Заметки", Value: p.Login.Notes},
+		}
+	case p.Card != nil:
+		return []payloadField{
+			{Label: "Карта", Value: maskCardNumber(p.Card.Number)},
+			{Label: "Держатель", Value: p.Card.Holder},
+			{Label: "Срок", Value: p.Card.Expiry},
+			{Label: "CVV", Value: p.Card.CVV, Sensitive: true},
+		}
+	case p.Binary != nil:
+		return []payloadField{
+			{Label: "Файл", Value: p.Binary.Name},
+			{Label: "Размер", Value: formatByteSize(int64(len(p.Binary.Data)))},
+			{Label: "Содержимое", Value: string(p.Binary.Data), Sensitive: true},
+		}
+	case p.SSHKey != nil:
+		return []payloadField{
+			{Label: "Публичный ключ", Value: p.SSHKey.PublicKey},
+			{Label: "Приватный ключ", Value: p.SSHKey.PrivateKey, Sensitive: true},
+			{Label: "Парольная фраза", Value: p.SSHKey.Passphrase, Sensitive: true},
+		}
+	case p.APIToken != nil:
+		return []payloadField{
+			{Label: "Сервис", Value: p.APIToken.Service},
+			{Label: "Токен", Value: p.APIToken.Token, Sensitive: true},
+			{Label: "Истекает", Value: p.APIToken.ExpiresAt},
+		}
+	case p.TOTP != nil:
+		key, err := totp.ParseURI(p.TOTP.URI)
+		if err != nil {
+			return []payloadField{{Label: "URI", Value: p.TOTP.URI, Sensitive: true}}
+		}
+		return []payloadField{
+			{Label: "Аккаунт", Value: totpKeyLabel(key)},
+			{Label: "Параметры", Value: totpParameters(key)},
+			{Label: "URI", Value: p.TOTP.URI, Sensitive: true},
+		}
+	case p.Note != nil:
+		return []payloadField{{Label: "Данные", Value: p.Note.Text}}
+	}
+	return nil
+}
+
+// payloadSummary is a one-line description of p for selection lists.
+func payloadSummary(p secretkind.Payload) string {
+	switch {
+	case p.Login != nil:
+		if p.Login.URL != "" {
+			return p.Login.Username + " @ " + p.Login.URL
+		}
+		return p.Login.Username
+	case p.Card != nil:
+		return maskCardNumber(p.Card.Number) + " до " + p.Card.Expiry
+	case p.Binary != nil:
+		return p.Binary.Name + " (" + formatByteSize(int64(len(p.Binary.Data))) + ")"
+	case p.SSHKey != nil:
+		if p.SSHKey.PublicKey != "" {
+			return truncateRunes(p.SSHKey.PublicKey, 60)
+		}
+		return "приватный ключ"
+	case p.APIToken != nil:
+		return fallbackText(p.APIToken.Service) + " " + maskSecret(p.APIToken.Token)
+	case p.TOTP != nil:
+		key, err := totp.ParseURI(p.TOTP.URI)
+		if err != nil {
+			return "код 2FA"
+		}
+		return totpKeyLabel(key)
+	case p.Note != nil:
+		first, _, _ := strings.Cut(strings.TrimSpace(p.Note.Text), "\n")
+		return truncateRunes(first, 60)
+	}
+	return ""
+}
+
+func secretSummary(item secretOutputItem) string {
+	payload, err := decodeSecretPayload(item.Type, item.Ciphertext)
+	if err != nil {
+		return "(данные недоступны)"
+	}
+	summary := strings.TrimSpace(payloadSummary(payload))
+	if summary == "" {
+		return "(пусто)"
+	}
+	return summary
+}
+
+// payloadChanges lists the fields that differ between two payloads.
+// Sensitive values are never shown, only reported as changed.
+func payloadChanges(old, current secretkind.Payload) []string {
+	oldFields := payloadFields(old)
+	currentFields := payloadFields(current)
+	oldValues := make(map[string]string, len(oldFields))
+	for _, field := range oldFields {
+		oldValues[field.Label] = field.Value
+	}
+
+	changes := make([]string, 0)
+	seen := make(map[string]struct{}, len(currentFields))
+	for _, field := range currentFields {
+		seen[field.Label] = struct{}{}
+		previous := oldValues[field.Label]
+		if previous == field.Value {
+			continue
+		}
+		if field.Sensitive {
+			changes = append(changes, field.Label+": изменено")
+			continue
+		}
+		changes = append(changes, field.Label+": "+fallbackText(previous)+" -> "+fallbackText(field.Value))
+	}
+	for _, field := range oldFields {
+		if _, ok := seen[field.Label]; ok || field.Value == "" {
+			continue
+		}
+		if field.Sensitive {
+			changes = append(changes, field.Label+": удалено")
+			continue
+		}
+		changes = append(changes, field.Label+": "+fallbackText(field.Value)+" -> -")
+	}
+	return changes
+}
+
+func maskCardNumber(number string) string {
+	if len(number) < 4 {
+		return "****"
+	}
+	return "**** " + number[len(number)-4:]
+}
+
+func maskSecret(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return "********"
+}
+
+func truncateRunes(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) > limit {
+		return string(runes[:limit]) + "..."
+	}
+	return value
+}
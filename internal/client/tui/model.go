@@ -16,6 +16,7 @@ func newTUIModel() tuiModel {
 		mode:             tuiModeMenu,
 		cursor:           0,
 		authorized:       authorized,
+		profile:          cli.ActiveProfile(),
 		autoSync:         authorized,
 		fieldValues:      make(map[string]string),
 		selectionFilters: make(map[string]string),
@@ -32,9 +33,9 @@ func isAuthorizedSession() bool {
 }
 
 func (m tuiModel) Init() tea.Cmd {
-	cmds := make([]tea.Cmd, 0, 2)
+	cmds := make([]tea.Cmd, 0, 3)
 	if m.autoSync {
-		cmds = append(cmds, syncTickCmd())
+		cmds = append(cmds, syncTickCmd(), startEventStreamCmd())
 	}
 	if m.authorized {
 		cmds = append(cmds, runTUIActionCmd("search", map[string]string{}))
@@ -53,8 +54,24 @@ func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleOperationResult(msg)
 	case secretSelectionLoadedMsg:
 		return m.handleSelectionLoaded(msg)
+	case secretHistoryLoadedMsg:
+		return m.handleHistoryLoaded(msg)
+	case attachmentsLoadedMsg:
+		return m.handleAttachmentsLoaded(msg)
+	case trashLoadedMsg:
+		return m.handleTrashLoaded(msg)
+	case conflictsLoadedMsg:
+		return m.handleConflictsLoaded(msg)
 	case syncTickMsg:
 		return m.handleSyncTick()
+	case totpTickMsg:
+		return m.handleTOTPTick(msg)
+	case eventStreamOpenedMsg:
+		return m.handleEventStreamOpened(msg)
+	case secretsChangedMsg:
+		return m.handleSecretsChanged(msg)
+	case eventStreamClosedMsg:
+		return m.handleEventStreamClosed(msg)
 	}
 	return m, nil
 }
@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/7StaSH7/practicum-diploma/internal/totp"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -19,6 +20,16 @@ func (m tuiModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleSelectKey(msg)
 	case tuiModeConfirmDelete:
 		return m.handleDeleteConfirmKey(msg)
+	case tuiModeHistory:
+		return m.handleHistoryKey(msg)
+	case tuiModeTrash:
+		return m.handleTrashKey(msg)
+	case tuiModeConflicts:
+		return m.handleConflictsKey(msg)
+	case tuiModeAttachments:
+		return m.handleAttachmentsKey(msg)
+	case tuiModeTOTP:
+		return m.handleTOTPKey(msg)
 	default:
 		return m.handleFormKey(msg)
 	}
@@ -120,6 +131,231 @@ func (m tuiModel) handleDeleteConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m tuiModel) handleHistoryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.ensureHistoryCursor()
+
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = tuiModeMenu
+		m.clearHistoryState()
+		m.selectedSecret = secretOutputItem{}
+		m.status = "[INFO] Просмотр истории закрыт"
+		return m, nil
+	case "up", "k":
+		if m.historyCursor > 0 {
+			m.historyCursor--
+		}
+		return m, nil
+	case "down", "j":
+		if m.historyCursor < len(m.historyItems)-1 {
+			m.historyCursor++
+		}
+		return m, nil
+	case "r", "R":
+		if len(m.historyItems) == 0 {
+			return m, nil
+		}
+		selected := m.historyItems[m.historyCursor]
+		secretID := strings.TrimSpace(m.selectedSecret.ID)
+		m.mode = tuiModeMenu
+		m.clearHistoryState()
+		m.status = "[INFO] Восстанавливаю версию..."
+		return m, runTUIActionCmd("restore", map[string]string{
+			"id":      secretID,
+			"version": strconv.FormatInt(selected.Version, 10),
+		})
+	}
+
+	return m, nil
+}
+
+func (m tuiModel) handleTrashKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.ensureTrashCursor()
+
+	if m.trashConfirm {
+		switch msg.String() {
+		case "esc", "n", "N":
+			m.trashConfirm = false
+			m.status = "[INFO] Окончательное удаление отменено"
+			return m, nil
+		case "enter", "y", "Y":
+			if len(m.trashItems) == 0 {
+				return m, nil
+			}
+			secretID := strings.TrimSpace(m.trashItems[m.trashCursor].ID)
+			m.mode = tuiModeMenu
+			m.clearTrashState()
+			m.status = "[INFO] Удаляю секрет навсегда..."
+			return m, runTUIActionCmd("trash_delete", map[string]string{"id": secretID})
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = tuiModeMenu
+		m.clearTrashState()
+		m.status = "[INFO] Корзина закрыта"
+		return m, nil
+	case "up", "k":
+		if m.trashCursor > 0 {
+			m.trashCursor--
+		}
+		return m, nil
+	case "down", "j":
+		if m.trashCursor < len(m.trashItems)-1 {
+			m.trashCursor++
+		}
+		return m, nil
+	case "r", "R", "enter":
+		if len(m.trashItems) == 0 {
+			return m, nil
+		}
+		secretID := strings.TrimSpace(m.trashItems[m.trashCursor].ID)
+		m.mode = tuiModeMenu
+		m.clearTrashState()
+		m.status = "[INFO] Восстанавливаю секрет из корзины..."
+		return m, runTUIActionCmd("trash_restore", map[string]string{"id": secretID})
+	case "d", "D", "delete":
+		if len(m.trashItems) == 0 {
+			return m, nil
+		}
+		m.trashConfirm = true
+		m.status = "[INFO] Подтвердите окончательное удаление"
+		return m, nil
+	}
+
+	return m, nil
+}
+
+func (m tuiModel) handleConflictsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.ensureConflictCursor()
+
+	var keep string
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = tuiModeMenu
+		m.clearConflictState()
+		m.status = "[INFO] Конфликты закрыты"
+		return m, nil
+	case "up", "k":
+		if m.conflictCursor > 0 {
+			m.conflictCursor--
+		}
+		return m, nil
+	case "down", "j":
+		if m.conflictCursor < len(m.conflictItems)-1 {
+			m.conflictCursor++
+		}
+		return m, nil
+	case "m", "M":
+		keep = "mine"
+	case "t", "T":
+		keep = "theirs"
+	case "b", "B":
+		keep = "both"
+	default:
+		return m, nil
+	}
+	if len(m.conflictItems) == 0 {
+		return m, nil
+	}
+	secretID := strings.TrimSpace(m.conflictItems[m.conflictCursor].SecretID)
+	m.mode = tuiModeMenu
+	m.clearConflictState()
+	m.status = "[INFO] Сохраняю решение..."
+	return m, runTUIActionCmd("conflict_resolve", map[string]string{"id": secretID, "keep": keep})
+}
+
+func (m tuiModel) handleAttachmentsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.ensureAttachmentCursor()
+
+	if m.attachmentConfirm {
+		switch msg.String() {
+		case "esc", "n", "N":
+			m.attachmentConfirm = false
+			m.status = "[INFO] Удаление вложения отменено"
+			return m, nil
+		case "enter", "y", "Y":
+			if len(m.attachmentItems) == 0 {
+				return m, nil
+			}
+			attachmentID := strings.TrimSpace(m.attachmentItems[m.attachmentCursor].ID)
+			m.mode = tuiModeMenu
+			m.clearAttachmentState()
+			m.status = "[INFO] Удаляю вложение..."
+			return m, runTUIActionCmd("detach", map[string]string{"attachment": attachmentID})
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = tuiModeMenu
+		m.clearAttachmentState()
+		m.selectedSecret = secretOutputItem{}
+		m.status = "[INFO] Просмотр вложений закрыт"
+		return m, nil
+	case "up", "k":
+		if m.attachmentCursor > 0 {
+			m.attachmentCursor--
+		}
+		return m, nil
+	case "down", "j":
+		if m.attachmentCursor < len(m.attachmentItems)-1 {
+			m.attachmentCursor++
+		}
+		return m, nil
+	case "a", "A":
+		m.mode = tuiModeForm
+		m.clearAttachmentState()
+		m.currentAction = attachFileAction
+		m.fieldIndex = 0
+		m.fieldValues = make(map[string]string)
+		m.input = ""
+		m.status = "[INFO] Укажите файл для загрузки"
+		return m, nil
+	case "s", "S", "enter":
+		if len(m.attachmentItems) == 0 {
+			return m, nil
+		}
+		selected := m.attachmentItems[m.attachmentCursor]
+		if !selected.Complete {
+			m.status = "[ERR] Загрузка вложения не завершена"
+			return m, nil
+		}
+		m.selectedAttachment = selected
+		m.mode = tuiModeForm
+		m.clearAttachmentState()
+		m.currentAction = downloadAttachmentAction
+		m.fieldIndex = 0
+		m.fieldValues = make(map[string]string)
+		m.input = ""
+		m.status = "[INFO] Выбрано вложение: " + selected.Name
+		return m, nil
+	case "d", "D", "delete":
+		if len(m.attachmentItems) == 0 {
+			return m, nil
+		}
+		m.attachmentConfirm = true
+		m.status = "[INFO] Подтвердите удаление вложения"
+		return m, nil
+	}
+
+	return m, nil
+}
+
+func (m tuiModel) handleTOTPKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = tuiModeMenu
+		m.totpKey = totp.Key{}
+		m.selectedSecret = secretOutputItem{}
+		m.status = "[INFO] Просмотр кода закрыт"
+	}
+	return m, nil
+}
+
 func (m tuiModel) handleFormKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "esc":
@@ -128,7 +364,15 @@ func (m tuiModel) handleFormKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.clearSelectionState()
 		m.status = "[INFO] Действие отменено"
 		return m, nil
+	case "ctrl+r", "ctrl+e":
+		return m.generateFieldValue(msg.String() == "ctrl+e")
+	case "ctrl+o":
+		if m.currentAction.Fields[m.fieldIndex].Secret {
+			m.revealInput = !m.revealInput
+		}
+		return m, nil
 	case "up", "k", "shift+tab":
+		m.revealInput = false
 		if m.fieldIndex > 0 {
 			currentField := m.currentAction.Fields[m.fieldIndex]
 			m.fieldValues[currentField.Key] = strings.TrimSpace(m.input)
@@ -146,6 +390,7 @@ func (m tuiModel) handleFormKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		m.fieldValues[field.Key] = value
 		m.input = ""
+		m.revealInput = false
 		if m.fieldIndex < len(m.currentAction.Fields)-1 {
 			m.fieldIndex++
 			next := m.currentAction.Fields[m.fieldIndex]
@@ -3,9 +3,12 @@ package tui
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/internal/totp"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -13,14 +16,24 @@ func isAuthAction(actionID string) bool {
 	return actionID == "signup" || actionID == "signin"
 }
 
+// isProfileAction reports the actions shown whether or not the profile is
+// signed in.
+func isProfileAction(actionID string) bool {
+	return actionID == "profile"
+}
+
 func requiresSecretSelection(actionID string) bool {
-	return actionID == "update" || actionID == "delete"
+	return actionID == "update" || actionID == "delete" || actionID == "history" || actionID == "attachments" || actionID == "totp"
 }
 
 func (m tuiModel) visibleActions() []tuiAction {
 	actions := make([]tuiAction, 0, len(tuiActions))
 	for _, action := range tuiActions {
 		authAction := isAuthAction(action.ID)
+		if isProfileAction(action.ID) {
+			actions = append(actions, action)
+			continue
+		}
 		if !m.authorized && !authAction {
 			continue
 		}
@@ -69,11 +82,75 @@ func (m *tuiModel) clearSelectionState() {
 	m.selectionFilters = nil
 }
 
+func (m *tuiModel) ensureHistoryCursor() {
+	if len(m.historyItems) == 0 {
+		m.historyCursor = 0
+		return
+	}
+	if m.historyCursor < 0 || m.historyCursor >= len(m.historyItems) {
+		m.historyCursor = 0
+	}
+}
+
+func (m *tuiModel) clearHistoryState() {
+	m.historyItems = nil
+	m.historyCursor = 0
+}
+
+func (m *tuiModel) ensureTrashCursor() {
+	if len(m.trashItems) == 0 {
+		m.trashCursor = 0
+		return
+	}
+	if m.trashCursor < 0 || m.trashCursor >= len(m.trashItems) {
+		m.trashCursor = 0
+	}
+}
+
+func (m *tuiModel) clearTrashState() {
+	m.trashItems = nil
+	m.trashCursor = 0
+	m.trashConfirm = false
+}
+
+func (m *tuiModel) ensureConflictCursor() {
+	if len(m.conflictItems) == 0 {
+		m.conflictCursor = 0
+		return
+	}
+	if m.conflictCursor < 0 || m.conflictCursor >= len(m.conflictItems) {
+		m.conflictCursor = 0
+	}
+}
+
+func (m *tuiModel) clearConflictState() {
+	m.conflictItems = nil
+	m.conflictCursor = 0
+}
+
+func (m *tuiModel) ensureAttachmentCursor() {
+	if len(m.attachmentItems) == 0 {
+		m.attachmentCursor = 0
+		return
+	}
+	if m.attachmentCursor < 0 || m.attachmentCursor >= len(m.attachmentItems) {
+		m.attachmentCursor = 0
+	}
+}
+
+func (m *tuiModel) clearAttachmentState() {
+	m.attachmentItems = nil
+	m.attachmentCursor = 0
+	m.attachmentConfirm = false
+	m.attachmentsLoaded = false
+}
+
 func (m *tuiModel) clearFormState() {
 	m.currentAction = tuiAction{}
 	m.fieldIndex = 0
 	m.fieldValues = make(map[string]string)
 	m.input = ""
+	m.revealInput = false
 }
 
 func (m tuiModel) startSecretSelection(actionID string, filters map[string]string) (tea.Model, tea.Cmd) {
@@ -95,7 +172,7 @@ func (m tuiModel) applySelectedSecret(item secretOutputItem) (tea.Model, tea.Cmd
 	switch actionID {
 	case "update":
 		m.mode = tuiModeForm
-		m.currentAction = updateSelectedAction
+		m.currentAction = updateKindAction(secretKind(item))
 		m.fieldIndex = 0
 		m.fieldValues = map[string]string{
 			"title": item.MetaOpen.Title,
@@ -110,6 +187,32 @@ func (m tuiModel) applySelectedSecret(item secretOutputItem) (tea.Model, tea.Cmd
 		m.clearFormState()
 		m.status = "[INFO] Подтвердите удаление выбранного секрета"
 		return m, nil
+	case "history":
+		m.mode = tuiModeHistory
+		m.clearFormState()
+		m.clearHistoryState()
+		m.status = "[INFO] Загружаю историю версий..."
+		return m, loadSecretHistoryCmd(item.ID)
+	case "attachments":
+		m.mode = tuiModeAttachments
+		m.clearFormState()
+		m.clearAttachmentState()
+		m.status = "[INFO] Загружаю вложения..."
+		return m, loadAttachmentsCmd(item.ID)
+	case "totp":
+		key, err := secretTOTPKey(item)
+		if err != nil {
+			m.mode = tuiModeMenu
+			m.selectedSecret = secretOutputItem{}
+			m.status = "[ERR] " + err.Error()
+			return m, nil
+		}
+		m.mode = tuiModeTOTP
+		m.clearFormState()
+		m.totpKey = key
+		m.totpGeneration++
+		m.status = "[INFO] Код обновляется автоматически"
+		return m, totpTickCmd(m.totpGeneration)
 	default:
 		m.mode = tuiModeMenu
 		m.status = "[ERR] Неизвестный режим выбора секрета"
@@ -118,7 +221,7 @@ func (m tuiModel) applySelectedSecret(item secretOutputItem) (tea.Model, tea.Cmd
 }
 
 func (m *tuiModel) startAction(action tuiAction) tea.Cmd {
-	if !m.authorized && !isAuthAction(action.ID) {
+	if !m.authorized && !isAuthAction(action.ID) && !isProfileAction(action.ID) {
 		m.status = "[INFO] Сначала войдите или зарегистрируйтесь"
 		return nil
 	}
@@ -127,6 +230,18 @@ func (m *tuiModel) startAction(action tuiAction) tea.Cmd {
 		return nil
 	}
 
+	if action.ID == "trash" {
+		m.mode = tuiModeTrash
+		m.clearTrashState()
+		m.status = "[INFO] Загружаю корзину..."
+		return loadTrashCmd()
+	}
+	if action.ID == "conflicts" {
+		m.mode = tuiModeConflicts
+		m.clearConflictState()
+		m.status = "[INFO] Загружаю конфликты..."
+		return loadConflictsCmd()
+	}
 	if len(action.Fields) == 0 {
 		m.status = "[INFO] Выполняю команду..."
 		return runTUIActionCmd(action.ID, nil)
@@ -140,6 +255,28 @@ func (m *tuiModel) startAction(action tuiAction) tea.Cmd {
 	return nil
 }
 
+// startKindForm moves from the create form to the form of the chosen kind,
+// carrying the metadata entered so far.
+func (m tuiModel) startKindForm(values map[string]string) (tea.Model, tea.Cmd) {
+	kind, err := parseKindInput(values[fieldKind])
+	if err != nil {
+		m.status = "[ERR] " + err.Error()
+		return m, nil
+	}
+	m.mode = tuiModeForm
+	m.currentAction = createKindAction(kind)
+	m.fieldIndex = 0
+	m.fieldValues = map[string]string{
+		fieldKind: kind,
+		"title":   values["title"],
+		"tags":    values["tags"],
+		"site":    values["site"],
+	}
+	m.input = ""
+	m.status = "[INFO] Заполните данные: " + kindTitle(kind)
+	return m, nil
+}
+
 func validateField(field tuiField, value string) error {
 	if field.Required && value == "" {
 		return fmt.Errorf("поле обязательно: %s", field.Label)
@@ -151,6 +288,46 @@ func validateField(field tuiField, value string) error {
 				return errors.New("время должно быть в RFC3339, например 2026-02-09T10:00:00Z")
 			}
 		}
+	case "file":
+		if value != "" {
+			info, err := os.Stat(value)
+			if err != nil {
+				return errors.New("файл не найден: " + value)
+			}
+			if info.IsDir() {
+				return errors.New("укажите файл, а не папку")
+			}
+		}
+	case "uri":
+		if value != "" {
+			if _, err := totp.ParseURI(value); err != nil {
+				return errors.New("ожидается otpauth://totp/... URI с параметром secret")
+			}
+		}
+	case fieldKind:
+		if _, err := parseKindInput(value); err != nil {
+			return err
+		}
+	case "number":
+		if value != "" && !secretkind.LuhnValid(value) {
+			return errors.New("номер карты не прошел проверку Луна")
+		}
+	case "expiry":
+		if value != "" {
+			expires, err := secretkind.ParseExpiry(value)
+			if err != nil {
+				return errors.New("срок действия должен быть в формате ММ/ГГ, например 08/29")
+			}
+			if !time.Now().Before(expires) {
+				return errors.New("срок действия карты истек")
+			}
+		}
+	case "expires":
+		if value != "" {
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return errors.New("дата должна быть в формате ГГГГ-ММ-ДД, например 2027-01-31")
+			}
+		}
 	case fieldFindDate:
 		if value != "" {
 			if _, err := time.Parse("2006-01-02", value); err != nil {
@@ -172,9 +349,64 @@ func (m tuiModel) submitCurrentAction() (tea.Model, tea.Cmd) {
 		return m.startSecretSelection(actionID, values)
 	}
 
-	if actionID == actionUpdateSelected {
-		values["id"] = m.selectedSecret.ID
+	switch actionID {
+	case "create":
+		return m.startKindForm(values)
+	case actionCreateKind:
+		kind := values[fieldKind]
+		payload, err := buildPayload(kind, values, nil)
+		if err != nil {
+			m.status = "[ERR] " + err.Error()
+			return m, nil
+		}
+		ciphertext, err := encodeSecretPayload(payload)
+		if err != nil {
+			m.status = "[ERR] " + err.Error()
+			return m, nil
+		}
+		values = map[string]string{
+			"type":       kind,
+			"ciphertext": ciphertext,
+			"title":      values["title"],
+			"tags":       values["tags"],
+			"site":       values["site"],
+		}
+		actionID = "create"
+	case actionUpdateSelected:
+		kind := secretKind(m.selectedSecret)
+		update := map[string]string{
+			"id":    m.selectedSecret.ID,
+			"title": values["title"],
+			"tags":  values["tags"],
+			"site":  values["site"],
+		}
+		if kindValuesChanged(kind, values) {
+			current, err := decodeSecretPayload(kind, m.selectedSecret.Ciphertext)
+			if err != nil {
+				m.status = "[ERR] Не удалось прочитать текущие данные секрета: " + err.Error()
+				return m, nil
+			}
+			payload, err := buildPayload(kind, values, &current)
+			if err != nil {
+				m.status = "[ERR] " + err.Error()
+				return m, nil
+			}
+			ciphertext, err := encodeSecretPayload(payload)
+			if err != nil {
+				m.status = "[ERR] " + err.Error()
+				return m, nil
+			}
+			update["type"] = kind
+			update["ciphertext"] = ciphertext
+		}
+		values = update
 		actionID = "update"
+	case actionAttachFile:
+		values["id"] = m.selectedSecret.ID
+		actionID = "attach"
+	case actionDownloadAttachment:
+		values["attachment"] = m.selectedAttachment.ID
+		actionID = "download"
 	}
 
 	m.mode = tuiModeMenu
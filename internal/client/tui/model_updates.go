@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/7StaSH7/practicum-diploma/internal/client/cli"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -22,17 +23,47 @@ func (m tuiModel) handleOperationResult(msg operationResultMsg) (tea.Model, tea.
 		}
 		if !isAutoSync {
 			m.status = "[OK] Готово"
+		} else if notice := strings.TrimSpace(msg.Output); notice != "" && m.mode == tuiModeMenu {
+			m.status = "[INFO] " + notice
 		}
 	}
 
+	if msg.ActionID == "profile" && msg.Err == nil {
+		return m.switchedProfile(previousAutoSync)
+	}
 	m.refreshAuthorizationState()
 	if !previousAutoSync && m.autoSync {
 		m.status = "[OK] Вход выполнен"
-		return m, syncTickCmd()
+		return m, tea.Batch(syncTickCmd(), startEventStreamCmd())
+	}
+	if isAutoSync && m.syncPending && m.autoSync {
+		m.syncPending = false
+		m.syncInFlight = true
+		return m, runTUIActionCmd("auto_sync", map[string]string{})
 	}
 	return m, nil
 }
 
+// switchedProfile starts over in the profile just selected: the old event
+// stream belongs to another account, so it is replaced.
+func (m tuiModel) switchedProfile(previousAutoSync bool) (tea.Model, tea.Cmd) {
+	m.stopEventStream()
+	m.profile = cli.ActiveProfile()
+	m.syncPending = false
+	m.clearSelectionState()
+	m.refreshAuthorizationState()
+	if !m.authorized {
+		m.status = "[OK] Профиль переключён. Войдите или зарегистрируйтесь"
+		return m, nil
+	}
+	m.status = "[OK] Профиль переключён"
+	cmds := []tea.Cmd{startEventStreamCmd(), runTUIActionCmd("search", map[string]string{})}
+	if !previousAutoSync {
+		cmds = append(cmds, syncTickCmd())
+	}
+	return m, tea.Batch(cmds...)
+}
+
 func (m tuiModel) handleSelectionLoaded(msg secretSelectionLoadedMsg) (tea.Model, tea.Cmd) {
 	if msg.Err != nil {
 		m.mode = tuiModeMenu
@@ -57,13 +88,112 @@ func (m tuiModel) handleSelectionLoaded(msg secretSelectionLoadedMsg) (tea.Model
 	return m, nil
 }
 
+func (m tuiModel) handleHistoryLoaded(msg secretHistoryLoadedMsg) (tea.Model, tea.Cmd) {
+	if m.mode != tuiModeHistory {
+		return m, nil
+	}
+	if msg.Err != nil {
+		m.mode = tuiModeMenu
+		m.clearHistoryState()
+		m.status = "[ERR] " + msg.Err.Error()
+		return m, nil
+	}
+	if len(msg.Items) == 0 {
+		m.mode = tuiModeMenu
+		m.clearHistoryState()
+		m.status = "[INFO] У секрета пока нет прошлых версий"
+		return m, nil
+	}
+
+	m.historyItems = msg.Items
+	m.historyCursor = 0
+	m.status = fmt.Sprintf("[INFO] Найдено %d прошл(ых) версий. Выберите версию для сравнения", len(msg.Items))
+	return m, nil
+}
+
+func (m tuiModel) handleAttachmentsLoaded(msg attachmentsLoadedMsg) (tea.Model, tea.Cmd) {
+	if m.mode != tuiModeAttachments {
+		return m, nil
+	}
+	if msg.Err != nil {
+		m.mode = tuiModeMenu
+		m.clearAttachmentState()
+		m.status = "[ERR] " + msg.Err.Error()
+		return m, nil
+	}
+
+	m.attachmentItems = msg.Items
+	m.attachmentCursor = 0
+	m.attachmentConfirm = false
+	m.attachmentsLoaded = true
+	if len(msg.Items) == 0 {
+		m.status = "[INFO] Вложений пока нет. Нажмите A, чтобы прикрепить файл"
+		return m, nil
+	}
+	m.status = fmt.Sprintf("[INFO] Найдено %d вложени(й)", len(msg.Items))
+	return m, nil
+}
+
+func (m tuiModel) handleTrashLoaded(msg trashLoadedMsg) (tea.Model, tea.Cmd) {
+	if m.mode != tuiModeTrash {
+		return m, nil
+	}
+	if msg.Err != nil {
+		m.mode = tuiModeMenu
+		m.clearTrashState()
+		m.status = "[ERR] " + msg.Err.Error()
+		return m, nil
+	}
+	if len(msg.Items) == 0 {
+		m.mode = tuiModeMenu
+		m.clearTrashState()
+		m.status = "[INFO] Корзина пуста"
+		return m, nil
+	}
+
+	m.trashItems = msg.Items
+	m.trashCursor = 0
+	m.trashConfirm = false
+	m.status = fmt.Sprintf("[INFO] В корзине %d секрет(ов)", len(msg.Items))
+	return m, nil
+}
+
+func (m tuiModel) handleConflictsLoaded(msg conflictsLoadedMsg) (tea.Model, tea.Cmd) {
+	if m.mode != tuiModeConflicts {
+		return m, nil
+	}
+	if msg.Err != nil {
+		m.mode = tuiModeMenu
+		m.clearConflictState()
+		m.status = "[ERR] " + msg.Err.Error()
+		return m, nil
+	}
+	if len(msg.Items) == 0 {
+		m.mode = tuiModeMenu
+		m.clearConflictState()
+		m.status = "[INFO] Конфликтов нет"
+		return m, nil
+	}
+
+	m.conflictItems = msg.Items
+	m.conflictCursor = 0
+	m.status = fmt.Sprintf("[INFO] Конфликтов: %d. Выберите, какую версию оставить", len(msg.Items))
+	return m, nil
+}
+
+// handleSyncTick polls only while no event stream is open and tries to
+// reopen the stream on every poll.
 func (m tuiModel) handleSyncTick() (tea.Model, tea.Cmd) {
 	if !m.autoSync {
 		return m, nil
 	}
-	if m.syncInFlight {
+	if m.syncInFlight || m.stream != nil {
 		return m, syncTickCmd()
 	}
 	m.syncInFlight = true
-	return m, tea.Batch(syncTickCmd(), runTUIActionCmd("auto_sync", map[string]string{}))
+	cmds := []tea.Cmd{syncTickCmd(), runTUIActionCmd("auto_sync", map[string]string{})}
+	if !m.streamUnsupported {
+		cmds = append(cmds, startEventStreamCmd())
+	}
+	return m, tea.Batch(cmds...)
 }
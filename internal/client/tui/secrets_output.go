@@ -26,6 +26,7 @@ type secretOutputItem struct {
 	Ciphertext string           `json:"ciphertext"`
 	Version    int64            `json:"version"`
 	UpdatedAt  string           `json:"updated_at"`
+	DeletedAt  string           `json:"deleted_at,omitempty"`
 }
 
 func formatSecretOutput(output string) string {
@@ -142,8 +143,19 @@ func loadSecretSelectionCmd(filters map[string]string) tea.Cmd {
 	}
 }
 
+// listSecretsOutput reads the offline cache, which auto sync keeps current,
+// so lists open instantly. The server is only asked while the cache is not
+// seeded yet.
+func listSecretsOutput() (string, error) {
+	output, err := executeCLI([]string{"secrets", "list", "--offline"})
+	if err == nil {
+		return output, nil
+	}
+	return executeCLI([]string{"secrets", "list"})
+}
+
 func loadSecretsForSelection(filters map[string]string) ([]secretOutputItem, error) {
-	rawOutput, err := executeCLI([]string{"secrets", "list"})
+	rawOutput, err := listSecretsOutput()
 	if err != nil {
 		return nil, err
 	}
@@ -364,7 +376,7 @@ func renderSecretList(items []secretOutputItem, headline string) string {
 		}
 		b.WriteString(fmt.Sprintf("   Сайт: %s\n", fallbackText(item.MetaOpen.Site)))
 		b.WriteString(fmt.Sprintf("   Обновлен: %s\n", fallbackText(item.UpdatedAt)))
-		appendSecretData(&b, decodeSecretData(item.Ciphertext))
+		appendSecretPayload(&b, item)
 	}
 	if len(items) > visibleCount {
 		b.WriteString("\n")
@@ -403,7 +415,11 @@ func decodeSecretData(ciphertext string) string {
 	if err != nil {
 		return "(данные недоступны)"
 	}
-	text := strings.TrimSpace(string(decoded))
+	return formatSecretText(string(decoded))
+}
+
+func formatSecretText(value string) string {
+	text := strings.TrimSpace(value)
 	if text == "" {
 		return "(пусто)"
 	}
@@ -414,6 +430,27 @@ func decodeSecretData(ciphertext string) string {
 	return text
 }
 
+// appendSecretPayload renders the decoded payload of item field by field,
+// masking sensitive values.
+func appendSecretPayload(b *strings.Builder, item secretOutputItem) {
+	payload, err := decodeSecretPayload(item.Type, item.Ciphertext)
+	if err != nil {
+		appendSecretData(b, decodeSecretData(item.Ciphertext))
+		return
+	}
+	if payload.Note != nil {
+		appendSecretData(b, formatSecretText(payload.Note.Text))
+		return
+	}
+	for _, field := range payloadFields(payload) {
+		value := fallbackText(field.Value)
+		if field.Sensitive {
+			value = maskSecret(field.Value)
+		}
+		b.WriteString(fmt.Sprintf("   %s: %s\n", field.Label, value))
+	}
+}
+
 func appendSecretData(b *strings.Builder, data string) {
 	if !strings.Contains(data, "\n") {
 		b.WriteString(fmt.Sprintf("   Данные: %s\n", data))
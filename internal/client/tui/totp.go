@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/internal/totp"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// totpNow is the clock of the TOTP view; tests pin it.
+var totpNow = time.Now
+
+// totpTickCmd redraws the TOTP view once a second. The generation ties a
+// tick to one opening of the view so reopening it does not double the rate.
+func totpTickCmd(generation int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return totpTickMsg{generation: generation}
+	})
+}
+
+func (m tuiModel) handleTOTPTick(msg totpTickMsg) (tea.Model, tea.Cmd) {
+	if m.mode != tuiModeTOTP || msg.generation != m.totpGeneration {
+		return m, nil
+	}
+	return m, totpTickCmd(m.totpGeneration)
+}
+
+// secretTOTPKey parses the otpauth URI stored in a totp secret.
+func secretTOTPKey(item secretOutputItem) (totp.Key, error) {
+	if item.Type != secretkind.TOTP {
+		return totp.Key{}, errors.New("выбранный секрет не является кодом 2FA")
+	}
+	payload, err := decodeSecretPayload(item.Type, item.Ciphertext)
+	if err != nil || payload.TOTP == nil {
+		return totp.Key{}, errors.New("не удалось прочитать данные секрета")
+	}
+	return totp.ParseURI(payload.TOTP.URI)
+}
+
+func totpKeyLabel(key totp.Key) string {
+	switch {
+	case key.Issuer != "" && key.Account != "":
+		return key.Issuer + ":" + key.Account
+	case key.Issuer != "":
+		return key.Issuer
+	default:
+		return fallbackText(key.Account)
+	}
+}
+
+func totpParameters(key totp.Key) string {
+	return fmt.Sprintf("%s, %d цифр, %d с", key.Algorithm, key.Digits, key.Period)
+}
+
+// totpProgress draws the time left in the current period as a bar.
+func totpProgress(remaining time.Duration, period int) string {
+	const width = 30
+	filled := int(remaining/time.Second) * width / period
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
+// formatTOTPCode splits a code in two halves for readability.
+func formatTOTPCode(code string) string {
+	half := len(code) / 2
+	return code[:half] + " " + code[half:]
+}
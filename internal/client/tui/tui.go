@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/7StaSH7/practicum-diploma/internal/client/cli"
 	"github.com/7StaSH7/practicum-diploma/internal/version"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -29,7 +30,12 @@ func (a *TUIApp) Run(args []string) int {
 		case "help", "-h", "--help":
 			_, _ = fmt.Fprintln(a.stdout, "PKeeper TUI")
 			_, _ = fmt.Fprintln(a.stdout, "Запустите без аргументов для интерактивного режима.")
+			_, _ = fmt.Fprintln(a.stdout, "agent start|serve|status|stop - фоновый агент с ключом хранилища.")
+			_, _ = fmt.Fprintln(a.stdout, "PKEEPER_PROFILE=имя - открыть другой профиль; сменить его можно и из меню.")
 			return 0
+		case "agent":
+			// Unlocking from the TUI starts the agent from this binary.
+			return cli.Execute(args, a.stdout, a.stderr)
 		default:
 			_, _ = fmt.Fprintf(a.stderr, "неизвестный аргумент: %s\n", args[0])
 			return 2
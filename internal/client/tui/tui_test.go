@@ -1,10 +1,27 @@
 package tui
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/client/cli"
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/7StaSH7/practicum-diploma/internal/vaultcache"
+	tea "github.com/charmbracelet/bubbletea"
 )
 
+// TestMain keeps the session key in a file so tests never reach the
+// developer's keyring.
+func TestMain(m *testing.M) {
+	_ = os.Setenv("PKEEPER_SESSION_KEY", "file")
+	os.Exit(m.Run())
+}
+
 func actionIDs(actions []tuiAction) []string {
 	ids := make([]string, 0, len(actions))
 	for _, action := range actions {
@@ -102,10 +119,10 @@ func TestVisibleActionsForGuest(t *testing.T) {
 	m := tuiModel{authorized: false}
 	ids := actionIDs(m.visibleActions())
 
-	if len(ids) != 2 {
-		t.Fatalf("guest should see only signup/signin, got: %v", ids)
+	if len(ids) != 3 {
+		t.Fatalf("guest should see only signup/signin and profile switching, got: %v", ids)
 	}
-	if ids[0] != "signup" || ids[1] != "signin" {
+	if ids[0] != "signup" || ids[1] != "signin" || ids[2] != "profile" {
 		t.Fatalf("unexpected guest actions: %v", ids)
 	}
 }
@@ -181,3 +198,398 @@ func TestExtractDate(t *testing.T) {
 		t.Fatalf("expected empty date for invalid value, got: %s", got)
 	}
 }
+
+func TestDiffLines(t *testing.T) {
+	lines := diffLines("login\nold-password", "login\nnew-password\nnote")
+	expected := []string{"  login", "- old-password", "+ new-password", "+ note"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Fatalf("unexpected diff: %#v", lines)
+	}
+}
+
+func TestRenderSecretVersionDiff(t *testing.T) {
+	old := secretVersionItem{
+		Version:    1,
+		MetaOpen:   secretOutputMeta{Title: "Почта"},
+		Ciphertext: encodeSecretData("old"),
+	}
+	current := secretOutputItem{
+		MetaOpen:   secretOutputMeta{Title: "Рабочая почта"},
+		Ciphertext: encodeSecretData("new"),
+	}
+
+	rendered := renderSecretVersionDiff(old, current)
+	if !strings.Contains(rendered, "Заголовок: Почта -> Рабочая почта") {
+		t.Fatalf("expected title change: %s", rendered)
+	}
+	if !strings.Contains(rendered, "- old") || !strings.Contains(rendered, "+ new") {
+		t.Fatalf("expected decrypted data diff: %s", rendered)
+	}
+}
+
+func TestTrashKeyRequiresConfirmationForPurge(t *testing.T) {
+	m := tuiModel{
+		mode:       tuiModeTrash,
+		authorized: true,
+		trashItems: []secretOutputItem{{ID: "a"}, {ID: "b"}},
+	}
+
+	next, cmd := m.handleTrashKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	updated := next.(tuiModel)
+	if cmd != nil || !updated.trashConfirm || updated.mode != tuiModeTrash {
+		t.Fatalf("expected confirmation step before purge, got mode=%v confirm=%v", updated.mode, updated.trashConfirm)
+	}
+
+	next, _ = updated.handleTrashKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	updated = next.(tuiModel)
+	if updated.trashConfirm || updated.mode != tuiModeTrash {
+		t.Fatalf("expected purge to be cancelled, got mode=%v confirm=%v", updated.mode, updated.trashConfirm)
+	}
+}
+
+func TestAttachmentDownloadFormTargetsSelectedAttachment(t *testing.T) {
+	m := tuiModel{
+		mode:              tuiModeAttachments,
+		authorized:        true,
+		selectedSecret:    secretOutputItem{ID: "s-1"},
+		attachmentsLoaded: true,
+		attachmentItems: []attachmentItem{
+			{ID: "a-1", Name: "partial.bin", Size: 10, Received: 4},
+			{ID: "a-2", Name: "key.pem", Size: 10, Received: 10, Complete: true},
+		},
+	}
+
+	next, _ := m.handleAttachmentsKey(tea.KeyMsg{Type: tea.KeyEnter})
+	updated := next.(tuiModel)
+	if updated.mode != tuiModeAttachments {
+		t.Fatalf("incomplete attachment should not be downloadable, got mode=%v", updated.mode)
+	}
+
+	next, _ = updated.handleAttachmentsKey(tea.KeyMsg{Type: tea.KeyDown})
+	next, _ = next.(tuiModel).handleAttachmentsKey(tea.KeyMsg{Type: tea.KeyEnter})
+	updated = next.(tuiModel)
+	if updated.mode != tuiModeForm || updated.currentAction.ID != actionDownloadAttachment {
+		t.Fatalf("expected download form, got mode=%v action=%s", updated.mode, updated.currentAction.ID)
+	}
+	if updated.selectedAttachment.ID != "a-2" {
+		t.Fatalf("unexpected selected attachment: %+v", updated.selectedAttachment)
+	}
+}
+
+func TestStreamEventsPausePollingAndCoalesceSyncs(t *testing.T) {
+	stream := &eventStream{changes: make(chan struct{}, 1), done: make(chan error, 1)}
+	m := tuiModel{autoSync: true, stream: stream}
+
+	updated, _ := m.handleSyncTick()
+	m = updated.(tuiModel)
+	if m.syncInFlight {
+		t.Fatalf("tick must not poll while the event stream is open")
+	}
+
+	updated, cmd := m.handleSecretsChanged(secretsChangedMsg{stream: stream})
+	m = updated.(tuiModel)
+	if !m.syncInFlight || cmd == nil {
+		t.Fatalf("expected event to start a sync")
+	}
+
+	updated, _ = m.handleSecretsChanged(secretsChangedMsg{stream: stream})
+	m = updated.(tuiModel)
+	if !m.syncPending {
+		t.Fatalf("expected event during sync to be queued")
+	}
+
+	updated, _ = m.handleEventStreamClosed(eventStreamClosedMsg{stream: stream})
+	m = updated.(tuiModel)
+	if m.stream != nil {
+		t.Fatalf("expected closed stream to be dropped")
+	}
+}
+
+func TestCreateFormSwitchesToKindFields(t *testing.T) {
+	var create tuiAction
+	for _, action := range tuiActions {
+		if action.ID == "create" {
+			create = action
+		}
+	}
+	m := tuiModel{
+		mode:          tuiModeForm,
+		authorized:    true,
+		currentAction: create,
+		fieldValues:   map[string]string{fieldKind: "2", "title": "Зарплатная"},
+	}
+
+	next, cmd := m.submitCurrentAction()
+	updated := next.(tuiModel)
+	if cmd != nil || updated.mode != tuiModeForm || updated.currentAction.ID != actionCreateKind {
+		t.Fatalf("expected card form, got mode=%v action=%s", updated.mode, updated.currentAction.ID)
+	}
+	if updated.fieldValues[fieldKind] != secretkind.Card || updated.fieldValues["title"] != "Зарплатная" {
+		t.Fatalf("expected kind and metadata to carry over: %#v", updated.fieldValues)
+	}
+
+	updated.fieldValues["number"] = "4111 1111 1111 1112"
+	updated.fieldValues["holder"] = "IVAN IVANOV"
+	updated.fieldValues["expiry"] = "12/99"
+	updated.fieldValues["cvv"] = "123"
+	next, cmd = updated.submitCurrentAction()
+	updated = next.(tuiModel)
+	if cmd != nil || updated.mode != tuiModeForm || !strings.HasPrefix(updated.status, "[ERR]") {
+		t.Fatalf("expected invalid card to keep the form open, got mode=%v status=%s", updated.mode, updated.status)
+	}
+}
+
+func TestValidateFieldChecksCardNumberAndExpiry(t *testing.T) {
+	if err := validateField(tuiField{Key: "number"}, "4111 1111 1111 1111"); err != nil {
+		t.Fatalf("expected valid card number: %v", err)
+	}
+	if err := validateField(tuiField{Key: "number"}, "4111 1111 1111 1112"); err == nil {
+		t.Fatalf("expected Luhn failure")
+	}
+	if err := validateField(tuiField{Key: "expiry"}, "13/30"); err == nil {
+		t.Fatalf("expected invalid month to fail")
+	}
+	if err := validateField(tuiField{Key: fieldKind}, "9"); err == nil {
+		t.Fatalf("expected unknown kind to fail")
+	}
+}
+
+func TestRenderSecretListMasksSensitiveFields(t *testing.T) {
+	encoded, err := encodeSecretPayload(secretkind.Payload{
+		Kind:  secretkind.Login,
+		Login: &secretkind.LoginData{Username: "alice", Password: "hunter22", URL: "https://example.com"},
+	})
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+
+	rendered := renderSecretList([]secretOutputItem{{ID: "s-1", Type: secretkind.Login, Ciphertext: encoded}}, "Список")
+	if !strings.Contains(rendered, "Пользователь: alice") || !strings.Contains(rendered, "Адрес: https://example.com") {
+		t.Fatalf("expected login fields: %s", rendered)
+	}
+	if strings.Contains(rendered, "hunter22") {
+		t.Fatalf("password must be masked: %s", rendered)
+	}
+}
+
+func TestBuildPayloadKeepsCurrentValuesOnBlankInput(t *testing.T) {
+	current := secretkind.Payload{
+		Schema:   secretkind.SchemaVersion,
+		Kind:     secretkind.APIToken,
+		APIToken: &secretkind.APITokenData{Token: "tok-1", Service: "GitHub"},
+	}
+
+	updated, err := buildPayload(secretkind.APIToken, map[string]string{"service": "GitLab"}, &current)
+	if err != nil {
+		t.Fatalf("build payload: %v", err)
+	}
+	if updated.APIToken.Token != "tok-1" || updated.APIToken.Service != "GitLab" {
+		t.Fatalf("unexpected payload: %+v", updated.APIToken)
+	}
+	if current.APIToken.Service != "GitHub" {
+		t.Fatalf("current payload must not be modified")
+	}
+
+	changes := payloadChanges(current, updated)
+	if strings.Join(changes, "|") != "Сервис: GitHub -> GitLab" {
+		t.Fatalf("unexpected changes: %#v", changes)
+	}
+}
+
+func TestTOTPViewShowsCodeWithCountdown(t *testing.T) {
+	prevNow := totpNow
+	totpNow = func() time.Time { return time.Unix(59, 0) }
+	t.Cleanup(func() { totpNow = prevNow })
+
+	encoded, err := encodeSecretPayload(secretkind.Payload{
+		Kind: secretkind.TOTP,
+		TOTP: &secretkind.TOTPData{URI: "otpauth://totp/ACME:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8"},
+	})
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	m := tuiModel{mode: tuiModeSelect, authorized: true, selectionAction: "totp"}
+
+	next, _ := m.applySelectedSecret(secretOutputItem{ID: "s-1", Type: secretkind.Note, Ciphertext: encodeSecretData("hello")})
+	updated := next.(tuiModel)
+	if updated.mode != tuiModeMenu || !strings.HasPrefix(updated.status, "[ERR]") {
+		t.Fatalf("expected non-totp secret to be rejected, got mode=%v status=%s", updated.mode, updated.status)
+	}
+
+	m.selectionAction = "totp"
+	next, cmd := m.applySelectedSecret(secretOutputItem{ID: "s-2", Type: secretkind.TOTP, Ciphertext: encoded})
+	updated = next.(tuiModel)
+	if updated.mode != tuiModeTOTP || cmd == nil {
+		t.Fatalf("expected TOTP view with a tick, got mode=%v", updated.mode)
+	}
+	rendered := updated.View()
+	if !strings.Contains(rendered, "9428 7082") || !strings.Contains(rendered, "Осталось: 1 с") {
+		t.Fatalf("expected code and countdown: %s", rendered)
+	}
+
+	next, cmd = updated.handleTOTPTick(totpTickMsg{generation: updated.totpGeneration - 1})
+	if cmd != nil {
+		t.Fatalf("stale tick must not reschedule")
+	}
+	next, _ = next.(tuiModel).handleTOTPKey(tea.KeyMsg{Type: tea.KeyEsc})
+	if _, cmd = next.(tuiModel).handleTOTPTick(totpTickMsg{generation: updated.totpGeneration}); cmd != nil {
+		t.Fatalf("tick after closing the view must not reschedule")
+	}
+}
+
+func TestGenerateKeyFillsMaskedPasswordField(t *testing.T) {
+	m := tuiModel{
+		mode:          tuiModeForm,
+		authorized:    true,
+		currentAction: createKindAction(secretkind.Login),
+		fieldValues:   map[string]string{fieldKind: secretkind.Login},
+	}
+
+	next, _ := m.handleFormKey(tea.KeyMsg{Type: tea.KeyCtrlR})
+	updated := next.(tuiModel)
+	if updated.input != "" {
+		t.Fatalf("generator must not fill fields without the generate flag")
+	}
+
+	next, _ = updated.handleFormKey(tea.KeyMsg{Type: tea.KeyTab})
+	next, _ = next.(tuiModel).handleFormKey(tea.KeyMsg{Type: tea.KeyCtrlR})
+	updated = next.(tuiModel)
+	if updated.fieldIndex != 0 {
+		t.Fatalf("required username should block moving on, got field %d", updated.fieldIndex)
+	}
+
+	updated.input = "alice"
+	next, _ = updated.handleFormKey(tea.KeyMsg{Type: tea.KeyTab})
+	next, _ = next.(tuiModel).handleFormKey(tea.KeyMsg{Type: tea.KeyCtrlR})
+	updated = next.(tuiModel)
+	if len(updated.input) != 20 {
+		t.Fatalf("expected generated password in the field, got %q", updated.input)
+	}
+	if strings.Contains(updated.View(), updated.input) || strings.Contains(updated.status, updated.input) {
+		t.Fatalf("generated password must stay masked")
+	}
+
+	next, _ = updated.handleFormKey(tea.KeyMsg{Type: tea.KeyCtrlO})
+	if !strings.Contains(next.(tuiModel).View(), updated.input) {
+		t.Fatalf("Ctrl+O should reveal the generated password")
+	}
+}
+
+func TestFormatKeyMappingMatchesKeysFlag(t *testing.T) {
+	got := formatKeyMapping(map[string]string{"username": "DB_USER", "password": "DB_PASSWORD"})
+	if got != "password=DB_PASSWORD,username=DB_USER" {
+		t.Fatalf("unexpected key mapping: %q", got)
+	}
+	if formatKeyMapping(nil) != "" {
+		t.Fatalf("expected no mapping to render empty")
+	}
+}
+
+func TestLoadSecretsForSelectionReadsOfflineCache(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(dir, "session.json"))
+	// No server is reachable at this address: the list must come from the cache.
+	sess := `{"server_url":"http://127.0.0.1:1","user_id":"u-1","access_token":"a","refresh_token":"r"}`
+	if err := os.WriteFile(filepath.Join(dir, "session.json"), []byte(sess), 0o600); err != nil {
+		t.Fatalf("write session: %v", err)
+	}
+	if err := vaultcache.NewStore(dir, "u-1").Update(func(c *vaultcache.Cache) error {
+		c.Replace([]dtosecret.SecretResponse{{ID: "s-1", Type: secretkind.Note, MetaOpen: models.MetaOpen{Title: "cached"}, UpdatedAt: "2026-03-01T10:00:00Z"}})
+		return nil
+	}); err != nil {
+		t.Fatalf("seed cache: %v", err)
+	}
+
+	items, err := loadSecretsForSelection(nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != "s-1" {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+}
+
+func TestConflictsKeyRecordsDecision(t *testing.T) {
+	mine := secretOutputItem{Type: "note", MetaOpen: secretOutputMeta{Title: "wifi"}, Ciphertext: encodeSecretData(`{"text":"mine"}`)}
+	theirs := secretOutputItem{Type: "note", MetaOpen: secretOutputMeta{Title: "home wifi"}, Ciphertext: encodeSecretData(`{"text":"theirs"}`)}
+	m := tuiModel{
+		mode:       tuiModeConflicts,
+		authorized: true,
+		conflictItems: []conflictItem{
+			{SecretID: "s-1", Title: "wifi", Fields: []string{"payload", "title"}, Mine: &mine, Theirs: &theirs},
+			{SecretID: "s-2", Title: "door", Fields: []string{"deleted"}},
+		},
+	}
+
+	rendered := m.View()
+	for _, want := range []string{"Расходятся: данные, заголовок", "Заголовок: home wifi -> wifi", "M оставить мою"} {
+		if !strings.Contains(rendered, want) {
+			t.Fatalf("expected %q in view:\n%s", want, rendered)
+		}
+	}
+
+	next, cmd := m.handleConflictsKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if cmd != nil || next.(tuiModel).mode != tuiModeConflicts {
+		t.Fatal("unknown keys must not resolve anything")
+	}
+	next, _ = m.handleConflictsKey(tea.KeyMsg{Type: tea.KeyDown})
+	next, cmd = next.(tuiModel).handleConflictsKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	updated := next.(tuiModel)
+	if cmd == nil || updated.mode != tuiModeMenu || updated.conflictItems != nil {
+		t.Fatalf("expected the decision to be sent, got mode=%v cmd=%v", updated.mode, cmd != nil)
+	}
+}
+
+func TestRunRoutesAgentCommandsToCLI(t *testing.T) {
+	t.Setenv("PKEEPER_AGENT_SOCK", filepath.Join(t.TempDir(), "agent.sock"))
+	var stdout, stderr strings.Builder
+	if code := NewTUI(&stdout, &stderr).Run([]string{"agent", "status"}); code != 0 {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"running": false`) {
+		t.Fatalf("unexpected agent status: %s", stdout.String())
+	}
+}
+
+func TestSwitchingProfileReplacesEventStream(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PKEEPER_SESSION_PATH", filepath.Join(dir, "session.json"))
+	t.Setenv("PKEEPER_PROFILE", "")
+	t.Cleanup(func() { _ = cli.SelectProfile("default") })
+	sess := `{"server_url":"http://127.0.0.1:1","user_id":"u-1","access_token":"a","refresh_token":"r"}`
+	if err := os.WriteFile(filepath.Join(dir, "session.json"), []byte(sess), 0o600); err != nil {
+		t.Fatalf("write session: %v", err)
+	}
+
+	cancelled := false
+	stream := &eventStream{changes: make(chan struct{}, 1), done: make(chan error, 1), cancel: func() { cancelled = true }}
+	m := tuiModel{authorized: true, autoSync: true, profile: "default", stream: stream}
+
+	output, err := runTUIAction("profile", map[string]string{"name": "work"})
+	if err != nil {
+		t.Fatalf("switch profile: %v", err)
+	}
+	if !strings.Contains(output, "Профили: default, work") {
+		t.Fatalf("unexpected output: %s", output)
+	}
+	updated, _ := m.handleOperationResult(operationResultMsg{ActionID: "profile", Output: output})
+	m = updated.(tuiModel)
+	if !cancelled || m.stream != nil {
+		t.Fatal("the old profile's event stream must be stopped")
+	}
+	if m.profile != "work" || m.authorized || m.autoSync {
+		t.Fatalf("expected a signed-out work profile, got profile=%s authorized=%v", m.profile, m.authorized)
+	}
+	if !strings.Contains(m.View(), "профиль work") {
+		t.Fatalf("expected the profile in the header:\n%s", m.View())
+	}
+
+	if _, err := runTUIAction("profile", map[string]string{"name": "default"}); err != nil {
+		t.Fatalf("switch back: %v", err)
+	}
+	updated, cmd := m.handleOperationResult(operationResultMsg{ActionID: "profile"})
+	m = updated.(tuiModel)
+	if !m.authorized || cmd == nil {
+		t.Fatalf("expected the signed-in default profile to resume syncing, authorized=%v", m.authorized)
+	}
+}
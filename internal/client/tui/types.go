@@ -1,10 +1,13 @@
 package tui
 
-const defaultSecretType = "note"
+import "github.com/7StaSH7/practicum-diploma/internal/totp"
+
 const actionUpdateSelected = "update_selected_secret"
 const fieldFindTitle = "find_title"
 const fieldFindTags = "find_tags"
 const fieldFindDate = "find_date"
+const actionAttachFile = "attach_file"
+const actionDownloadAttachment = "download_attachment"
 
 type tuiMode int
 
@@ -13,6 +16,11 @@ const (
 	tuiModeForm
 	tuiModeSelect
 	tuiModeConfirmDelete
+	tuiModeHistory
+	tuiModeTrash
+	tuiModeConflicts
+	tuiModeAttachments
+	tuiModeTOTP
 )
 
 type tuiField struct {
@@ -21,6 +29,8 @@ type tuiField struct {
 	Hint     string
 	Required bool
 	Secret   bool
+	// Generate lets the user fill the field with a generated password.
+	Generate bool
 }
 
 type tuiAction struct {
@@ -49,6 +59,14 @@ var tuiActions = []tuiAction{
 			{Key: "password", Label: "Пароль", Required: true, Secret: true},
 		},
 	},
+	{
+		ID:          "profile",
+		Title:       "Сменить Профиль",
+		Description: "Переключиться на другой сервер или учётную запись; новый профиль создаётся при первом выборе",
+		Fields: []tuiField{
+			{Key: "name", Label: "Профиль", Hint: "Например: work. Основной профиль: default", Required: true},
+		},
+	},
 	{
 		ID:          "search",
 		Title:       "Поиск Секретов",
@@ -62,9 +80,9 @@ var tuiActions = []tuiAction{
 	{
 		ID:          "create",
 		Title:       "Создать Секрет",
-		Description: "Сохранить данные (шифрование выполнится автоматически)",
+		Description: "Выбрать тип и сохранить данные (шифрование выполнится автоматически)",
 		Fields: []tuiField{
-			{Key: "data", Label: "Данные", Hint: "Любой текст, который хотите сохранить", Required: true},
+			{Key: fieldKind, Label: "Тип", Hint: kindHint()},
 			{Key: "title", Label: "Заголовок", Hint: "Короткое имя секрета"},
 			{Key: "tags", Label: "Теги через запятую", Hint: "Например: работа,почта"},
 			{Key: "site", Label: "Сайт", Hint: "Например: https://example.com"},
@@ -90,6 +108,59 @@ var tuiActions = []tuiAction{
 			{Key: fieldFindDate, Label: "С даты (ГГГГ-ММ-ДД)", Hint: "Например: 2026-02-09"},
 		},
 	},
+	{
+		ID:          "history",
+		Title:       "История Версий",
+		Description: "Найти секрет, сравнить прошлые версии и восстановить нужную",
+		Fields: []tuiField{
+			{Key: fieldFindTitle, Label: "Название содержит", Hint: "Можно оставить пустым"},
+			{Key: fieldFindTags, Label: "Теги через запятую", Hint: "Например: работа,почта"},
+			{Key: fieldFindDate, Label: "С даты (ГГГГ-ММ-ДД)", Hint: "Например: 2026-02-09"},
+		},
+	},
+	{
+		ID:          "attachments",
+		Title:       "Вложения",
+		Description: "Найти секрет, прикрепить к нему файл или скачать вложение",
+		Fields: []tuiField{
+			{Key: fieldFindTitle, Label: "Название содержит", Hint: "Можно оставить пустым"},
+			{Key: fieldFindTags, Label: "Теги через запятую", Hint: "Например: работа,почта"},
+			{Key: fieldFindDate, Label: "С даты (ГГГГ-ММ-ДД)", Hint: "Например: 2026-02-09"},
+		},
+	},
+	{
+		ID:          "totp",
+		Title:       "Коды 2FA",
+		Description: "Найти TOTP-секрет и показать текущий код с обратным отсчетом",
+		Fields: []tuiField{
+			{Key: fieldFindTitle, Label: "Название содержит", Hint: "Можно оставить пустым"},
+			{Key: fieldFindTags, Label: "Теги через запятую", Hint: "Например: работа,почта"},
+			{Key: fieldFindDate, Label: "С даты (ГГГГ-ММ-ДД)", Hint: "Например: 2026-02-09"},
+		},
+	},
+	{
+		ID:          "unlock",
+		Title:       "Разблокировать Хранилище",
+		Description: "Передать мастер-пароль агенту: секреты шифруются и расшифровываются без повторного ввода",
+		Fields: []tuiField{
+			{Key: "password", Label: "Мастер-пароль", Required: true, Secret: true},
+		},
+	},
+	{
+		ID:          "lock",
+		Title:       "Заблокировать Хранилище",
+		Description: "Агент забудет ключ; секреты снова потребуют мастер-пароль",
+	},
+	{
+		ID:          "conflicts",
+		Title:       "Конфликты",
+		Description: "Разобрать офлайн-изменения, которые пересеклись с правками на сервере",
+	},
+	{
+		ID:          "trash",
+		Title:       "Корзина",
+		Description: "Восстановить удаленные секреты или удалить их навсегда",
+	},
 	{
 		ID:          "version",
 		Title:       "Версия",
@@ -108,35 +179,96 @@ type secretSelectionLoadedMsg struct {
 	Err   error
 }
 
+type secretHistoryLoadedMsg struct {
+	Items []secretVersionItem
+	Err   error
+}
+
+type trashLoadedMsg struct {
+	Items []secretOutputItem
+	Err   error
+}
+
+type conflictsLoadedMsg struct {
+	Items []conflictItem
+	Err   error
+}
+
+type attachmentsLoadedMsg struct {
+	Items []attachmentItem
+	Err   error
+}
+
 type syncTickMsg struct{}
 
+type totpTickMsg struct {
+	generation int
+}
+
 var updateSelectedAction = tuiAction{
 	ID:          actionUpdateSelected,
 	Title:       "Обновить выбранный секрет",
 	Description: "Измените только нужные поля. Пусто = оставить как есть",
 	Fields: []tuiField{
-		{Key: "data", Label: "Новые данные", Hint: "Пусто = оставить текущие данные"},
 		{Key: "title", Label: "Заголовок", Hint: "Пусто = оставить, '-' = очистить"},
 		{Key: "tags", Label: "Теги через запятую", Hint: "Пусто = оставить, '-' = очистить"},
 		{Key: "site", Label: "Сайт", Hint: "Пусто = оставить, '-' = очистить"},
 	},
 }
 
+var attachFileAction = tuiAction{
+	ID:          actionAttachFile,
+	Title:       "Прикрепить файл",
+	Description: "Загрузить файл с диска и привязать его к выбранному секрету",
+	Fields: []tuiField{
+		{Key: "file", Label: "Путь к файлу", Hint: "Например: /home/alice/.ssh/id_ed25519", Required: true},
+		{Key: "name", Label: "Имя вложения", Hint: "Пусто = имя файла"},
+	},
+}
+
+var downloadAttachmentAction = tuiAction{
+	ID:          actionDownloadAttachment,
+	Title:       "Скачать вложение",
+	Description: "Сохранить вложение в файл",
+	Fields: []tuiField{
+		{Key: "out", Label: "Куда сохранить", Hint: "Пусто = имя вложения в текущей папке"},
+	},
+}
+
 type tuiModel struct {
-	mode             tuiMode
-	cursor           int
-	authorized       bool
-	currentAction    tuiAction
-	selectedSecret   secretOutputItem
-	fieldIndex       int
-	fieldValues      map[string]string
-	selectionAction  string
-	selectionFilters map[string]string
-	selectionItems   []secretOutputItem
-	selectionCursor  int
-	input            string
-	status           string
-	output           string
-	autoSync         bool
-	syncInFlight     bool
+	mode               tuiMode
+	cursor             int
+	authorized         bool
+	profile            string
+	currentAction      tuiAction
+	selectedSecret     secretOutputItem
+	fieldIndex         int
+	fieldValues        map[string]string
+	selectionAction    string
+	selectionFilters   map[string]string
+	selectionItems     []secretOutputItem
+	selectionCursor    int
+	historyItems       []secretVersionItem
+	historyCursor      int
+	trashItems         []secretOutputItem
+	trashCursor        int
+	trashConfirm       bool
+	conflictItems      []conflictItem
+	conflictCursor     int
+	attachmentItems    []attachmentItem
+	attachmentCursor   int
+	attachmentConfirm  bool
+	attachmentsLoaded  bool
+	selectedAttachment attachmentItem
+	totpKey            totp.Key
+	totpGeneration     int
+	input              string
+	revealInput        bool
+	status             string
+	output             string
+	autoSync           bool
+	syncInFlight       bool
+	syncPending        bool
+	stream             *eventStream
+	streamUnsupported  bool
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -22,7 +23,7 @@ func (m tuiModel) View() string {
 	if m.authorized {
 		authState = "АВТОРИЗОВАН"
 	}
-	b.WriteString(panelStyle.Render("Сессия: " + authState))
+	b.WriteString(panelStyle.Render("Сессия: " + authState + " · профиль " + m.profile))
 	b.WriteString("\n\n")
 
 	if m.mode == tuiModeMenu {
@@ -31,6 +32,16 @@ func (m tuiModel) View() string {
 		b.WriteString(m.renderSecretSelection(panelStyle, mutedStyle, descriptionStyle, hintStyle))
 	} else if m.mode == tuiModeConfirmDelete {
 		b.WriteString(m.renderDeleteConfirm(panelStyle, mutedStyle, descriptionStyle, hintStyle))
+	} else if m.mode == tuiModeHistory {
+		b.WriteString(m.renderHistory(panelStyle, mutedStyle, descriptionStyle, hintStyle))
+	} else if m.mode == tuiModeTrash {
+		b.WriteString(m.renderTrash(panelStyle, mutedStyle, descriptionStyle, hintStyle))
+	} else if m.mode == tuiModeConflicts {
+		b.WriteString(m.renderConflicts(panelStyle, mutedStyle, descriptionStyle, hintStyle))
+	} else if m.mode == tuiModeAttachments {
+		b.WriteString(m.renderAttachments(panelStyle, mutedStyle, descriptionStyle, hintStyle))
+	} else if m.mode == tuiModeTOTP {
+		b.WriteString(m.renderTOTP(panelStyle, mutedStyle, descriptionStyle, hintStyle))
 	} else {
 		b.WriteString(m.renderForm(panelStyle, mutedStyle, descriptionStyle, hintStyle))
 	}
@@ -105,7 +116,7 @@ func (m tuiModel) renderForm(panelStyle, mutedStyle, descriptionStyle, hintStyle
 		if i == m.fieldIndex {
 			value = m.input
 		}
-		if f.Secret {
+		if f.Secret && (i != m.fieldIndex || !m.revealInput) {
 			value = strings.Repeat("*", len(value))
 		}
 		req := "необязательно"
@@ -128,6 +139,10 @@ func (m tuiModel) renderForm(panelStyle, mutedStyle, descriptionStyle, hintStyle
 		b.WriteString(hintStyle.Render("Подсказка: " + currentField.Hint))
 		b.WriteString("\n")
 	}
+	if currentField.Generate {
+		b.WriteString(hintStyle.Render("Генератор: Ctrl+R пароль | Ctrl+E парольная фраза | Ctrl+O показать/скрыть"))
+		b.WriteString("\n")
+	}
 	b.WriteString(hintStyle.Render("Можно нажать Ctrl+G на любом шаге, чтобы сразу выполнить команду"))
 	return b.String()
 }
@@ -136,7 +151,7 @@ func (m tuiModel) renderDeleteConfirm(panelStyle, mutedStyle, descriptionStyle,
 	var b strings.Builder
 	b.WriteString(descriptionStyle.Render("Подтверждение удаления"))
 	b.WriteString("\n")
-	b.WriteString(mutedStyle.Render("Проверьте секрет перед удалением. Это действие необратимо."))
+	b.WriteString(mutedStyle.Render("Проверьте секрет перед удалением. Его можно будет восстановить из корзины."))
 	b.WriteString("\n\n")
 	b.WriteString(panelStyle.Render(
 		"Секрет: " + secretDisplayTitle(m.selectedSecret) + "\n" +
@@ -194,18 +209,213 @@ func (m tuiModel) renderSecretSelection(panelStyle, mutedStyle, descriptionStyle
 
 	selected := m.selectionItems[m.selectionCursor]
 	b.WriteString("\n\n")
-	b.WriteString(panelStyle.Render("Выбран: " + secretDisplayTitle(selected) + "\nДанные: " + decodeSecretData(selected.Ciphertext)))
+	b.WriteString(panelStyle.Render("Выбран: " + secretDisplayTitle(selected) + "\nДанные: " + secretSummary(selected)))
 	b.WriteString("\n")
 	b.WriteString(hintStyle.Render("Клавиши: Enter выбрать | Up/Down перемещение | 1-9 быстрый выбор | Esc отмена"))
 	return b.String()
 }
 
+func (m tuiModel) renderHistory(panelStyle, mutedStyle, descriptionStyle, hintStyle lipgloss.Style) string {
+	var b strings.Builder
+	b.WriteString(descriptionStyle.Render("История версий: " + secretDisplayTitle(m.selectedSecret)))
+	b.WriteString("\n")
+	b.WriteString(mutedStyle.Render(fmt.Sprintf("Текущая версия: %d", m.selectedSecret.Version)))
+	b.WriteString("\n\n")
+
+	if len(m.historyItems) == 0 {
+		b.WriteString(panelStyle.Render("Загружаю прошлые версии..."))
+		return b.String()
+	}
+
+	m.ensureHistoryCursor()
+	start := 0
+	if m.historyCursor > 5 {
+		start = m.historyCursor - 5
+	}
+	end := minInt(len(m.historyItems), start+10)
+	if end-start < 10 {
+		start = maxInt(0, end-10)
+	}
+	for i := start; i < end; i++ {
+		item := m.historyItems[i]
+		prefix := "  "
+		if i == m.historyCursor {
+			prefix = "> "
+		}
+		line := fmt.Sprintf("%sВерсия %d | %s", prefix, item.Version, fallbackText(item.UpdatedAt))
+		if i == m.historyCursor {
+			line = lipgloss.NewStyle().Bold(true).Render(line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	selected := m.historyItems[m.historyCursor]
+	b.WriteString("\n")
+	b.WriteString(panelStyle.Render(fmt.Sprintf("Версия %d -> текущая\n", selected.Version) + renderSecretVersionDiff(selected, m.selectedSecret)))
+	b.WriteString("\n")
+	b.WriteString(hintStyle.Render("Клавиши: Up/Down выбор версии | R восстановить | Esc назад"))
+	return b.String()
+}
+
+func (m tuiModel) renderTrash(panelStyle, mutedStyle, descriptionStyle, hintStyle lipgloss.Style) string {
+	var b strings.Builder
+	b.WriteString(descriptionStyle.Render("Корзина"))
+	b.WriteString("\n")
+	b.WriteString(mutedStyle.Render("Удаленные секреты хранятся ограниченное время, затем удаляются автоматически"))
+	b.WriteString("\n\n")
+
+	if len(m.trashItems) == 0 {
+		b.WriteString(panelStyle.Render("Загружаю содержимое корзины..."))
+		return b.String()
+	}
+
+	m.ensureTrashCursor()
+	start := 0
+	if m.trashCursor > 5 {
+		start = m.trashCursor - 5
+	}
+	end := minInt(len(m.trashItems), start+10)
+	if end-start < 10 {
+		start = maxInt(0, end-10)
+	}
+	for i := start; i < end; i++ {
+		item := m.trashItems[i]
+		prefix := "  "
+		if i == m.trashCursor {
+			prefix = "> "
+		}
+		line := fmt.Sprintf("%s%d. %s", prefix, i+1, secretDisplayTitle(item))
+		if i == m.trashCursor {
+			line = lipgloss.NewStyle().Bold(true).Render(line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		b.WriteString("   ")
+		b.WriteString(descriptionStyle.Render("Удален: " + fallbackText(item.DeletedAt)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	if m.trashConfirm {
+		selected := m.trashItems[m.trashCursor]
+		b.WriteString(panelStyle.Render("Удалить навсегда: " + secretDisplayTitle(selected) + "\nЭто действие необратимо."))
+		b.WriteString("\n")
+		b.WriteString(hintStyle.Render("Нажмите Enter или Y, чтобы удалить | N или Esc, чтобы отменить"))
+		return b.String()
+	}
+	b.WriteString(hintStyle.Render("Клавиши: R/Enter восстановить | D удалить навсегда | Up/Down перемещение | Esc назад"))
+	return b.String()
+}
+
+func (m tuiModel) renderConflicts(panelStyle, mutedStyle, descriptionStyle, hintStyle lipgloss.Style) string {
+	var b strings.Builder
+	b.WriteString(descriptionStyle.Render("Конфликты"))
+	b.WriteString("\n")
+	b.WriteString(mutedStyle.Render("Эти офлайн-изменения пересеклись с правками на сервере и ждут вашего решения"))
+	b.WriteString("\n\n")
+
+	if len(m.conflictItems) == 0 {
+		b.WriteString(panelStyle.Render("Загружаю конфликты..."))
+		return b.String()
+	}
+
+	m.ensureConflictCursor()
+	start := 0
+	if m.conflictCursor > 5 {
+		start = m.conflictCursor - 5
+	}
+	end := minInt(len(m.conflictItems), start+10)
+	if end-start < 10 {
+		start = maxInt(0, end-10)
+	}
+	for i := start; i < end; i++ {
+		item := m.conflictItems[i]
+		prefix := "  "
+		if i == m.conflictCursor {
+			prefix = "> "
+		}
+		line := fmt.Sprintf("%s%d. %s", prefix, i+1, conflictDisplayTitle(item))
+		if i == m.conflictCursor {
+			line = lipgloss.NewStyle().Bold(true).Render(line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		b.WriteString("   ")
+		b.WriteString(descriptionStyle.Render("Расходятся: " + fallbackText(conflictFieldsText(item.Fields))))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(panelStyle.Render(renderConflictDiff(m.conflictItems[m.conflictCursor])))
+	b.WriteString("\n")
+	b.WriteString(hintStyle.Render("Клавиши: M оставить мою | T оставить с сервера | B сохранить обе | Up/Down перемещение | Esc назад"))
+	return b.String()
+}
+
+func (m tuiModel) renderAttachments(panelStyle, mutedStyle, descriptionStyle, hintStyle lipgloss.Style) string {
+	var b strings.Builder
+	b.WriteString(descriptionStyle.Render("Вложения: " + secretDisplayTitle(m.selectedSecret)))
+	b.WriteString("\n\n")
+
+	if !m.attachmentsLoaded {
+		b.WriteString(panelStyle.Render("Загружаю вложения..."))
+		return b.String()
+	}
+	if len(m.attachmentItems) == 0 {
+		b.WriteString(panelStyle.Render("У секрета нет вложений"))
+		b.WriteString("\n")
+		b.WriteString(hintStyle.Render("Клавиши: A прикрепить файл | Esc назад"))
+		return b.String()
+	}
+
+	m.ensureAttachmentCursor()
+	start := 0
+	if m.attachmentCursor > 5 {
+		start = m.attachmentCursor - 5
+	}
+	end := minInt(len(m.attachmentItems), start+10)
+	if end-start < 10 {
+		start = maxInt(0, end-10)
+	}
+	for i := start; i < end; i++ {
+		item := m.attachmentItems[i]
+		prefix := "  "
+		if i == m.attachmentCursor {
+			prefix = "> "
+		}
+		line := fmt.Sprintf("%s%d. %s", prefix, i+1, attachmentLine(item))
+		if i == m.attachmentCursor {
+			line = lipgloss.NewStyle().Bold(true).Render(line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	if m.attachmentConfirm {
+		selected := m.attachmentItems[m.attachmentCursor]
+		b.WriteString(panelStyle.Render("Удалить вложение: " + selected.Name + "\nЭто действие необратимо."))
+		b.WriteString("\n")
+		b.WriteString(hintStyle.Render("Нажмите Enter или Y, чтобы удалить | N или Esc, чтобы отменить"))
+		return b.String()
+	}
+	b.WriteString(mutedStyle.Render("Добавлено: " + fallbackText(m.attachmentItems[m.attachmentCursor].CreatedAt)))
+	b.WriteString("\n")
+	b.WriteString(hintStyle.Render("Клавиши: S/Enter скачать | A прикрепить файл | D удалить | Up/Down перемещение | Esc назад"))
+	return b.String()
+}
+
 func selectionActionLabel(actionID string) string {
 	switch actionID {
 	case "update":
 		return "Выбор секрета для обновления"
 	case "delete":
 		return "Выбор секрета для удаления"
+	case "history":
+		return "Выбор секрета для просмотра истории"
+	case "attachments":
+		return "Выбор секрета для работы с вложениями"
 	default:
 		return "Выбор секрета"
 	}
@@ -256,3 +466,19 @@ func maxInt(a, b int) int {
 	}
 	return b
 }
+
+func (m tuiModel) renderTOTP(panelStyle, mutedStyle, descriptionStyle, hintStyle lipgloss.Style) string {
+	var b strings.Builder
+	b.WriteString(descriptionStyle.Render("Код 2FA: " + secretDisplayTitle(m.selectedSecret)))
+	b.WriteString("\n")
+	b.WriteString(mutedStyle.Render(totpKeyLabel(m.totpKey) + " | " + totpParameters(m.totpKey)))
+	b.WriteString("\n\n")
+
+	now := totpNow()
+	remaining := m.totpKey.Remaining(now)
+	code := lipgloss.NewStyle().Bold(true).Render(formatTOTPCode(m.totpKey.Code(now)))
+	b.WriteString(panelStyle.Render(code + fmt.Sprintf("\nОсталось: %d с %s", int(remaining/time.Second), totpProgress(remaining, m.totpKey.Period))))
+	b.WriteString("\n")
+	b.WriteString(hintStyle.Render("Клавиши: Esc назад"))
+	return b.String()
+}
@@ -18,6 +18,15 @@ type Config struct {
 	AccessTTL      time.Duration
 	RefreshTTL     time.Duration
 	MigrationsPath string
+	HistoryLimit   int
+	HistoryTTL     time.Duration
+	TrashTTL       time.Duration
+	TrashPurge     time.Duration
+	IdempotencyTTL time.Duration
+	EventsTTL      time.Duration
+	BlobStore      string
+	BlobDir        string
+	AttachmentMax  int64
 }
 
 func Load() (Config, error) {
@@ -28,6 +37,15 @@ func Load() (Config, error) {
 	v.SetDefault("ACCESS_TTL", 15*time.Minute)
 	v.SetDefault("REFRESH_TTL", 7*24*time.Hour)
 	v.SetDefault("MIGRATIONS_PATH", "migrations")
+	v.SetDefault("HISTORY_LIMIT", 20)
+	v.SetDefault("HISTORY_TTL", 90*24*time.Hour)
+	v.SetDefault("TRASH_TTL", 30*24*time.Hour)
+	v.SetDefault("TRASH_PURGE_INTERVAL", time.Hour)
+	v.SetDefault("IDEMPOTENCY_TTL", 24*time.Hour)
+	v.SetDefault("EVENTS_TTL", 7*24*time.Hour)
+	v.SetDefault("BLOB_STORE", "postgres")
+	v.SetDefault("BLOB_DIR", "data/blobs")
+	v.SetDefault("ATTACHMENT_MAX_SIZE", int64(64<<20))
 	v.SetConfigFile(".env")
 	if err := v.ReadInConfig(); err != nil {
 		var configFileNotFoundError viper.ConfigFileNotFoundError
@@ -44,6 +62,15 @@ func Load() (Config, error) {
 		AccessTTL:      v.GetDuration("ACCESS_TTL"),
 		RefreshTTL:     v.GetDuration("REFRESH_TTL"),
 		MigrationsPath: v.GetString("MIGRATIONS_PATH"),
+		HistoryLimit:   v.GetInt("HISTORY_LIMIT"),
+		HistoryTTL:     v.GetDuration("HISTORY_TTL"),
+		TrashTTL:       v.GetDuration("TRASH_TTL"),
+		TrashPurge:     v.GetDuration("TRASH_PURGE_INTERVAL"),
+		IdempotencyTTL: v.GetDuration("IDEMPOTENCY_TTL"),
+		EventsTTL:      v.GetDuration("EVENTS_TTL"),
+		BlobStore:      v.GetString("BLOB_STORE"),
+		BlobDir:        v.GetString("BLOB_DIR"),
+		AttachmentMax:  v.GetInt64("ATTACHMENT_MAX_SIZE"),
 	}
 	return cfg, nil
 }
@@ -58,6 +85,15 @@ func BindFlags(fs *flag.FlagSet, cfg *Config) {
 	fs.DurationVar(&cfg.AccessTTL, "access-ttl", cfg.AccessTTL, "JWT access token TTL")
 	fs.DurationVar(&cfg.RefreshTTL, "refresh-ttl", cfg.RefreshTTL, "Refresh token TTL")
 	fs.StringVar(&cfg.MigrationsPath, "migrations-path", cfg.MigrationsPath, "Migrations directory")
+	fs.IntVar(&cfg.HistoryLimit, "history-limit", cfg.HistoryLimit, "Secret versions kept per secret (0 = unlimited)")
+	fs.DurationVar(&cfg.HistoryTTL, "history-ttl", cfg.HistoryTTL, "Secret version retention (0 = unlimited)")
+	fs.DurationVar(&cfg.TrashTTL, "trash-ttl", cfg.TrashTTL, "How long deleted secrets stay in the trash (0 = forever)")
+	fs.DurationVar(&cfg.TrashPurge, "trash-purge-interval", cfg.TrashPurge, "Interval between trash purge runs")
+	fs.DurationVar(&cfg.IdempotencyTTL, "idempotency-ttl", cfg.IdempotencyTTL, "How long Idempotency-Key responses are replayed")
+	fs.DurationVar(&cfg.EventsTTL, "events-ttl", cfg.EventsTTL, "How long secret events are kept for stream resume (0 = forever)")
+	fs.StringVar(&cfg.BlobStore, "blob-store", cfg.BlobStore, "Attachment blob store: postgres or fs")
+	fs.StringVar(&cfg.BlobDir, "blob-dir", cfg.BlobDir, "Directory for the fs blob store")
+	fs.Int64Var(&cfg.AttachmentMax, "attachment-max-size", cfg.AttachmentMax, "Maximum attachment size in bytes")
 }
 
 func ResolveHTTPAddr(serverURL string) string {
@@ -0,0 +1,17 @@
+package attachment
+
+type CreateAttachmentRequest struct {
+	Name string `json:"name" binding:"required"`
+	Size int64  `json:"size" binding:"required"`
+}
+
+type AttachmentResponse struct {
+	ID          string `json:"id"`
+	SecretID    string `json:"secret_id"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	Received    int64  `json:"received"`
+	Complete    bool   `json:"complete"`
+	CreatedAt   string `json:"created_at"`
+	CompletedAt string `json:"completed_at,omitempty"`
+}
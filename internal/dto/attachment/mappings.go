@@ -0,0 +1,23 @@
+package attachment
+
+import (
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+)
+
+func ToAttachmentResponse(attachment models.Attachment) AttachmentResponse {
+	response := AttachmentResponse{
+		ID:        attachment.ID.String(),
+		SecretID:  attachment.SecretID.String(),
+		Name:      attachment.Name,
+		Size:      attachment.Size,
+		Received:  attachment.Received,
+		Complete:  !attachment.CompletedAt.IsZero(),
+		CreatedAt: attachment.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if response.Complete {
+		response.CompletedAt = attachment.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	return response
+}
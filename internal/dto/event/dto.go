@@ -0,0 +1,9 @@
+package event
+
+type EventResponse struct {
+	Seq      int64  `json:"seq"`
+	Type     string `json:"type"`
+	SecretID string `json:"secret_id"`
+	Version  int64  `json:"version"`
+	At       string `json:"at"`
+}
@@ -0,0 +1,17 @@
+package event
+
+import (
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+)
+
+func ToEventResponse(event models.SecretEvent) EventResponse {
+	return EventResponse{
+		Seq:      event.Seq,
+		Type:     event.Kind,
+		SecretID: event.SecretID.String(),
+		Version:  event.Version,
+		At:       event.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -1,6 +1,9 @@
 package secret
 
-import "github.com/7StaSH7/practicum-diploma/internal/models"
+import (
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/google/uuid"
+)
 
 type SecretPayload struct {
 	Type       string          `json:"type"`
@@ -21,4 +24,79 @@ type SecretResponse struct {
 	Ciphertext string          `json:"ciphertext"`
 	Version    int64           `json:"version"`
 	UpdatedAt  string          `json:"updated_at"`
+	DeletedAt  string          `json:"deleted_at,omitempty"`
+}
+
+type SecretVersionResponse struct {
+	SecretID   string          `json:"secret_id"`
+	Version    int64           `json:"version"`
+	Type       string          `json:"type"`
+	MetaOpen   models.MetaOpen `json:"meta_open"`
+	Ciphertext string          `json:"ciphertext"`
+	UpdatedAt  string          `json:"updated_at"`
+	ArchivedAt string          `json:"archived_at"`
+}
+
+type RestoreRequest struct {
+	Version int64 `json:"version"`
+}
+
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+
+	BatchModeAtomic  = "atomic"
+	BatchModePerItem = "per_item"
+
+	BatchStatusOK         = "ok"
+	BatchStatusFailed     = "failed"
+	BatchStatusRolledBack = "rolled_back"
+)
+
+type BatchOperation struct {
+	Op              string         `json:"op"`
+	ID              string         `json:"id,omitempty"`
+	ExpectedVersion int64          `json:"expected_version,omitempty"`
+	Secret          *SecretPayload `json:"secret,omitempty"`
+}
+
+type BatchRequest struct {
+	Mode       string           `json:"mode,omitempty"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchItemResult struct {
+	Index  int             `json:"index"`
+	Op     string          `json:"op"`
+	ID     string          `json:"id,omitempty"`
+	Status string          `json:"status"`
+	Error  string          `json:"error,omitempty"`
+	Secret *SecretResponse `json:"secret,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode      string            `json:"mode"`
+	Committed bool              `json:"committed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+type BatchItemInput struct {
+	Op              string
+	ID              uuid.UUID
+	ExpectedVersion int64
+	Payload         SecretInput
+}
+
+type BatchInput struct {
+	Atomic bool
+	Items  []BatchItemInput
+}
+
+type BatchItemOutcome struct {
+	Op      string
+	ID      uuid.UUID
+	Secret  models.Secret
+	Applied bool
+	Err     error
 }
@@ -2,14 +2,16 @@ package secret
 
 import (
 	"encoding/base64"
+	"fmt"
 	"time"
 
 	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/google/uuid"
 )
 
 func ToSecretResponse(secret models.Secret) SecretResponse {
 	updated := secret.UpdatedAt.UTC().Format(time.RFC3339)
-	return SecretResponse{
+	resp := SecretResponse{
 		ID:         secret.ID.String(),
 		Type:       secret.Type,
 		MetaOpen:   secret.MetaOpen,
@@ -17,6 +19,10 @@ func ToSecretResponse(secret models.Secret) SecretResponse {
 		Version:    secret.Version,
 		UpdatedAt:  updated,
 	}
+	if !secret.DeletedAt.IsZero() {
+		resp.DeletedAt = secret.DeletedAt.UTC().Format(time.RFC3339)
+	}
+	return resp
 }
 
 func ToSecretInput(payload SecretPayload) SecretInput {
@@ -26,3 +32,47 @@ func ToSecretInput(payload SecretPayload) SecretInput {
 		Ciphertext: payload.Ciphertext,
 	}
 }
+
+func ToSecretVersionResponse(version models.SecretVersion) SecretVersionResponse {
+	return SecretVersionResponse{
+		SecretID:   version.SecretID.String(),
+		Version:    version.Version,
+		Type:       version.Type,
+		MetaOpen:   version.MetaOpen,
+		Ciphertext: base64.StdEncoding.EncodeToString(version.Ciphertext),
+		UpdatedAt:  version.UpdatedAt.UTC().Format(time.RFC3339),
+		ArchivedAt: version.ArchivedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func ToBatchInput(req BatchRequest) (BatchInput, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = BatchModeAtomic
+	}
+	if mode != BatchModeAtomic && mode != BatchModePerItem {
+		return BatchInput{}, fmt.Errorf("unknown batch mode: %s", req.Mode)
+	}
+	input := BatchInput{
+		Atomic: mode == BatchModeAtomic,
+		Items:  make([]BatchItemInput, 0, len(req.Operations)),
+	}
+	for i, op := range req.Operations {
+		item := BatchItemInput{
+			Op:              op.Op,
+			ExpectedVersion: op.ExpectedVersion,
+		}
+		if op.Op != BatchOpCreate {
+			id, err := uuid.Parse(op.ID)
+			if err != nil {
+				return BatchInput{}, fmt.Errorf("operation %d: invalid id: %w", i, err)
+			}
+			item.ID = id
+		}
+		if op.Secret != nil {
+			item.Payload = ToSecretInput(*op.Secret)
+		}
+		input.Items = append(input.Items, item)
+	}
+	return input, nil
+}
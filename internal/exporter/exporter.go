@@ -0,0 +1,213 @@
+// Package exporter renders decrypted secrets as configuration for other
+// tools: dotenv files, flat JSON or YAML maps and Kubernetes Secret
+// manifests. Every secret contributes one variable per mapped field, named
+// after its title unless its metadata maps fields to explicit keys.
+package exporter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+const (
+	FormatDotenv    = "dotenv"
+	FormatJSON      = "json"
+	FormatYAML      = "yaml"
+	FormatK8sSecret = "k8s-secret"
+)
+
+// DefaultSecretName names Kubernetes Secrets when no name is given.
+const DefaultSecretName = "pkeeper"
+
+var (
+	ErrUnknownFormat = errors.New("unknown export format")
+	ErrInvalidKey    = errors.New("invalid variable name")
+	ErrDuplicateKey  = errors.New("duplicate variable name")
+)
+
+var (
+	keyPattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	namePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+)
+
+// Var is one rendered variable.
+type Var struct {
+	Key   string
+	Value string
+}
+
+// Formats lists the supported formats.
+func Formats() []string {
+	return []string{FormatDotenv, FormatJSON, FormatYAML, FormatK8sSecret}
+}
+
+// ValidKey reports whether key can be used as a variable name in every
+// format.
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// KeyFromTitle turns a title such as "db password" into DB_PASSWORD.
+// Titles without ASCII letters or digits give an empty key.
+func KeyFromTitle(title string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.TrimSpace(title) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			underscore = false
+			b.WriteRune(r)
+		default:
+			underscore = true
+		}
+	}
+	key := strings.ToUpper(b.String())
+	if key != "" && key[0] >= '0' && key[0] <= '9' {
+		key = "_" + key
+	}
+	return key
+}
+
+// Variables maps one secret to variables. Without explicit keys the primary
+// field is exported under the title-derived name.
+func Variables(meta models.MetaOpen, payload secretkind.Payload) ([]Var, error) {
+	if len(meta.Keys) == 0 {
+		key := KeyFromTitle(meta.Title)
+		if key == "" {
+			return nil, fmt.Errorf("%w: title %q has no usable characters, map fields to keys explicitly", ErrInvalidKey, meta.Title)
+		}
+		value, _ := payload.Field(secretkind.PrimaryField(payload.Kind))
+		return []Var{{Key: key, Value: value}}, nil
+	}
+	vars := make([]Var, 0, len(meta.Keys))
+	for field, key := range meta.Keys {
+		if !ValidKey(key) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+		value, ok := payload.Field(field)
+		if !ok {
+			return nil, fmt.Errorf("%s secrets have no field %q", payload.Kind, field)
+		}
+		vars = append(vars, Var{Key: key, Value: value})
+	}
+	return vars, nil
+}
+
+// Options tune rendering.
+type Options struct {
+	// Name is the metadata.name of a Kubernetes Secret.
+	Name string
+}
+
+// Sorted returns vars sorted by key. Two secrets exporting the same key is
+// an error rather than a silent overwrite.
+func Sorted(vars []Var) ([]Var, error) {
+	sorted := slices.Clone(vars)
+	slices.SortFunc(sorted, func(a, b Var) int { return strings.Compare(a.Key, b.Key) })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Key == sorted[i-1].Key {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateKey, sorted[i].Key)
+		}
+	}
+	return sorted, nil
+}
+
+// Render writes vars in format, in the order of Sorted.
+func Render(w io.Writer, format string, vars []Var, opts Options) error {
+	sorted, err := Sorted(vars)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case FormatDotenv:
+		return renderDotenv(w, sorted)
+	case FormatJSON:
+		return renderJSON(w, sorted)
+	case FormatYAML:
+		return renderYAML(w, sorted)
+	case FormatK8sSecret:
+		return renderK8sSecret(w, sorted, opts)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, `$`, `\$`)
+
+func renderDotenv(w io.Writer, vars []Var) error {
+	for _, v := range vars {
+		if _, err := fmt.Fprintf(w, "%s=\"%s\"\n", v.Key, dotenvEscaper.Replace(v.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderJSON(w io.Writer, vars []Var) error {
+	values := make(map[string]string, len(vars))
+	for _, v := range vars {
+		values[v.Key] = v.Value
+	}
+	encoded, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", encoded)
+	return err
+}
+
+// yamlString quotes a value as a YAML double-quoted scalar. JSON string
+// escapes are a subset of YAML's, so any string round-trips.
+func yamlString(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func renderYAML(w io.Writer, vars []Var) error {
+	if len(vars) == 0 {
+		_, err := io.WriteString(w, "{}\n")
+		return err
+	}
+	for _, v := range vars {
+		if _, err := fmt.Fprintf(w, "%s: %s\n", v.Key, yamlString(v.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderK8sSecret(w io.Writer, vars []Var, opts Options) error {
+	name := opts.Name
+	if name == "" {
+		name = DefaultSecretName
+	}
+	if len(name) > 253 || !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid kubernetes secret name %q", name)
+	}
+	var b strings.Builder
+	b.WriteString("apiVersion: v1\nkind: Secret\nmetadata:\n")
+	fmt.Fprintf(&b, "  name: %s\n", name)
+	b.WriteString("type: Opaque\n")
+	if len(vars) == 0 {
+		b.WriteString("data: {}\n")
+	} else {
+		b.WriteString("data:\n")
+		for _, v := range vars {
+			fmt.Fprintf(&b, "  %s: %s\n", v.Key, base64.StdEncoding.EncodeToString([]byte(v.Value)))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
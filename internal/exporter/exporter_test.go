@@ -0,0 +1,73 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyFromTitle(t *testing.T) {
+	tests := map[string]string{
+		"db password":     "DB_PASSWORD",
+		"  Stripe--key! ": "STRIPE_KEY",
+		"2fa backup":      "_2FA_BACKUP",
+		"Пароль":          "",
+	}
+	for title, want := range tests {
+		assert.Equal(t, want, KeyFromTitle(title), title)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	login := secretkind.Payload{Kind: secretkind.Login, Login: &secretkind.LoginData{Username: "app", Password: "pw"}}
+
+	vars, err := Variables(models.MetaOpen{Title: "db password"}, login)
+	require.NoError(t, err)
+	assert.Equal(t, []Var{{Key: "DB_PASSWORD", Value: "pw"}}, vars)
+
+	vars, err = Variables(models.MetaOpen{Title: "db", Keys: map[string]string{"username": "DB_USER"}}, login)
+	require.NoError(t, err)
+	assert.Equal(t, []Var{{Key: "DB_USER", Value: "app"}}, vars)
+
+	_, err = Variables(models.MetaOpen{Title: "db", Keys: map[string]string{"cvv": "DB_CVV"}}, login)
+	assert.Error(t, err)
+	_, err = Variables(models.MetaOpen{Title: "db", Keys: map[string]string{"password": "db-password"}}, login)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = Variables(models.MetaOpen{Title: "Пароль"}, login)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestRenderFormats(t *testing.T) {
+	vars := []Var{{Key: "TOKEN", Value: "a\"b$c\nd"}, {Key: "DB_USER", Value: "app"}}
+
+	var dotenv bytes.Buffer
+	require.NoError(t, Render(&dotenv, FormatDotenv, vars, Options{}))
+	assert.Equal(t, "DB_USER=\"app\"\nTOKEN=\"a\\\"b\\$c\\nd\"\n", dotenv.String())
+
+	var encoded bytes.Buffer
+	require.NoError(t, Render(&encoded, FormatJSON, vars, Options{}))
+	var decoded map[string]string
+	require.NoError(t, json.Unmarshal(encoded.Bytes(), &decoded))
+	assert.Equal(t, "a\"b$c\nd", decoded["TOKEN"])
+
+	var yaml bytes.Buffer
+	require.NoError(t, Render(&yaml, FormatYAML, vars, Options{}))
+	assert.Equal(t, "DB_USER: \"app\"\nTOKEN: \"a\\\"b$c\\nd\"\n", yaml.String())
+
+	var manifest bytes.Buffer
+	require.NoError(t, Render(&manifest, FormatK8sSecret, vars[1:], Options{Name: "db-creds"}))
+	assert.Equal(t, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db-creds\ntype: Opaque\ndata:\n  DB_USER: YXBw\n", manifest.String())
+}
+
+func TestRenderRejectsDuplicatesAndBadInput(t *testing.T) {
+	var out bytes.Buffer
+	err := Render(&out, FormatDotenv, []Var{{Key: "A", Value: "1"}, {Key: "A", Value: "2"}}, Options{})
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.ErrorIs(t, Render(&out, "toml", nil, Options{}), ErrUnknownFormat)
+	assert.Error(t, Render(&out, FormatK8sSecret, nil, Options{Name: "Bad_Name"}))
+}
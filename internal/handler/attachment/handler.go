@@ -0,0 +1,231 @@
+package attachment
+
+//go:generate go run go.uber.org/mock/mockgen@latest -destination=./mocks/attachment_service_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/service/attachment Service
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	dtoattachment "github.com/7StaSH7/practicum-diploma/internal/dto/attachment"
+	"github.com/7StaSH7/practicum-diploma/internal/middleware"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	attachmentservice "github.com/7StaSH7/practicum-diploma/internal/service/attachment"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// UploadOffsetHeader carries the position of an uploaded chunk and, in
+// responses, the number of bytes the server has stored so far.
+const UploadOffsetHeader = "Upload-Offset"
+
+type Handler interface {
+	CreateAttachment(c *gin.Context)
+	ListAttachments(c *gin.Context)
+	GetAttachment(c *gin.Context)
+	UploadChunk(c *gin.Context)
+	DownloadAttachment(c *gin.Context)
+	DeleteAttachment(c *gin.Context)
+}
+
+type handler struct {
+	service attachmentservice.Service
+}
+
+func New(service attachmentservice.Service) Handler {
+	return &handler{
+		service: service,
+	}
+}
+
+func (h *handler) CreateAttachment(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	secretID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	var req dtoattachment.CreateAttachmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	attachment, err := h.service.Create(c.Request.Context(), userID, secretID, req.Name, req.Size)
+	if err != nil {
+		abortWithServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, dtoattachment.ToAttachmentResponse(attachment))
+}
+
+func (h *handler) ListAttachments(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	secretID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	attachments, err := h.service.List(c.Request.Context(), userID, secretID)
+	if err != nil {
+		abortWithServiceError(c, err)
+		return
+	}
+	response := make([]dtoattachment.AttachmentResponse, 0, len(attachments))
+	for _, attachment := range attachments {
+		response = append(response, dtoattachment.ToAttachmentResponse(attachment))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *handler) GetAttachment(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	attachment, err := h.service.Get(c.Request.Context(), userID, id)
+	if err != nil {
+		abortWithServiceError(c, err)
+		return
+	}
+	c.Header(UploadOffsetHeader, strconv.FormatInt(attachment.Received, 10))
+	c.JSON(http.StatusOK, dtoattachment.ToAttachmentResponse(attachment))
+}
+
+// UploadChunk appends the raw request body at the Upload-Offset position. A
+// chunk whose offset does not match the stored progress is rejected with 409
+// and the current attachment, so the client can resume from there.
+func (h *handler) UploadChunk(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader(UploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, attachmentservice.MaxChunkSize))
+	if err != nil {
+		_ = c.Error(err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := h.service.WriteChunk(c.Request.Context(), userID, id, offset, data)
+	if errors.Is(err, attachmentservice.ErrOffsetMismatch) {
+		_ = c.Error(err)
+		respondAttachment(c, http.StatusConflict, attachment)
+		c.Abort()
+		return
+	}
+	if err != nil {
+		abortWithServiceError(c, err)
+		return
+	}
+	respondAttachment(c, http.StatusOK, attachment)
+}
+
+func (h *handler) DownloadAttachment(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	attachment, reader, err := h.service.Open(c.Request.Context(), userID, id)
+	if err != nil {
+		abortWithServiceError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	// Large downloads may outlive the server write timeout.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.DataFromReader(http.StatusOK, attachment.Size, "application/octet-stream", reader, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}),
+	})
+}
+
+func (h *handler) DeleteAttachment(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if err := h.service.Delete(c.Request.Context(), userID, id); err != nil {
+		abortWithServiceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondAttachment(c *gin.Context, status int, attachment models.Attachment) {
+	c.Header(UploadOffsetHeader, strconv.FormatInt(attachment.Received, 10))
+	c.JSON(status, dtoattachment.ToAttachmentResponse(attachment))
+}
+
+func abortWithServiceError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	switch {
+	case errors.Is(err, attachmentservice.ErrNotFound), errors.Is(err, attachmentservice.ErrSecretNotFound):
+		c.AbortWithStatus(http.StatusNotFound)
+	case errors.Is(err, attachmentservice.ErrInvalidAttachment), errors.Is(err, attachmentservice.ErrEmptyChunk):
+		c.AbortWithStatus(http.StatusBadRequest)
+	case errors.Is(err, attachmentservice.ErrTooLarge):
+		c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+	case errors.Is(err, attachmentservice.ErrIncomplete):
+		c.AbortWithStatus(http.StatusConflict)
+	default:
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+}
+
+func userIDFromContext(c *gin.Context) (uuid.UUID, bool) {
+	value, ok := c.Get(middleware.UserIDKey)
+	if !ok {
+		return uuid.UUID{}, false
+	}
+	parsed, err := uuid.Parse(value.(string))
+	if err != nil {
+		return uuid.UUID{}, false
+	}
+	return parsed, true
+}
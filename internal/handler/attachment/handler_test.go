@@ -0,0 +1,161 @@
+package attachment
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	dtoattachment "github.com/7StaSH7/practicum-diploma/internal/dto/attachment"
+	attachmentmocks "github.com/7StaSH7/practicum-diploma/internal/handler/attachment/mocks"
+	"github.com/7StaSH7/practicum-diploma/internal/middleware"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	attachmentservice "github.com/7StaSH7/practicum-diploma/internal/service/attachment"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestRouter(t *testing.T, userID uuid.UUID) (*gin.Engine, *attachmentmocks.MockService) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	mockService := attachmentmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.POST("/secrets/:id/attachments", h.CreateAttachment)
+	r.GET("/secrets/:id/attachments", h.ListAttachments)
+	r.GET("/attachments/:id", h.GetAttachment)
+	r.PUT("/attachments/:id/content", h.UploadChunk)
+	r.GET("/attachments/:id/content", h.DownloadAttachment)
+	r.DELETE("/attachments/:id", h.DeleteAttachment)
+	return r, mockService
+}
+
+func TestCreateAttachmentRejectsOversizedFile(t *testing.T) {
+	userID := uuid.New()
+	secretID := uuid.New()
+	r, mockService := newTestRouter(t, userID)
+
+	mockService.EXPECT().Create(gomock.Any(), userID, secretID, "disk.img", int64(1<<40)).
+		Return(models.Attachment{}, attachmentservice.ErrTooLarge)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/secrets/"+secretID.String()+"/attachments",
+		strings.NewReader(`{"name":"disk.img","size":1099511627776}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestUploadChunkStoresBody(t *testing.T) {
+	userID := uuid.New()
+	id := uuid.New()
+	r, mockService := newTestRouter(t, userID)
+
+	mockService.EXPECT().WriteChunk(gomock.Any(), userID, id, int64(4), []byte("data")).
+		Return(models.Attachment{ID: id, Size: 8, Received: 8, CompletedAt: time.Now()}, nil)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/attachments/"+id.String()+"/content", bytes.NewReader([]byte("data")))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(UploadOffsetHeader, "4")
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "8", w.Header().Get(UploadOffsetHeader))
+	var response dtoattachment.AttachmentResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Complete)
+}
+
+func TestUploadChunkReportsOffsetOnConflict(t *testing.T) {
+	userID := uuid.New()
+	id := uuid.New()
+	r, mockService := newTestRouter(t, userID)
+
+	mockService.EXPECT().WriteChunk(gomock.Any(), userID, id, int64(0), []byte("data")).
+		Return(models.Attachment{ID: id, Size: 8, Received: 4}, attachmentservice.ErrOffsetMismatch)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/attachments/"+id.String()+"/content", bytes.NewReader([]byte("data")))
+	req.Header.Set(UploadOffsetHeader, "0")
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "4", w.Header().Get(UploadOffsetHeader))
+}
+
+func TestUploadChunkRequiresOffset(t *testing.T) {
+	r, _ := newTestRouter(t, uuid.New())
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/attachments/"+uuid.NewString()+"/content", bytes.NewReader([]byte("data")))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDownloadAttachmentStreamsBlob(t *testing.T) {
+	userID := uuid.New()
+	id := uuid.New()
+	r, mockService := newTestRouter(t, userID)
+
+	mockService.EXPECT().Open(gomock.Any(), userID, id).Return(
+		models.Attachment{ID: id, Name: "id_rsa", Size: 5, CompletedAt: time.Now()},
+		io.NopCloser(strings.NewReader("bytes")),
+		nil,
+	)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/attachments/"+id.String()+"/content", nil)
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bytes", w.Body.String())
+	assert.Equal(t, "5", w.Header().Get("Content-Length"))
+	assert.Equal(t, `attachment; filename=id_rsa`, w.Header().Get("Content-Disposition"))
+}
+
+func TestDownloadAttachmentIncomplete(t *testing.T) {
+	userID := uuid.New()
+	id := uuid.New()
+	r, mockService := newTestRouter(t, userID)
+
+	mockService.EXPECT().Open(gomock.Any(), userID, id).Return(models.Attachment{}, nil, attachmentservice.ErrIncomplete)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/attachments/"+id.String()+"/content", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestDeleteAttachmentNotFound(t *testing.T) {
+	userID := uuid.New()
+	id := uuid.New()
+	r, mockService := newTestRouter(t, userID)
+
+	mockService.EXPECT().Delete(gomock.Any(), userID, id).Return(attachmentservice.ErrNotFound)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/attachments/"+id.String(), nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func withUserID(userID uuid.UUID) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(middleware.UserIDKey, userID.String())
+		c.Next()
+	}
+}
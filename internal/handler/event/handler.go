@@ -0,0 +1,135 @@
+package event
+
+//go:generate go run go.uber.org/mock/mockgen@latest -destination=./mocks/event_service_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/service/event Service
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	dtoevent "github.com/7StaSH7/practicum-diploma/internal/dto/event"
+	"github.com/7StaSH7/practicum-diploma/internal/middleware"
+	eventservice "github.com/7StaSH7/practicum-diploma/internal/service/event"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+	eventBatchSize    = 100
+	heartbeatInterval = 15 * time.Second
+)
+
+type Handler interface {
+	Stream(c *gin.Context)
+}
+
+type handler struct {
+	service eventservice.Service
+}
+
+func New(service eventservice.Service) Handler {
+	return &handler{
+		service: service,
+	}
+}
+
+// Stream sends the user's secret events as Server-Sent Events. A client that
+// reconnects with Last-Event-ID (or ?last_event_id=) receives everything it
+// missed; a fresh client starts from the current position.
+func (h *handler) Stream(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	rawLastID := c.GetHeader(lastEventIDHeader)
+	if rawLastID == "" {
+		rawLastID = c.Query("last_event_id")
+	}
+
+	ctx := c.Request.Context()
+	wake, unsubscribe := h.service.Subscribe(userID)
+	defer unsubscribe()
+
+	var lastID int64
+	if rawLastID != "" {
+		parsed, err := strconv.ParseInt(rawLastID, 10, 64)
+		if err != nil || parsed < 0 {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		lastID = parsed
+	} else {
+		latest, err := h.service.LatestSeq(ctx, userID)
+		if err != nil {
+			_ = c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		lastID = latest
+	}
+
+	// The stream outlives the server write timeout by design.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		events, err := h.service.ListAfter(ctx, userID, lastID, eventBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				_ = c.Error(err)
+			}
+			return
+		}
+		for _, event := range events {
+			if err := sse.Encode(c.Writer, sse.Event{
+				Id:    strconv.FormatInt(event.Seq, 10),
+				Event: event.Kind,
+				Data:  dtoevent.ToEventResponse(event),
+			}); err != nil {
+				return
+			}
+			lastID = event.Seq
+		}
+		if len(events) > 0 {
+			c.Writer.Flush()
+		}
+		if len(events) == eventBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(":\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func userIDFromContext(c *gin.Context) (uuid.UUID, bool) {
+	value, ok := c.Get(middleware.UserIDKey)
+	if !ok {
+		return uuid.UUID{}, false
+	}
+	parsed, err := uuid.Parse(value.(string))
+	if err != nil {
+		return uuid.UUID{}, false
+	}
+	return parsed, true
+}
@@ -0,0 +1,124 @@
+package event
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	eventmocks "github.com/7StaSH7/practicum-diploma/internal/handler/event/mocks"
+	"github.com/7StaSH7/practicum-diploma/internal/middleware"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStreamResumesFromLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	secretID := uuid.New()
+	mockService := eventmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockService.EXPECT().Subscribe(userID).Return(make(chan struct{}), func() {})
+	mockService.EXPECT().ListAfter(gomock.Any(), userID, int64(7), eventBatchSize).DoAndReturn(
+		func(context.Context, uuid.UUID, int64, int) ([]models.SecretEvent, error) {
+			cancel()
+			return []models.SecretEvent{{
+				Seq:       8,
+				UserID:    userID,
+				SecretID:  secretID,
+				Kind:      models.SecretEventUpdated,
+				Version:   3,
+				CreatedAt: time.Date(2026, 2, 9, 10, 0, 0, 0, time.UTC),
+			}}, nil
+		},
+	)
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.GET("/events", h.Stream)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "7")
+
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/event-stream")
+	body := w.Body.String()
+	assert.Contains(t, body, "id:8\n")
+	assert.Contains(t, body, "event:updated\n")
+	assert.Contains(t, body, `"secret_id":"`+secretID.String()+`"`)
+}
+
+func TestStreamStartsFromLatestWithoutLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	mockService := eventmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockService.EXPECT().Subscribe(userID).Return(make(chan struct{}), func() {})
+	mockService.EXPECT().LatestSeq(gomock.Any(), userID).Return(int64(40), nil)
+	mockService.EXPECT().ListAfter(gomock.Any(), userID, int64(40), eventBatchSize).DoAndReturn(
+		func(context.Context, uuid.UUID, int64, int) ([]models.SecretEvent, error) {
+			cancel()
+			return nil, nil
+		},
+	)
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.GET("/events", h.Stream)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestStreamBadRequestOnInvalidLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	mockService := eventmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	mockService.EXPECT().Subscribe(userID).Return(make(chan struct{}), func() {})
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.GET("/events", h.Stream)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/events?last_event_id=abc", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func withUserID(userID uuid.UUID) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(middleware.UserIDKey, userID.String())
+		c.Next()
+	}
+}
@@ -5,6 +5,7 @@ package secret
 import (
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
@@ -21,6 +22,13 @@ type Handler interface {
 	DeleteSecret(c *gin.Context)
 	GetSecret(c *gin.Context)
 	ListSecrets(c *gin.Context)
+	ListVersions(c *gin.Context)
+	GetVersion(c *gin.Context)
+	RestoreSecret(c *gin.Context)
+	ListTrash(c *gin.Context)
+	RestoreFromTrash(c *gin.Context)
+	PurgeSecret(c *gin.Context)
+	BatchSecrets(c *gin.Context)
 }
 
 type handler struct {
@@ -48,7 +56,7 @@ func (h *handler) CreateSecret(c *gin.Context) {
 	created, err := h.service.Create(c.Request.Context(), userID, dtosecret.ToSecretInput(payload))
 	if err != nil {
 		_ = c.Error(err)
-		if errors.Is(err, secretservice.ErrInvalidCiphertext) {
+		if errors.Is(err, secretservice.ErrInvalidCiphertext) || errors.Is(err, secretservice.ErrUnknownType) {
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
@@ -79,7 +87,7 @@ func (h *handler) UpdateSecret(c *gin.Context) {
 	current, err := h.service.Update(c.Request.Context(), userID, secretID, dtosecret.ToSecretInput(payload))
 	if err != nil {
 		_ = c.Error(err)
-		if errors.Is(err, secretservice.ErrInvalidCiphertext) {
+		if errors.Is(err, secretservice.ErrInvalidCiphertext) || errors.Is(err, secretservice.ErrUnknownType) {
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
@@ -87,6 +95,10 @@ func (h *handler) UpdateSecret(c *gin.Context) {
 			c.AbortWithStatus(http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, secretservice.ErrVersionConflict) {
+			c.AbortWithStatus(http.StatusConflict)
+			return
+		}
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
@@ -158,7 +170,12 @@ func (h *handler) ListSecrets(c *gin.Context) {
 		}
 		since = parsed
 	}
-	secrets, err := h.service.ListSince(c.Request.Context(), userID, since)
+	list := h.service.ListSince
+	if c.Query("include_deleted") == "true" {
+		// Offline replicas need deletions as well as changes.
+		list = h.service.ListChanges
+	}
+	secrets, err := list(c.Request.Context(), userID, since)
 	if err != nil {
 		_ = c.Error(err)
 		c.AbortWithStatus(http.StatusInternalServerError)
@@ -171,6 +188,256 @@ func (h *handler) ListSecrets(c *gin.Context) {
 	c.JSON(http.StatusOK, responses)
 }
 
+func (h *handler) ListVersions(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	secretID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	versions, err := h.service.ListVersions(c.Request.Context(), userID, secretID)
+	if err != nil {
+		_ = c.Error(err)
+		if errors.Is(err, secretservice.ErrNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	responses := make([]dtosecret.SecretVersionResponse, 0, len(versions))
+	for _, version := range versions {
+		responses = append(responses, dtosecret.ToSecretVersionResponse(version))
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+func (h *handler) GetVersion(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	secretID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	version, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if err != nil || version < 1 {
+		_ = c.Error(errors.New("invalid version"))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	found, err := h.service.GetVersion(c.Request.Context(), userID, secretID, version)
+	if err != nil {
+		_ = c.Error(err)
+		if errors.Is(err, secretservice.ErrNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, dtosecret.ToSecretVersionResponse(found))
+}
+
+func (h *handler) RestoreSecret(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	secretID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	var req dtosecret.RestoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if req.Version < 1 {
+		_ = c.Error(errors.New("invalid version"))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	restored, err := h.service.Restore(c.Request.Context(), userID, secretID, req.Version)
+	if err != nil {
+		_ = c.Error(err)
+		if errors.Is(err, secretservice.ErrNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, secretservice.ErrVersionConflict) {
+			c.AbortWithStatus(http.StatusConflict)
+			return
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	respondSecret(c, restored)
+}
+
+func (h *handler) ListTrash(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	secrets, err := h.service.ListTrash(c.Request.Context(), userID)
+	if err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	responses := make([]dtosecret.SecretResponse, 0, len(secrets))
+	for _, secret := range secrets {
+		responses = append(responses, dtosecret.ToSecretResponse(secret))
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+func (h *handler) RestoreFromTrash(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	secretID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	restored, err := h.service.RestoreFromTrash(c.Request.Context(), userID, secretID)
+	if err != nil {
+		_ = c.Error(err)
+		if errors.Is(err, secretservice.ErrNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	respondSecret(c, restored)
+}
+
+func (h *handler) PurgeSecret(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	secretID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if err := h.service.Purge(c.Request.Context(), userID, secretID); err != nil {
+		_ = c.Error(err)
+		if errors.Is(err, secretservice.ErrNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handler) BatchSecrets(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	var req dtosecret.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	input, err := dtosecret.ToBatchInput(req)
+	if err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	outcomes, committed, err := h.service.Batch(c.Request.Context(), userID, input)
+	if err != nil {
+		_ = c.Error(err)
+		if errors.Is(err, secretservice.ErrInvalidBatch) {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	mode := dtosecret.BatchModePerItem
+	if input.Atomic {
+		mode = dtosecret.BatchModeAtomic
+	}
+	response := dtosecret.BatchResponse{
+		Mode:      mode,
+		Committed: committed,
+		Results:   make([]dtosecret.BatchItemResult, 0, len(outcomes)),
+	}
+	for i, outcome := range outcomes {
+		response.Results = append(response.Results, toBatchItemResult(i, outcome))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func toBatchItemResult(index int, outcome dtosecret.BatchItemOutcome) dtosecret.BatchItemResult {
+	result := dtosecret.BatchItemResult{
+		Index: index,
+		Op:    outcome.Op,
+	}
+	if outcome.ID != uuid.Nil {
+		result.ID = outcome.ID.String()
+	}
+	switch {
+	case outcome.Err != nil:
+		result.Status = dtosecret.BatchStatusFailed
+		result.Error = batchErrorCode(outcome.Err)
+	case outcome.Applied:
+		result.Status = dtosecret.BatchStatusOK
+		secret := dtosecret.ToSecretResponse(outcome.Secret)
+		result.Secret = &secret
+	default:
+		result.Status = dtosecret.BatchStatusRolledBack
+	}
+	return result
+}
+
+func batchErrorCode(err error) string {
+	switch {
+	case errors.Is(err, secretservice.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, secretservice.ErrVersionConflict):
+		return "version_conflict"
+	case errors.Is(err, secretservice.ErrInvalidCiphertext):
+		return "invalid_ciphertext"
+	case errors.Is(err, secretservice.ErrUnknownType):
+		return "unknown_type"
+	case errors.Is(err, secretservice.ErrInvalidOperation):
+		return "invalid_operation"
+	default:
+		return "internal_error"
+	}
+}
+
 func respondSecret(c *gin.Context, secret models.Secret) {
 	c.JSON(http.StatusOK, dtosecret.ToSecretResponse(secret))
 }
@@ -63,6 +63,31 @@ func TestCreateSecretBadRequestOnInvalidCiphertext(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestUpdateSecretBadRequestOnUnknownType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	secretID := uuid.New()
+	mockService := secretmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	mockService.EXPECT().Update(gomock.Any(), userID, secretID, gomock.Any()).Return(models.Secret{}, secretservice.ErrUnknownType)
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.PUT("/secrets/:id", h.UpdateSecret)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/secrets/"+secretID.String(), strings.NewReader(`{"type":"password","ciphertext":"Y2lwaGVy"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestGetSecretNotFound(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	ctrl := gomock.NewController(t)
@@ -148,9 +173,234 @@ func TestListSecretsSuccess(t *testing.T) {
 	assert.Equal(t, updatedAt.Format(time.RFC3339), response[0].UpdatedAt)
 }
 
+func TestListSecretsIncludeDeletedReturnsTombstones(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+	mockService := secretmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	mockService.EXPECT().ListChanges(gomock.Any(), userID, time.Time{}).Return([]models.Secret{
+		{ID: uuid.New(), UserID: userID, Type: "note", Version: 3, UpdatedAt: deletedAt, DeletedAt: deletedAt},
+	}, nil)
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.GET("/secrets", h.ListSecrets)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/secrets?include_deleted=true", nil)
+
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response []dtosecret.SecretResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response, 1)
+	assert.Equal(t, deletedAt.Format(time.RFC3339), response[0].DeletedAt)
+}
+
+func TestListVersionsNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	secretID := uuid.New()
+	mockService := secretmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	mockService.EXPECT().ListVersions(gomock.Any(), userID, secretID).Return(nil, secretservice.ErrNotFound)
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.GET("/secrets/:id/versions", h.ListVersions)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/secrets/"+secretID.String()+"/versions", nil)
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRestoreSecretBadRequestWithoutVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	h := New(secretmocks.NewMockService(ctrl))
+	r := gin.New()
+	r.Use(withUserID(uuid.New()))
+	r.POST("/secrets/:id/restore", h.RestoreSecret)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/secrets/"+uuid.NewString()+"/restore", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRestoreSecretSuccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	secretID := uuid.New()
+	mockService := secretmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	mockService.EXPECT().Restore(gomock.Any(), userID, secretID, int64(2)).Return(models.Secret{
+		ID:         secretID,
+		UserID:     userID,
+		Type:       "note",
+		Ciphertext: []byte("restored"),
+		Version:    5,
+		UpdatedAt:  time.Now().UTC(),
+	}, nil)
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.POST("/secrets/:id/restore", h.RestoreSecret)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/secrets/"+secretID.String()+"/restore", strings.NewReader(`{"version":2}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response dtosecret.SecretResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, int64(5), response.Version)
+}
+
+func TestListTrashSuccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+	mockService := secretmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	mockService.EXPECT().ListTrash(gomock.Any(), userID).Return([]models.Secret{
+		{ID: uuid.New(), UserID: userID, Type: "note", Version: 2, UpdatedAt: deletedAt, DeletedAt: deletedAt},
+	}, nil)
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.GET("/trash", h.ListTrash)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/trash", nil)
+
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response []dtosecret.SecretResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response, 1)
+	assert.Equal(t, deletedAt.Format(time.RFC3339), response[0].DeletedAt)
+}
+
+func TestPurgeSecretNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	secretID := uuid.New()
+	mockService := secretmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	mockService.EXPECT().Purge(gomock.Any(), userID, secretID).Return(secretservice.ErrNotFound)
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.DELETE("/trash/:id", h.PurgeSecret)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/trash/"+secretID.String(), nil)
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
 func withUserID(userID uuid.UUID) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Set(middleware.UserIDKey, userID.String())
 		c.Next()
 	}
 }
+
+func TestBatchSecretsReportsPerItemOutcomes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	secretID := uuid.New()
+	mockService := secretmocks.NewMockService(ctrl)
+	h := New(mockService)
+
+	mockService.EXPECT().Batch(gomock.Any(), userID, gomock.Any()).DoAndReturn(
+		func(_ any, _ uuid.UUID, input dtosecret.BatchInput) ([]dtosecret.BatchItemOutcome, bool, error) {
+			require.True(t, input.Atomic)
+			require.Len(t, input.Items, 2)
+			return []dtosecret.BatchItemOutcome{
+				{Op: dtosecret.BatchOpCreate},
+				{Op: dtosecret.BatchOpDelete, ID: secretID, Err: secretservice.ErrVersionConflict},
+			}, false, nil
+		})
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.POST("/secrets/batch", h.BatchSecrets)
+
+	body := `{"operations":[{"op":"create","secret":{"type":"note","ciphertext":"YQ=="}},{"op":"delete","id":"` + secretID.String() + `","expected_version":2}]}`
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/secrets/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp dtosecret.BatchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, dtosecret.BatchModeAtomic, resp.Mode)
+	assert.False(t, resp.Committed)
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, dtosecret.BatchStatusRolledBack, resp.Results[0].Status)
+	assert.Equal(t, dtosecret.BatchStatusFailed, resp.Results[1].Status)
+	assert.Equal(t, "version_conflict", resp.Results[1].Error)
+}
+
+func TestBatchSecretsBadRequestOnInvalidID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	h := New(secretmocks.NewMockService(ctrl))
+
+	r := gin.New()
+	r.Use(withUserID(userID))
+	r.POST("/secrets/batch", h.BatchSecrets)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/secrets/batch", strings.NewReader(`{"operations":[{"op":"delete","id":"nope"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
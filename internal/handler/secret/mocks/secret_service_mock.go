@@ -44,6 +44,22 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 	return m.recorder
 }
 
+// Batch mocks base method.
+func (m *MockService) Batch(ctx context.Context, userID uuid.UUID, input secret.BatchInput) ([]secret.BatchItemOutcome, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Batch", ctx, userID, input)
+	ret0, _ := ret[0].([]secret.BatchItemOutcome)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Batch indicates an expected call of Batch.
+func (mr *MockServiceMockRecorder) Batch(ctx, userID, input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Batch", reflect.TypeOf((*MockService)(nil).Batch), ctx, userID, input)
+}
+
 // Create mocks base method.
 func (m *MockService) Create(ctx context.Context, userID uuid.UUID, payload secret.SecretInput) (models.Secret, error) {
 	m.ctrl.T.Helper()
@@ -88,6 +104,36 @@ func (mr *MockServiceMockRecorder) Get(ctx, userID, secretID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockService)(nil).Get), ctx, userID, secretID)
 }
 
+// GetVersion mocks base method.
+func (m *MockService) GetVersion(ctx context.Context, userID, secretID uuid.UUID, version int64) (models.SecretVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVersion", ctx, userID, secretID, version)
+	ret0, _ := ret[0].(models.SecretVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVersion indicates an expected call of GetVersion.
+func (mr *MockServiceMockRecorder) GetVersion(ctx, userID, secretID, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersion", reflect.TypeOf((*MockService)(nil).GetVersion), ctx, userID, secretID, version)
+}
+
+// ListChanges mocks base method.
+func (m *MockService) ListChanges(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListChanges", ctx, userID, since)
+	ret0, _ := ret[0].([]models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListChanges indicates an expected call of ListChanges.
+func (mr *MockServiceMockRecorder) ListChanges(ctx, userID, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListChanges", reflect.TypeOf((*MockService)(nil).ListChanges), ctx, userID, since)
+}
+
 // ListSince mocks base method.
 func (m *MockService) ListSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error) {
 	m.ctrl.T.Helper()
@@ -103,6 +149,95 @@ func (mr *MockServiceMockRecorder) ListSince(ctx, userID, since any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSince", reflect.TypeOf((*MockService)(nil).ListSince), ctx, userID, since)
 }
 
+// ListTrash mocks base method.
+func (m *MockService) ListTrash(ctx context.Context, userID uuid.UUID) ([]models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrash", ctx, userID)
+	ret0, _ := ret[0].([]models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrash indicates an expected call of ListTrash.
+func (mr *MockServiceMockRecorder) ListTrash(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrash", reflect.TypeOf((*MockService)(nil).ListTrash), ctx, userID)
+}
+
+// ListVersions mocks base method.
+func (m *MockService) ListVersions(ctx context.Context, userID, secretID uuid.UUID) ([]models.SecretVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVersions", ctx, userID, secretID)
+	ret0, _ := ret[0].([]models.SecretVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVersions indicates an expected call of ListVersions.
+func (mr *MockServiceMockRecorder) ListVersions(ctx, userID, secretID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVersions", reflect.TypeOf((*MockService)(nil).ListVersions), ctx, userID, secretID)
+}
+
+// Purge mocks base method.
+func (m *MockService) Purge(ctx context.Context, userID, secretID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", ctx, userID, secretID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockServiceMockRecorder) Purge(ctx, userID, secretID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockService)(nil).Purge), ctx, userID, secretID)
+}
+
+// PurgeExpired mocks base method.
+func (m *MockService) PurgeExpired(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeExpired", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeExpired indicates an expected call of PurgeExpired.
+func (mr *MockServiceMockRecorder) PurgeExpired(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeExpired", reflect.TypeOf((*MockService)(nil).PurgeExpired), ctx)
+}
+
+// Restore mocks base method.
+func (m *MockService) Restore(ctx context.Context, userID, secretID uuid.UUID, version int64) (models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, userID, secretID, version)
+	ret0, _ := ret[0].(models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockServiceMockRecorder) Restore(ctx, userID, secretID, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockService)(nil).Restore), ctx, userID, secretID, version)
+}
+
+// RestoreFromTrash mocks base method.
+func (m *MockService) RestoreFromTrash(ctx context.Context, userID, secretID uuid.UUID) (models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreFromTrash", ctx, userID, secretID)
+	ret0, _ := ret[0].(models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreFromTrash indicates an expected call of RestoreFromTrash.
+func (mr *MockServiceMockRecorder) RestoreFromTrash(ctx, userID, secretID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreFromTrash", reflect.TypeOf((*MockService)(nil).RestoreFromTrash), ctx, userID, secretID)
+}
+
 // Update mocks base method.
 func (m *MockService) Update(ctx context.Context, userID, secretID uuid.UUID, payload secret.SecretInput) (models.Secret, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+// Bitwarden item types.
+const (
+	bitwardenLogin      = 1
+	bitwardenSecureNote = 2
+	bitwardenCard       = 3
+	bitwardenIdentity   = 4
+	bitwardenSSHKey     = 5
+)
+
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	Type     int    `json:"type"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes"`
+	FolderID string `json:"folderId"`
+	Login    *struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+		URIs     []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+	} `json:"login"`
+	Card *struct {
+		CardholderName string `json:"cardholderName"`
+		Number         string `json:"number"`
+		ExpMonth       string `json:"expMonth"`
+		ExpYear        string `json:"expYear"`
+		Code           string `json:"code"`
+	} `json:"card"`
+	SSHKey *struct {
+		PrivateKey string `json:"privateKey"`
+		PublicKey  string `json:"publicKey"`
+	} `json:"sshKey"`
+}
+
+// parseBitwardenJSON reads an unencrypted Bitwarden JSON export. Folder
+// names become tags.
+func parseBitwardenJSON(r io.Reader) (Result, error) {
+	var export bitwardenExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return Result{}, fmt.Errorf("parse bitwarden json: %w", err)
+	}
+	if export.Encrypted {
+		return Result{}, errors.New("encrypted bitwarden exports are not supported, export as unencrypted json")
+	}
+	folders := make(map[string]string, len(export.Folders))
+	for _, folder := range export.Folders {
+		folders[folder.ID] = folder.Name
+	}
+
+	var res Result
+	for i, item := range export.Items {
+		row := i + 1
+		var tags []string
+		if folder := folders[item.FolderID]; folder != "" {
+			tags = []string{folder}
+		}
+		switch item.Type {
+		case bitwardenLogin:
+			rec := record{row: row, title: item.Name, notes: item.Notes, tags: tags}
+			if item.Login != nil {
+				rec.username = item.Login.Username
+				rec.password = item.Login.Password
+				rec.totp = item.Login.TOTP
+				if len(item.Login.URIs) > 0 {
+					rec.site = item.Login.URIs[0].URI
+				}
+			}
+			res.add(rec)
+		case bitwardenSecureNote:
+			res.add(record{row: row, title: item.Name, notes: item.Notes, tags: tags})
+		case bitwardenCard:
+			if item.Card == nil {
+				res.fail(row, item.Name, errors.New("card item has no card data"))
+				continue
+			}
+			res.addPayload(row, item.Name, "", tags, secretkind.Payload{Kind: secretkind.Card, Card: &secretkind.CardData{
+				Number: secretkind.NormalizeCardNumber(item.Card.Number),
+				Holder: strings.TrimSpace(item.Card.CardholderName),
+				Expiry: bitwardenExpiry(item.Card.ExpMonth, item.Card.ExpYear),
+				CVV:    strings.TrimSpace(item.Card.Code),
+			}})
+		case bitwardenSSHKey:
+			if item.SSHKey == nil {
+				res.fail(row, item.Name, errors.New("ssh key item has no key data"))
+				continue
+			}
+			res.addPayload(row, item.Name, "", tags, secretkind.Payload{Kind: secretkind.SSHKey, SSHKey: &secretkind.SSHKeyData{
+				PrivateKey: item.SSHKey.PrivateKey,
+				PublicKey:  strings.TrimSpace(item.SSHKey.PublicKey),
+			}})
+		case bitwardenIdentity:
+			res.fail(row, item.Name, errors.New("identity items are not supported"))
+		default:
+			res.fail(row, item.Name, fmt.Errorf("unsupported item type %d", item.Type))
+		}
+	}
+	return res, nil
+}
+
+// bitwardenExpiry converts month "8" and year "2029" to "08/29".
+func bitwardenExpiry(month, year string) string {
+	month = strings.TrimSpace(month)
+	year = strings.TrimSpace(year)
+	if len(month) == 1 {
+		month = "0" + month
+	}
+	if len(year) == 4 {
+		year = year[2:]
+	}
+	return month + "/" + year
+}
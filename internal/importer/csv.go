@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvColumns maps a record field to the header names exporters use for it.
+type csvColumns map[string][]string
+
+var chromeColumns = csvColumns{
+	"title":    {"name"},
+	"site":     {"url"},
+	"username": {"username"},
+	"password": {"password"},
+	"notes":    {"note", "notes"},
+}
+
+var onePasswordColumns = csvColumns{
+	"title":    {"title"},
+	"site":     {"url", "website", "urls"},
+	"username": {"username"},
+	"password": {"password"},
+	"notes":    {"notes", "notesplain"},
+	"totp":     {"otpauth", "one-time password"},
+	"tags":     {"tags"},
+}
+
+func parseChromeCSV(r io.Reader) (Result, error) {
+	return parseCSV(r, chromeColumns)
+}
+
+func parse1PasswordCSV(r io.Reader) (Result, error) {
+	return parseCSV(r, onePasswordColumns)
+}
+
+func parseCSV(r io.Reader, columns csvColumns) (Result, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return Result{}, fmt.Errorf("read csv header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		normalized := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for field, aliases := range columns {
+			if _, ok := index[field]; ok {
+				continue
+			}
+			for _, alias := range aliases {
+				if normalized == alias {
+					index[field] = i
+				}
+			}
+		}
+	}
+	if _, ok := index["password"]; !ok {
+		return Result{}, errors.New("csv header has no password column")
+	}
+
+	var res Result
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return res, err
+			}
+			res.fail(parseErr.Line, "", parseErr.Err)
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		value := func(field string) string {
+			i, ok := index[field]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return row[i]
+		}
+		res.add(record{
+			row:      line,
+			title:    value("title"),
+			site:     value("site"),
+			username: value("username"),
+			password: value("password"),
+			notes:    value("notes"),
+			totp:     value("totp"),
+			tags:     splitTags(value("tags")),
+		})
+	}
+	return res, nil
+}
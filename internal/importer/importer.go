@@ -0,0 +1,204 @@
+// Package importer maps exports of other password managers onto typed
+// secrets. Parsing never stops at a bad row: problems are collected per row
+// so a migration imports everything it can.
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+)
+
+const (
+	FormatKeePassXML    = "keepass-xml"
+	FormatBitwardenJSON = "bitwarden-json"
+	FormatChromeCSV     = "chrome-csv"
+	Format1PasswordCSV  = "1password-csv"
+)
+
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// Entry is one secret ready to be encrypted and uploaded.
+type Entry struct {
+	// Row points back into the source file: a CSV line or a 1-based item
+	// number for JSON and XML exports.
+	Row     int
+	Title   string
+	Tags    []string
+	Site    string
+	Payload secretkind.Payload
+}
+
+type RowError struct {
+	Row   int    `json:"row"`
+	Title string `json:"title,omitempty"`
+	Error string `json:"error"`
+}
+
+type Result struct {
+	Entries []Entry
+	Errors  []RowError
+}
+
+// Formats lists the supported format names.
+func Formats() []string {
+	return []string{FormatKeePassXML, FormatBitwardenJSON, FormatChromeCSV, Format1PasswordCSV}
+}
+
+// Parse reads an export in format. The error is only set when the file as a
+// whole cannot be read.
+func Parse(format string, r io.Reader) (Result, error) {
+	switch format {
+	case FormatKeePassXML:
+		return parseKeePassXML(r)
+	case FormatBitwardenJSON:
+		return parseBitwardenJSON(r)
+	case FormatChromeCSV:
+		return parseChromeCSV(r)
+	case Format1PasswordCSV:
+		return parse1PasswordCSV(r)
+	default:
+		return Result{}, fmt.Errorf("%w: %q (expected one of: %s)", ErrUnknownFormat, format, strings.Join(Formats(), ", "))
+	}
+}
+
+// Fingerprint identifies a secret by kind, title, site and payload so the
+// same item exported twice, or already in the vault, is imported once.
+func Fingerprint(title, site string, p secretkind.Payload) string {
+	p.Schema = 0
+	section, _ := json.Marshal(p)
+	sum := sha256.Sum256([]byte(strings.Join([]string{p.Kind, strings.TrimSpace(title), strings.TrimSpace(site), string(section)}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Dedupe drops entries whose fingerprint is in seen or repeats an earlier
+// entry, and returns how many were dropped. seen is updated in place.
+func Dedupe(entries []Entry, seen map[string]struct{}) ([]Entry, int) {
+	unique := make([]Entry, 0, len(entries))
+	duplicates := 0
+	for _, entry := range entries {
+		key := Fingerprint(entry.Title, entry.Site, entry.Payload)
+		if _, ok := seen[key]; ok {
+			duplicates++
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, entry)
+	}
+	return unique, duplicates
+}
+
+// record is the format-neutral shape most exports reduce to.
+type record struct {
+	row      int
+	title    string
+	site     string
+	username string
+	password string
+	notes    string
+	totp     string
+	tags     []string
+}
+
+// add converts rec into a login or, without credentials, a note. A TOTP seed
+// becomes a separate totp secret next to the login.
+func (res *Result) add(rec record) {
+	site := normalizeSite(rec.site)
+	title := strings.TrimSpace(rec.title)
+	if title == "" {
+		title = siteHost(site)
+	}
+
+	var p secretkind.Payload
+	switch {
+	case rec.password != "":
+		p = secretkind.Payload{Kind: secretkind.Login, Login: &secretkind.LoginData{
+			Username: strings.TrimSpace(rec.username),
+			Password: rec.password,
+			URL:      site,
+			Notes:    strings.TrimSpace(rec.notes),
+		}}
+	case strings.TrimSpace(rec.notes) != "":
+		p = secretkind.Payload{Kind: secretkind.Note, Note: &secretkind.NoteData{Text: strings.TrimSpace(rec.notes)}}
+	case strings.TrimSpace(rec.totp) == "":
+		res.fail(rec.row, title, errors.New("entry has no password or notes"))
+		return
+	}
+	if p.Kind != "" {
+		res.addPayload(rec.row, title, site, rec.tags, p)
+	}
+	if seed := strings.TrimSpace(rec.totp); seed != "" {
+		res.addPayload(rec.row, title, site, rec.tags, secretkind.Payload{
+			Kind: secretkind.TOTP,
+			TOTP: &secretkind.TOTPData{URI: totpURI(seed, title)},
+		})
+	}
+}
+
+func (res *Result) addPayload(row int, title, site string, tags []string, p secretkind.Payload) {
+	if err := p.Validate(); err != nil {
+		res.fail(row, title, err)
+		return
+	}
+	p.Schema = secretkind.SchemaVersion
+	res.Entries = append(res.Entries, Entry{Row: row, Title: title, Tags: tags, Site: site, Payload: p})
+}
+
+func (res *Result) fail(row int, title string, err error) {
+	res.Errors = append(res.Errors, RowError{Row: row, Title: title, Error: err.Error()})
+}
+
+// totpURI accepts either a full otpauth URI or the bare base32 seed some
+// managers export.
+func totpURI(seed, title string) string {
+	if strings.HasPrefix(strings.ToLower(seed), "otpauth://") {
+		return seed
+	}
+	label := title
+	if label == "" {
+		label = "imported"
+	}
+	return "otpauth://totp/" + url.PathEscape(label) + "?secret=" + url.QueryEscape(strings.ReplaceAll(seed, " ", ""))
+}
+
+// normalizeSite makes bare host names absolute so they pass login URL
+// validation.
+func normalizeSite(site string) string {
+	trimmed := strings.TrimSpace(site)
+	if trimmed == "" {
+		return ""
+	}
+	if parsed, err := url.Parse(trimmed); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		return trimmed
+	}
+	return "https://" + trimmed
+}
+
+func siteHost(site string) string {
+	parsed, err := url.Parse(site)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+func splitTags(raw string) []string {
+	fields := strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ';' })
+	tags := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if tag := strings.TrimSpace(field); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
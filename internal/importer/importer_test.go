@@ -0,0 +1,153 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const keepassExport = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+  <Root>
+    <Group>
+      <Name>Database</Name>
+      <Entry>
+        <String><Key>Title</Key><Value>Mail</Value></String>
+        <String><Key>UserName</Key><Value>alice</Value></String>
+        <String><Key>Password</Key><Value>pw1</Value></String>
+        <String><Key>URL</Key><Value>mail.example.com</Value></String>
+        <History>
+          <Entry>
+            <String><Key>Password</Key><Value>old</Value></String>
+          </Entry>
+        </History>
+      </Entry>
+      <Group>
+        <Name>Work</Name>
+        <Entry>
+          <Tags>vpn</Tags>
+          <String><Key>Title</Key><Value>VPN</Value></String>
+          <String><Key>Password</Key><Value>pw2</Value></String>
+          <String><Key>otp</Key><Value>otpauth://totp/VPN?secret=GEZDGNBVGY3TQOJQ</Value></String>
+        </Entry>
+        <Entry>
+          <String><Key>Title</Key><Value>Empty</Value></String>
+        </Entry>
+      </Group>
+      <Group>
+        <Name>Recycle Bin</Name>
+        <Entry>
+          <String><Key>Title</Key><Value>Deleted</Value></String>
+          <String><Key>Password</Key><Value>gone</Value></String>
+        </Entry>
+      </Group>
+    </Group>
+  </Root>
+</KeePassFile>`
+
+func TestParseKeePassXML(t *testing.T) {
+	res, err := Parse(FormatKeePassXML, strings.NewReader(keepassExport))
+	require.NoError(t, err)
+
+	require.Len(t, res.Entries, 3)
+	mail := res.Entries[0]
+	assert.Equal(t, "Mail", mail.Title)
+	assert.Equal(t, "https://mail.example.com", mail.Site)
+	assert.Equal(t, secretkind.Login, mail.Payload.Kind)
+	assert.Equal(t, "pw1", mail.Payload.Login.Password)
+	assert.Nil(t, mail.Tags)
+
+	vpn := res.Entries[1]
+	assert.Equal(t, []string{"Work", "vpn"}, vpn.Tags)
+	assert.Equal(t, secretkind.TOTP, res.Entries[2].Payload.Kind)
+
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, RowError{Row: 3, Title: "Empty", Error: "entry has no password or notes"}, res.Errors[0])
+}
+
+func TestParseBitwardenJSON(t *testing.T) {
+	export := `{
+	  "encrypted": false,
+	  "folders": [{"id": "f1", "name": "Finance"}],
+	  "items": [
+	    {"type": 1, "name": "Bank", "folderId": "f1", "login": {"username": "bob", "password": "pw", "totp": "GEZDGNBVGY3TQOJQ", "uris": [{"uri": "https://bank.example.com"}]}},
+	    {"type": 2, "name": "Wifi", "notes": "guest / hunter2"},
+	    {"type": 3, "name": "Visa", "card": {"cardholderName": "BOB", "number": "4111 1111 1111 1111", "expMonth": "8", "expYear": "2099", "code": "123"}},
+	    {"type": 3, "name": "Broken card", "card": {"cardholderName": "BOB", "number": "4111 1111 1111 1112", "expMonth": "8", "expYear": "2099", "code": "123"}},
+	    {"type": 4, "name": "Passport"}
+	  ]
+	}`
+	res, err := Parse(FormatBitwardenJSON, strings.NewReader(export))
+	require.NoError(t, err)
+
+	kinds := make([]string, 0, len(res.Entries))
+	for _, entry := range res.Entries {
+		kinds = append(kinds, entry.Payload.Kind)
+	}
+	assert.Equal(t, []string{secretkind.Login, secretkind.TOTP, secretkind.Note, secretkind.Card}, kinds)
+	assert.Equal(t, []string{"Finance"}, res.Entries[0].Tags)
+	assert.Equal(t, "otpauth://totp/Bank?secret=GEZDGNBVGY3TQOJQ", res.Entries[1].Payload.TOTP.URI)
+	assert.Equal(t, "08/99", res.Entries[3].Payload.Card.Expiry)
+	assert.Equal(t, "4111111111111111", res.Entries[3].Payload.Card.Number)
+
+	require.Len(t, res.Errors, 2)
+	assert.Equal(t, 4, res.Errors[0].Row)
+	assert.Equal(t, 5, res.Errors[1].Row)
+}
+
+func TestParseBitwardenRejectsEncryptedExport(t *testing.T) {
+	_, err := Parse(FormatBitwardenJSON, strings.NewReader(`{"encrypted": true, "items": []}`))
+	assert.Error(t, err)
+}
+
+func TestParseChromeCSV(t *testing.T) {
+	export := "name,url,username,password,note\n" +
+		"Example,https://example.com/login,alice,pw,\n" +
+		",https://nameless.example.com,bob,pw2,\n" +
+		"Nothing,https://empty.example.com,carol,,\n"
+	res, err := Parse(FormatChromeCSV, strings.NewReader(export))
+	require.NoError(t, err)
+
+	require.Len(t, res.Entries, 2)
+	assert.Equal(t, "Example", res.Entries[0].Title)
+	assert.Equal(t, 2, res.Entries[0].Row)
+	assert.Equal(t, "nameless.example.com", res.Entries[1].Title)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, 4, res.Errors[0].Row)
+}
+
+func TestParse1PasswordCSV(t *testing.T) {
+	export := "Title,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes\n" +
+		"GitHub,github.com,alice,pw,otpauth://totp/GitHub:alice?secret=GEZDGNBVGY3TQOJQ,false,false,dev;work,recovery codes in safe\n"
+	res, err := Parse(Format1PasswordCSV, strings.NewReader(export))
+	require.NoError(t, err)
+	require.Empty(t, res.Errors)
+	require.Len(t, res.Entries, 2)
+	assert.Equal(t, []string{"dev", "work"}, res.Entries[0].Tags)
+	assert.Equal(t, "recovery codes in safe", res.Entries[0].Payload.Login.Notes)
+	assert.Equal(t, secretkind.TOTP, res.Entries[1].Payload.Kind)
+}
+
+func TestParseRejectsUnknownFormat(t *testing.T) {
+	_, err := Parse("lastpass", strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestDedupe(t *testing.T) {
+	login := secretkind.Payload{Schema: 1, Kind: secretkind.Login, Login: &secretkind.LoginData{Username: "a", Password: "pw"}}
+	entries := []Entry{
+		{Title: "A", Payload: login},
+		{Title: "A", Payload: login},
+		{Title: "B", Payload: login},
+	}
+	existing := secretkind.Payload{Kind: secretkind.Login, Login: &secretkind.LoginData{Username: "a", Password: "pw"}}
+	seen := map[string]struct{}{Fingerprint("B", "", existing): {}}
+
+	unique, duplicates := Dedupe(entries, seen)
+	assert.Equal(t, 2, duplicates)
+	require.Len(t, unique, 1)
+	assert.Equal(t, "A", unique[0].Title)
+}
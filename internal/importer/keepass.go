@@ -0,0 +1,76 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// keepassRecycleBin is skipped: deleted entries are not worth migrating.
+const keepassRecycleBin = "Recycle Bin"
+
+type keepassFile struct {
+	Groups []keepassGroup `xml:"Root>Group"`
+}
+
+type keepassGroup struct {
+	Name    string         `xml:"Name"`
+	Entries []keepassEntry `xml:"Entry"`
+	Groups  []keepassGroup `xml:"Group"`
+}
+
+type keepassEntry struct {
+	Tags    string          `xml:"Tags"`
+	Strings []keepassString `xml:"String"`
+}
+
+type keepassString struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// parseKeePassXML reads a KeePass 2 XML export. Group names below the
+// database root become tags.
+func parseKeePassXML(r io.Reader) (Result, error) {
+	var file keepassFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return Result{}, fmt.Errorf("parse keepass xml: %w", err)
+	}
+	var res Result
+	row := 0
+	var walk func(group keepassGroup, path []string)
+	walk = func(group keepassGroup, path []string) {
+		if group.Name == keepassRecycleBin {
+			return
+		}
+		for _, entry := range group.Entries {
+			row++
+			fields := make(map[string]string, len(entry.Strings))
+			for _, s := range entry.Strings {
+				fields[strings.ToLower(s.Key)] = s.Value
+			}
+			tags := append(append([]string(nil), path...), splitTags(entry.Tags)...)
+			if len(tags) == 0 {
+				tags = nil
+			}
+			res.add(record{
+				row:      row,
+				title:    fields["title"],
+				site:     fields["url"],
+				username: fields["username"],
+				password: fields["password"],
+				notes:    fields["notes"],
+				totp:     fields["otp"],
+				tags:     tags,
+			})
+		}
+		for _, child := range group.Groups {
+			walk(child, append(append([]string(nil), path...), child.Name))
+		}
+	}
+	for _, root := range file.Groups {
+		walk(root, nil)
+	}
+	return res, nil
+}
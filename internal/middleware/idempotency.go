@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	idempotencyservice "github.com/7StaSH7/practicum-diploma/internal/service/idempotency"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	// inProgressRetryAfter is the Retry-After, in seconds, sent while another
+	// request with the same key is still running.
+	inProgressRetryAfter = "1"
+)
+
+var idempotencyHeartbeat = idempotencyservice.HeartbeatInterval
+
+// IdempotencyMiddleware stores the response of POST, PUT and DELETE requests
+// carrying an Idempotency-Key header and replays it for retries of the same
+// request, so a client that timed out after the server committed can retry
+// without applying the change twice. Server errors are not stored. Raw
+// octet-stream bodies are passed through: chunk uploads are already safe to
+// retry through their offsets and are too large to buffer and store. A retry
+// that arrives while the first request is still running gets 409 with a
+// Retry-After header; the running request keeps its reservation alive with
+// heartbeats so that the retry cannot take it over.
+func IdempotencyMiddleware(svc idempotencyservice.Service, log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !isMutatingMethod(c.Request.Method) || isRawUpload(c.Request) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		userID, err := uuid.Parse(c.GetString(UserIDKey))
+		if err != nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			_ = c.Error(err)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		stored, replay, err := svc.Begin(ctx, userID, key, requestHash(c.Request, body))
+		if err != nil {
+			_ = c.Error(err)
+			switch {
+			case errors.Is(err, idempotencyservice.ErrKeyReused):
+				c.AbortWithStatus(http.StatusUnprocessableEntity)
+			case errors.Is(err, idempotencyservice.ErrInProgress):
+				c.Header("Retry-After", inProgressRetryAfter)
+				c.AbortWithStatus(http.StatusConflict)
+			default:
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+			return
+		}
+		if replay {
+			c.Header(IdempotentReplayedHeader, "true")
+			if len(stored.Body) == 0 {
+				c.AbortWithStatus(stored.StatusCode)
+				return
+			}
+			c.Data(stored.StatusCode, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		// Results are recorded even if the client has gone away, which is
+		// exactly the case retries are meant to cover.
+		storeCtx := context.WithoutCancel(ctx)
+		stopHeartbeat := startHeartbeat(storeCtx, svc, log, userID, key)
+		finished := false
+		defer func() {
+			if !finished {
+				stopHeartbeat()
+				releaseKey(storeCtx, svc, log, userID, key)
+			}
+		}()
+
+		c.Next()
+		stopHeartbeat()
+		finished = true
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			releaseKey(storeCtx, svc, log, userID, key)
+			return
+		}
+		if err := svc.Complete(storeCtx, userID, key, status, writer.Header().Get("Content-Type"), writer.body.Bytes()); err != nil {
+			log.Warn("store idempotent response failed", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+func isMutatingMethod(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodDelete
+}
+
+func isRawUpload(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/octet-stream"
+}
+
+func requestHash(r *http.Request, body []byte) []byte {
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.Method)
+	_, _ = io.WriteString(h, "\n")
+	_, _ = io.WriteString(h, r.URL.RequestURI())
+	_, _ = io.WriteString(h, "\n")
+	_, _ = h.Write(body)
+	return h.Sum(nil)
+}
+
+// startHeartbeat refreshes the reservation of key until the returned function
+// is called.
+func startHeartbeat(ctx context.Context, svc idempotencyservice.Service, log *zap.Logger, userID uuid.UUID, key string) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(idempotencyHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := svc.Heartbeat(ctx, userID, key); err != nil && ctx.Err() == nil {
+					log.Warn("idempotency heartbeat failed", zap.String("key", key), zap.Error(err))
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func releaseKey(ctx context.Context, svc idempotencyservice.Service, log *zap.Logger, userID uuid.UUID, key string) {
+	if err := svc.Release(ctx, userID, key); err != nil {
+		log.Warn("release idempotency key failed", zap.String("key", key), zap.Error(err))
+	}
+}
+
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
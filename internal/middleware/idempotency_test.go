@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	idempotencyservice "github.com/7StaSH7/practicum-diploma/internal/service/idempotency"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type memoryIdempotency struct {
+	records    map[string]models.IdempotencyRecord
+	heartbeats atomic.Int32
+}
+
+func (m *memoryIdempotency) Begin(_ context.Context, _ uuid.UUID, key string, requestHash []byte) (models.IdempotencyRecord, bool, error) {
+	existing, ok := m.records[key]
+	if !ok {
+		m.records[key] = models.IdempotencyRecord{Key: key, RequestHash: requestHash}
+		return models.IdempotencyRecord{}, false, nil
+	}
+	if !bytes.Equal(existing.RequestHash, requestHash) {
+		return models.IdempotencyRecord{}, false, idempotencyservice.ErrKeyReused
+	}
+	if existing.CompletedAt.IsZero() {
+		return models.IdempotencyRecord{}, false, idempotencyservice.ErrInProgress
+	}
+	return existing, true, nil
+}
+
+func (m *memoryIdempotency) Complete(_ context.Context, _ uuid.UUID, key string, statusCode int, contentType string, body []byte) error {
+	record := m.records[key]
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Body = append([]byte(nil), body...)
+	record.CompletedAt = time.Now()
+	m.records[key] = record
+	return nil
+}
+
+func (m *memoryIdempotency) Release(_ context.Context, _ uuid.UUID, key string) error {
+	delete(m.records, key)
+	return nil
+}
+
+func (m *memoryIdempotency) Heartbeat(context.Context, uuid.UUID, string) error {
+	m.heartbeats.Add(1)
+	return nil
+}
+
+func (m *memoryIdempotency) PurgeExpired(context.Context) (int64, error) {
+	return 0, nil
+}
+
+func newIdempotencyRouter(store *memoryIdempotency, calls *int, status int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	userID := uuid.NewString()
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(UserIDKey, userID)
+		c.Next()
+	})
+	r.Use(IdempotencyMiddleware(store, zap.NewNop()))
+	r.POST("/secrets", func(c *gin.Context) {
+		*calls++
+		c.JSON(status, gin.H{"call": *calls})
+	})
+	return r
+}
+
+func postWithKey(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/secrets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, key)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyMiddlewareReplaysStoredResponse(t *testing.T) {
+	store := &memoryIdempotency{records: make(map[string]models.IdempotencyRecord)}
+	calls := 0
+	r := newIdempotencyRouter(store, &calls, http.StatusOK)
+
+	first := postWithKey(r, "k1", `{"a":1}`)
+	second := postWithKey(r, "k1", `{"a":1}`)
+
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+}
+
+func TestIdempotencyMiddlewareRejectsDifferentBody(t *testing.T) {
+	store := &memoryIdempotency{records: make(map[string]models.IdempotencyRecord)}
+	calls := 0
+	r := newIdempotencyRouter(store, &calls, http.StatusOK)
+
+	postWithKey(r, "k1", `{"a":1}`)
+	w := postWithKey(r, "k1", `{"a":2}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotencyMiddlewareReleasesKeyOnServerError(t *testing.T) {
+	store := &memoryIdempotency{records: make(map[string]models.IdempotencyRecord)}
+	calls := 0
+	r := newIdempotencyRouter(store, &calls, http.StatusInternalServerError)
+
+	postWithKey(r, "k1", `{}`)
+	postWithKey(r, "k1", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, store.records)
+}
+
+func TestIdempotencyMiddlewarePassesRawUploadsThrough(t *testing.T) {
+	store := &memoryIdempotency{records: make(map[string]models.IdempotencyRecord)}
+	calls := 0
+	r := newIdempotencyRouter(store, &calls, http.StatusOK)
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/secrets", strings.NewReader("chunk"))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, store.records)
+}
+
+func TestIdempotencyMiddlewareAsksRetryWhileInFlight(t *testing.T) {
+	store := &memoryIdempotency{records: make(map[string]models.IdempotencyRecord)}
+	calls := 0
+	r := newIdempotencyRouter(store, &calls, http.StatusOK)
+	first := httptest.NewRequest(http.MethodPost, "/secrets", strings.NewReader(`{}`))
+	store.records["k1"] = models.IdempotencyRecord{Key: "k1", RequestHash: requestHash(first, []byte(`{}`))}
+
+	w := postWithKey(r, "k1", `{}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, inProgressRetryAfter, w.Header().Get("Retry-After"))
+	assert.Zero(t, calls)
+}
+
+func TestIdempotencyMiddlewareHeartbeatsWhileRunning(t *testing.T) {
+	prev := idempotencyHeartbeat
+	idempotencyHeartbeat = time.Millisecond
+	t.Cleanup(func() { idempotencyHeartbeat = prev })
+
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotency{records: make(map[string]models.IdempotencyRecord)}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(UserIDKey, uuid.NewString())
+		c.Next()
+	})
+	r.Use(IdempotencyMiddleware(store, zap.NewNop()))
+	r.POST("/secrets", func(c *gin.Context) {
+		time.Sleep(20 * time.Millisecond)
+		c.Status(http.StatusNoContent)
+	})
+
+	w := postWithKey(r, "k1", `{}`)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	beats := store.heartbeats.Load()
+	assert.Positive(t, beats)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, beats, store.heartbeats.Load(), "heartbeat must stop with the request")
+}
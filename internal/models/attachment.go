@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment is a client-encrypted blob linked to a secret. Uploads are
+// appended in chunks; Received tracks how much of Size has been stored and a
+// non-zero CompletedAt marks the blob as complete and downloadable.
+type Attachment struct {
+	ID          uuid.UUID
+	SecretID    uuid.UUID
+	UserID      uuid.UUID
+	Name        string
+	Size        int64
+	Received    int64
+	BlobKey     string
+	CreatedAt   time.Time
+	CompletedAt time.Time
+}
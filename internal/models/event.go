@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of secret events written by the secrets table trigger.
+const (
+	SecretEventCreated  = "created"
+	SecretEventUpdated  = "updated"
+	SecretEventDeleted  = "deleted"
+	SecretEventRestored = "restored"
+	SecretEventPurged   = "purged"
+)
+
+type SecretEvent struct {
+	Seq       int64
+	UserID    uuid.UUID
+	SecretID  uuid.UUID
+	Kind      string
+	Version   int64
+	CreatedAt time.Time
+}
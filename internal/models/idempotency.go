@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyRecord is the stored outcome of a mutating request sent with an
+// Idempotency-Key header. A zero CompletedAt means the request is in flight.
+type IdempotencyRecord struct {
+	UserID      uuid.UUID
+	Key         string
+	RequestHash []byte
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	CompletedAt time.Time
+}
@@ -10,6 +10,12 @@ type MetaOpen struct {
 	Title string   `json:"title"`
 	Tags  []string `json:"tags,omitempty"`
 	Site  string   `json:"site,omitempty"`
+	// Match is how Site is compared with page URLs: "domain" (also when
+	// empty), "host", or "regex", in which case Site is the expression.
+	Match string `json:"match,omitempty"`
+	// Keys maps payload fields to the variable names plaintext exports use
+	// for them, e.g. {"password": "DB_PASSWORD"}.
+	Keys map[string]string `json:"keys,omitempty"`
 }
 
 type Secret struct {
@@ -20,4 +26,16 @@ type Secret struct {
 	Ciphertext []byte
 	Version    int64
 	UpdatedAt  time.Time
+	DeletedAt  time.Time
+}
+
+type SecretVersion struct {
+	SecretID   uuid.UUID
+	UserID     uuid.UUID
+	Version    int64
+	Type       string
+	MetaOpen   MetaOpen
+	Ciphertext []byte
+	UpdatedAt  time.Time
+	ArchivedAt time.Time
 }
@@ -0,0 +1,165 @@
+// Package passgen generates random passwords and diceware passphrases from
+// crypto/rand and estimates their entropy.
+package passgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+const (
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!#$%&*+-.:;=?@^_~"
+	// ambiguousChars look alike in common fonts.
+	ambiguousChars = "Il1O0o"
+
+	MinLength = 8
+	MaxLength = 128
+)
+
+var ErrInvalidPolicy = errors.New("invalid generator policy")
+
+// Policy describes a random password.
+type Policy struct {
+	Length  int
+	Lower   bool
+	Upper   bool
+	Digits  bool
+	Symbols bool
+	// ExcludeAmbiguous drops characters such as 0/O and 1/l/I.
+	ExcludeAmbiguous bool
+	// RequireEach guarantees at least one character of every enabled class.
+	RequireEach bool
+}
+
+// DefaultPolicy is a 20 character password using every class.
+func DefaultPolicy() Policy {
+	return Policy{Length: 20, Lower: true, Upper: true, Digits: true, Symbols: true, RequireEach: true}
+}
+
+// Generate returns a password matching p.
+func (p Policy) Generate() (string, error) {
+	classes, err := p.classes()
+	if err != nil {
+		return "", err
+	}
+	alphabet := strings.Join(classes, "")
+
+	out := make([]byte, 0, p.Length)
+	if p.RequireEach {
+		for _, class := range classes {
+			c, err := pick(class)
+			if err != nil {
+				return "", err
+			}
+			out = append(out, c)
+		}
+	}
+	for len(out) < p.Length {
+		c, err := pick(alphabet)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, c)
+	}
+	if err := shuffle(out); err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Entropy estimates the strength of passwords generated by p in bits. The
+// small loss from RequireEach is ignored.
+func (p Policy) Entropy() float64 {
+	classes, err := p.classes()
+	if err != nil {
+		return 0
+	}
+	return float64(p.Length) * math.Log2(float64(len(strings.Join(classes, ""))))
+}
+
+func (p Policy) classes() ([]string, error) {
+	if p.Length < MinLength || p.Length > MaxLength {
+		return nil, fmt.Errorf("%w: length must be %d to %d", ErrInvalidPolicy, MinLength, MaxLength)
+	}
+	var classes []string
+	for _, class := range []struct {
+		enabled bool
+		chars   string
+	}{
+		{p.Lower, lowerChars},
+		{p.Upper, upperChars},
+		{p.Digits, digitChars},
+		{p.Symbols, symbolChars},
+	} {
+		if !class.enabled {
+			continue
+		}
+		chars := class.chars
+		if p.ExcludeAmbiguous {
+			chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(ambiguousChars, r) {
+					return -1
+				}
+				return r
+			}, chars)
+		}
+		classes = append(classes, chars)
+	}
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("%w: enable at least one character class", ErrInvalidPolicy)
+	}
+	if p.RequireEach && len(classes) > p.Length {
+		return nil, fmt.Errorf("%w: length is shorter than the number of required classes", ErrInvalidPolicy)
+	}
+	return classes, nil
+}
+
+// Strength buckets an entropy estimate for display.
+func Strength(bits float64) string {
+	switch {
+	case bits < 50:
+		return "weak"
+	case bits < 80:
+		return "fair"
+	case bits < 110:
+		return "strong"
+	default:
+		return "very strong"
+	}
+}
+
+func pick(chars string) (byte, error) {
+	i, err := randIndex(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+// shuffle is a Fisher-Yates shuffle so required characters do not sit at
+// predictable positions.
+func shuffle(b []byte) error {
+	for i := len(b) - 1; i > 0; i-- {
+		j, err := randIndex(i + 1)
+		if err != nil {
+			return err
+		}
+		b[i], b[j] = b[j], b[i]
+	}
+	return nil
+}
+
+func randIndex(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
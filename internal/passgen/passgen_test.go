@@ -0,0 +1,61 @@
+package passgen
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateHonoursPolicy(t *testing.T) {
+	policy := Policy{Length: 12, Lower: true, Digits: true, ExcludeAmbiguous: true, RequireEach: true}
+	for range 200 {
+		password, err := policy.Generate()
+		require.NoError(t, err)
+		assert.Len(t, password, 12)
+		assert.True(t, strings.ContainsAny(password, lowerChars))
+		assert.True(t, strings.ContainsAny(password, digitChars))
+		assert.False(t, strings.ContainsAny(password, upperChars+symbolChars))
+		assert.False(t, strings.ContainsAny(password, ambiguousChars), password)
+	}
+}
+
+func TestGenerateRejectsInvalidPolicies(t *testing.T) {
+	for _, policy := range []Policy{
+		{Length: 4, Lower: true},
+		{Length: 20},
+		{Length: MaxLength + 1, Lower: true},
+	} {
+		_, err := policy.Generate()
+		assert.ErrorIs(t, err, ErrInvalidPolicy)
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	policy := Policy{Length: 10, Digits: true}
+	assert.InDelta(t, 10*math.Log2(10), policy.Entropy(), 1e-9)
+
+	passphrase := PassphrasePolicy{Words: 6}
+	assert.InDelta(t, 6*math.Log2(7776), passphrase.Entropy(), 1e-9)
+	assert.Equal(t, "very strong", Strength(DefaultPolicy().Entropy()))
+	assert.Equal(t, "weak", Strength(Policy{Length: 8, Digits: true}.Entropy()))
+}
+
+func TestPassphraseUsesEmbeddedWordlist(t *testing.T) {
+	require.Len(t, wordlist(), 7776)
+	assert.Equal(t, "abacus", wordlist()[0])
+
+	phrase, err := PassphrasePolicy{Words: 5, Separator: " ", Capitalize: true, Number: true}.Generate()
+	require.NoError(t, err)
+	words := strings.Split(phrase, " ")
+	require.Len(t, words, 5)
+	for _, word := range words {
+		assert.Equal(t, strings.ToUpper(word[:1]), word[:1])
+	}
+	assert.True(t, strings.ContainsAny(phrase, digitChars))
+
+	_, err = PassphrasePolicy{Words: 2}.Generate()
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+}
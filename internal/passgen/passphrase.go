@@ -0,0 +1,95 @@
+package passgen
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// effLargeWordlist is the EFF large diceware list (CC BY 3.0 US):
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases
+//
+//go:embed eff_large_wordlist.txt
+var effLargeWordlist string
+
+var wordlist = sync.OnceValue(func() []string {
+	lines := strings.Split(strings.TrimSpace(effLargeWordlist), "\n")
+	words := make([]string, 0, len(lines))
+	for _, line := range lines {
+		_, word, _ := strings.Cut(line, "\t")
+		words = append(words, strings.TrimSpace(word))
+	}
+	return words
+})
+
+const (
+	MinWords = 4
+	MaxWords = 20
+)
+
+// PassphrasePolicy describes a diceware passphrase.
+type PassphrasePolicy struct {
+	Words      int
+	Separator  string
+	Capitalize bool
+	// Number appends a random digit to one of the words.
+	Number bool
+}
+
+// DefaultPassphrasePolicy is six words joined by dashes.
+func DefaultPassphrasePolicy() PassphrasePolicy {
+	return PassphrasePolicy{Words: 6, Separator: "-"}
+}
+
+// Generate returns a passphrase matching p.
+func (p PassphrasePolicy) Generate() (string, error) {
+	if err := p.validate(); err != nil {
+		return "", err
+	}
+	list := wordlist()
+	words := make([]string, p.Words)
+	for i := range words {
+		idx, err := randIndex(len(list))
+		if err != nil {
+			return "", err
+		}
+		words[i] = list[idx]
+		if p.Capitalize {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	if p.Number {
+		at, err := randIndex(len(words))
+		if err != nil {
+			return "", err
+		}
+		digit, err := randIndex(10)
+		if err != nil {
+			return "", err
+		}
+		words[at] += strconv.Itoa(digit)
+	}
+	return strings.Join(words, p.Separator), nil
+}
+
+// Entropy estimates the strength of passphrases generated by p in bits.
+func (p PassphrasePolicy) Entropy() float64 {
+	if p.validate() != nil {
+		return 0
+	}
+	bits := float64(p.Words) * math.Log2(float64(len(wordlist())))
+	if p.Number {
+		bits += math.Log2(10) + math.Log2(float64(p.Words))
+	}
+	return bits
+}
+
+func (p PassphrasePolicy) validate() error {
+	if p.Words < MinWords || p.Words > MaxWords {
+		return fmt.Errorf("%w: words must be %d to %d", ErrInvalidPolicy, MinWords, MaxWords)
+	}
+	return nil
+}
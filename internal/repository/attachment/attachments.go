@@ -0,0 +1,202 @@
+package attachment
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/google/uuid"
+)
+
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment models.Attachment) error
+	Get(ctx context.Context, id uuid.UUID, userID uuid.UUID) (models.Attachment, error)
+	ListBySecret(ctx context.Context, secretID uuid.UUID, userID uuid.UUID) ([]models.Attachment, error)
+	Advance(ctx context.Context, id uuid.UUID, userID uuid.UUID, from int64, to int64, at time.Time) error
+	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	DeleteIncomplete(ctx context.Context, before time.Time) (int64, error)
+	ListGarbage(ctx context.Context, limit int) ([]string, error)
+	ForgetGarbage(ctx context.Context, blobKey string) error
+}
+
+type attachmentRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository(db *sql.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (r *attachmentRepository) Create(ctx context.Context, attachment models.Attachment) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO attachments (id, secret_id, user_id, name, size, received, blob_key, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		attachment.ID,
+		attachment.SecretID,
+		attachment.UserID,
+		attachment.Name,
+		attachment.Size,
+		attachment.Received,
+		attachment.BlobKey,
+		attachment.CreatedAt,
+	)
+	return err
+}
+
+func (r *attachmentRepository) Get(ctx context.Context, id uuid.UUID, userID uuid.UUID) (models.Attachment, error) {
+	row := r.db.QueryRowContext(
+		ctx,
+		`SELECT id, secret_id, user_id, name, size, received, blob_key, created_at, completed_at
+		 FROM attachments WHERE id = $1 AND user_id = $2`,
+		id,
+		userID,
+	)
+	return scanAttachment(row)
+}
+
+func (r *attachmentRepository) ListBySecret(ctx context.Context, secretID uuid.UUID, userID uuid.UUID) ([]models.Attachment, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, secret_id, user_id, name, size, received, blob_key, created_at, completed_at
+		 FROM attachments WHERE secret_id = $1 AND user_id = $2
+		 ORDER BY created_at ASC`,
+		secretID,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make([]models.Attachment, 0)
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// Advance moves the upload offset from one position to the next and marks the
+// attachment complete once every byte has arrived. It returns sql.ErrNoRows
+// when the stored offset is no longer from, so concurrent writers of the same
+// chunk cannot both advance it.
+func (r *attachmentRepository) Advance(ctx context.Context, id uuid.UUID, userID uuid.UUID, from int64, to int64, at time.Time) error {
+	result, err := r.db.ExecContext(
+		ctx,
+		`UPDATE attachments
+		 SET received = $4, completed_at = CASE WHEN $4 = size THEN $5::timestamptz ELSE NULL END
+		 WHERE id = $1 AND user_id = $2 AND received = $3 AND completed_at IS NULL`,
+		id,
+		userID,
+		from,
+		to,
+		at,
+	)
+	return requireAffected(result, err)
+}
+
+func (r *attachmentRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	result, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM attachments WHERE id = $1 AND user_id = $2`,
+		id,
+		userID,
+	)
+	return requireAffected(result, err)
+}
+
+func (r *attachmentRepository) DeleteIncomplete(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM attachments WHERE completed_at IS NULL AND created_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *attachmentRepository) ListGarbage(ctx context.Context, limit int) ([]string, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT blob_key FROM attachment_blob_gc ORDER BY queued_at ASC LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]string, 0)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *attachmentRepository) ForgetGarbage(ctx context.Context, blobKey string) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM attachment_blob_gc WHERE blob_key = $1`,
+		blobKey,
+	)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAttachment(row scanner) (models.Attachment, error) {
+	var (
+		attachment  models.Attachment
+		completedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&attachment.ID,
+		&attachment.SecretID,
+		&attachment.UserID,
+		&attachment.Name,
+		&attachment.Size,
+		&attachment.Received,
+		&attachment.BlobKey,
+		&attachment.CreatedAt,
+		&completedAt,
+	); err != nil {
+		return models.Attachment{}, err
+	}
+	if completedAt.Valid {
+		attachment.CompletedAt = completedAt.Time
+	}
+	return attachment, nil
+}
+
+func requireAffected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package attachment
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachmentRepositoryGet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewAttachmentRepository(db)
+	id := uuid.New()
+	secretID := uuid.New()
+	userID := uuid.New()
+	createdAt := time.Now().UTC()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, secret_id, user_id, name, size, received, blob_key, created_at, completed_at
+		 FROM attachments WHERE id = $1 AND user_id = $2`)).
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "secret_id", "user_id", "name", "size", "received", "blob_key", "created_at", "completed_at"}).
+			AddRow(id, secretID, userID, "key.pem", int64(10), int64(4), "42", createdAt, nil))
+
+	attachment, err := repo.Get(context.Background(), id, userID)
+	require.NoError(t, err)
+	assert.Equal(t, models.Attachment{
+		ID:        id,
+		SecretID:  secretID,
+		UserID:    userID,
+		Name:      "key.pem",
+		Size:      10,
+		Received:  4,
+		BlobKey:   "42",
+		CreatedAt: createdAt,
+	}, attachment)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttachmentRepositoryAdvanceConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewAttachmentRepository(db)
+	id := uuid.New()
+	userID := uuid.New()
+	at := time.Now().UTC()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE attachments
+		 SET received = $4, completed_at = CASE WHEN $4 = size THEN $5::timestamptz ELSE NULL END
+		 WHERE id = $1 AND user_id = $2 AND received = $3 AND completed_at IS NULL`)).
+		WithArgs(id, userID, int64(0), int64(4), at).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.Advance(context.Background(), id, userID, 0, 4, at)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttachmentRepositoryListGarbage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewAttachmentRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT blob_key FROM attachment_blob_gc ORDER BY queued_at ASC LIMIT $1`)).
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"blob_key"}).AddRow("1").AddRow("2"))
+
+	keys, err := repo.ListGarbage(context.Background(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, keys)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
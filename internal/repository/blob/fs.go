@@ -0,0 +1,87 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+type fsStore struct {
+	dir string
+}
+
+// NewFSStore stores each blob as a file named by a random UUID under dir.
+func NewFSStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fsStore{dir: dir}, nil
+}
+
+func (s *fsStore) Create(_ context.Context) (string, error) {
+	key := uuid.NewString()
+	file, err := os.OpenFile(filepath.Join(s.dir, key), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+	return key, file.Close()
+}
+
+func (s *fsStore) WriteAt(_ context.Context, key string, offset int64, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return mapFSError(err)
+	}
+	if _, err := file.WriteAt(data, offset); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s *fsStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, mapFSError(err)
+	}
+	return file, nil
+}
+
+func (s *fsStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path only accepts keys minted by Create, so a key can never escape dir.
+func (s *fsStore) path(key string) (string, error) {
+	if _, err := uuid.Parse(key); err != nil {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+func mapFSError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSStoreWritesAtOffsets(t *testing.T) {
+	store, err := NewFSStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	key, err := store.Create(ctx)
+	require.NoError(t, err)
+	require.NoError(t, store.WriteAt(ctx, key, 0, []byte("hello ")))
+	require.NoError(t, store.WriteAt(ctx, key, 6, []byte("world")))
+	// Re-sending a chunk after a lost response rewrites the same bytes.
+	require.NoError(t, store.WriteAt(ctx, key, 6, []byte("world")))
+
+	reader, err := store.Open(ctx, key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "hello world", string(data))
+
+	require.NoError(t, store.Delete(ctx, key))
+	require.NoError(t, store.Delete(ctx, key))
+	_, err = store.Open(ctx, key)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFSStoreRejectsForeignKeys(t *testing.T) {
+	store, err := NewFSStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = store.Open(context.Background(), "../../etc/passwd")
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,128 @@
+package blob
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// readChunk bounds how much of a large object a single lo_get call returns.
+const readChunk = 1 << 20
+
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore keeps blobs as PostgreSQL large objects keyed by their OID.
+// The server-side lo_* functions are used so no transaction has to stay open
+// across the chunks of an upload or download.
+func NewPostgresStore(db *sql.DB) Store {
+	return &postgresStore{db: db}
+}
+
+func (s *postgresStore) Create(ctx context.Context) (string, error) {
+	var oid int64
+	if err := s.db.QueryRowContext(ctx, `SELECT lo_create(0)`).Scan(&oid); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(oid, 10), nil
+}
+
+func (s *postgresStore) WriteAt(ctx context.Context, key string, offset int64, data []byte) error {
+	oid, err := parseOID(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `SELECT lo_put($1::oid, $2, $3)`, oid, offset, data)
+	return err
+}
+
+func (s *postgresStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	oid, err := parseOID(key)
+	if err != nil {
+		return nil, err
+	}
+	var exists bool
+	if err := s.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM pg_largeobject_metadata WHERE oid = $1::oid)`,
+		oid,
+	).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &largeObjectReader{ctx: ctx, db: s.db, oid: oid}, nil
+}
+
+func (s *postgresStore) Delete(ctx context.Context, key string) error {
+	oid, err := parseOID(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(
+		ctx,
+		`SELECT lo_unlink(oid) FROM pg_largeobject_metadata WHERE oid = $1::oid`,
+		oid,
+	)
+	return err
+}
+
+func parseOID(key string) (int64, error) {
+	oid, err := strconv.ParseInt(key, 10, 64)
+	if err != nil || oid <= 0 {
+		return 0, ErrNotFound
+	}
+	return oid, nil
+}
+
+type largeObjectReader struct {
+	ctx    context.Context
+	db     *sql.DB
+	oid    int64
+	offset int64
+	buf    []byte
+	eof    bool
+}
+
+func (r *largeObjectReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		var chunk []byte
+		err := r.db.QueryRowContext(
+			r.ctx,
+			`SELECT lo_get($1::oid, $2, $3)`,
+			r.oid,
+			r.offset,
+			readChunk,
+		).Scan(&chunk)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		if err != nil {
+			return 0, err
+		}
+		r.offset += int64(len(chunk))
+		r.buf = chunk
+		if len(chunk) < readChunk {
+			r.eof = true
+		}
+		if len(chunk) == 0 {
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *largeObjectReader) Close() error {
+	r.buf = nil
+	r.eof = true
+	return nil
+}
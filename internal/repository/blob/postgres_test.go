@@ -0,0 +1,58 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStoreOpenReadsInChunks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	store := NewPostgresStore(db)
+	full := make([]byte, readChunk)
+	tail := []byte("tail")
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM pg_largeobject_metadata WHERE oid = $1::oid)`)).
+		WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT lo_get($1::oid, $2, $3)`)).
+		WithArgs(int64(42), int64(0), readChunk).
+		WillReturnRows(sqlmock.NewRows([]string{"lo_get"}).AddRow(full))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT lo_get($1::oid, $2, $3)`)).
+		WithArgs(int64(42), int64(readChunk), readChunk).
+		WillReturnRows(sqlmock.NewRows([]string{"lo_get"}).AddRow(tail))
+
+	reader, err := store.Open(context.Background(), "42")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Len(t, data, readChunk+len(tail))
+	assert.Equal(t, tail, data[readChunk:])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStoreOpenMissingObject(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	store := NewPostgresStore(db)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM pg_largeobject_metadata WHERE oid = $1::oid)`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err = store.Open(context.Background(), "7")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = store.Open(context.Background(), "not-an-oid")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
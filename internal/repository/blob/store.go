@@ -0,0 +1,35 @@
+package blob
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps attachment bytes outside the attachments table. Blobs are
+// written at explicit offsets so an interrupted upload can resume where the
+// last stored chunk ended.
+type Store interface {
+	Create(ctx context.Context) (string, error)
+	WriteAt(ctx context.Context, key string, offset int64, data []byte) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStore selects the blob backend configured by BLOB_STORE.
+func NewStore(cfg config.Config, db *sql.DB) (Store, error) {
+	switch cfg.BlobStore {
+	case "", "postgres":
+		return NewPostgresStore(db), nil
+	case "fs":
+		return NewFSStore(cfg.BlobDir)
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", cfg.BlobStore)
+	}
+}
@@ -0,0 +1,78 @@
+package event
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/google/uuid"
+)
+
+type EventRepository interface {
+	ListAfter(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]models.SecretEvent, error)
+	LatestSeq(ctx context.Context, userID uuid.UUID) (int64, error)
+	PurgeBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type eventRepository struct {
+	db *sql.DB
+}
+
+func NewEventRepository(db *sql.DB) EventRepository {
+	return &eventRepository{db: db}
+}
+
+// ListAfter returns the user's events with seq above after. Events are
+// recorded at commit under a per-user lock, so a user's seq follows commit
+// order and nothing can later appear below a seq already returned.
+func (r *eventRepository) ListAfter(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]models.SecretEvent, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT seq, user_id, secret_id, kind, version, created_at
+		 FROM secret_events WHERE user_id = $1 AND seq > $2
+		 ORDER BY seq ASC LIMIT $3`,
+		userID,
+		after,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]models.SecretEvent, 0)
+	for rows.Next() {
+		var event models.SecretEvent
+		if err := rows.Scan(&event.Seq, &event.UserID, &event.SecretID, &event.Kind, &event.Version, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *eventRepository) LatestSeq(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var seq int64
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(MAX(seq), 0) FROM secret_events WHERE user_id = $1`,
+		userID,
+	).Scan(&seq)
+	return seq, err
+}
+
+func (r *eventRepository) PurgeBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM secret_events WHERE created_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,82 @@
+package event
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+)
+
+// testPostgresDSNEnv points the Postgres tests at a disposable database;
+// they migrate it to the latest schema. Without it they are skipped.
+const testPostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+func openTestPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(testPostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testPostgresDSNEnv)
+	}
+	m, err := migrate.New("file://../../../migrations", dsn)
+	require.NoError(t, err)
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		require.NoError(t, err)
+	}
+	_, _ = m.Close()
+
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestEventsOfOutOfOrderCommitsAreNotSkipped(t *testing.T) {
+	db := openTestPostgres(t)
+	ctx := context.Background()
+	userID := uuid.New()
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO users (id, login, password_hash, kdf_salt) VALUES ($1, $2, $3, $4)`,
+		userID, "events-"+userID.String(), []byte("hash"), []byte("salt"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID) })
+	insertSecret := func(tx *sql.Tx) uuid.UUID {
+		id := uuid.New()
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO secrets (id, user_id, type, ciphertext) VALUES ($1, $2, 'note', $3)`,
+			id, userID, []byte("c"))
+		require.NoError(t, err)
+		return id
+	}
+
+	// first writes before second but commits after it.
+	first, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer func() { _ = first.Rollback() }()
+	firstID := insertSecret(first)
+
+	second, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	secondID := insertSecret(second)
+	require.NoError(t, second.Commit())
+
+	repo := NewEventRepository(db)
+	events, err := repo.ListAfter(ctx, userID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, secondID, events[0].SecretID)
+	cursor := events[0].Seq
+
+	require.NoError(t, first.Commit())
+	events, err = repo.ListAfter(ctx, userID, cursor, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "the later commit must come after the cursor")
+	require.Equal(t, firstID, events[0].SecretID)
+}
@@ -0,0 +1,63 @@
+package event
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventRepositoryListAfter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewEventRepository(db)
+	userID := uuid.New()
+	secretID := uuid.New()
+	createdAt := time.Now().UTC()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT seq, user_id, secret_id, kind, version, created_at
+		 FROM secret_events WHERE user_id = $1 AND seq > $2
+		 ORDER BY seq ASC LIMIT $3`)).
+		WithArgs(userID, int64(10), 50).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "user_id", "secret_id", "kind", "version", "created_at"}).
+			AddRow(int64(11), userID, secretID, models.SecretEventUpdated, int64(2), createdAt))
+
+	events, err := repo.ListAfter(context.Background(), userID, 10, 50)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.SecretEvent{
+		Seq:       11,
+		UserID:    userID,
+		SecretID:  secretID,
+		Kind:      models.SecretEventUpdated,
+		Version:   2,
+		CreatedAt: createdAt,
+	}, events[0])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventRepositoryLatestSeq(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewEventRepository(db)
+	userID := uuid.New()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(seq), 0) FROM secret_events WHERE user_id = $1`)).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"seq"}).AddRow(int64(42)))
+
+	seq, err := repo.LatestSeq(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), seq)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,140 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/google/uuid"
+)
+
+type KeyRepository interface {
+	Reserve(ctx context.Context, record models.IdempotencyRecord, staleBefore time.Time) (bool, error)
+	Get(ctx context.Context, userID uuid.UUID, key string) (models.IdempotencyRecord, error)
+	Complete(ctx context.Context, record models.IdempotencyRecord) error
+	Release(ctx context.Context, userID uuid.UUID, key string) error
+	Touch(ctx context.Context, userID uuid.UUID, key string, at time.Time) error
+	PurgeBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type keyRepository struct {
+	db *sql.DB
+}
+
+func NewKeyRepository(db *sql.DB) KeyRepository {
+	return &keyRepository{db: db}
+}
+
+// Reserve claims a key for a new request. A pending reservation whose last
+// heartbeat is older than staleBefore with the same request hash is taken
+// over, so a request that died mid-flight does not block retries until the key
+// expires.
+func (r *keyRepository) Reserve(ctx context.Context, record models.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+	result, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO idempotency_keys (user_id, key, request_hash, created_at)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (user_id, key) DO UPDATE SET created_at = EXCLUDED.created_at
+		 WHERE idempotency_keys.completed_at IS NULL
+		   AND idempotency_keys.created_at < $5
+		   AND idempotency_keys.request_hash = EXCLUDED.request_hash`,
+		record.UserID,
+		record.Key,
+		record.RequestHash,
+		record.CreatedAt,
+		staleBefore,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *keyRepository) Get(ctx context.Context, userID uuid.UUID, key string) (models.IdempotencyRecord, error) {
+	row := r.db.QueryRowContext(
+		ctx,
+		`SELECT user_id, key, request_hash, status_code, content_type, response_body, created_at, completed_at
+		 FROM idempotency_keys WHERE user_id = $1 AND key = $2`,
+		userID,
+		key,
+	)
+	var (
+		record      models.IdempotencyRecord
+		statusCode  sql.NullInt64
+		completedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&record.UserID,
+		&record.Key,
+		&record.RequestHash,
+		&statusCode,
+		&record.ContentType,
+		&record.Body,
+		&record.CreatedAt,
+		&completedAt,
+	); err != nil {
+		return models.IdempotencyRecord{}, err
+	}
+	record.StatusCode = int(statusCode.Int64)
+	if completedAt.Valid {
+		record.CompletedAt = completedAt.Time
+	}
+	return record, nil
+}
+
+func (r *keyRepository) Complete(ctx context.Context, record models.IdempotencyRecord) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`UPDATE idempotency_keys
+		 SET status_code = $3, content_type = $4, response_body = $5, completed_at = $6
+		 WHERE user_id = $1 AND key = $2`,
+		record.UserID,
+		record.Key,
+		record.StatusCode,
+		record.ContentType,
+		record.Body,
+		record.CompletedAt,
+	)
+	return err
+}
+
+func (r *keyRepository) Release(ctx context.Context, userID uuid.UUID, key string) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND completed_at IS NULL`,
+		userID,
+		key,
+	)
+	return err
+}
+
+// Touch refreshes the heartbeat of a pending reservation so that retries keep
+// waiting for the request holding it instead of taking the key over.
+func (r *keyRepository) Touch(ctx context.Context, userID uuid.UUID, key string, at time.Time) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`UPDATE idempotency_keys SET created_at = $3
+		 WHERE user_id = $1 AND key = $2 AND completed_at IS NULL`,
+		userID,
+		key,
+		at,
+	)
+	return err
+}
+
+func (r *keyRepository) PurgeBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE created_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
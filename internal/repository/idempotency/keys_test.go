@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRepositoryReserve(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewKeyRepository(db)
+	record := models.IdempotencyRecord{
+		UserID:      uuid.New(),
+		Key:         "key-1",
+		RequestHash: []byte("hash"),
+		CreatedAt:   time.Now().UTC(),
+	}
+	staleBefore := record.CreatedAt.Add(-time.Minute)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO idempotency_keys (user_id, key, request_hash, created_at)`)).
+		WithArgs(record.UserID, record.Key, record.RequestHash, record.CreatedAt, staleBefore).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	reserved, err := repo.Reserve(context.Background(), record, staleBefore)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestKeyRepositoryGetPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewKeyRepository(db)
+	userID := uuid.New()
+	createdAt := time.Now().UTC()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT user_id, key, request_hash, status_code, content_type, response_body, created_at, completed_at
+		 FROM idempotency_keys WHERE user_id = $1 AND key = $2`)).
+		WithArgs(userID, "key-1").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "key", "request_hash", "status_code", "content_type", "response_body", "created_at", "completed_at"}).
+			AddRow(userID, "key-1", []byte("hash"), nil, "", nil, createdAt, nil))
+
+	got, err := repo.Get(context.Background(), userID, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, got.StatusCode)
+	assert.True(t, got.CompletedAt.IsZero())
+	assert.Equal(t, createdAt, got.CreatedAt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestKeyRepositoryGetNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewKeyRepository(db)
+	userID := uuid.New()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM idempotency_keys WHERE user_id = $1 AND key = $2`)).
+		WithArgs(userID, "missing").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.Get(context.Background(), userID, "missing")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestKeyRepositoryTouch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewKeyRepository(db)
+	userID := uuid.New()
+	at := time.Now().UTC()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE idempotency_keys SET created_at = $3
+		 WHERE user_id = $1 AND key = $2 AND completed_at IS NULL`)).
+		WithArgs(userID, "key-1", at).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, repo.Touch(context.Background(), userID, "key-1", at))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestKeyRepositoryPurgeBefore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewKeyRepository(db)
+	before := time.Now().UTC()
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM idempotency_keys WHERE created_at < $1`)).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	purged, err := repo.PurgeBefore(context.Background(), before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), purged)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
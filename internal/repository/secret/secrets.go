@@ -4,26 +4,76 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/7StaSH7/practicum-diploma/internal/models"
 	"github.com/google/uuid"
 )
 
+// ErrVersionConflict is returned by Update when the stored secret is no
+// longer the version the update was based on.
+var ErrVersionConflict = errors.New("secret version changed")
+
 type SecretRepository interface {
 	Create(ctx context.Context, secret models.Secret) error
 	Update(ctx context.Context, secret models.Secret) error
 	Get(ctx context.Context, id uuid.UUID, userID uuid.UUID) (models.Secret, error)
 	ListSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error)
+	ListChanges(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error)
+	Trash(ctx context.Context, id uuid.UUID, userID uuid.UUID, at time.Time) error
+	ListTrash(ctx context.Context, userID uuid.UUID) ([]models.Secret, error)
+	RestoreTrashed(ctx context.Context, id uuid.UUID, userID uuid.UUID, at time.Time) error
 	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	PurgeTrashed(ctx context.Context, before time.Time) (int64, error)
+	ListVersions(ctx context.Context, id uuid.UUID, userID uuid.UUID) ([]models.SecretVersion, error)
+	GetVersion(ctx context.Context, id uuid.UUID, userID uuid.UUID, version int64) (models.SecretVersion, error)
+	PruneVersions(ctx context.Context, id uuid.UUID, userID uuid.UUID, keep int, before time.Time) error
+	Lock(ctx context.Context, id uuid.UUID, userID uuid.UUID) (models.Secret, error)
+	InTx(ctx context.Context, fn func(repo SecretRepository) error) error
+}
+
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 type secretRepository struct {
-	db *sql.DB
+	db   dbtx
+	conn *sql.DB
 }
 
 func NewSecretRepository(db *sql.DB) SecretRepository {
-	return &secretRepository{db: db}
+	return &secretRepository{db: db, conn: db}
+}
+
+// InTx runs fn against a repository bound to a single transaction. The
+// transaction is committed when fn returns nil and rolled back otherwise.
+// Calls made on a repository that is already transactional reuse it.
+func (r *secretRepository) InTx(ctx context.Context, fn func(repo SecretRepository) error) error {
+	if r.conn == nil {
+		return fn(r)
+	}
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err := fn(&secretRepository{db: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
 }
 
 func (r *secretRepository) Create(ctx context.Context, secret models.Secret) error {
@@ -46,6 +96,9 @@ func (r *secretRepository) Create(ctx context.Context, secret models.Secret) err
 	return err
 }
 
+// Update stores secret.Version in place of the version before it and
+// archives that one. An update based on an older version fails with
+// ErrVersionConflict, so no version is overwritten without being archived.
 func (r *secretRepository) Update(ctx context.Context, secret models.Secret) error {
 	metaBytes, err := json.Marshal(secret.MetaOpen)
 	if err != nil {
@@ -53,9 +106,15 @@ func (r *secretRepository) Update(ctx context.Context, secret models.Secret) err
 	}
 	result, err := r.db.ExecContext(
 		ctx,
-		`UPDATE secrets
+		`WITH archived AS (
+		     INSERT INTO secret_versions (secret_id, user_id, version, type, meta_open, ciphertext, updated_at, archived_at)
+		     SELECT id, user_id, version, type, meta_open, ciphertext, updated_at, $5
+		     FROM secrets WHERE id = $6 AND user_id = $7 AND deleted_at IS NULL AND version = $4 - 1
+		     ON CONFLICT (secret_id, version) DO NOTHING
+		 )
+		 UPDATE secrets
 		 SET type = $1, meta_open = $2, ciphertext = $3, version = $4, updated_at = $5
-		 WHERE id = $6 AND user_id = $7`,
+		 WHERE id = $6 AND user_id = $7 AND deleted_at IS NULL AND version = $4 - 1`,
 		secret.Type,
 		metaBytes,
 		secret.Ciphertext,
@@ -64,24 +123,43 @@ func (r *secretRepository) Update(ctx context.Context, secret models.Secret) err
 		secret.ID,
 		secret.UserID,
 	)
-	if err != nil {
+	if err := requireAffected(result, err); !errors.Is(err, sql.ErrNoRows) {
 		return err
 	}
-	affected, err := result.RowsAffected()
-	if err != nil {
+	var live bool
+	if err := r.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM secrets WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`,
+		secret.ID,
+		secret.UserID,
+	).Scan(&live); err != nil {
 		return err
 	}
-	if affected == 0 {
-		return sql.ErrNoRows
+	if live {
+		return ErrVersionConflict
 	}
-	return nil
+	return sql.ErrNoRows
 }
 
 func (r *secretRepository) Get(ctx context.Context, id uuid.UUID, userID uuid.UUID) (models.Secret, error) {
 	row := r.db.QueryRowContext(
 		ctx,
 		`SELECT id, user_id, type, meta_open, ciphertext, version, updated_at
-		 FROM secrets WHERE id = $1 AND user_id = $2`,
+		 FROM secrets WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`,
+		id,
+		userID,
+	)
+	return scanSecret(row)
+}
+
+// Lock reads a live secret and locks its row until the surrounding
+// transaction ends.
+func (r *secretRepository) Lock(ctx context.Context, id uuid.UUID, userID uuid.UUID) (models.Secret, error) {
+	row := r.db.QueryRowContext(
+		ctx,
+		`SELECT id, user_id, type, meta_open, ciphertext, version, updated_at
+		 FROM secrets WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		 FOR UPDATE`,
 		id,
 		userID,
 	)
@@ -92,7 +170,7 @@ func (r *secretRepository) ListSince(ctx context.Context, userID uuid.UUID, sinc
 	rows, err := r.db.QueryContext(
 		ctx,
 		`SELECT id, user_id, type, meta_open, ciphertext, version, updated_at
-		 FROM secrets WHERE user_id = $1 AND updated_at > $2
+		 FROM secrets WHERE user_id = $1 AND updated_at > $2 AND deleted_at IS NULL
 		 ORDER BY updated_at ASC`,
 		userID,
 		since,
@@ -116,33 +194,180 @@ func (r *secretRepository) ListSince(ctx context.Context, userID uuid.UUID, sinc
 	return secrets, nil
 }
 
+// ListChanges is ListSince including trashed secrets, which carry their
+// deletion time, so a client replica can drop them.
+func (r *secretRepository) ListChanges(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, user_id, type, meta_open, ciphertext, version, updated_at, deleted_at
+		 FROM secrets WHERE user_id = $1 AND updated_at > $2
+		 ORDER BY updated_at ASC`,
+		userID,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []models.Secret
+	for rows.Next() {
+		var deletedAt sql.NullTime
+		secret, err := scanSecret(rows, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		secret.DeletedAt = deletedAt.Time
+		secrets = append(secrets, secret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// Trash marks a live secret as deleted. The row stays in the table until it is
+// purged, so it can still be restored from the trash.
+func (r *secretRepository) Trash(ctx context.Context, id uuid.UUID, userID uuid.UUID, at time.Time) error {
+	result, err := r.db.ExecContext(
+		ctx,
+		`UPDATE secrets SET deleted_at = $3, updated_at = $3
+		 WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`,
+		id, userID, at,
+	)
+	return requireAffected(result, err)
+}
+
+func (r *secretRepository) ListTrash(ctx context.Context, userID uuid.UUID) ([]models.Secret, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, user_id, type, meta_open, ciphertext, version, updated_at, deleted_at
+		 FROM secrets WHERE user_id = $1 AND deleted_at IS NOT NULL
+		 ORDER BY deleted_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []models.Secret
+	for rows.Next() {
+		var deletedAt sql.NullTime
+		secret, err := scanSecret(rows, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		secret.DeletedAt = deletedAt.Time
+		secrets = append(secrets, secret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (r *secretRepository) RestoreTrashed(ctx context.Context, id uuid.UUID, userID uuid.UUID, at time.Time) error {
+	result, err := r.db.ExecContext(
+		ctx,
+		`UPDATE secrets SET deleted_at = NULL, updated_at = $3, version = version + 1
+		 WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`,
+		id, userID, at,
+	)
+	return requireAffected(result, err)
+}
+
+// Delete permanently removes a secret that is already in the trash.
 func (r *secretRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
 	result, err := r.db.ExecContext(
 		ctx,
-		`DELETE FROM secrets WHERE id = $1 AND user_id = $2`,
+		`DELETE FROM secrets WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`,
 		id, userID,
 	)
+	return requireAffected(result, err)
+}
+
+func (r *secretRepository) PurgeTrashed(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM secrets WHERE deleted_at IS NOT NULL AND deleted_at < $1`,
+		before,
+	)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	affected, err := result.RowsAffected()
+	return result.RowsAffected()
+}
+
+func (r *secretRepository) ListVersions(ctx context.Context, id uuid.UUID, userID uuid.UUID) ([]models.SecretVersion, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT secret_id, user_id, version, type, meta_open, ciphertext, updated_at, archived_at
+		 FROM secret_versions WHERE secret_id = $1 AND user_id = $2
+		 ORDER BY version DESC`,
+		id,
+		userID,
+	)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if affected == 0 {
-		return sql.ErrNoRows
+	defer rows.Close()
+
+	var versions []models.SecretVersion
+	for rows.Next() {
+		version, err := scanSecretVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
 	}
-	return nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *secretRepository) GetVersion(ctx context.Context, id uuid.UUID, userID uuid.UUID, version int64) (models.SecretVersion, error) {
+	row := r.db.QueryRowContext(
+		ctx,
+		`SELECT secret_id, user_id, version, type, meta_open, ciphertext, updated_at, archived_at
+		 FROM secret_versions WHERE secret_id = $1 AND user_id = $2 AND version = $3`,
+		id,
+		userID,
+		version,
+	)
+	return scanSecretVersion(row)
+}
+
+// PruneVersions keeps at most keep newest versions of a secret and drops the
+// ones archived before the cutoff. A non-positive keep disables the count limit.
+func (r *secretRepository) PruneVersions(ctx context.Context, id uuid.UUID, userID uuid.UUID, keep int, before time.Time) error {
+	limit := sql.NullInt64{Int64: int64(keep), Valid: keep > 0}
+	_, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM secret_versions
+		 WHERE secret_id = $1 AND user_id = $2
+		   AND (archived_at < $3 OR version NOT IN (
+		       SELECT version FROM secret_versions
+		       WHERE secret_id = $1 AND user_id = $2
+		       ORDER BY version DESC LIMIT $4
+		   ))`,
+		id,
+		userID,
+		before,
+		limit,
+	)
+	return err
 }
 
 type scanner interface {
 	Scan(dest ...any) error
 }
 
-func scanSecret(row scanner) (models.Secret, error) {
+func scanSecret(row scanner, extra ...any) (models.Secret, error) {
 	var secret models.Secret
 	var metaBytes []byte
-	if err := row.Scan(
+	dest := []any{
 		&secret.ID,
 		&secret.UserID,
 		&secret.Type,
@@ -150,7 +375,8 @@ func scanSecret(row scanner) (models.Secret, error) {
 		&secret.Ciphertext,
 		&secret.Version,
 		&secret.UpdatedAt,
-	); err != nil {
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
 		return models.Secret{}, err
 	}
 	if len(metaBytes) > 0 {
@@ -160,3 +386,40 @@ func scanSecret(row scanner) (models.Secret, error) {
 	}
 	return secret, nil
 }
+
+func requireAffected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanSecretVersion(row scanner) (models.SecretVersion, error) {
+	var version models.SecretVersion
+	var metaBytes []byte
+	if err := row.Scan(
+		&version.SecretID,
+		&version.UserID,
+		&version.Version,
+		&version.Type,
+		&metaBytes,
+		&version.Ciphertext,
+		&version.UpdatedAt,
+		&version.ArchivedAt,
+	); err != nil {
+		return models.SecretVersion{}, err
+	}
+	if len(metaBytes) > 0 {
+		if err := json.Unmarshal(metaBytes, &version.MetaOpen); err != nil {
+			return models.SecretVersion{}, err
+		}
+	}
+	return version, nil
+}
@@ -63,11 +63,20 @@ func TestSecretRepositoryUpdateNotFound(t *testing.T) {
 	metaBytes, err := json.Marshal(secret.MetaOpen)
 	require.NoError(t, err)
 
-	mock.ExpectExec(regexp.QuoteMeta(`UPDATE secrets
+	mock.ExpectExec(regexp.QuoteMeta(`WITH archived AS (
+		     INSERT INTO secret_versions (secret_id, user_id, version, type, meta_open, ciphertext, updated_at, archived_at)
+		     SELECT id, user_id, version, type, meta_open, ciphertext, updated_at, $5
+		     FROM secrets WHERE id = $6 AND user_id = $7 AND deleted_at IS NULL AND version = $4 - 1
+		     ON CONFLICT (secret_id, version) DO NOTHING
+		 )
+		 UPDATE secrets
 		 SET type = $1, meta_open = $2, ciphertext = $3, version = $4, updated_at = $5
-		 WHERE id = $6 AND user_id = $7`)).
+		 WHERE id = $6 AND user_id = $7 AND deleted_at IS NULL AND version = $4 - 1`)).
 		WithArgs(secret.Type, metaBytes, secret.Ciphertext, secret.Version, secret.UpdatedAt, secret.ID, secret.UserID).
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM secrets WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`)).
+		WithArgs(secret.ID, secret.UserID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 	err = repo.Update(context.Background(), secret)
 	require.Error(t, err)
@@ -75,6 +84,48 @@ func TestSecretRepositoryUpdateNotFound(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+// TestSecretRepositoryUpdateStaleVersion covers two updates based on the same
+// version: the one writing second must not replace the first one unarchived.
+func TestSecretRepositoryUpdateStaleVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewSecretRepository(db)
+	secret := models.Secret{
+		ID:         uuid.New(),
+		UserID:     uuid.New(),
+		Type:       "note",
+		MetaOpen:   models.MetaOpen{Title: "title"},
+		Ciphertext: []byte("cipher"),
+		Version:    2,
+		UpdatedAt:  time.Now().UTC(),
+	}
+
+	metaBytes, err := json.Marshal(secret.MetaOpen)
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta(`WITH archived AS (
+		     INSERT INTO secret_versions (secret_id, user_id, version, type, meta_open, ciphertext, updated_at, archived_at)
+		     SELECT id, user_id, version, type, meta_open, ciphertext, updated_at, $5
+		     FROM secrets WHERE id = $6 AND user_id = $7 AND deleted_at IS NULL AND version = $4 - 1
+		     ON CONFLICT (secret_id, version) DO NOTHING
+		 )
+		 UPDATE secrets
+		 SET type = $1, meta_open = $2, ciphertext = $3, version = $4, updated_at = $5
+		 WHERE id = $6 AND user_id = $7 AND deleted_at IS NULL AND version = $4 - 1`)).
+		WithArgs(secret.Type, metaBytes, secret.Ciphertext, secret.Version, secret.UpdatedAt, secret.ID, secret.UserID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM secrets WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`)).
+		WithArgs(secret.ID, secret.UserID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	err = repo.Update(context.Background(), secret)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestSecretRepositoryGet(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -87,7 +138,7 @@ func TestSecretRepositoryGet(t *testing.T) {
 	metaBytes := []byte(`{"title":"title","tags":["x"]}`)
 
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, type, meta_open, ciphertext, version, updated_at
-		 FROM secrets WHERE id = $1 AND user_id = $2`)).
+		 FROM secrets WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`)).
 		WithArgs(secretID, userID).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "type", "meta_open", "ciphertext", "version", "updated_at"}).
 			AddRow(secretID, userID, "note", metaBytes, []byte("cipher"), int64(3), updatedAt))
@@ -115,7 +166,7 @@ func TestSecretRepositoryListSince(t *testing.T) {
 	updatedAt := time.Now().UTC().Truncate(time.Second)
 
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, type, meta_open, ciphertext, version, updated_at
-		 FROM secrets WHERE user_id = $1 AND updated_at > $2
+		 FROM secrets WHERE user_id = $1 AND updated_at > $2 AND deleted_at IS NULL
 		 ORDER BY updated_at ASC`)).
 		WithArgs(userID, since).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "type", "meta_open", "ciphertext", "version", "updated_at"}).
@@ -130,6 +181,32 @@ func TestSecretRepositoryListSince(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestSecretRepositoryListChangesIncludesTrashed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewSecretRepository(db)
+	userID := uuid.New()
+	since := time.Now().UTC().Add(-time.Hour)
+	updatedAt := time.Now().UTC().Truncate(time.Second)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, type, meta_open, ciphertext, version, updated_at, deleted_at
+		 FROM secrets WHERE user_id = $1 AND updated_at > $2
+		 ORDER BY updated_at ASC`)).
+		WithArgs(userID, since).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "type", "meta_open", "ciphertext", "version", "updated_at", "deleted_at"}).
+			AddRow(uuid.New(), userID, "note", []byte(`{"title":"live"}`), []byte("a"), int64(1), updatedAt.Add(-time.Minute), nil).
+			AddRow(uuid.New(), userID, "note", []byte(`{"title":"gone"}`), []byte("b"), int64(3), updatedAt, updatedAt))
+
+	items, err := repo.ListChanges(context.Background(), userID, since)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.True(t, items[0].DeletedAt.IsZero())
+	assert.Equal(t, updatedAt, items[1].DeletedAt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestSecretRepositoryDeleteNotFound(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -139,7 +216,7 @@ func TestSecretRepositoryDeleteNotFound(t *testing.T) {
 	secretID := uuid.New()
 	userID := uuid.New()
 
-	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM secrets WHERE id = $1 AND user_id = $2`)).
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM secrets WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`)).
 		WithArgs(secretID, userID).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
@@ -148,3 +225,186 @@ func TestSecretRepositoryDeleteNotFound(t *testing.T) {
 	assert.ErrorIs(t, err, sql.ErrNoRows)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestSecretRepositoryTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewSecretRepository(db)
+	secretID := uuid.New()
+	userID := uuid.New()
+	at := time.Now().UTC()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE secrets SET deleted_at = $3, updated_at = $3
+		 WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`)).
+		WithArgs(secretID, userID, at).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.Trash(context.Background(), secretID, userID, at)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSecretRepositoryListTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewSecretRepository(db)
+	userID := uuid.New()
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, type, meta_open, ciphertext, version, updated_at, deleted_at
+		 FROM secrets WHERE user_id = $1 AND deleted_at IS NOT NULL
+		 ORDER BY deleted_at DESC`)).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "type", "meta_open", "ciphertext", "version", "updated_at", "deleted_at"}).
+			AddRow(uuid.New(), userID, "note", []byte(`{"title":"gone"}`), []byte("a"), int64(2), deletedAt, deletedAt))
+
+	items, err := repo.ListTrash(context.Background(), userID)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "gone", items[0].MetaOpen.Title)
+	assert.Equal(t, deletedAt, items[0].DeletedAt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSecretRepositoryRestoreTrashedNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewSecretRepository(db)
+	secretID := uuid.New()
+	userID := uuid.New()
+	at := time.Now().UTC()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE secrets SET deleted_at = NULL, updated_at = $3, version = version + 1
+		 WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`)).
+		WithArgs(secretID, userID, at).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.RestoreTrashed(context.Background(), secretID, userID, at)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSecretRepositoryPurgeTrashed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewSecretRepository(db)
+	before := time.Now().UTC().Add(-30 * 24 * time.Hour)
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM secrets WHERE deleted_at IS NOT NULL AND deleted_at < $1`)).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	purged, err := repo.PurgeTrashed(context.Background(), before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSecretRepositoryListVersions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewSecretRepository(db)
+	secretID := uuid.New()
+	userID := uuid.New()
+	archivedAt := time.Now().UTC().Truncate(time.Second)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT secret_id, user_id, version, type, meta_open, ciphertext, updated_at, archived_at
+		 FROM secret_versions WHERE secret_id = $1 AND user_id = $2
+		 ORDER BY version DESC`)).
+		WithArgs(secretID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"secret_id", "user_id", "version", "type", "meta_open", "ciphertext", "updated_at", "archived_at"}).
+			AddRow(secretID, userID, int64(2), "note", []byte(`{"title":"second"}`), []byte("b"), archivedAt.Add(-time.Hour), archivedAt).
+			AddRow(secretID, userID, int64(1), "note", []byte(`{"title":"first"}`), []byte("a"), archivedAt.Add(-2*time.Hour), archivedAt.Add(-time.Hour)))
+
+	versions, err := repo.ListVersions(context.Background(), secretID, userID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, int64(2), versions[0].Version)
+	assert.Equal(t, "first", versions[1].MetaOpen.Title)
+	assert.Equal(t, []byte("a"), versions[1].Ciphertext)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSecretRepositoryPruneVersions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewSecretRepository(db)
+	secretID := uuid.New()
+	userID := uuid.New()
+	before := time.Now().UTC().Add(-time.Hour)
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM secret_versions
+		 WHERE secret_id = $1 AND user_id = $2
+		   AND (archived_at < $3 OR version NOT IN (
+		       SELECT version FROM secret_versions
+		       WHERE secret_id = $1 AND user_id = $2
+		       ORDER BY version DESC LIMIT $4
+		   ))`)).
+		WithArgs(secretID, userID, before, sql.NullInt64{Int64: 3, Valid: true}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = repo.PruneVersions(context.Background(), secretID, userID, 3, before)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSecretRepositoryInTxCommits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewSecretRepository(db)
+	secretID := uuid.New()
+	userID := uuid.New()
+	at := time.Now().UTC()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE secrets SET deleted_at = $3, updated_at = $3`)).
+		WithArgs(secretID, userID, at).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.InTx(context.Background(), func(tx SecretRepository) error {
+		return tx.Trash(context.Background(), secretID, userID, at)
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSecretRepositoryInTxRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := NewSecretRepository(db)
+	secretID := uuid.New()
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, type, meta_open, ciphertext, version, updated_at
+		 FROM secrets WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		 FOR UPDATE`)).
+		WithArgs(secretID, userID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = repo.InTx(context.Background(), func(tx SecretRepository) error {
+		_, lockErr := tx.Lock(context.Background(), secretID, userID)
+		return lockErr
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
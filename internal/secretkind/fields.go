@@ -0,0 +1,60 @@
+package secretkind
+
+import "encoding/base64"
+
+// fieldNames lists the addressable fields of each kind, in display order.
+var fieldNames = map[string][]string{
+	Login:    {"username", "password", "url", "notes"},
+	Card:     {"number", "holder", "expiry", "cvv"},
+	Note:     {"text"},
+	Binary:   {"name", "data"},
+	SSHKey:   {"private_key", "public_key", "passphrase"},
+	APIToken: {"token", "service", "expires_at"},
+	TOTP:     {"uri"},
+}
+
+// primaryFields is the field that stands for the whole secret when only
+// one value is wanted.
+var primaryFields = map[string]string{
+	Login:    "password",
+	Card:     "number",
+	Note:     "text",
+	Binary:   "data",
+	SSHKey:   "private_key",
+	APIToken: "token",
+	TOTP:     "uri",
+}
+
+// FieldNames returns the field names of kind, or nil for an unknown kind.
+func FieldNames(kind string) []string {
+	return append([]string(nil), fieldNames[kind]...)
+}
+
+// PrimaryField returns the field that holds the secret value of kind.
+func PrimaryField(kind string) string {
+	return primaryFields[kind]
+}
+
+// Field returns a field of the payload as text. Binary data is base64
+// encoded.
+func (p Payload) Field(name string) (string, bool) {
+	var fields map[string]string
+	switch section := p.section().(type) {
+	case *LoginData:
+		fields = map[string]string{"username": section.Username, "password": section.Password, "url": section.URL, "notes": section.Notes}
+	case *CardData:
+		fields = map[string]string{"number": section.Number, "holder": section.Holder, "expiry": section.Expiry, "cvv": section.CVV}
+	case *NoteData:
+		fields = map[string]string{"text": section.Text}
+	case *BinaryData:
+		fields = map[string]string{"name": section.Name, "data": base64.StdEncoding.EncodeToString(section.Data)}
+	case *SSHKeyData:
+		fields = map[string]string{"private_key": section.PrivateKey, "public_key": section.PublicKey, "passphrase": section.Passphrase}
+	case *APITokenData:
+		fields = map[string]string{"token": section.Token, "service": section.Service, "expires_at": section.ExpiresAt}
+	case *TOTPData:
+		fields = map[string]string{"uri": section.URI}
+	}
+	value, ok := fields[name]
+	return value, ok
+}
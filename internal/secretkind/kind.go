@@ -0,0 +1,32 @@
+// Package secretkind defines the registry of secret kinds and the versioned
+// plaintext schema clients encrypt into a secret's ciphertext. The server only
+// sees the kind name; payload validation happens on the client before
+// encryption.
+package secretkind
+
+import "slices"
+
+// SchemaVersion is the payload schema written by this client.
+const SchemaVersion = 1
+
+const (
+	Login    = "login"
+	Card     = "card"
+	Note     = "note"
+	Binary   = "binary"
+	SSHKey   = "ssh_key"
+	APIToken = "api_token"
+	TOTP     = "totp"
+)
+
+var registry = []string{Login, Card, Note, Binary, SSHKey, APIToken, TOTP}
+
+// Registered reports whether kind is a known secret kind.
+func Registered(kind string) bool {
+	return slices.Contains(registry, kind)
+}
+
+// Kinds returns the registered kinds in display order.
+func Kinds() []string {
+	return slices.Clone(registry)
+}
@@ -0,0 +1,134 @@
+package secretkind
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownKind       = errors.New("unknown secret kind")
+	ErrInvalidPayload    = errors.New("invalid secret payload")
+	ErrUnsupportedSchema = errors.New("unsupported payload schema")
+)
+
+// Payload is the plaintext of a secret. Exactly the section matching Kind is
+// set.
+type Payload struct {
+	Schema   int           `json:"schema"`
+	Kind     string        `json:"kind"`
+	Login    *LoginData    `json:"login,omitempty"`
+	Card     *CardData     `json:"card,omitempty"`
+	Note     *NoteData     `json:"note,omitempty"`
+	Binary   *BinaryData   `json:"binary,omitempty"`
+	SSHKey   *SSHKeyData   `json:"ssh_key,omitempty"`
+	APIToken *APITokenData `json:"api_token,omitempty"`
+	TOTP     *TOTPData     `json:"totp,omitempty"`
+}
+
+type LoginData struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	URL      string `json:"url,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+}
+
+type CardData struct {
+	Number string `json:"number"`
+	Holder string `json:"holder"`
+	// Expiry is formatted as MM/YY.
+	Expiry string `json:"expiry"`
+	CVV    string `json:"cvv"`
+}
+
+type NoteData struct {
+	Text string `json:"text"`
+}
+
+type BinaryData struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+type SSHKeyData struct {
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+type APITokenData struct {
+	Token   string `json:"token"`
+	Service string `json:"service,omitempty"`
+	// ExpiresAt is an optional YYYY-MM-DD date.
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// TOTPData keeps the otpauth:// URI verbatim so issuer, algorithm, digits and
+// period survive a round trip to other authenticators.
+type TOTPData struct {
+	URI string `json:"uri"`
+}
+
+// Encode validates p and serialises it with the current schema version.
+func Encode(p Payload) ([]byte, error) {
+	p.Schema = SchemaVersion
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(p)
+}
+
+// Decode parses a decrypted payload stored under kind. Plaintext written
+// before kinds existed is not a schema document; it is returned as a note so
+// old secrets stay readable.
+func Decode(kind string, data []byte) (Payload, error) {
+	var p Payload
+	if err := json.Unmarshal(data, &p); err != nil || p.Schema == 0 {
+		return Payload{Schema: SchemaVersion, Kind: Note, Note: &NoteData{Text: string(data)}}, nil
+	}
+	if p.Schema > SchemaVersion {
+		return Payload{}, fmt.Errorf("%w: %d", ErrUnsupportedSchema, p.Schema)
+	}
+	if p.Kind != kind {
+		return Payload{}, fmt.Errorf("%w: payload kind %q does not match %q", ErrInvalidPayload, p.Kind, kind)
+	}
+	if p.section() == nil {
+		return Payload{}, fmt.Errorf("%w: missing %s section", ErrInvalidPayload, kind)
+	}
+	return p, nil
+}
+
+// section returns the data section matching Kind, or nil when it is absent.
+func (p Payload) section() any {
+	switch p.Kind {
+	case Login:
+		if p.Login != nil {
+			return p.Login
+		}
+	case Card:
+		if p.Card != nil {
+			return p.Card
+		}
+	case Note:
+		if p.Note != nil {
+			return p.Note
+		}
+	case TOTP:
+		if p.TOTP != nil {
+			return p.TOTP
+		}
+	case Binary:
+		if p.Binary != nil {
+			return p.Binary
+		}
+	case SSHKey:
+		if p.SSHKey != nil {
+			return p.SSHKey
+		}
+	case APIToken:
+		if p.APIToken != nil {
+			return p.APIToken
+		}
+	}
+	return nil
+}
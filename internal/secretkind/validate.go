@@ -0,0 +1,172 @@
+package secretkind
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/totp"
+)
+
+// Validate checks the section matching Kind.
+func (p Payload) Validate() error {
+	return p.validateAt(time.Now())
+}
+
+func (p Payload) validateAt(now time.Time) error {
+	if !Registered(p.Kind) {
+		return fmt.Errorf("%w: %q", ErrUnknownKind, p.Kind)
+	}
+	if p.section() == nil {
+		return invalid("missing %s section", p.Kind)
+	}
+	switch p.Kind {
+	case Login:
+		return p.Login.validate()
+	case Card:
+		return p.Card.validate(now)
+	case Note:
+		if strings.TrimSpace(p.Note.Text) == "" {
+			return invalid("note text is empty")
+		}
+	case Binary:
+		if strings.TrimSpace(p.Binary.Name) == "" {
+			return invalid("binary name is empty")
+		}
+		if len(p.Binary.Data) == 0 {
+			return invalid("binary data is empty")
+		}
+	case SSHKey:
+		return p.SSHKey.validate()
+	case APIToken:
+		return p.APIToken.validate()
+	case TOTP:
+		if _, err := totp.ParseURI(p.TOTP.URI); err != nil {
+			return invalid("%v", err)
+		}
+	}
+	return nil
+}
+
+// validate only insists on the password: imported logins often have no
+// username, e.g. PINs and shared passwords.
+func (d *LoginData) validate() error {
+	if d.Password == "" {
+		return invalid("login password is empty")
+	}
+	if d.URL != "" {
+		parsed, err := url.Parse(d.URL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return invalid("login url must be absolute, e.g. https://example.com")
+		}
+	}
+	return nil
+}
+
+func (d *CardData) validate(now time.Time) error {
+	if !LuhnValid(d.Number) {
+		return invalid("card number fails the Luhn check")
+	}
+	if strings.TrimSpace(d.Holder) == "" {
+		return invalid("card holder is empty")
+	}
+	expires, err := ParseExpiry(d.Expiry)
+	if err != nil {
+		return err
+	}
+	if !now.Before(expires) {
+		return invalid("card expired in %s", d.Expiry)
+	}
+	if len(d.CVV) < 3 || len(d.CVV) > 4 || !isDigits(d.CVV) {
+		return invalid("card cvv must be 3 or 4 digits")
+	}
+	return nil
+}
+
+func (d *SSHKeyData) validate() error {
+	block, _ := pem.Decode([]byte(d.PrivateKey))
+	if block == nil || !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return invalid("ssh private key must be a PEM encoded private key")
+	}
+	return nil
+}
+
+func (d *APITokenData) validate() error {
+	if strings.TrimSpace(d.Token) == "" {
+		return invalid("api token is empty")
+	}
+	if d.ExpiresAt != "" {
+		if _, err := time.Parse("2006-01-02", d.ExpiresAt); err != nil {
+			return invalid("api token expiry must be YYYY-MM-DD")
+		}
+	}
+	return nil
+}
+
+// NormalizeCardNumber drops the spaces and dashes people type between digit
+// groups.
+func NormalizeCardNumber(number string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, number)
+}
+
+// LuhnValid reports whether number is a 12 to 19 digit card number with a
+// valid Luhn check digit.
+func LuhnValid(number string) bool {
+	digits := NormalizeCardNumber(number)
+	if len(digits) < 12 || len(digits) > 19 || !isDigits(digits) {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ParseExpiry parses an MM/YY card expiry and returns the first instant after
+// the card stops being valid.
+func ParseExpiry(expiry string) (time.Time, error) {
+	month, year, ok := strings.Cut(strings.TrimSpace(expiry), "/")
+	if !ok || len(month) != 2 || len(year) != 2 || !isDigits(month) || !isDigits(year) {
+		return time.Time{}, invalid("card expiry must be MM/YY")
+	}
+	m, _ := strconv.Atoi(month)
+	y, _ := strconv.Atoi(year)
+	if m < 1 || m > 12 {
+		return time.Time{}, invalid("card expiry month must be 01-12")
+	}
+	return time.Date(2000+y, time.Month(m)+1, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrInvalidPayload}, args...)...)
+}
@@ -2,13 +2,17 @@ package server
 
 import (
 	"github.com/7StaSH7/practicum-diploma/internal/config"
+	handlerattachment "github.com/7StaSH7/practicum-diploma/internal/handler/attachment"
 	handlerauth "github.com/7StaSH7/practicum-diploma/internal/handler/auth"
+	handlerevent "github.com/7StaSH7/practicum-diploma/internal/handler/event"
 	handlersecret "github.com/7StaSH7/practicum-diploma/internal/handler/secret"
 	"github.com/7StaSH7/practicum-diploma/internal/middleware"
+	idempotencyservice "github.com/7StaSH7/practicum-diploma/internal/service/idempotency"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
-func RegisterRoutes(router *gin.Engine, cfg config.Config, authHandlers handlerauth.Handler, secretHandlers handlersecret.Handler) {
+func RegisterRoutes(router *gin.Engine, cfg config.Config, authHandlers handlerauth.Handler, secretHandlers handlersecret.Handler, eventHandlers handlerevent.Handler, attachmentHandlers handlerattachment.Handler, idempotency idempotencyservice.Service, log *zap.Logger) {
 	authRoutes := router.Group("/auth")
 	{
 		authRoutes.POST("/signup", authHandlers.Signup)
@@ -18,11 +22,26 @@ func RegisterRoutes(router *gin.Engine, cfg config.Config, authHandlers handlera
 
 	protected := router.Group("/")
 	protected.Use(middleware.AuthMiddleware(cfg))
+	protected.Use(middleware.IdempotencyMiddleware(idempotency, log))
 	{
 		protected.GET("/secrets", secretHandlers.ListSecrets)
 		protected.POST("/secrets", secretHandlers.CreateSecret)
+		protected.POST("/secrets/batch", secretHandlers.BatchSecrets)
 		protected.GET("/secrets/:id", secretHandlers.GetSecret)
 		protected.PUT("/secrets/:id", secretHandlers.UpdateSecret)
 		protected.DELETE("/secrets/:id", secretHandlers.DeleteSecret)
+		protected.GET("/secrets/:id/versions", secretHandlers.ListVersions)
+		protected.GET("/secrets/:id/versions/:version", secretHandlers.GetVersion)
+		protected.POST("/secrets/:id/restore", secretHandlers.RestoreSecret)
+		protected.GET("/secrets/:id/attachments", attachmentHandlers.ListAttachments)
+		protected.POST("/secrets/:id/attachments", attachmentHandlers.CreateAttachment)
+		protected.GET("/attachments/:id", attachmentHandlers.GetAttachment)
+		protected.DELETE("/attachments/:id", attachmentHandlers.DeleteAttachment)
+		protected.GET("/attachments/:id/content", attachmentHandlers.DownloadAttachment)
+		protected.PUT("/attachments/:id/content", attachmentHandlers.UploadChunk)
+		protected.GET("/trash", secretHandlers.ListTrash)
+		protected.POST("/trash/:id/restore", secretHandlers.RestoreFromTrash)
+		protected.DELETE("/trash/:id", secretHandlers.PurgeSecret)
+		protected.GET("/events", eventHandlers.Stream)
 	}
 }
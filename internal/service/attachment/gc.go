@@ -0,0 +1,58 @@
+package attachment
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const gcInterval = 10 * time.Minute
+
+// RegisterGC periodically removes abandoned uploads and the blobs of deleted
+// attachments.
+func RegisterGC(lc fx.Lifecycle, svc Service, log *zap.Logger) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(gcInterval)
+				defer ticker.Stop()
+				for {
+					collectGarbage(ctx, svc, log)
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
+
+func collectGarbage(ctx context.Context, svc Service, log *zap.Logger) {
+	removed, err := svc.CollectGarbage(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("attachment garbage collection failed", zap.Error(err))
+		}
+		return
+	}
+	if removed > 0 {
+		log.Info("attachment blobs removed", zap.Int64("blobs", removed))
+	}
+}
@@ -0,0 +1,160 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/7StaSH7/practicum-diploma/internal/repository/attachment (interfaces: AttachmentRepository)
+//
+// Generated by this command:
+//
+//	mockgen -destination=./mocks/attachment_repository_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/repository/attachment AttachmentRepository
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	models "github.com/7StaSH7/practicum-diploma/internal/models"
+	uuid "github.com/google/uuid"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAttachmentRepository is a mock of AttachmentRepository interface.
+type MockAttachmentRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAttachmentRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockAttachmentRepositoryMockRecorder is the mock recorder for MockAttachmentRepository.
+type MockAttachmentRepositoryMockRecorder struct {
+	mock *MockAttachmentRepository
+}
+
+// NewMockAttachmentRepository creates a new mock instance.
+func NewMockAttachmentRepository(ctrl *gomock.Controller) *MockAttachmentRepository {
+	mock := &MockAttachmentRepository{ctrl: ctrl}
+	mock.recorder = &MockAttachmentRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAttachmentRepository) EXPECT() *MockAttachmentRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Advance mocks base method.
+func (m *MockAttachmentRepository) Advance(ctx context.Context, id, userID uuid.UUID, from, to int64, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Advance", ctx, id, userID, from, to, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Advance indicates an expected call of Advance.
+func (mr *MockAttachmentRepositoryMockRecorder) Advance(ctx, id, userID, from, to, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Advance", reflect.TypeOf((*MockAttachmentRepository)(nil).Advance), ctx, id, userID, from, to, at)
+}
+
+// Create mocks base method.
+func (m *MockAttachmentRepository) Create(ctx context.Context, attachment models.Attachment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, attachment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAttachmentRepositoryMockRecorder) Create(ctx, attachment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAttachmentRepository)(nil).Create), ctx, attachment)
+}
+
+// Delete mocks base method.
+func (m *MockAttachmentRepository) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, id, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockAttachmentRepositoryMockRecorder) Delete(ctx, id, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAttachmentRepository)(nil).Delete), ctx, id, userID)
+}
+
+// DeleteIncomplete mocks base method.
+func (m *MockAttachmentRepository) DeleteIncomplete(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIncomplete", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteIncomplete indicates an expected call of DeleteIncomplete.
+func (mr *MockAttachmentRepositoryMockRecorder) DeleteIncomplete(ctx, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIncomplete", reflect.TypeOf((*MockAttachmentRepository)(nil).DeleteIncomplete), ctx, before)
+}
+
+// ForgetGarbage mocks base method.
+func (m *MockAttachmentRepository) ForgetGarbage(ctx context.Context, blobKey string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgetGarbage", ctx, blobKey)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgetGarbage indicates an expected call of ForgetGarbage.
+func (mr *MockAttachmentRepositoryMockRecorder) ForgetGarbage(ctx, blobKey any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgetGarbage", reflect.TypeOf((*MockAttachmentRepository)(nil).ForgetGarbage), ctx, blobKey)
+}
+
+// Get mocks base method.
+func (m *MockAttachmentRepository) Get(ctx context.Context, id, userID uuid.UUID) (models.Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id, userID)
+	ret0, _ := ret[0].(models.Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockAttachmentRepositoryMockRecorder) Get(ctx, id, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockAttachmentRepository)(nil).Get), ctx, id, userID)
+}
+
+// ListBySecret mocks base method.
+func (m *MockAttachmentRepository) ListBySecret(ctx context.Context, secretID, userID uuid.UUID) ([]models.Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBySecret", ctx, secretID, userID)
+	ret0, _ := ret[0].([]models.Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBySecret indicates an expected call of ListBySecret.
+func (mr *MockAttachmentRepositoryMockRecorder) ListBySecret(ctx, secretID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBySecret", reflect.TypeOf((*MockAttachmentRepository)(nil).ListBySecret), ctx, secretID, userID)
+}
+
+// ListGarbage mocks base method.
+func (m *MockAttachmentRepository) ListGarbage(ctx context.Context, limit int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListGarbage", ctx, limit)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGarbage indicates an expected call of ListGarbage.
+func (mr *MockAttachmentRepositoryMockRecorder) ListGarbage(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGarbage", reflect.TypeOf((*MockAttachmentRepository)(nil).ListGarbage), ctx, limit)
+}
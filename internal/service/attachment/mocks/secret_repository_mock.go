@@ -0,0 +1,263 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/7StaSH7/practicum-diploma/internal/repository/secret (interfaces: SecretRepository)
+//
+// Generated by this command:
+//
+//	mockgen -destination=./mocks/secret_repository_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/repository/secret SecretRepository
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	models "github.com/7StaSH7/practicum-diploma/internal/models"
+	secret "github.com/7StaSH7/practicum-diploma/internal/repository/secret"
+	uuid "github.com/google/uuid"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSecretRepository is a mock of SecretRepository interface.
+type MockSecretRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecretRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockSecretRepositoryMockRecorder is the mock recorder for MockSecretRepository.
+type MockSecretRepositoryMockRecorder struct {
+	mock *MockSecretRepository
+}
+
+// NewMockSecretRepository creates a new mock instance.
+func NewMockSecretRepository(ctrl *gomock.Controller) *MockSecretRepository {
+	mock := &MockSecretRepository{ctrl: ctrl}
+	mock.recorder = &MockSecretRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSecretRepository) EXPECT() *MockSecretRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockSecretRepository) Create(ctx context.Context, secret models.Secret) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockSecretRepositoryMockRecorder) Create(ctx, secret any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSecretRepository)(nil).Create), ctx, secret)
+}
+
+// Delete mocks base method.
+func (m *MockSecretRepository) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, id, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockSecretRepositoryMockRecorder) Delete(ctx, id, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSecretRepository)(nil).Delete), ctx, id, userID)
+}
+
+// Get mocks base method.
+func (m *MockSecretRepository) Get(ctx context.Context, id, userID uuid.UUID) (models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id, userID)
+	ret0, _ := ret[0].(models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockSecretRepositoryMockRecorder) Get(ctx, id, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockSecretRepository)(nil).Get), ctx, id, userID)
+}
+
+// GetVersion mocks base method.
+func (m *MockSecretRepository) GetVersion(ctx context.Context, id, userID uuid.UUID, version int64) (models.SecretVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVersion", ctx, id, userID, version)
+	ret0, _ := ret[0].(models.SecretVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVersion indicates an expected call of GetVersion.
+func (mr *MockSecretRepositoryMockRecorder) GetVersion(ctx, id, userID, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersion", reflect.TypeOf((*MockSecretRepository)(nil).GetVersion), ctx, id, userID, version)
+}
+
+// InTx mocks base method.
+func (m *MockSecretRepository) InTx(ctx context.Context, fn func(secret.SecretRepository) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InTx indicates an expected call of InTx.
+func (mr *MockSecretRepositoryMockRecorder) InTx(ctx, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InTx", reflect.TypeOf((*MockSecretRepository)(nil).InTx), ctx, fn)
+}
+
+// ListChanges mocks base method.
+func (m *MockSecretRepository) ListChanges(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListChanges", ctx, userID, since)
+	ret0, _ := ret[0].([]models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListChanges indicates an expected call of ListChanges.
+func (mr *MockSecretRepositoryMockRecorder) ListChanges(ctx, userID, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListChanges", reflect.TypeOf((*MockSecretRepository)(nil).ListChanges), ctx, userID, since)
+}
+
+// ListSince mocks base method.
+func (m *MockSecretRepository) ListSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSince", ctx, userID, since)
+	ret0, _ := ret[0].([]models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSince indicates an expected call of ListSince.
+func (mr *MockSecretRepositoryMockRecorder) ListSince(ctx, userID, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSince", reflect.TypeOf((*MockSecretRepository)(nil).ListSince), ctx, userID, since)
+}
+
+// ListTrash mocks base method.
+func (m *MockSecretRepository) ListTrash(ctx context.Context, userID uuid.UUID) ([]models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrash", ctx, userID)
+	ret0, _ := ret[0].([]models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrash indicates an expected call of ListTrash.
+func (mr *MockSecretRepositoryMockRecorder) ListTrash(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrash", reflect.TypeOf((*MockSecretRepository)(nil).ListTrash), ctx, userID)
+}
+
+// ListVersions mocks base method.
+func (m *MockSecretRepository) ListVersions(ctx context.Context, id, userID uuid.UUID) ([]models.SecretVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVersions", ctx, id, userID)
+	ret0, _ := ret[0].([]models.SecretVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVersions indicates an expected call of ListVersions.
+func (mr *MockSecretRepositoryMockRecorder) ListVersions(ctx, id, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVersions", reflect.TypeOf((*MockSecretRepository)(nil).ListVersions), ctx, id, userID)
+}
+
+// Lock mocks base method.
+func (m *MockSecretRepository) Lock(ctx context.Context, id, userID uuid.UUID) (models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", ctx, id, userID)
+	ret0, _ := ret[0].(models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Lock indicates an expected call of Lock.
+func (mr *MockSecretRepositoryMockRecorder) Lock(ctx, id, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockSecretRepository)(nil).Lock), ctx, id, userID)
+}
+
+// PruneVersions mocks base method.
+func (m *MockSecretRepository) PruneVersions(ctx context.Context, id, userID uuid.UUID, keep int, before time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneVersions", ctx, id, userID, keep, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PruneVersions indicates an expected call of PruneVersions.
+func (mr *MockSecretRepositoryMockRecorder) PruneVersions(ctx, id, userID, keep, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneVersions", reflect.TypeOf((*MockSecretRepository)(nil).PruneVersions), ctx, id, userID, keep, before)
+}
+
+// PurgeTrashed mocks base method.
+func (m *MockSecretRepository) PurgeTrashed(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeTrashed", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeTrashed indicates an expected call of PurgeTrashed.
+func (mr *MockSecretRepositoryMockRecorder) PurgeTrashed(ctx, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeTrashed", reflect.TypeOf((*MockSecretRepository)(nil).PurgeTrashed), ctx, before)
+}
+
+// RestoreTrashed mocks base method.
+func (m *MockSecretRepository) RestoreTrashed(ctx context.Context, id, userID uuid.UUID, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreTrashed", ctx, id, userID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreTrashed indicates an expected call of RestoreTrashed.
+func (mr *MockSecretRepositoryMockRecorder) RestoreTrashed(ctx, id, userID, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreTrashed", reflect.TypeOf((*MockSecretRepository)(nil).RestoreTrashed), ctx, id, userID, at)
+}
+
+// Trash mocks base method.
+func (m *MockSecretRepository) Trash(ctx context.Context, id, userID uuid.UUID, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trash", ctx, id, userID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Trash indicates an expected call of Trash.
+func (mr *MockSecretRepositoryMockRecorder) Trash(ctx, id, userID, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trash", reflect.TypeOf((*MockSecretRepository)(nil).Trash), ctx, id, userID, at)
+}
+
+// Update mocks base method.
+func (m *MockSecretRepository) Update(ctx context.Context, secret models.Secret) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockSecretRepositoryMockRecorder) Update(ctx, secret any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockSecretRepository)(nil).Update), ctx, secret)
+}
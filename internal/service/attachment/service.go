@@ -0,0 +1,250 @@
+package attachment
+
+//go:generate go run go.uber.org/mock/mockgen@latest -destination=./mocks/attachment_repository_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/repository/attachment AttachmentRepository
+//go:generate go run go.uber.org/mock/mockgen@latest -destination=./mocks/blob_store_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/repository/blob Store
+//go:generate go run go.uber.org/mock/mockgen@latest -destination=./mocks/secret_repository_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/repository/secret SecretRepository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	attachmentrepository "github.com/7StaSH7/practicum-diploma/internal/repository/attachment"
+	"github.com/7StaSH7/practicum-diploma/internal/repository/blob"
+	secretrepository "github.com/7StaSH7/practicum-diploma/internal/repository/secret"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// MaxChunkSize bounds the body of a single upload request.
+const MaxChunkSize = 8 << 20
+
+const (
+	// pendingTTL is how long an unfinished upload may sit idle before the
+	// garbage collector drops it together with its partial blob.
+	pendingTTL   = 24 * time.Hour
+	gcBatchLimit = 100
+)
+
+var (
+	ErrNotFound          = errors.New("attachment not found")
+	ErrSecretNotFound    = errors.New("secret not found")
+	ErrInvalidAttachment = errors.New("invalid attachment")
+	ErrTooLarge          = errors.New("attachment too large")
+	ErrEmptyChunk        = errors.New("empty attachment chunk")
+	ErrOffsetMismatch    = errors.New("upload offset mismatch")
+	ErrIncomplete        = errors.New("attachment upload incomplete")
+)
+
+type Service interface {
+	Create(ctx context.Context, userID uuid.UUID, secretID uuid.UUID, name string, size int64) (models.Attachment, error)
+	Get(ctx context.Context, userID uuid.UUID, id uuid.UUID) (models.Attachment, error)
+	List(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) ([]models.Attachment, error)
+	// WriteChunk stores data at offset, which must equal the bytes received
+	// so far. On ErrOffsetMismatch the returned attachment carries the offset
+	// the client should resume from.
+	WriteChunk(ctx context.Context, userID uuid.UUID, id uuid.UUID, offset int64, data []byte) (models.Attachment, error)
+	Open(ctx context.Context, userID uuid.UUID, id uuid.UUID) (models.Attachment, io.ReadCloser, error)
+	Delete(ctx context.Context, userID uuid.UUID, id uuid.UUID) error
+	CollectGarbage(ctx context.Context) (int64, error)
+}
+
+type service struct {
+	attachments attachmentrepository.AttachmentRepository
+	blobs       blob.Store
+	secrets     secretrepository.SecretRepository
+	cfg         config.Config
+	log         *zap.Logger
+}
+
+func NewService(
+	attachments attachmentrepository.AttachmentRepository,
+	blobs blob.Store,
+	secrets secretrepository.SecretRepository,
+	cfg config.Config,
+	log *zap.Logger,
+) Service {
+	return &service{
+		attachments: attachments,
+		blobs:       blobs,
+		secrets:     secrets,
+		cfg:         cfg,
+		log:         log,
+	}
+}
+
+func (s *service) Create(ctx context.Context, userID uuid.UUID, secretID uuid.UUID, name string, size int64) (models.Attachment, error) {
+	name = cleanName(name)
+	if name == "" || size <= 0 {
+		return models.Attachment{}, ErrInvalidAttachment
+	}
+	if s.cfg.AttachmentMax > 0 && size > s.cfg.AttachmentMax {
+		return models.Attachment{}, ErrTooLarge
+	}
+	if _, err := s.secrets.Get(ctx, secretID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Attachment{}, ErrSecretNotFound
+		}
+		return models.Attachment{}, err
+	}
+
+	key, err := s.blobs.Create(ctx)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	attachment := models.Attachment{
+		ID:        uuid.New(),
+		SecretID:  secretID,
+		UserID:    userID,
+		Name:      name,
+		Size:      size,
+		BlobKey:   key,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.attachments.Create(ctx, attachment); err != nil {
+		if deleteErr := s.blobs.Delete(context.WithoutCancel(ctx), key); deleteErr != nil {
+			s.log.Warn("drop orphaned blob failed", zap.String("blob_key", key), zap.Error(deleteErr))
+		}
+		return models.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// Get returns an attachment of a live secret; attachments of a secret in the
+// trash are not found, like the secret itself.
+func (s *service) Get(ctx context.Context, userID uuid.UUID, id uuid.UUID) (models.Attachment, error) {
+	attachment, err := s.attachments.Get(ctx, id, userID)
+	if err != nil {
+		return models.Attachment{}, mapNotFound(err)
+	}
+	if _, err := s.secrets.Get(ctx, attachment.SecretID, userID); err != nil {
+		return models.Attachment{}, mapNotFound(err)
+	}
+	return attachment, nil
+}
+
+func (s *service) List(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) ([]models.Attachment, error) {
+	if _, err := s.secrets.Get(ctx, secretID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSecretNotFound
+		}
+		return nil, err
+	}
+	return s.attachments.ListBySecret(ctx, secretID, userID)
+}
+
+func (s *service) WriteChunk(ctx context.Context, userID uuid.UUID, id uuid.UUID, offset int64, data []byte) (models.Attachment, error) {
+	attachment, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if !attachment.CompletedAt.IsZero() || offset != attachment.Received {
+		return attachment, ErrOffsetMismatch
+	}
+	if len(data) == 0 {
+		return attachment, ErrEmptyChunk
+	}
+	next := offset + int64(len(data))
+	if next > attachment.Size {
+		return attachment, ErrTooLarge
+	}
+
+	if err := s.blobs.WriteAt(ctx, attachment.BlobKey, offset, data); err != nil {
+		return models.Attachment{}, err
+	}
+	now := time.Now().UTC()
+	if err := s.attachments.Advance(ctx, id, userID, offset, next, now); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return models.Attachment{}, err
+		}
+		// Another request advanced the offset first; report where it stands.
+		current, getErr := s.attachments.Get(ctx, id, userID)
+		if getErr != nil {
+			return models.Attachment{}, mapNotFound(getErr)
+		}
+		return current, ErrOffsetMismatch
+	}
+	attachment.Received = next
+	if next == attachment.Size {
+		attachment.CompletedAt = now
+	}
+	return attachment, nil
+}
+
+func (s *service) Open(ctx context.Context, userID uuid.UUID, id uuid.UUID) (models.Attachment, io.ReadCloser, error) {
+	attachment, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+	if attachment.CompletedAt.IsZero() {
+		return attachment, nil, ErrIncomplete
+	}
+	reader, err := s.blobs.Open(ctx, attachment.BlobKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			return models.Attachment{}, nil, ErrNotFound
+		}
+		return models.Attachment{}, nil, err
+	}
+	return attachment, reader, nil
+}
+
+// Delete removes the attachment row; its blob is queued for the garbage
+// collector by the database.
+func (s *service) Delete(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	if err := s.attachments.Delete(ctx, id, userID); err != nil {
+		return mapNotFound(err)
+	}
+	return nil
+}
+
+// CollectGarbage drops abandoned uploads and deletes the blobs queued by
+// removed attachments. Queue entries are only forgotten once their blob is
+// gone, so a failed delete is retried on the next run.
+func (s *service) CollectGarbage(ctx context.Context) (int64, error) {
+	if _, err := s.attachments.DeleteIncomplete(ctx, time.Now().UTC().Add(-pendingTTL)); err != nil {
+		return 0, err
+	}
+	keys, err := s.attachments.ListGarbage(ctx, gcBatchLimit)
+	if err != nil {
+		return 0, err
+	}
+	var removed int64
+	for _, key := range keys {
+		if err := s.blobs.Delete(ctx, key); err != nil && !errors.Is(err, blob.ErrNotFound) {
+			return removed, err
+		}
+		if err := s.attachments.ForgetGarbage(ctx, key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// cleanName keeps only the base name of an uploaded file so a stored name is
+// always safe to use as a download target.
+func cleanName(name string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "\\", "/"))
+	if name == "" {
+		return ""
+	}
+	name = filepath.Base(name)
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return name
+}
+
+func mapNotFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,195 @@
+package attachment
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/7StaSH7/practicum-diploma/internal/repository/blob"
+	attachmentmocks "github.com/7StaSH7/practicum-diploma/internal/service/attachment/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+type testDeps struct {
+	attachments *attachmentmocks.MockAttachmentRepository
+	blobs       *attachmentmocks.MockStore
+	secrets     *attachmentmocks.MockSecretRepository
+	svc         Service
+}
+
+func newTestService(t *testing.T, cfg config.Config) testDeps {
+	ctrl := gomock.NewController(t)
+	deps := testDeps{
+		attachments: attachmentmocks.NewMockAttachmentRepository(ctrl),
+		blobs:       attachmentmocks.NewMockStore(ctrl),
+		secrets:     attachmentmocks.NewMockSecretRepository(ctrl),
+	}
+	deps.svc = NewService(deps.attachments, deps.blobs, deps.secrets, cfg, zap.NewNop())
+	return deps
+}
+
+// expectLiveSecret lets one liveness check of the test attachments' secret
+// pass; they carry no secret ID.
+func (d testDeps) expectLiveSecret(userID uuid.UUID) {
+	d.secrets.EXPECT().Get(gomock.Any(), uuid.Nil, userID).Return(models.Secret{}, nil)
+}
+
+func TestCreateStoresAttachment(t *testing.T) {
+	deps := newTestService(t, config.Config{AttachmentMax: 1024})
+	userID := uuid.New()
+	secretID := uuid.New()
+
+	deps.secrets.EXPECT().Get(gomock.Any(), secretID, userID).Return(models.Secret{ID: secretID}, nil)
+	deps.blobs.EXPECT().Create(gomock.Any()).Return("blob-1", nil)
+	deps.attachments.EXPECT().Create(gomock.Any(), gomock.AssignableToTypeOf(models.Attachment{})).DoAndReturn(
+		func(_ context.Context, attachment models.Attachment) error {
+			assert.Equal(t, "id_rsa", attachment.Name)
+			assert.Equal(t, "blob-1", attachment.BlobKey)
+			assert.Equal(t, int64(100), attachment.Size)
+			return nil
+		},
+	)
+
+	attachment, err := deps.svc.Create(context.Background(), userID, secretID, "/home/user/.ssh/id_rsa", 100)
+	require.NoError(t, err)
+	assert.Equal(t, secretID, attachment.SecretID)
+}
+
+func TestCreateRejectsOversizedAttachment(t *testing.T) {
+	deps := newTestService(t, config.Config{AttachmentMax: 10})
+
+	_, err := deps.svc.Create(context.Background(), uuid.New(), uuid.New(), "big.bin", 11)
+	require.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestCreateDropsBlobWhenInsertFails(t *testing.T) {
+	deps := newTestService(t, config.Config{})
+	userID := uuid.New()
+	secretID := uuid.New()
+
+	deps.secrets.EXPECT().Get(gomock.Any(), secretID, userID).Return(models.Secret{ID: secretID}, nil)
+	deps.blobs.EXPECT().Create(gomock.Any()).Return("blob-1", nil)
+	deps.attachments.EXPECT().Create(gomock.Any(), gomock.Any()).Return(errors.New("boom"))
+	deps.blobs.EXPECT().Delete(gomock.Any(), "blob-1").Return(nil)
+
+	_, err := deps.svc.Create(context.Background(), userID, secretID, "file.txt", 5)
+	require.Error(t, err)
+}
+
+func TestWriteChunkCompletesUpload(t *testing.T) {
+	deps := newTestService(t, config.Config{})
+	userID := uuid.New()
+	id := uuid.New()
+	stored := models.Attachment{ID: id, UserID: userID, Size: 10, Received: 6, BlobKey: "blob-1"}
+
+	deps.attachments.EXPECT().Get(gomock.Any(), id, userID).Return(stored, nil)
+	deps.expectLiveSecret(userID)
+	deps.blobs.EXPECT().WriteAt(gomock.Any(), "blob-1", int64(6), []byte("tail")).Return(nil)
+	deps.attachments.EXPECT().Advance(gomock.Any(), id, userID, int64(6), int64(10), gomock.Any()).Return(nil)
+
+	attachment, err := deps.svc.WriteChunk(context.Background(), userID, id, 6, []byte("tail"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), attachment.Received)
+	assert.False(t, attachment.CompletedAt.IsZero())
+}
+
+func TestWriteChunkReportsCurrentOffset(t *testing.T) {
+	deps := newTestService(t, config.Config{})
+	userID := uuid.New()
+	id := uuid.New()
+	stored := models.Attachment{ID: id, UserID: userID, Size: 10, Received: 6, BlobKey: "blob-1"}
+
+	deps.attachments.EXPECT().Get(gomock.Any(), id, userID).Return(stored, nil)
+	deps.expectLiveSecret(userID)
+
+	attachment, err := deps.svc.WriteChunk(context.Background(), userID, id, 0, []byte("head"))
+	require.ErrorIs(t, err, ErrOffsetMismatch)
+	assert.Equal(t, int64(6), attachment.Received)
+}
+
+func TestWriteChunkLosesRaceToConcurrentWriter(t *testing.T) {
+	deps := newTestService(t, config.Config{})
+	userID := uuid.New()
+	id := uuid.New()
+	stored := models.Attachment{ID: id, UserID: userID, Size: 10, BlobKey: "blob-1"}
+	advanced := stored
+	advanced.Received = 4
+
+	gomock.InOrder(
+		deps.attachments.EXPECT().Get(gomock.Any(), id, userID).Return(stored, nil),
+		deps.attachments.EXPECT().Get(gomock.Any(), id, userID).Return(advanced, nil),
+	)
+	deps.expectLiveSecret(userID)
+	deps.blobs.EXPECT().WriteAt(gomock.Any(), "blob-1", int64(0), []byte("head")).Return(nil)
+	deps.attachments.EXPECT().Advance(gomock.Any(), id, userID, int64(0), int64(4), gomock.Any()).Return(sql.ErrNoRows)
+
+	attachment, err := deps.svc.WriteChunk(context.Background(), userID, id, 0, []byte("head"))
+	require.ErrorIs(t, err, ErrOffsetMismatch)
+	assert.Equal(t, int64(4), attachment.Received)
+}
+
+func TestWriteChunkRejectsOverflow(t *testing.T) {
+	deps := newTestService(t, config.Config{})
+	userID := uuid.New()
+	id := uuid.New()
+
+	deps.attachments.EXPECT().Get(gomock.Any(), id, userID).Return(models.Attachment{ID: id, Size: 3}, nil)
+	deps.expectLiveSecret(userID)
+
+	_, err := deps.svc.WriteChunk(context.Background(), userID, id, 0, []byte("four"))
+	require.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestOpenRequiresCompletedUpload(t *testing.T) {
+	deps := newTestService(t, config.Config{})
+	userID := uuid.New()
+	id := uuid.New()
+
+	deps.attachments.EXPECT().Get(gomock.Any(), id, userID).Return(models.Attachment{ID: id, Size: 3, Received: 1}, nil)
+	deps.expectLiveSecret(userID)
+
+	_, _, err := deps.svc.Open(context.Background(), userID, id)
+	require.ErrorIs(t, err, ErrIncomplete)
+}
+
+func TestOpenHidesAttachmentsOfTrashedSecrets(t *testing.T) {
+	deps := newTestService(t, config.Config{})
+	userID := uuid.New()
+	secretID := uuid.New()
+	id := uuid.New()
+
+	deps.attachments.EXPECT().Get(gomock.Any(), id, userID).Return(models.Attachment{ID: id, SecretID: secretID, Size: 3, Received: 3, CompletedAt: time.Now()}, nil).Times(2)
+	deps.secrets.EXPECT().Get(gomock.Any(), secretID, userID).Return(models.Secret{}, sql.ErrNoRows).Times(2)
+
+	_, _, err := deps.svc.Open(context.Background(), userID, id)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = deps.svc.Get(context.Background(), userID, id)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCollectGarbageKeepsQueueEntryWhenDeleteFails(t *testing.T) {
+	deps := newTestService(t, config.Config{})
+
+	deps.attachments.EXPECT().DeleteIncomplete(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, before time.Time) (int64, error) {
+			assert.WithinDuration(t, time.Now().Add(-pendingTTL), before, time.Minute)
+			return 1, nil
+		},
+	)
+	deps.attachments.EXPECT().ListGarbage(gomock.Any(), gcBatchLimit).Return([]string{"gone", "stuck"}, nil)
+	deps.blobs.EXPECT().Delete(gomock.Any(), "gone").Return(blob.ErrNotFound)
+	deps.attachments.EXPECT().ForgetGarbage(gomock.Any(), "gone").Return(nil)
+	deps.blobs.EXPECT().Delete(gomock.Any(), "stuck").Return(errors.New("io error"))
+
+	removed, err := deps.svc.CollectGarbage(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, int64(1), removed)
+}
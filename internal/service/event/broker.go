@@ -0,0 +1,66 @@
+package event
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// broker wakes up open event streams of a user. Signals carry no payload:
+// streams read the events themselves, so a dropped signal is never lost as
+// long as one is pending.
+type broker struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[chan struct{}]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{subs: make(map[uuid.UUID]map[chan struct{}]struct{})}
+}
+
+func (b *broker) subscribe(userID uuid.UUID) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan struct{}]struct{})
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs[userID], ch)
+			if len(b.subs[userID]) == 0 {
+				delete(b.subs, userID)
+			}
+		})
+	}
+}
+
+func (b *broker) notify(userID uuid.UUID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[userID] {
+		wake(ch)
+	}
+}
+
+func (b *broker) notifyAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, subs := range b.subs {
+		for ch := range subs {
+			wake(ch)
+		}
+	}
+}
+
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,26 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrokerNotifiesOnlySubscribedUser(t *testing.T) {
+	b := newBroker()
+	userID := uuid.New()
+	wake, unsubscribe := b.subscribe(userID)
+	other, unsubscribeOther := b.subscribe(uuid.New())
+	defer unsubscribeOther()
+
+	b.notify(userID)
+	b.notify(userID)
+
+	assert.Len(t, wake, 1)
+	assert.Len(t, other, 0)
+
+	unsubscribe()
+	unsubscribe()
+	assert.NotContains(t, b.subs, userID)
+}
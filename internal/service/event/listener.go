@@ -0,0 +1,111 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// notifyChannel is the channel the secrets trigger notifies with the owner id
+// of every recorded event.
+const notifyChannel = "secret_events"
+
+const (
+	listenRetryMin = time.Second
+	listenRetryMax = 30 * time.Second
+)
+
+type notificationConn interface {
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+type dialFunc func(ctx context.Context) (notificationConn, error)
+
+// RegisterListener keeps a dedicated connection listening for change
+// notifications, so streams served by this instance also see changes written
+// through other replicas.
+func RegisterListener(lc fx.Lifecycle, svc Service, cfg config.Config, log *zap.Logger) {
+	if cfg.POSTGRES_DSN == "" {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	dial := func(ctx context.Context) (notificationConn, error) {
+		conn, err := pgx.Connect(ctx, cfg.POSTGRES_DSN)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+			_ = conn.Close(ctx)
+			return nil, err
+		}
+		return conn, nil
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				listen(ctx, dial, svc, log)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
+
+// listen forwards notifications to local subscribers until ctx is done,
+// reconnecting with backoff. Notifications sent while the connection was down
+// are lost, so after every (re)connect all local streams are woken up to
+// backfill from the event log.
+func listen(ctx context.Context, dial dialFunc, svc Service, log *zap.Logger) {
+	retry := listenRetryMin
+	for {
+		conn, err := dial(ctx)
+		if err == nil {
+			retry = listenRetryMin
+			svc.NotifyAll()
+			err = forward(ctx, conn, svc, log)
+			_ = conn.Close(context.Background())
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("event listener disconnected", zap.Error(err), zap.Duration("retry_in", retry))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, listenRetryMax)
+	}
+}
+
+func forward(ctx context.Context, conn notificationConn, svc Service, log *zap.Logger) error {
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		userID, err := uuid.Parse(notification.Payload)
+		if err != nil {
+			log.Warn("unexpected event notification", zap.String("payload", notification.Payload))
+			continue
+		}
+		svc.Notify(userID)
+	}
+}
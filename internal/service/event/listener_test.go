@@ -0,0 +1,94 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeConn struct {
+	notifications chan *pgconn.Notification
+}
+
+func (c *fakeConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case n, ok := <-c.notifications:
+		if !ok {
+			return nil, errors.New("connection lost")
+		}
+		return n, nil
+	}
+}
+
+func (c *fakeConn) Close(context.Context) error {
+	return nil
+}
+
+func TestListenForwardsNotificationsAndBackfillsOnReconnect(t *testing.T) {
+	svc := NewService(nil, config.Config{}).(*service)
+	userID := uuid.New()
+	wake, unsubscribe := svc.Subscribe(userID)
+	defer unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	first := &fakeConn{notifications: make(chan *pgconn.Notification)}
+	second := &fakeConn{notifications: make(chan *pgconn.Notification)}
+	conns := []*fakeConn{first, second}
+	dials := 0
+	dial := func(context.Context) (notificationConn, error) {
+		if dials == len(conns) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		conn := conns[dials]
+		dials++
+		return conn, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		listen(ctx, dial, svc, zap.NewNop())
+	}()
+
+	// Initial connect wakes everyone for backfill.
+	requireWake(t, wake)
+
+	first.notifications <- &pgconn.Notification{Channel: notifyChannel, Payload: "not-a-uuid"}
+	first.notifications <- &pgconn.Notification{Channel: notifyChannel, Payload: userID.String()}
+	requireWake(t, wake)
+
+	// A dropped connection is re-established and triggers another backfill.
+	close(first.notifications)
+	requireWakeWithin(t, wake, listenRetryMin+2*time.Second)
+
+	cancel()
+	<-done
+	assert.Equal(t, 2, dials)
+}
+
+func requireWake(t *testing.T, wake <-chan struct{}) {
+	t.Helper()
+	requireWakeWithin(t, wake, time.Second)
+}
+
+func requireWakeWithin(t *testing.T, wake <-chan struct{}, timeout time.Duration) {
+	t.Helper()
+	select {
+	case <-wake:
+	case <-time.After(timeout):
+		require.Fail(t, "expected stream to be woken up")
+	}
+}
@@ -0,0 +1,63 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const purgeInterval = time.Hour
+
+// RegisterPurge periodically drops secret events older than the configured
+// retention. Clients that resume past it still catch up through the regular
+// incremental sync they run on every reconnect.
+func RegisterPurge(lc fx.Lifecycle, svc Service, cfg config.Config, log *zap.Logger) {
+	if cfg.EventsTTL <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(purgeInterval)
+				defer ticker.Stop()
+				for {
+					purgeEvents(ctx, svc, log)
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
+
+func purgeEvents(ctx context.Context, svc Service, log *zap.Logger) {
+	purged, err := svc.PurgeExpired(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("event purge failed", zap.Error(err))
+		}
+		return
+	}
+	if purged > 0 {
+		log.Info("secret events purged", zap.Int64("events", purged))
+	}
+}
@@ -0,0 +1,67 @@
+package event
+
+//go:generate go run go.uber.org/mock/mockgen@latest -destination=./mocks/event_repository_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/repository/event EventRepository
+
+import (
+	"context"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	eventrepository "github.com/7StaSH7/practicum-diploma/internal/repository/event"
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	ListAfter(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]models.SecretEvent, error)
+	LatestSeq(ctx context.Context, userID uuid.UUID) (int64, error)
+	// Subscribe returns a channel that receives a signal whenever new events
+	// may be available for the user, and a function releasing it.
+	Subscribe(userID uuid.UUID) (<-chan struct{}, func())
+	Notify(userID uuid.UUID)
+	// NotifyAll wakes every open stream, e.g. after notifications may have
+	// been missed.
+	NotifyAll()
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+type service struct {
+	events eventrepository.EventRepository
+	broker *broker
+	cfg    config.Config
+}
+
+func NewService(events eventrepository.EventRepository, cfg config.Config) Service {
+	return &service{
+		events: events,
+		broker: newBroker(),
+		cfg:    cfg,
+	}
+}
+
+func (s *service) ListAfter(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]models.SecretEvent, error) {
+	return s.events.ListAfter(ctx, userID, after, limit)
+}
+
+func (s *service) LatestSeq(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.events.LatestSeq(ctx, userID)
+}
+
+func (s *service) Subscribe(userID uuid.UUID) (<-chan struct{}, func()) {
+	return s.broker.subscribe(userID)
+}
+
+func (s *service) Notify(userID uuid.UUID) {
+	s.broker.notify(userID)
+}
+
+func (s *service) NotifyAll() {
+	s.broker.notifyAll()
+}
+
+func (s *service) PurgeExpired(ctx context.Context) (int64, error) {
+	if s.cfg.EventsTTL <= 0 {
+		return 0, nil
+	}
+	return s.events.PurgeBefore(ctx, time.Now().UTC().Add(-s.cfg.EventsTTL))
+}
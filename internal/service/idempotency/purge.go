@@ -0,0 +1,62 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const purgeInterval = time.Hour
+
+// RegisterPurge periodically drops stored responses older than the
+// configured idempotency retention.
+func RegisterPurge(lc fx.Lifecycle, svc Service, cfg config.Config, log *zap.Logger) {
+	if cfg.IdempotencyTTL <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(purgeInterval)
+				defer ticker.Stop()
+				for {
+					purgeKeys(ctx, svc, log)
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
+
+func purgeKeys(ctx context.Context, svc Service, log *zap.Logger) {
+	purged, err := svc.PurgeExpired(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("idempotency purge failed", zap.Error(err))
+		}
+		return
+	}
+	if purged > 0 {
+		log.Info("idempotency keys purged", zap.Int64("keys", purged))
+	}
+}
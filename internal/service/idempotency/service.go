@@ -0,0 +1,112 @@
+package idempotency
+
+//go:generate go run go.uber.org/mock/mockgen@latest -destination=./mocks/key_repository_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/repository/idempotency KeyRepository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	idempotencyrepository "github.com/7StaSH7/practicum-diploma/internal/repository/idempotency"
+	"github.com/google/uuid"
+)
+
+// HeartbeatInterval is how often the request holding a reservation refreshes
+// it while its handler is still running.
+const HeartbeatInterval = 10 * time.Second
+
+// pendingTimeout is how long a reservation may go without a heartbeat before a
+// retry with the same request is allowed to take it over. Only a request whose
+// server went away stops sending heartbeats, so a slow request keeps its key.
+const pendingTimeout = 6 * HeartbeatInterval
+
+var (
+	ErrKeyReused  = errors.New("idempotency key reused with a different request")
+	ErrInProgress = errors.New("request with this idempotency key is in progress")
+)
+
+type Service interface {
+	Begin(ctx context.Context, userID uuid.UUID, key string, requestHash []byte) (models.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, userID uuid.UUID, key string, statusCode int, contentType string, body []byte) error
+	Release(ctx context.Context, userID uuid.UUID, key string) error
+	Heartbeat(ctx context.Context, userID uuid.UUID, key string) error
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+type service struct {
+	keys idempotencyrepository.KeyRepository
+	cfg  config.Config
+}
+
+func NewService(keys idempotencyrepository.KeyRepository, cfg config.Config) Service {
+	return &service{
+		keys: keys,
+		cfg:  cfg,
+	}
+}
+
+// Begin reserves key for a new request. When the key already holds a stored
+// response for the same request it is returned with replay set to true.
+func (s *service) Begin(ctx context.Context, userID uuid.UUID, key string, requestHash []byte) (models.IdempotencyRecord, bool, error) {
+	now := time.Now().UTC()
+	reserved, err := s.keys.Reserve(ctx, models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+	}, now.Add(-pendingTimeout))
+	if err != nil {
+		return models.IdempotencyRecord{}, false, err
+	}
+	if reserved {
+		return models.IdempotencyRecord{}, false, nil
+	}
+
+	existing, err := s.keys.Get(ctx, userID, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.IdempotencyRecord{}, false, ErrInProgress
+		}
+		return models.IdempotencyRecord{}, false, err
+	}
+	if !bytes.Equal(existing.RequestHash, requestHash) {
+		return models.IdempotencyRecord{}, false, ErrKeyReused
+	}
+	if existing.CompletedAt.IsZero() {
+		return models.IdempotencyRecord{}, false, ErrInProgress
+	}
+	return existing, true, nil
+}
+
+func (s *service) Complete(ctx context.Context, userID uuid.UUID, key string, statusCode int, contentType string, body []byte) error {
+	return s.keys.Complete(ctx, models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		StatusCode:  statusCode,
+		ContentType: contentType,
+		Body:        body,
+		CompletedAt: time.Now().UTC(),
+	})
+}
+
+// Release drops a pending reservation so the request can be retried, e.g.
+// after the handler failed with a server error.
+func (s *service) Release(ctx context.Context, userID uuid.UUID, key string) error {
+	return s.keys.Release(ctx, userID, key)
+}
+
+// Heartbeat marks the reservation of key as still held by a running request.
+func (s *service) Heartbeat(ctx context.Context, userID uuid.UUID, key string) error {
+	return s.keys.Touch(ctx, userID, key, time.Now().UTC())
+}
+
+func (s *service) PurgeExpired(ctx context.Context) (int64, error) {
+	if s.cfg.IdempotencyTTL <= 0 {
+		return 0, nil
+	}
+	return s.keys.PurgeBefore(ctx, time.Now().UTC().Add(-s.cfg.IdempotencyTTL))
+}
@@ -0,0 +1,122 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	idempotencymocks "github.com/7StaSH7/practicum-diploma/internal/service/idempotency/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestBeginReservesNewKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := idempotencymocks.NewMockKeyRepository(ctrl)
+	svc := NewService(repo, config.Config{})
+	userID := uuid.New()
+
+	repo.EXPECT().Reserve(gomock.Any(), gomock.AssignableToTypeOf(models.IdempotencyRecord{}), gomock.Any()).DoAndReturn(
+		func(_ context.Context, record models.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+			assert.Equal(t, userID, record.UserID)
+			assert.Equal(t, "key", record.Key)
+			assert.Equal(t, pendingTimeout, record.CreatedAt.Sub(staleBefore))
+			return true, nil
+		},
+	)
+
+	_, replay, err := svc.Begin(context.Background(), userID, "key", []byte("hash"))
+	require.NoError(t, err)
+	assert.False(t, replay)
+}
+
+func TestBeginReplaysCompletedRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := idempotencymocks.NewMockKeyRepository(ctrl)
+	svc := NewService(repo, config.Config{})
+	userID := uuid.New()
+	stored := models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         "key",
+		RequestHash: []byte("hash"),
+		StatusCode:  200,
+		Body:        []byte(`{"id":"x"}`),
+		CompletedAt: time.Now().UTC(),
+	}
+
+	repo.EXPECT().Reserve(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+	repo.EXPECT().Get(gomock.Any(), userID, "key").Return(stored, nil)
+
+	got, replay, err := svc.Begin(context.Background(), userID, "key", []byte("hash"))
+	require.NoError(t, err)
+	assert.True(t, replay)
+	assert.Equal(t, stored, got)
+}
+
+func TestBeginRejectsReusedKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := idempotencymocks.NewMockKeyRepository(ctrl)
+	svc := NewService(repo, config.Config{})
+	userID := uuid.New()
+
+	repo.EXPECT().Reserve(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+	repo.EXPECT().Get(gomock.Any(), userID, "key").Return(models.IdempotencyRecord{RequestHash: []byte("other")}, nil)
+
+	_, _, err := svc.Begin(context.Background(), userID, "key", []byte("hash"))
+	assert.ErrorIs(t, err, ErrKeyReused)
+}
+
+func TestBeginReportsPendingRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := idempotencymocks.NewMockKeyRepository(ctrl)
+	svc := NewService(repo, config.Config{})
+	userID := uuid.New()
+
+	repo.EXPECT().Reserve(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+	repo.EXPECT().Get(gomock.Any(), userID, "key").Return(models.IdempotencyRecord{RequestHash: []byte("hash")}, nil)
+
+	_, _, err := svc.Begin(context.Background(), userID, "key", []byte("hash"))
+	assert.ErrorIs(t, err, ErrInProgress)
+}
+
+func TestHeartbeatRefreshesReservation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := idempotencymocks.NewMockKeyRepository(ctrl)
+	svc := NewService(repo, config.Config{})
+	userID := uuid.New()
+	before := time.Now().UTC()
+
+	repo.EXPECT().Touch(gomock.Any(), userID, "key", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ uuid.UUID, _ string, at time.Time) error {
+			assert.False(t, at.Before(before))
+			return nil
+		},
+	)
+
+	require.NoError(t, svc.Heartbeat(context.Background(), userID, "key"))
+}
+
+func TestPurgeExpiredSkipsWithoutTTL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := NewService(idempotencymocks.NewMockKeyRepository(ctrl), config.Config{})
+
+	purged, err := svc.PurgeExpired(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+}
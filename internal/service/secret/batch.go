@@ -0,0 +1,165 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	secretrepository "github.com/7StaSH7/practicum-diploma/internal/repository/secret"
+	"github.com/google/uuid"
+)
+
+// MaxBatchOperations caps a single batch so one request cannot hold a
+// transaction open for an unbounded number of rows.
+const MaxBatchOperations = 500
+
+var (
+	ErrInvalidBatch     = errors.New("invalid batch")
+	ErrInvalidOperation = errors.New("invalid batch operation")
+)
+
+// Batch applies create, update and delete operations for one user. In atomic
+// mode every operation shares a transaction and the first failure rolls all of
+// them back; otherwise each operation commits on its own. The returned flag
+// reports whether anything was committed.
+func (s *service) Batch(ctx context.Context, userID uuid.UUID, input dtosecret.BatchInput) ([]dtosecret.BatchItemOutcome, bool, error) {
+	if len(input.Items) == 0 || len(input.Items) > MaxBatchOperations {
+		return nil, false, ErrInvalidBatch
+	}
+	outcomes := make([]dtosecret.BatchItemOutcome, len(input.Items))
+	for i, item := range input.Items {
+		outcomes[i] = dtosecret.BatchItemOutcome{Op: item.Op, ID: item.ID}
+	}
+
+	if input.Atomic {
+		outcomes, committed, err := s.batchAtomic(ctx, userID, input.Items, outcomes)
+		if committed {
+			for i, item := range input.Items {
+				s.pruneBatchItem(ctx, item, outcomes[i].Secret)
+			}
+			s.notify(userID)
+		}
+		return outcomes, committed, err
+	}
+
+	committed := false
+	for i, item := range input.Items {
+		var applied models.Secret
+		err := s.secrets.InTx(ctx, func(repo secretrepository.SecretRepository) error {
+			var err error
+			applied, err = s.applyBatchItem(ctx, repo, userID, item)
+			return err
+		})
+		if err != nil {
+			outcomes[i].Err = err
+			continue
+		}
+		outcomes[i].Secret = applied
+		outcomes[i].ID = applied.ID
+		outcomes[i].Applied = true
+		committed = true
+		s.pruneBatchItem(ctx, item, applied)
+	}
+	if committed {
+		s.notify(userID)
+	}
+	return outcomes, committed, nil
+}
+
+func (s *service) batchAtomic(ctx context.Context, userID uuid.UUID, items []dtosecret.BatchItemInput, outcomes []dtosecret.BatchItemOutcome) ([]dtosecret.BatchItemOutcome, bool, error) {
+	var itemErr error
+	err := s.secrets.InTx(ctx, func(repo secretrepository.SecretRepository) error {
+		for i, item := range items {
+			applied, err := s.applyBatchItem(ctx, repo, userID, item)
+			if err != nil {
+				outcomes[i].Err = err
+				itemErr = err
+				return err
+			}
+			outcomes[i].Secret = applied
+			outcomes[i].ID = applied.ID
+		}
+		return nil
+	})
+	if err != nil {
+		if itemErr != nil && isBatchItemError(itemErr) {
+			return outcomes, false, nil
+		}
+		return nil, false, err
+	}
+	for i := range outcomes {
+		outcomes[i].Applied = true
+	}
+	return outcomes, true, nil
+}
+
+func (s *service) applyBatchItem(ctx context.Context, repo secretrepository.SecretRepository, userID uuid.UUID, item dtosecret.BatchItemInput) (models.Secret, error) {
+	switch item.Op {
+	case dtosecret.BatchOpCreate:
+		secret, err := newSecret(userID, item.Payload)
+		if err != nil {
+			return models.Secret{}, err
+		}
+		if err := repo.Create(ctx, secret); err != nil {
+			return models.Secret{}, err
+		}
+		return secret, nil
+	case dtosecret.BatchOpUpdate:
+		current, err := lockExpected(ctx, repo, userID, item)
+		if err != nil {
+			return models.Secret{}, err
+		}
+		payload, data, err := decodeUpdate(item.Payload, current.Type)
+		if err != nil {
+			return models.Secret{}, err
+		}
+		current.Type = payload.Type
+		current.MetaOpen = payload.MetaOpen
+		current.Ciphertext = data
+		return s.replace(ctx, repo, current)
+	case dtosecret.BatchOpDelete:
+		current, err := lockExpected(ctx, repo, userID, item)
+		if err != nil {
+			return models.Secret{}, err
+		}
+		now := time.Now().UTC()
+		if err := repo.Trash(ctx, current.ID, userID, now); err != nil {
+			return models.Secret{}, mapNotFound(err)
+		}
+		current.DeletedAt = now
+		current.UpdatedAt = now
+		return current, nil
+	default:
+		return models.Secret{}, ErrInvalidOperation
+	}
+}
+
+// pruneBatchItem trims the history of a committed batch update.
+func (s *service) pruneBatchItem(ctx context.Context, item dtosecret.BatchItemInput, applied models.Secret) {
+	if item.Op == dtosecret.BatchOpUpdate {
+		s.pruneHistory(ctx, applied)
+	}
+}
+
+// lockExpected locks the current row and checks it against the version the
+// client based its change on; zero skips the check.
+func lockExpected(ctx context.Context, repo secretrepository.SecretRepository, userID uuid.UUID, item dtosecret.BatchItemInput) (models.Secret, error) {
+	current, err := repo.Lock(ctx, item.ID, userID)
+	if err != nil {
+		return models.Secret{}, mapNotFound(err)
+	}
+	if item.ExpectedVersion > 0 && current.Version != item.ExpectedVersion {
+		return models.Secret{}, ErrVersionConflict
+	}
+	return current, nil
+}
+
+func isBatchItemError(err error) bool {
+	return errors.Is(err, ErrNotFound) ||
+		errors.Is(err, ErrVersionConflict) ||
+		errors.Is(err, ErrInvalidCiphertext) ||
+		errors.Is(err, ErrUnknownType) ||
+		errors.Is(err, ErrInvalidOperation)
+}
@@ -15,6 +15,7 @@ import (
 	time "time"
 
 	models "github.com/7StaSH7/practicum-diploma/internal/models"
+	secret "github.com/7StaSH7/practicum-diploma/internal/repository/secret"
 	uuid "github.com/google/uuid"
 	gomock "go.uber.org/mock/gomock"
 )
@@ -86,6 +87,50 @@ func (mr *MockSecretRepositoryMockRecorder) Get(ctx, id, userID any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockSecretRepository)(nil).Get), ctx, id, userID)
 }
 
+// GetVersion mocks base method.
+func (m *MockSecretRepository) GetVersion(ctx context.Context, id, userID uuid.UUID, version int64) (models.SecretVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVersion", ctx, id, userID, version)
+	ret0, _ := ret[0].(models.SecretVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVersion indicates an expected call of GetVersion.
+func (mr *MockSecretRepositoryMockRecorder) GetVersion(ctx, id, userID, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersion", reflect.TypeOf((*MockSecretRepository)(nil).GetVersion), ctx, id, userID, version)
+}
+
+// InTx mocks base method.
+func (m *MockSecretRepository) InTx(ctx context.Context, fn func(secret.SecretRepository) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InTx indicates an expected call of InTx.
+func (mr *MockSecretRepositoryMockRecorder) InTx(ctx, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InTx", reflect.TypeOf((*MockSecretRepository)(nil).InTx), ctx, fn)
+}
+
+// ListChanges mocks base method.
+func (m *MockSecretRepository) ListChanges(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListChanges", ctx, userID, since)
+	ret0, _ := ret[0].([]models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListChanges indicates an expected call of ListChanges.
+func (mr *MockSecretRepositoryMockRecorder) ListChanges(ctx, userID, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListChanges", reflect.TypeOf((*MockSecretRepository)(nil).ListChanges), ctx, userID, since)
+}
+
 // ListSince mocks base method.
 func (m *MockSecretRepository) ListSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error) {
 	m.ctrl.T.Helper()
@@ -101,6 +146,108 @@ func (mr *MockSecretRepositoryMockRecorder) ListSince(ctx, userID, since any) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSince", reflect.TypeOf((*MockSecretRepository)(nil).ListSince), ctx, userID, since)
 }
 
+// ListTrash mocks base method.
+func (m *MockSecretRepository) ListTrash(ctx context.Context, userID uuid.UUID) ([]models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrash", ctx, userID)
+	ret0, _ := ret[0].([]models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrash indicates an expected call of ListTrash.
+func (mr *MockSecretRepositoryMockRecorder) ListTrash(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrash", reflect.TypeOf((*MockSecretRepository)(nil).ListTrash), ctx, userID)
+}
+
+// ListVersions mocks base method.
+func (m *MockSecretRepository) ListVersions(ctx context.Context, id, userID uuid.UUID) ([]models.SecretVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVersions", ctx, id, userID)
+	ret0, _ := ret[0].([]models.SecretVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVersions indicates an expected call of ListVersions.
+func (mr *MockSecretRepositoryMockRecorder) ListVersions(ctx, id, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVersions", reflect.TypeOf((*MockSecretRepository)(nil).ListVersions), ctx, id, userID)
+}
+
+// Lock mocks base method.
+func (m *MockSecretRepository) Lock(ctx context.Context, id, userID uuid.UUID) (models.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", ctx, id, userID)
+	ret0, _ := ret[0].(models.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Lock indicates an expected call of Lock.
+func (mr *MockSecretRepositoryMockRecorder) Lock(ctx, id, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockSecretRepository)(nil).Lock), ctx, id, userID)
+}
+
+// PruneVersions mocks base method.
+func (m *MockSecretRepository) PruneVersions(ctx context.Context, id, userID uuid.UUID, keep int, before time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneVersions", ctx, id, userID, keep, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PruneVersions indicates an expected call of PruneVersions.
+func (mr *MockSecretRepositoryMockRecorder) PruneVersions(ctx, id, userID, keep, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneVersions", reflect.TypeOf((*MockSecretRepository)(nil).PruneVersions), ctx, id, userID, keep, before)
+}
+
+// PurgeTrashed mocks base method.
+func (m *MockSecretRepository) PurgeTrashed(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeTrashed", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeTrashed indicates an expected call of PurgeTrashed.
+func (mr *MockSecretRepositoryMockRecorder) PurgeTrashed(ctx, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeTrashed", reflect.TypeOf((*MockSecretRepository)(nil).PurgeTrashed), ctx, before)
+}
+
+// RestoreTrashed mocks base method.
+func (m *MockSecretRepository) RestoreTrashed(ctx context.Context, id, userID uuid.UUID, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreTrashed", ctx, id, userID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreTrashed indicates an expected call of RestoreTrashed.
+func (mr *MockSecretRepositoryMockRecorder) RestoreTrashed(ctx, id, userID, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreTrashed", reflect.TypeOf((*MockSecretRepository)(nil).RestoreTrashed), ctx, id, userID, at)
+}
+
+// Trash mocks base method.
+func (m *MockSecretRepository) Trash(ctx context.Context, id, userID uuid.UUID, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trash", ctx, id, userID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Trash indicates an expected call of Trash.
+func (mr *MockSecretRepositoryMockRecorder) Trash(ctx, id, userID, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trash", reflect.TypeOf((*MockSecretRepository)(nil).Trash), ctx, id, userID, at)
+}
+
 // Update mocks base method.
 func (m *MockSecretRepository) Update(ctx context.Context, secret models.Secret) error {
 	m.ctrl.T.Helper()
@@ -0,0 +1,60 @@
+package secret
+
+import (
+	"context"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// RegisterTrashPurge periodically removes secrets that stayed in the trash
+// longer than the configured retention.
+func RegisterTrashPurge(lc fx.Lifecycle, svc Service, cfg config.Config, log *zap.Logger) {
+	if cfg.TrashTTL <= 0 || cfg.TrashPurge <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(cfg.TrashPurge)
+				defer ticker.Stop()
+				for {
+					purgeTrash(ctx, svc, log)
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
+
+func purgeTrash(ctx context.Context, svc Service, log *zap.Logger) {
+	purged, err := svc.PurgeExpired(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("trash purge failed", zap.Error(err))
+		}
+		return
+	}
+	if purged > 0 {
+		log.Info("trash purged", zap.Int64("secrets", purged))
+	}
+}
@@ -1,6 +1,7 @@
 package secret
 
 //go:generate go run go.uber.org/mock/mockgen@latest -destination=./mocks/secret_repository_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/repository/secret SecretRepository
+//go:generate go run go.uber.org/mock/mockgen@latest -destination=./mocks/event_service_mock.go -package=mocks github.com/7StaSH7/practicum-diploma/internal/service/event Service
 
 import (
 	"context"
@@ -9,15 +10,21 @@ import (
 	"errors"
 	"time"
 
+	"github.com/7StaSH7/practicum-diploma/internal/config"
 	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
 	"github.com/7StaSH7/practicum-diploma/internal/models"
 	secretrepository "github.com/7StaSH7/practicum-diploma/internal/repository/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	eventservice "github.com/7StaSH7/practicum-diploma/internal/service/event"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 var (
 	ErrInvalidCiphertext = errors.New("invalid ciphertext")
 	ErrNotFound          = errors.New("secret not found")
+	ErrVersionConflict   = errors.New("secret version conflict")
+	ErrUnknownType       = errors.New("unknown secret type")
 )
 
 type Service interface {
@@ -26,24 +33,51 @@ type Service interface {
 	Delete(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) error
 	Get(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) (models.Secret, error)
 	ListSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error)
+	ListChanges(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error)
+	ListVersions(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) ([]models.SecretVersion, error)
+	GetVersion(ctx context.Context, userID uuid.UUID, secretID uuid.UUID, version int64) (models.SecretVersion, error)
+	Restore(ctx context.Context, userID uuid.UUID, secretID uuid.UUID, version int64) (models.Secret, error)
+	ListTrash(ctx context.Context, userID uuid.UUID) ([]models.Secret, error)
+	RestoreFromTrash(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) (models.Secret, error)
+	Purge(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) error
+	PurgeExpired(ctx context.Context) (int64, error)
+	Batch(ctx context.Context, userID uuid.UUID, input dtosecret.BatchInput) ([]dtosecret.BatchItemOutcome, bool, error)
 }
 
 type service struct {
 	secrets secretrepository.SecretRepository
+	events  eventservice.Service
+	cfg     config.Config
+	log     *zap.Logger
 }
 
-func NewService(secrets secretrepository.SecretRepository) Service {
+func NewService(secrets secretrepository.SecretRepository, events eventservice.Service, cfg config.Config, log *zap.Logger) Service {
 	return &service{
 		secrets: secrets,
+		events:  events,
+		cfg:     cfg,
+		log:     log,
 	}
 }
 
 func (s *service) Create(ctx context.Context, userID uuid.UUID, payload dtosecret.SecretInput) (models.Secret, error) {
-	data, err := decodeCiphertext(payload.Ciphertext)
+	secret, err := newSecret(userID, payload)
+	if err != nil {
+		return models.Secret{}, err
+	}
+	if err := s.secrets.Create(ctx, secret); err != nil {
+		return models.Secret{}, err
+	}
+	s.notify(userID)
+	return secret, nil
+}
+
+func newSecret(userID uuid.UUID, payload dtosecret.SecretInput) (models.Secret, error) {
+	data, err := decodeInput(payload)
 	if err != nil {
-		return models.Secret{}, ErrInvalidCiphertext
+		return models.Secret{}, err
 	}
-	secret := models.Secret{
+	return models.Secret{
 		ID:         uuid.New(),
 		UserID:     userID,
 		Type:       payload.Type,
@@ -51,35 +85,136 @@ func (s *service) Create(ctx context.Context, userID uuid.UUID, payload dtosecre
 		Ciphertext: data,
 		Version:    1,
 		UpdatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+func (s *service) Update(ctx context.Context, userID uuid.UUID, secretID uuid.UUID, payload dtosecret.SecretInput) (models.Secret, error) {
+	current, err := s.secrets.Get(ctx, secretID, userID)
+	if err != nil {
+		return models.Secret{}, mapNotFound(err)
 	}
-	if err := s.secrets.Create(ctx, secret); err != nil {
+	payload, data, err := decodeUpdate(payload, current.Type)
+	if err != nil {
 		return models.Secret{}, err
 	}
-	return secret, nil
+	current.Type = payload.Type
+	current.MetaOpen = payload.MetaOpen
+	current.Ciphertext = data
+	return s.replaceAndNotify(ctx, current)
 }
 
-func (s *service) Update(ctx context.Context, userID uuid.UUID, secretID uuid.UUID, payload dtosecret.SecretInput) (models.Secret, error) {
-	data, err := decodeCiphertext(payload.Ciphertext)
+func (s *service) ListVersions(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) ([]models.SecretVersion, error) {
+	if _, err := s.secrets.Get(ctx, secretID, userID); err != nil {
+		return nil, mapNotFound(err)
+	}
+	return s.secrets.ListVersions(ctx, secretID, userID)
+}
+
+func (s *service) GetVersion(ctx context.Context, userID uuid.UUID, secretID uuid.UUID, version int64) (models.SecretVersion, error) {
+	if _, err := s.secrets.Get(ctx, secretID, userID); err != nil {
+		return models.SecretVersion{}, mapNotFound(err)
+	}
+	found, err := s.secrets.GetVersion(ctx, secretID, userID, version)
 	if err != nil {
-		return models.Secret{}, ErrInvalidCiphertext
+		return models.SecretVersion{}, mapNotFound(err)
+	}
+	return found, nil
+}
+
+func (s *service) Restore(ctx context.Context, userID uuid.UUID, secretID uuid.UUID, version int64) (models.Secret, error) {
+	archived, err := s.secrets.GetVersion(ctx, secretID, userID, version)
+	if err != nil {
+		return models.Secret{}, mapNotFound(err)
 	}
 	current, err := s.secrets.Get(ctx, secretID, userID)
 	if err != nil {
 		return models.Secret{}, mapNotFound(err)
 	}
-	current.Type = payload.Type
-	current.MetaOpen = payload.MetaOpen
-	current.Ciphertext = data
-	current.Version++
-	current.UpdatedAt = time.Now().UTC()
-	if err := s.secrets.Update(ctx, current); err != nil {
+	current.Type = archived.Type
+	if !secretkind.Registered(current.Type) {
+		current.Type = secretkind.Note
+	}
+	current.MetaOpen = archived.MetaOpen
+	current.Ciphertext = archived.Ciphertext
+	return s.replaceAndNotify(ctx, current)
+}
+
+func (s *service) replaceAndNotify(ctx context.Context, next models.Secret) (models.Secret, error) {
+	updated, err := s.replace(ctx, s.secrets, next)
+	if err != nil {
+		return models.Secret{}, err
+	}
+	s.pruneHistory(ctx, updated)
+	s.notify(updated.UserID)
+	return updated, nil
+}
+
+// replace stores the next version of a secret; the repository archives the
+// previous row. Callers trim the history with pruneHistory once the change
+// is committed.
+func (s *service) replace(ctx context.Context, repo secretrepository.SecretRepository, next models.Secret) (models.Secret, error) {
+	next.Version++
+	next.UpdatedAt = time.Now().UTC()
+	if err := repo.Update(ctx, next); err != nil {
+		if errors.Is(err, secretrepository.ErrVersionConflict) {
+			return models.Secret{}, ErrVersionConflict
+		}
 		return models.Secret{}, mapNotFound(err)
 	}
-	return current, nil
+	return next, nil
 }
 
+// pruneHistory trims the history of a committed secret to the configured
+// bounds. It runs outside any transaction: a failed prune is only logged,
+// and on Postgres it would otherwise abort the transaction of the write.
+func (s *service) pruneHistory(ctx context.Context, secret models.Secret) {
+	if s.cfg.HistoryLimit <= 0 && s.cfg.HistoryTTL <= 0 {
+		return
+	}
+	var before time.Time
+	if s.cfg.HistoryTTL > 0 {
+		before = secret.UpdatedAt.Add(-s.cfg.HistoryTTL)
+	}
+	if err := s.secrets.PruneVersions(ctx, secret.ID, secret.UserID, s.cfg.HistoryLimit, before); err != nil {
+		s.log.Warn("prune secret history failed", zap.String("secret_id", secret.ID.String()), zap.Error(err))
+	}
+}
+
+// Delete moves a secret to the trash; it is removed for good by Purge or by
+// the background purge once the trash retention expires.
 func (s *service) Delete(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) error {
-	return mapNotFound(s.secrets.Delete(ctx, secretID, userID))
+	if err := s.secrets.Trash(ctx, secretID, userID, time.Now().UTC()); err != nil {
+		return mapNotFound(err)
+	}
+	s.notify(userID)
+	return nil
+}
+
+func (s *service) ListTrash(ctx context.Context, userID uuid.UUID) ([]models.Secret, error) {
+	return s.secrets.ListTrash(ctx, userID)
+}
+
+func (s *service) RestoreFromTrash(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) (models.Secret, error) {
+	if err := s.secrets.RestoreTrashed(ctx, secretID, userID, time.Now().UTC()); err != nil {
+		return models.Secret{}, mapNotFound(err)
+	}
+	s.notify(userID)
+	return s.Get(ctx, userID, secretID)
+}
+
+func (s *service) Purge(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) error {
+	if err := s.secrets.Delete(ctx, secretID, userID); err != nil {
+		return mapNotFound(err)
+	}
+	s.notify(userID)
+	return nil
+}
+
+func (s *service) PurgeExpired(ctx context.Context) (int64, error) {
+	if s.cfg.TrashTTL <= 0 {
+		return 0, nil
+	}
+	return s.secrets.PurgeTrashed(ctx, time.Now().UTC().Add(-s.cfg.TrashTTL))
 }
 
 func (s *service) Get(ctx context.Context, userID uuid.UUID, secretID uuid.UUID) (models.Secret, error) {
@@ -94,6 +229,43 @@ func (s *service) ListSince(ctx context.Context, userID uuid.UUID, since time.Ti
 	return s.secrets.ListSince(ctx, userID, since)
 }
 
+func (s *service) ListChanges(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error) {
+	return s.secrets.ListChanges(ctx, userID, since)
+}
+
+// notify wakes the user's event streams once a change is committed; the
+// events themselves are recorded by the database.
+func (s *service) notify(userID uuid.UUID) {
+	if s.events != nil {
+		s.events.Notify(userID)
+	}
+}
+
+// decodeInput checks the secret type against the kind registry and decodes
+// the ciphertext. The payload itself is encrypted and is validated by clients.
+func decodeInput(payload dtosecret.SecretInput) ([]byte, error) {
+	if !secretkind.Registered(payload.Type) {
+		return nil, ErrUnknownType
+	}
+	data, err := decodeCiphertext(payload.Ciphertext)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return data, nil
+}
+
+// decodeUpdate is decodeInput for a write to an existing secret. Secrets from
+// before typed kinds had free-form types; a client still sending one for such
+// a secret writes a note, the kind its plaintext decodes to. Any other secret
+// refuses unregistered types.
+func decodeUpdate(payload dtosecret.SecretInput, currentType string) (dtosecret.SecretInput, []byte, error) {
+	if !secretkind.Registered(payload.Type) && !secretkind.Registered(currentType) {
+		payload.Type = secretkind.Note
+	}
+	data, err := decodeInput(payload)
+	return payload, data, err
+}
+
 func decodeCiphertext(ciphertext string) ([]byte, error) {
 	return base64.StdEncoding.DecodeString(ciphertext)
 }
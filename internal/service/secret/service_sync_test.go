@@ -4,17 +4,26 @@ import (
 	"context"
 	"database/sql"
 	"encoding/base64"
+	"errors"
 	"sort"
 	"testing"
 	"time"
 
+	"github.com/7StaSH7/practicum-diploma/internal/config"
 	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
 	"github.com/7StaSH7/practicum-diploma/internal/models"
+	secretrepository "github.com/7StaSH7/practicum-diploma/internal/repository/secret"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 type memorySecretRepo struct {
 	items map[uuid.UUID]models.Secret
+	// inTx is set while InTx runs. Like Postgres, a transaction fails for
+	// good once a statement in it failed, and a prune inside one fails.
+	inTx    bool
+	aborted bool
+	pruned  []uuid.UUID
 }
 
 func newMemorySecretRepo() *memorySecretRepo {
@@ -30,16 +39,19 @@ func (m *memorySecretRepo) Create(_ context.Context, secret models.Secret) error
 
 func (m *memorySecretRepo) Update(_ context.Context, secret models.Secret) error {
 	current, ok := m.items[secret.ID]
-	if !ok || current.UserID != secret.UserID {
+	if !ok || current.UserID != secret.UserID || !current.DeletedAt.IsZero() {
 		return sql.ErrNoRows
 	}
+	if current.Version != secret.Version-1 {
+		return secretrepository.ErrVersionConflict
+	}
 	m.items[secret.ID] = secret
 	return nil
 }
 
 func (m *memorySecretRepo) Get(_ context.Context, id uuid.UUID, userID uuid.UUID) (models.Secret, error) {
 	secret, ok := m.items[id]
-	if !ok || secret.UserID != userID {
+	if !ok || secret.UserID != userID || !secret.DeletedAt.IsZero() {
 		return models.Secret{}, sql.ErrNoRows
 	}
 	return secret, nil
@@ -48,7 +60,7 @@ func (m *memorySecretRepo) Get(_ context.Context, id uuid.UUID, userID uuid.UUID
 func (m *memorySecretRepo) ListSince(_ context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error) {
 	out := make([]models.Secret, 0)
 	for _, secret := range m.items {
-		if secret.UserID != userID {
+		if secret.UserID != userID || !secret.DeletedAt.IsZero() {
 			continue
 		}
 		if secret.UpdatedAt.After(since) {
@@ -61,18 +73,114 @@ func (m *memorySecretRepo) ListSince(_ context.Context, userID uuid.UUID, since
 	return out, nil
 }
 
+func (m *memorySecretRepo) ListChanges(_ context.Context, userID uuid.UUID, since time.Time) ([]models.Secret, error) {
+	out := make([]models.Secret, 0)
+	for _, secret := range m.items {
+		if secret.UserID == userID && secret.UpdatedAt.After(since) {
+			out = append(out, secret)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].UpdatedAt.Before(out[j].UpdatedAt)
+	})
+	return out, nil
+}
+
+func (m *memorySecretRepo) Trash(_ context.Context, id uuid.UUID, userID uuid.UUID, at time.Time) error {
+	secret, ok := m.items[id]
+	if !ok || secret.UserID != userID || !secret.DeletedAt.IsZero() {
+		return sql.ErrNoRows
+	}
+	secret.DeletedAt = at
+	secret.UpdatedAt = at
+	m.items[id] = secret
+	return nil
+}
+
+func (m *memorySecretRepo) ListTrash(_ context.Context, userID uuid.UUID) ([]models.Secret, error) {
+	out := make([]models.Secret, 0)
+	for _, secret := range m.items {
+		if secret.UserID == userID && !secret.DeletedAt.IsZero() {
+			out = append(out, secret)
+		}
+	}
+	return out, nil
+}
+
+func (m *memorySecretRepo) RestoreTrashed(_ context.Context, id uuid.UUID, userID uuid.UUID, at time.Time) error {
+	secret, ok := m.items[id]
+	if !ok || secret.UserID != userID || secret.DeletedAt.IsZero() {
+		return sql.ErrNoRows
+	}
+	secret.DeletedAt = time.Time{}
+	secret.UpdatedAt = at
+	secret.Version++
+	m.items[id] = secret
+	return nil
+}
+
 func (m *memorySecretRepo) Delete(_ context.Context, id uuid.UUID, userID uuid.UUID) error {
 	secret, ok := m.items[id]
-	if !ok || secret.UserID != userID {
+	if !ok || secret.UserID != userID || secret.DeletedAt.IsZero() {
 		return sql.ErrNoRows
 	}
 	delete(m.items, id)
 	return nil
 }
 
-func TestDeleteRemovesSecretCompletely(t *testing.T) {
+func (m *memorySecretRepo) PurgeTrashed(_ context.Context, before time.Time) (int64, error) {
+	var purged int64
+	for id, secret := range m.items {
+		if !secret.DeletedAt.IsZero() && secret.DeletedAt.Before(before) {
+			delete(m.items, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *memorySecretRepo) ListVersions(_ context.Context, _ uuid.UUID, _ uuid.UUID) ([]models.SecretVersion, error) {
+	return nil, nil
+}
+
+func (m *memorySecretRepo) GetVersion(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ int64) (models.SecretVersion, error) {
+	return models.SecretVersion{}, sql.ErrNoRows
+}
+
+func (m *memorySecretRepo) PruneVersions(_ context.Context, id uuid.UUID, _ uuid.UUID, _ int, _ time.Time) error {
+	if m.inTx {
+		m.aborted = true
+		return errors.New("prune failed")
+	}
+	m.pruned = append(m.pruned, id)
+	return nil
+}
+
+func (m *memorySecretRepo) Lock(ctx context.Context, id uuid.UUID, userID uuid.UUID) (models.Secret, error) {
+	return m.Get(ctx, id, userID)
+}
+
+func (m *memorySecretRepo) InTx(_ context.Context, fn func(repo secretrepository.SecretRepository) error) error {
+	snapshot := make(map[uuid.UUID]models.Secret, len(m.items))
+	for id, secret := range m.items {
+		snapshot[id] = secret
+	}
+	m.inTx, m.aborted = true, false
+	err := fn(m)
+	m.inTx = false
+	if err == nil && m.aborted {
+		err = errors.New("current transaction is aborted")
+	}
+	if err != nil {
+		m.items = snapshot
+		return err
+	}
+	return nil
+}
+
+func TestDeleteMovesSecretToTrash(t *testing.T) {
 	repo := newMemorySecretRepo()
-	service := NewService(repo)
+	service := NewService(repo, nil, config.Config{}, zap.NewNop())
 	userID := uuid.New()
 
 	payload := dtosecret.SecretInput{
@@ -103,6 +211,211 @@ func TestDeleteRemovesSecretCompletely(t *testing.T) {
 		t.Fatalf("list since: %v", err)
 	}
 	if len(changes) != 0 {
-		t.Fatalf("expected no changes after hard delete, got %d", len(changes))
+		t.Fatalf("expected no live changes after delete, got %d", len(changes))
+	}
+
+	withDeleted, err := service.ListChanges(context.Background(), userID, checkpoint)
+	if err != nil {
+		t.Fatalf("list changes: %v", err)
+	}
+	if len(withDeleted) != 1 || withDeleted[0].ID != created.ID || withDeleted[0].DeletedAt.IsZero() {
+		t.Fatalf("expected the deletion in the change list, got %+v", withDeleted)
+	}
+
+	trashed, err := service.ListTrash(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("list trash: %v", err)
+	}
+	if len(trashed) != 1 || trashed[0].ID != created.ID {
+		t.Fatalf("expected deleted secret in trash, got %+v", trashed)
+	}
+}
+
+func TestRestoreFromTrashAndPurge(t *testing.T) {
+	repo := newMemorySecretRepo()
+	service := NewService(repo, nil, config.Config{}, zap.NewNop())
+	userID := uuid.New()
+
+	created, err := service.Create(context.Background(), userID, dtosecret.SecretInput{
+		Type:       "note",
+		Ciphertext: base64.StdEncoding.EncodeToString([]byte("payload")),
+	})
+	if err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+	if err := service.Delete(context.Background(), userID, created.ID); err != nil {
+		t.Fatalf("delete secret: %v", err)
+	}
+	if err := service.Purge(context.Background(), userID, uuid.New()); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for unknown trashed secret, got: %v", err)
+	}
+
+	restored, err := service.RestoreFromTrash(context.Background(), userID, created.ID)
+	if err != nil {
+		t.Fatalf("restore from trash: %v", err)
+	}
+	if restored.Version != created.Version+1 {
+		t.Fatalf("expected version bump on restore, got %d", restored.Version)
+	}
+	if err := service.Purge(context.Background(), userID, created.ID); err != ErrNotFound {
+		t.Fatalf("live secret must not be purged, got: %v", err)
+	}
+
+	if err := service.Delete(context.Background(), userID, created.ID); err != nil {
+		t.Fatalf("delete secret again: %v", err)
+	}
+	if err := service.Purge(context.Background(), userID, created.ID); err != nil {
+		t.Fatalf("purge secret: %v", err)
+	}
+	trashed, err := service.ListTrash(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("list trash: %v", err)
+	}
+	if len(trashed) != 0 {
+		t.Fatalf("expected empty trash after purge, got %d", len(trashed))
+	}
+}
+
+func TestBatchAtomicRollsBackOnFailure(t *testing.T) {
+	repo := newMemorySecretRepo()
+	service := NewService(repo, nil, config.Config{}, zap.NewNop())
+	userID := uuid.New()
+	ciphertext := base64.StdEncoding.EncodeToString([]byte("secret"))
+
+	existing, err := service.Create(context.Background(), userID, dtosecret.SecretInput{Type: "note", Ciphertext: ciphertext})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	outcomes, committed, err := service.Batch(context.Background(), userID, dtosecret.BatchInput{
+		Atomic: true,
+		Items: []dtosecret.BatchItemInput{
+			{Op: dtosecret.BatchOpCreate, Payload: dtosecret.SecretInput{Type: "note", Ciphertext: ciphertext}},
+			{Op: dtosecret.BatchOpUpdate, ID: existing.ID, ExpectedVersion: 7, Payload: dtosecret.SecretInput{Type: "note", Ciphertext: ciphertext}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if committed {
+		t.Fatalf("expected atomic batch to roll back")
+	}
+	if outcomes[0].Applied || outcomes[1].Err != ErrVersionConflict {
+		t.Fatalf("unexpected outcomes: %+v", outcomes)
+	}
+	if len(repo.items) != 1 {
+		t.Fatalf("expected rollback to drop created secret, got %d items", len(repo.items))
+	}
+}
+
+func TestBatchPerItemKeepsSuccessfulOperations(t *testing.T) {
+	repo := newMemorySecretRepo()
+	service := NewService(repo, nil, config.Config{}, zap.NewNop())
+	userID := uuid.New()
+	ciphertext := base64.StdEncoding.EncodeToString([]byte("secret"))
+
+	existing, err := service.Create(context.Background(), userID, dtosecret.SecretInput{Type: "note", Ciphertext: ciphertext})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	outcomes, committed, err := service.Batch(context.Background(), userID, dtosecret.BatchInput{
+		Items: []dtosecret.BatchItemInput{
+			{Op: dtosecret.BatchOpDelete, ID: existing.ID, ExpectedVersion: existing.Version},
+			{Op: dtosecret.BatchOpDelete, ID: uuid.New()},
+		},
+	})
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if !committed || !outcomes[0].Applied || outcomes[1].Err != ErrNotFound {
+		t.Fatalf("unexpected outcomes: %+v", outcomes)
+	}
+	if repo.items[existing.ID].DeletedAt.IsZero() {
+		t.Fatalf("expected secret to be moved to trash")
+	}
+}
+
+func TestBatchRejectsEmptyInput(t *testing.T) {
+	service := NewService(newMemorySecretRepo(), nil, config.Config{}, zap.NewNop())
+	if _, _, err := service.Batch(context.Background(), uuid.New(), dtosecret.BatchInput{Atomic: true}); err != ErrInvalidBatch {
+		t.Fatalf("expected ErrInvalidBatch, got %v", err)
+	}
+}
+
+func TestBatchPrunesHistoryAfterCommit(t *testing.T) {
+	repo := newMemorySecretRepo()
+	service := NewService(repo, nil, config.Config{HistoryLimit: 3}, zap.NewNop())
+	userID := uuid.New()
+	ciphertext := base64.StdEncoding.EncodeToString([]byte("secret"))
+
+	first, err := service.Create(context.Background(), userID, dtosecret.SecretInput{Type: "note", Ciphertext: ciphertext})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	second, err := service.Create(context.Background(), userID, dtosecret.SecretInput{Type: "note", Ciphertext: ciphertext})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for _, atomic := range []bool{true, false} {
+		repo.pruned = nil
+		outcomes, committed, err := service.Batch(context.Background(), userID, dtosecret.BatchInput{
+			Atomic: atomic,
+			Items: []dtosecret.BatchItemInput{
+				{Op: dtosecret.BatchOpUpdate, ID: first.ID, Payload: dtosecret.SecretInput{Type: "note", Ciphertext: ciphertext}},
+				{Op: dtosecret.BatchOpUpdate, ID: second.ID, Payload: dtosecret.SecretInput{Type: "note", Ciphertext: ciphertext}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("atomic=%v batch: %v", atomic, err)
+		}
+		if !committed || !outcomes[0].Applied || !outcomes[1].Applied {
+			t.Fatalf("atomic=%v unexpected outcomes: %+v", atomic, outcomes)
+		}
+		if len(repo.pruned) != 2 || repo.pruned[0] != first.ID || repo.pruned[1] != second.ID {
+			t.Fatalf("atomic=%v expected both histories pruned after commit, got %v", atomic, repo.pruned)
+		}
+	}
+}
+
+func TestUpdateKeepsLegacyTypedSecretsWritable(t *testing.T) {
+	repo := newMemorySecretRepo()
+	service := NewService(repo, nil, config.Config{}, zap.NewNop())
+	userID := uuid.New()
+	ciphertext := base64.StdEncoding.EncodeToString([]byte("plain old text"))
+	legacy := models.Secret{ID: uuid.New(), UserID: userID, Type: "text", Ciphertext: []byte("old"), Version: 1}
+	batched := models.Secret{ID: uuid.New(), UserID: userID, Type: "text", Ciphertext: []byte("old"), Version: 1}
+	repo.items[legacy.ID] = legacy
+	repo.items[batched.ID] = batched
+
+	updated, err := service.Update(context.Background(), userID, legacy.ID, dtosecret.SecretInput{Type: "text", Ciphertext: ciphertext})
+	if err != nil {
+		t.Fatalf("update legacy secret: %v", err)
+	}
+	if updated.Type != "note" || updated.Version != 2 || string(repo.items[legacy.ID].Ciphertext) != "plain old text" {
+		t.Fatalf("expected the legacy secret stored as a note, got %+v", repo.items[legacy.ID])
+	}
+
+	outcomes, committed, err := service.Batch(context.Background(), userID, dtosecret.BatchInput{
+		Atomic: true,
+		Items: []dtosecret.BatchItemInput{
+			{Op: dtosecret.BatchOpUpdate, ID: batched.ID, ExpectedVersion: 1, Payload: dtosecret.SecretInput{Type: "text", Ciphertext: ciphertext}},
+		},
+	})
+	if err != nil || !committed || outcomes[0].Secret.Type != "note" {
+		t.Fatalf("expected the batch update to apply as a note: %+v %v", outcomes, err)
+	}
+
+	// Once stored as a note the secret is typed like any other.
+	if _, err := service.Update(context.Background(), userID, legacy.ID, dtosecret.SecretInput{Type: "text", Ciphertext: ciphertext}); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("expected an unknown type to be refused for a note, got %v", err)
+	}
+	login, err := service.Create(context.Background(), userID, dtosecret.SecretInput{Type: "login", Ciphertext: ciphertext})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := service.Update(context.Background(), userID, login.ID, dtosecret.SecretInput{Type: "text", Ciphertext: ciphertext}); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("expected an unknown type to be refused for a typed secret, got %v", err)
 	}
 }
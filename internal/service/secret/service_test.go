@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/7StaSH7/practicum-diploma/internal/config"
 	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
 	"github.com/7StaSH7/practicum-diploma/internal/models"
 	secretmocks "github.com/7StaSH7/practicum-diploma/internal/service/secret/mocks"
@@ -14,6 +15,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
 )
 
 func TestCreateReturnsInvalidCiphertext(t *testing.T) {
@@ -21,19 +23,34 @@ func TestCreateReturnsInvalidCiphertext(t *testing.T) {
 	defer ctrl.Finish()
 
 	repo := secretmocks.NewMockSecretRepository(ctrl)
-	svc := NewService(repo)
+	svc := NewService(repo, nil, config.Config{}, zap.NewNop())
 
-	_, err := svc.Create(context.Background(), uuid.New(), dtosecret.SecretInput{Ciphertext: "!!!"})
+	_, err := svc.Create(context.Background(), uuid.New(), dtosecret.SecretInput{Type: "note", Ciphertext: "!!!"})
 	require.Error(t, err)
 	assert.ErrorIs(t, err, ErrInvalidCiphertext)
 }
 
+func TestCreateRejectsUnknownType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := secretmocks.NewMockSecretRepository(ctrl)
+	svc := NewService(repo, nil, config.Config{}, zap.NewNop())
+
+	_, err := svc.Create(context.Background(), uuid.New(), dtosecret.SecretInput{
+		Type:       "password",
+		Ciphertext: base64.StdEncoding.EncodeToString([]byte("cipher")),
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrUnknownType)
+}
+
 func TestCreateStoresDecodedCiphertext(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	repo := secretmocks.NewMockSecretRepository(ctrl)
-	svc := NewService(repo)
+	svc := NewService(repo, nil, config.Config{}, zap.NewNop())
 	userID := uuid.New()
 
 	payload := dtosecret.SecretInput{
@@ -65,11 +82,11 @@ func TestUpdateReturnsNotFoundWhenSecretMissing(t *testing.T) {
 	defer ctrl.Finish()
 
 	repo := secretmocks.NewMockSecretRepository(ctrl)
-	svc := NewService(repo)
+	svc := NewService(repo, nil, config.Config{}, zap.NewNop())
 
 	repo.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.Secret{}, sql.ErrNoRows)
 
-	_, err := svc.Update(context.Background(), uuid.New(), uuid.New(), dtosecret.SecretInput{Ciphertext: base64.StdEncoding.EncodeToString([]byte("cipher"))})
+	_, err := svc.Update(context.Background(), uuid.New(), uuid.New(), dtosecret.SecretInput{Type: "note", Ciphertext: base64.StdEncoding.EncodeToString([]byte("cipher"))})
 	require.Error(t, err)
 	assert.ErrorIs(t, err, ErrNotFound)
 }
@@ -79,21 +96,37 @@ func TestDeleteReturnsNotFoundWhenMissing(t *testing.T) {
 	defer ctrl.Finish()
 
 	repo := secretmocks.NewMockSecretRepository(ctrl)
-	svc := NewService(repo)
+	svc := NewService(repo, nil, config.Config{}, zap.NewNop())
 
-	repo.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).Return(sql.ErrNoRows)
+	repo.EXPECT().Trash(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(sql.ErrNoRows)
 
 	err := svc.Delete(context.Background(), uuid.New(), uuid.New())
 	require.Error(t, err)
 	assert.ErrorIs(t, err, ErrNotFound)
 }
 
+func TestDeleteNotifiesEventStreams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := secretmocks.NewMockSecretRepository(ctrl)
+	events := secretmocks.NewMockService(ctrl)
+	svc := NewService(repo, events, config.Config{}, zap.NewNop())
+	userID := uuid.New()
+	secretID := uuid.New()
+
+	repo.EXPECT().Trash(gomock.Any(), secretID, userID, gomock.Any()).Return(nil)
+	events.EXPECT().Notify(userID)
+
+	require.NoError(t, svc.Delete(context.Background(), userID, secretID))
+}
+
 func TestListSinceDelegatesToRepository(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	repo := secretmocks.NewMockSecretRepository(ctrl)
-	svc := NewService(repo)
+	svc := NewService(repo, nil, config.Config{}, zap.NewNop())
 	userID := uuid.New()
 	since := time.Now().UTC().Add(-time.Hour)
 	expected := []models.Secret{{ID: uuid.New(), UserID: userID, Version: 2}}
@@ -104,3 +137,125 @@ func TestListSinceDelegatesToRepository(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, expected, got)
 }
+
+func TestUpdatePrunesHistoryWithConfiguredBounds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := secretmocks.NewMockSecretRepository(ctrl)
+	svc := NewService(repo, nil, config.Config{HistoryLimit: 5, HistoryTTL: time.Hour}, zap.NewNop())
+	userID := uuid.New()
+	secretID := uuid.New()
+
+	repo.EXPECT().Get(gomock.Any(), secretID, userID).Return(models.Secret{ID: secretID, UserID: userID, Version: 2}, nil)
+	repo.EXPECT().Update(gomock.Any(), gomock.AssignableToTypeOf(models.Secret{})).Return(nil)
+	repo.EXPECT().PruneVersions(gomock.Any(), secretID, userID, 5, gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ int, before time.Time) error {
+			assert.WithinDuration(t, time.Now().UTC().Add(-time.Hour), before, 2*time.Second)
+			return nil
+		},
+	)
+
+	updated, err := svc.Update(context.Background(), userID, secretID, dtosecret.SecretInput{Type: "note", Ciphertext: base64.StdEncoding.EncodeToString([]byte("cipher"))})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), updated.Version)
+}
+
+func TestRestoreAppliesArchivedVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := secretmocks.NewMockSecretRepository(ctrl)
+	svc := NewService(repo, nil, config.Config{}, zap.NewNop())
+	userID := uuid.New()
+	secretID := uuid.New()
+
+	repo.EXPECT().GetVersion(gomock.Any(), secretID, userID, int64(1)).Return(models.SecretVersion{
+		SecretID:   secretID,
+		UserID:     userID,
+		Version:    1,
+		Type:       "note",
+		MetaOpen:   models.MetaOpen{Title: "old"},
+		Ciphertext: []byte("old-cipher"),
+	}, nil)
+	repo.EXPECT().Get(gomock.Any(), secretID, userID).Return(models.Secret{
+		ID:         secretID,
+		UserID:     userID,
+		Type:       "note",
+		MetaOpen:   models.MetaOpen{Title: "new"},
+		Ciphertext: []byte("new-cipher"),
+		Version:    4,
+	}, nil)
+	repo.EXPECT().Update(gomock.Any(), gomock.AssignableToTypeOf(models.Secret{})).DoAndReturn(
+		func(_ context.Context, secret models.Secret) error {
+			assert.Equal(t, []byte("old-cipher"), secret.Ciphertext)
+			assert.Equal(t, "old", secret.MetaOpen.Title)
+			assert.Equal(t, int64(5), secret.Version)
+			return nil
+		},
+	)
+
+	restored, err := svc.Restore(context.Background(), userID, secretID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), restored.Version)
+}
+
+func TestRestoreReturnsNotFoundForUnknownVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := secretmocks.NewMockSecretRepository(ctrl)
+	svc := NewService(repo, nil, config.Config{}, zap.NewNop())
+
+	repo.EXPECT().GetVersion(gomock.Any(), gomock.Any(), gomock.Any(), int64(7)).Return(models.SecretVersion{}, sql.ErrNoRows)
+
+	_, err := svc.Restore(context.Background(), uuid.New(), uuid.New(), 7)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGetVersionHidesHistoryOfTrashedSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := secretmocks.NewMockSecretRepository(ctrl)
+	svc := NewService(repo, nil, config.Config{}, zap.NewNop())
+	userID := uuid.New()
+	secretID := uuid.New()
+
+	repo.EXPECT().Get(gomock.Any(), secretID, userID).Return(models.Secret{}, sql.ErrNoRows)
+
+	_, err := svc.GetVersion(context.Background(), userID, secretID, 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPurgeExpiredUsesTrashRetention(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := secretmocks.NewMockSecretRepository(ctrl)
+	svc := NewService(repo, nil, config.Config{TrashTTL: 24 * time.Hour}, zap.NewNop())
+
+	repo.EXPECT().PurgeTrashed(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, before time.Time) (int64, error) {
+			assert.WithinDuration(t, time.Now().UTC().Add(-24*time.Hour), before, 2*time.Second)
+			return 2, nil
+		},
+	)
+
+	purged, err := svc.PurgeExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+}
+
+func TestPurgeExpiredDisabledWithoutRetention(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := secretmocks.NewMockSecretRepository(ctrl)
+	svc := NewService(repo, nil, config.Config{}, zap.NewNop())
+
+	purged, err := svc.PurgeExpired(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+}
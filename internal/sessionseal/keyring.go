@@ -0,0 +1,103 @@
+package sessionseal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	KeyringProviderName = "keyring"
+
+	keyringTimeout = 10 * time.Second
+)
+
+// errNoEntry is what secretTool reports for a lookup without a match.
+var errNoEntry = errors.New("no keyring entry")
+
+// secretTool runs the libsecret command line client. Tests replace it.
+var secretTool = func(stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyringTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "secret-tool", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return out, nil
+	case stderr.Len() > 0:
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	case errors.As(err, &exitErr) && len(bytes.TrimSpace(out)) == 0:
+		// secret-tool exits quietly with 1 when nothing matches.
+		return nil, errNoEntry
+	default:
+		return nil, err
+	}
+}
+
+// KeyringAvailable reports whether the Secret Service can be reached:
+// Linux with a session bus and secret-tool installed.
+func KeyringAvailable() bool {
+	if runtime.GOOS != "linux" || os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return false
+	}
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+// Keyring keeps a random key in the Secret Service (GNOME Keyring, KWallet)
+// through secret-tool, so the key is locked with the desktop login rather
+// than stored next to the session.
+type Keyring struct {
+	// Account tells apart installations sharing a keyring, e.g. the
+	// configuration directory.
+	Account string
+}
+
+func (Keyring) Name() string { return KeyringProviderName }
+
+func (k Keyring) attributes() []string {
+	return []string{"service", "pkeeper", "kind", "session-key", "account", k.Account}
+}
+
+func (k Keyring) SealKey() ([]byte, []byte, error) {
+	key, err := k.OpenKey(nil)
+	if !errors.Is(err, ErrNoKey) {
+		return key, nil, err
+	}
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	args := append([]string{"store", "--label=pkeeper session key"}, k.attributes()...)
+	if _, err := secretTool([]byte(base64.StdEncoding.EncodeToString(key)), args...); err != nil {
+		return nil, nil, fmt.Errorf("store session key in the keyring: %w", err)
+	}
+	return key, nil, nil
+}
+
+func (k Keyring) OpenKey([]byte) ([]byte, error) {
+	out, err := secretTool(nil, append([]string{"lookup"}, k.attributes()...)...)
+	if errors.Is(err, errNoEntry) {
+		return nil, fmt.Errorf("%w: no entry in the keyring", ErrNoKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read session key from the keyring: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("%w: bad keyring entry", ErrDecrypt)
+	}
+	return key, nil
+}
@@ -0,0 +1,132 @@
+package sessionseal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	FileProviderName       = "file"
+	PassphraseProviderName = "passphrase"
+
+	saltSize = 16
+)
+
+// FileKey keeps a random key in a file only its owner may read. It works
+// everywhere, including headless machines without a keyring, but protects
+// the session only from copies that leave the key file behind.
+type FileKey struct {
+	Path string
+}
+
+func (FileKey) Name() string { return FileProviderName }
+
+func (f FileKey) SealKey() ([]byte, []byte, error) {
+	key, err := f.OpenKey(nil)
+	if !errors.Is(err, ErrNoKey) {
+		return key, nil, err
+	}
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o700); err != nil {
+		return nil, nil, err
+	}
+	file, err := os.OpenFile(f.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		// Another process created it first.
+		key, err := f.OpenKey(nil)
+		return key, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := file.Write(key); err != nil {
+		_ = file.Close()
+		_ = os.Remove(f.Path)
+		return nil, nil, err
+	}
+	return key, nil, file.Close()
+}
+
+func (f FileKey) OpenKey([]byte) ([]byte, error) {
+	key, err := os.ReadFile(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s does not exist", ErrNoKey, f.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("%w: bad key file %s", ErrDecrypt, f.Path)
+	}
+	return key, nil
+}
+
+// kdf follows the RFC 9106 second recommended option. Tests lower it to
+// keep key derivation cheap.
+var kdf = struct {
+	time      uint32
+	memoryKiB uint32
+	threads   uint8
+}{time: 3, memoryKiB: 64 << 10, threads: 4}
+
+// Passphrase derives the key from a passphrase with Argon2id and a salt
+// stored in the sealed file. The last derived key is remembered, so a
+// command that reads and writes the session derives it once.
+type Passphrase struct {
+	passphrase []byte
+
+	mu   sync.Mutex
+	salt []byte
+	key  []byte
+}
+
+// NewPassphrase returns a provider for passphrase, which must not be empty.
+func NewPassphrase(passphrase []byte) (*Passphrase, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("session passphrase is empty")
+	}
+	return &Passphrase{passphrase: bytes.Clone(passphrase)}, nil
+}
+
+func (*Passphrase) Name() string { return PassphraseProviderName }
+
+func (p *Passphrase) SealKey() ([]byte, []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.key == nil {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, nil, err
+		}
+		p.derive(salt)
+	}
+	return p.key, p.salt, nil
+}
+
+func (p *Passphrase) OpenKey(salt []byte) ([]byte, error) {
+	if len(salt) == 0 {
+		return nil, fmt.Errorf("%w: no salt stored with the session", ErrDecrypt)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.key == nil || !bytes.Equal(p.salt, salt) {
+		p.derive(salt)
+	}
+	return p.key, nil
+}
+
+func (p *Passphrase) derive(salt []byte) {
+	p.salt = bytes.Clone(salt)
+	p.key = argon2.IDKey(p.passphrase, p.salt, kdf.time, kdf.memoryKiB, kdf.threads, keySize)
+}
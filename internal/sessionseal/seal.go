@@ -0,0 +1,141 @@
+// Package sessionseal encrypts the CLI session file at rest. The key comes
+// from a pluggable Provider: the desktop keyring, a passphrase or a key file.
+// The provider's name is stored in the sealed file, so a session stays
+// readable when another provider would be picked for new files.
+package sessionseal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	magic         = "PKEEPERS"
+	FormatVersion = 1
+
+	keySize  = chacha20poly1305.KeySize
+	maxField = 255
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported session file")
+	ErrDecrypt     = errors.New("session file is damaged or its key was replaced")
+	// ErrNoKey means the provider has no key yet, so nothing it sealed can
+	// be read.
+	ErrNoKey = errors.New("session key is missing")
+)
+
+// Provider supplies the key sealing the session.
+type Provider interface {
+	// Name is stored in sealed files to find the provider again.
+	Name() string
+	// SealKey returns the key to seal with, created on first use, and the
+	// salt to store next to it. Providers that need no salt return nil.
+	SealKey() (key, salt []byte, err error)
+	// OpenKey returns the key of a file sealed with salt, or ErrNoKey.
+	OpenKey(salt []byte) ([]byte, error)
+}
+
+// IsSealed reports whether data was produced by Seal. Anything else is a
+// session written before encryption.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Seal encrypts plain with a key from p.
+//
+// Layout: magic "PKEEPERS", a version byte, the provider name and salt, each
+// behind a length byte, a 24-byte nonce and the sealed data. Everything
+// before the nonce is authenticated as AAD.
+func Seal(p Provider, plain []byte) ([]byte, error) {
+	key, salt, err := p.SealKey()
+	if err != nil {
+		return nil, err
+	}
+	name := p.Name()
+	if len(name) > maxField || len(salt) > maxField {
+		return nil, fmt.Errorf("provider %q returned an oversized header", name)
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	head := append([]byte(magic), FormatVersion, byte(len(name)))
+	head = append(head, name...)
+	head = append(head, byte(len(salt)))
+	head = append(head, salt...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(bytes.Clone(head), nonce...)
+	return aead.Seal(out, nonce, plain, head), nil
+}
+
+// Open decrypts sealed with the provider lookup returns for its name.
+func Open(lookup func(name string) (Provider, error), sealed []byte) ([]byte, error) {
+	h, err := parseHeader(sealed)
+	if err != nil {
+		return nil, err
+	}
+	p, err := lookup(h.provider)
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.OpenKey(h.salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	body := sealed[h.size:]
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	plain, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], sealed[:h.size])
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+type header struct {
+	provider string
+	salt     []byte
+	size     int
+}
+
+func parseHeader(sealed []byte) (header, error) {
+	if !IsSealed(sealed) {
+		return header{}, fmt.Errorf("%w: not a sealed session", ErrUnsupported)
+	}
+	rest := sealed[len(magic):]
+	if len(rest) == 0 || rest[0] != FormatVersion {
+		return header{}, fmt.Errorf("%w: unknown version", ErrUnsupported)
+	}
+	rest = rest[1:]
+	name, rest, ok := cutField(rest)
+	if !ok {
+		return header{}, ErrDecrypt
+	}
+	salt, rest, ok := cutField(rest)
+	if !ok {
+		return header{}, ErrDecrypt
+	}
+	return header{provider: string(name), salt: salt, size: len(sealed) - len(rest)}, nil
+}
+
+func cutField(data []byte) ([]byte, []byte, bool) {
+	if len(data) == 0 || len(data) < 1+int(data[0]) {
+		return nil, nil, false
+	}
+	n := int(data[0])
+	return data[1 : 1+n], data[1+n:], true
+}
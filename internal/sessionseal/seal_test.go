@@ -0,0 +1,145 @@
+package sessionseal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	kdf.time = 1
+	kdf.memoryKiB = 64
+	kdf.threads = 1
+}
+
+func lookupOf(providers ...Provider) func(string) (Provider, error) {
+	return func(name string) (Provider, error) {
+		for _, p := range providers {
+			if p.Name() == name {
+				return p, nil
+			}
+		}
+		return nil, fmt.Errorf("no provider %q", name)
+	}
+}
+
+func TestFileKeySealsAndOpens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "session.key")
+	provider := FileKey{Path: path}
+
+	_, err := provider.OpenKey(nil)
+	require.ErrorIs(t, err, ErrNoKey)
+
+	plain := []byte(`{"refresh_token":"r"}`)
+	sealed, err := Seal(provider, plain)
+	require.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.False(t, bytes.Contains(sealed, []byte("refresh_token")))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// A second provider on the same file reads what the first sealed.
+	opened, err := Open(lookupOf(FileKey{Path: path}), sealed)
+	require.NoError(t, err)
+	assert.Equal(t, plain, opened)
+
+	again, err := Seal(provider, plain)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "every seal needs a fresh nonce")
+}
+
+func TestFileKeyRejectsTamperingAndOtherKeys(t *testing.T) {
+	dir := t.TempDir()
+	provider := FileKey{Path: filepath.Join(dir, "session.key")}
+	sealed, err := Seal(provider, []byte("session"))
+	require.NoError(t, err)
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	_, err = Open(lookupOf(provider), tampered)
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	// The provider name is authenticated: relabelling the file fails.
+	relabelled := bytes.Replace(sealed, []byte(FileProviderName), []byte("elif"), 1)
+	_, err = Open(lookupOf(provider, renamed{provider, "elif"}), relabelled)
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = Open(lookupOf(FileKey{Path: filepath.Join(dir, "other.key")}), sealed)
+	require.ErrorIs(t, err, ErrNoKey)
+
+	_, err = Open(lookupOf(provider), []byte(`{"access_token":"a"}`))
+	require.ErrorIs(t, err, ErrUnsupported)
+}
+
+type renamed struct {
+	Provider
+	name string
+}
+
+func (r renamed) Name() string { return r.name }
+
+func TestPassphraseDerivesKeyWithStoredSalt(t *testing.T) {
+	provider, err := NewPassphrase([]byte("correct horse"))
+	require.NoError(t, err)
+	sealed, err := Seal(provider, []byte("session"))
+	require.NoError(t, err)
+
+	fresh, err := NewPassphrase([]byte("correct horse"))
+	require.NoError(t, err)
+	opened, err := Open(lookupOf(fresh), sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("session"), opened)
+
+	wrong, err := NewPassphrase([]byte("battery staple"))
+	require.NoError(t, err)
+	_, err = Open(lookupOf(wrong), sealed)
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = NewPassphrase(nil)
+	require.Error(t, err)
+}
+
+func TestKeyringStoresKeyThroughSecretTool(t *testing.T) {
+	stored := map[string][]byte{}
+	prev := secretTool
+	secretTool = func(stdin []byte, args ...string) ([]byte, error) {
+		switch args[0] {
+		case "lookup":
+			value, ok := stored[strings.Join(args[1:], " ")]
+			if !ok {
+				return nil, errNoEntry
+			}
+			return value, nil
+		case "store":
+			stored[strings.Join(args[2:], " ")] = stdin
+			return nil, nil
+		default:
+			t.Fatalf("unexpected secret-tool call: %v", args)
+			return nil, nil
+		}
+	}
+	t.Cleanup(func() { secretTool = prev })
+
+	provider := Keyring{Account: "/home/alice/.config/pkeeper"}
+	_, err := provider.OpenKey(nil)
+	require.ErrorIs(t, err, ErrNoKey)
+
+	sealed, err := Seal(provider, []byte("session"))
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+
+	opened, err := Open(lookupOf(Keyring{Account: "/home/alice/.config/pkeeper"}), sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("session"), opened)
+
+	_, err = Open(lookupOf(Keyring{Account: "/home/bob/.config/pkeeper"}), sealed)
+	require.ErrorIs(t, err, ErrNoKey)
+}
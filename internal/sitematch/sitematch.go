@@ -0,0 +1,159 @@
+// Package sitematch decides whether a saved site and a page URL belong to
+// the same website. Hosts are compared by registrable domain (eTLD+1) from
+// the public suffix list embedded in golang.org/x/net/publicsuffix, so
+// login.example.com matches a site saved as example.com while
+// alice.github.io does not match bob.github.io. A per-site rule narrows
+// this to the exact host or replaces it with a regular expression.
+package sitematch
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	ErrInvalidURL  = errors.New("url must name a host, e.g. https://example.com")
+	ErrInvalidRule = errors.New("match rule must be domain, host or regex")
+)
+
+// Rules say how a saved site is compared with pages.
+const (
+	// RuleDomain matches any host of the registrable domain. An empty rule
+	// means RuleDomain.
+	RuleDomain = "domain"
+	// RuleHost matches the saved host only.
+	RuleHost = "host"
+	// RuleRegex takes the site as a regular expression that must match the
+	// whole page origin, e.g. https://[a-z]+\.example\.com.
+	RuleRegex = "regex"
+)
+
+// Level is how closely a site matches a page.
+type Level int
+
+const (
+	None Level = iota
+	// Domain means the same registrable domain on another host.
+	Domain
+	// Host means the same host, ignoring www. and the port.
+	Host
+)
+
+// Target is a normalised page or site address.
+type Target struct {
+	// Origin is scheme://host[:port] in lower case, as the page gave it.
+	// Path, query and fragment are left out: anyone can put a trusted
+	// name there.
+	Origin string
+	Scheme string
+	// Host is lower case, without port and leading www.
+	Host string
+	// Domain is the registrable domain of Host, or Host itself for IP
+	// addresses, single-label names such as localhost and bare suffixes.
+	Domain string
+}
+
+// Parse normalises a URL. Addresses without a scheme are taken as https.
+func Parse(raw string) (Target, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Target{}, ErrInvalidURL
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return Target{}, ErrInvalidURL
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "" {
+		return Target{}, ErrInvalidURL
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	origin := scheme + "://" + strings.ToLower(parsed.Host)
+	host = strings.TrimPrefix(host, "www.")
+	return Target{Origin: origin, Scheme: scheme, Host: host, Domain: registrable(host)}, nil
+}
+
+func registrable(host string) string {
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// Match compares a saved site with a page. A site that does not parse
+// matches nothing, and neither does a page with another scheme: a login
+// saved for https is never offered to a plain http page.
+func Match(site string, page Target) Level {
+	saved, err := Parse(site)
+	if err != nil || saved.Scheme != page.Scheme {
+		return None
+	}
+	switch {
+	case saved.Host == page.Host:
+		return Host
+	case saved.Domain == page.Domain:
+		return Domain
+	default:
+		return None
+	}
+}
+
+// ValidateRule checks a rule and the site it applies to.
+func ValidateRule(rule, site string) error {
+	switch rule {
+	case "", RuleDomain, RuleHost:
+		if _, err := Parse(site); err != nil {
+			return err
+		}
+		return nil
+	case RuleRegex:
+		if _, err := compileRule(site); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w, got %q", ErrInvalidRule, rule)
+	}
+}
+
+// MatchRule compares a saved site with a page under rule. A regular
+// expression must match the whole origin and, being an explicit choice,
+// matches as Host. Unknown rules and invalid expressions match nothing.
+func MatchRule(site, rule string, page Target) Level {
+	switch rule {
+	case "", RuleDomain:
+		return Match(site, page)
+	case RuleHost:
+		if level := Match(site, page); level == Host {
+			return Host
+		}
+		return None
+	case RuleRegex:
+		re, err := compileRule(site)
+		if err != nil || !re.MatchString(page.Origin) {
+			return None
+		}
+		return Host
+	default:
+		return None
+	}
+}
+
+// compileRule anchors a regex rule, so example\.com cannot match a page
+// that merely mentions it.
+func compileRule(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + expr + `)$`)
+}
@@ -0,0 +1,79 @@
+package sitematch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNormalisesHost(t *testing.T) {
+	target, err := Parse("HTTPS://WWW.Login.Example.co.uk:8443/path?q=1")
+	require.NoError(t, err)
+	assert.Equal(t, Target{Origin: "https://www.login.example.co.uk:8443", Scheme: "https", Host: "login.example.co.uk", Domain: "example.co.uk"}, target)
+
+	target, err = Parse("localhost:3000")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", target.Domain)
+
+	target, err = Parse("http://192.168.1.10/admin")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.10", target.Domain)
+
+	_, err = Parse("https://")
+	require.ErrorIs(t, err, ErrInvalidURL)
+}
+
+func TestMatch(t *testing.T) {
+	page, err := Parse("https://login.example.com/signin")
+	require.NoError(t, err)
+
+	assert.Equal(t, Host, Match("https://login.example.com", page))
+	assert.Equal(t, Host, Match("www.login.example.com", page))
+	assert.Equal(t, Domain, Match("example.com", page))
+	assert.Equal(t, Domain, Match("https://accounts.example.com", page))
+	assert.Equal(t, None, Match("https://example.org", page))
+	assert.Equal(t, None, Match("", page))
+
+	plain, err := Parse("http://login.example.com/signin")
+	require.NoError(t, err)
+	assert.Equal(t, None, Match("https://login.example.com", plain), "https logins must not reach http pages")
+	assert.Equal(t, None, Match("login.example.com", plain), "sites without a scheme are https")
+	assert.Equal(t, Host, Match("http://login.example.com", plain))
+	assert.Equal(t, None, Match("http://login.example.com", page))
+
+	pages, err := Parse("https://alice.github.io")
+	require.NoError(t, err)
+	assert.Equal(t, None, Match("https://bob.github.io", pages), "hosts under a public suffix are different sites")
+}
+
+func TestMatchRule(t *testing.T) {
+	page, err := Parse("https://login.example.com/signin")
+	require.NoError(t, err)
+
+	assert.Equal(t, Domain, MatchRule("example.com", "", page))
+	assert.Equal(t, Domain, MatchRule("example.com", RuleDomain, page))
+	assert.Equal(t, None, MatchRule("example.com", RuleHost, page))
+	assert.Equal(t, Host, MatchRule("login.example.com:443", RuleHost, page))
+	assert.Equal(t, Host, MatchRule(`https://login\.example\.com`, RuleRegex, page))
+	assert.Equal(t, Host, MatchRule(`https://[a-z]+\.example\.com`, RuleRegex, page))
+	assert.Equal(t, None, MatchRule(`https://example\.com`, RuleRegex, page))
+	assert.Equal(t, None, MatchRule(`login\.example`, RuleRegex, page), "rules match the whole origin")
+
+	evil, err := Parse("https://evil.test/login.example.com?next=https://login.example.com#login.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, None, MatchRule(`.*login\.example\.com`, RuleRegex, evil), "path, query and fragment are not matched")
+	assert.Equal(t, None, MatchRule(`example\.com`, RuleRegex, evil))
+
+	assert.Equal(t, None, MatchRule("(", RuleRegex, page))
+	assert.Equal(t, None, MatchRule("example.com", "subdomain", page))
+}
+
+func TestValidateRule(t *testing.T) {
+	require.NoError(t, ValidateRule("", "example.com"))
+	require.NoError(t, ValidateRule(RuleHost, "https://login.example.com"))
+	require.NoError(t, ValidateRule(RuleRegex, `https://[a-z]+\.example\.com`))
+	require.ErrorIs(t, ValidateRule(RuleRegex, "("), ErrInvalidRule)
+	require.ErrorIs(t, ValidateRule("exact", "example.com"), ErrInvalidRule)
+	require.ErrorIs(t, ValidateRule(RuleHost, ""), ErrInvalidURL)
+}
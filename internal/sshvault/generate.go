@@ -0,0 +1,36 @@
+package sshvault
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"golang.org/x/crypto/ssh"
+)
+
+// Generate creates an ed25519 key pair as an ssh_key payload: the private
+// key in OpenSSH PEM form and the public key as an authorized_keys line.
+func Generate(comment string) (secretkind.SSHKeyData, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return secretkind.SSHKeyData{}, err
+	}
+	block, err := ssh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return secretkind.SSHKeyData{}, err
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return secretkind.SSHKeyData{}, err
+	}
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+	if comment != "" {
+		line += " " + comment
+	}
+	return secretkind.SSHKeyData{
+		PrivateKey: string(pem.EncodeToMemory(block)),
+		PublicKey:  line,
+	}, nil
+}
@@ -0,0 +1,176 @@
+// Package sshvault serves SSH keys kept in the vault over the OpenSSH agent
+// protocol, so ssh and git can sign with them without the private keys ever
+// being written to ~/.ssh.
+package sshvault
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var (
+	ErrReadOnly   = errors.New("keys are kept in the vault; add them with pkeeper ssh-keygen or as ssh_key secrets")
+	ErrUnknownKey = errors.New("key is not in the vault")
+	ErrDenied     = errors.New("use of the key was not confirmed")
+)
+
+var _ agent.ExtendedAgent = (*Keyring)(nil)
+
+// Key is a vault SSH key ready to sign.
+type Key struct {
+	// Comment is shown by ssh-add -l, usually the secret title.
+	Comment string
+	Signer  ssh.Signer
+}
+
+// Source returns the current vault keys. The keyring calls it on every
+// listing, so keys added or removed in the vault show up without a restart.
+type Source func() ([]Key, error)
+
+// Confirm asks the user whether the key with comment may sign now.
+type Confirm func(comment string) bool
+
+// Keyring is a read-only agent.ExtendedAgent over the vault keys.
+type Keyring struct {
+	source  Source
+	confirm Confirm
+
+	mu   sync.Mutex
+	keys []Key
+}
+
+// NewKeyring returns a keyring over source. With a nil confirm every
+// signature is allowed.
+func NewKeyring(source Source, confirm Confirm) *Keyring {
+	return &Keyring{source: source, confirm: confirm}
+}
+
+// ParseKey turns an ssh_key payload into a signer, using its passphrase
+// when the key is encrypted.
+func ParseKey(data secretkind.SSHKeyData) (ssh.Signer, error) {
+	if data.Passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase([]byte(data.PrivateKey), []byte(data.Passphrase))
+	}
+	return ssh.ParsePrivateKey([]byte(data.PrivateKey))
+}
+
+func (k *Keyring) reload() ([]Key, error) {
+	keys, err := k.source()
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+	return keys, nil
+}
+
+func (k *Keyring) List() ([]*agent.Key, error) {
+	keys, err := k.reload()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*agent.Key, 0, len(keys))
+	for _, key := range keys {
+		pub := key.Signer.PublicKey()
+		out = append(out, &agent.Key{Format: pub.Type(), Blob: pub.Marshal(), Comment: key.Comment})
+	}
+	return out, nil
+}
+
+func (k *Keyring) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return k.SignWithFlags(key, data, 0)
+}
+
+// SignWithFlags signs with the vault key matching pub. Keys not seen in
+// the last listing are looked up again first.
+func (k *Keyring) SignWithFlags(pub ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	key, ok := k.find(pub)
+	if !ok {
+		if _, err := k.reload(); err != nil {
+			return nil, err
+		}
+		if key, ok = k.find(pub); !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+	if k.confirm != nil && !k.confirm(key.Comment) {
+		return nil, ErrDenied
+	}
+
+	var algorithm string
+	switch {
+	case flags&agent.SignatureFlagRsaSha256 != 0:
+		algorithm = ssh.KeyAlgoRSASHA256
+	case flags&agent.SignatureFlagRsaSha512 != 0:
+		algorithm = ssh.KeyAlgoRSASHA512
+	default:
+		return key.Signer.Sign(nil, data)
+	}
+	signer, ok := key.Signer.(ssh.AlgorithmSigner)
+	if !ok {
+		return nil, fmt.Errorf("key %q cannot sign with %s", key.Comment, algorithm)
+	}
+	return signer.SignWithAlgorithm(nil, data, algorithm)
+}
+
+func (k *Keyring) find(pub ssh.PublicKey) (Key, bool) {
+	want := pub.Marshal()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, key := range k.keys {
+		if bytes.Equal(key.Signer.PublicKey().Marshal(), want) {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+func (k *Keyring) Signers() ([]ssh.Signer, error) {
+	keys, err := k.reload()
+	if err != nil {
+		return nil, err
+	}
+	signers := make([]ssh.Signer, len(keys))
+	for i, key := range keys {
+		signers[i] = key.Signer
+	}
+	return signers, nil
+}
+
+func (k *Keyring) Add(agent.AddedKey) error       { return ErrReadOnly }
+func (k *Keyring) Remove(ssh.PublicKey) error     { return ErrReadOnly }
+func (k *Keyring) RemoveAll() error               { return ErrReadOnly }
+func (k *Keyring) Lock(passphrase []byte) error   { return ErrReadOnly }
+func (k *Keyring) Unlock(passphrase []byte) error { return ErrReadOnly }
+
+func (k *Keyring) Extension(string, []byte) ([]byte, error) {
+	return nil, agent.ErrExtensionUnsupported
+}
+
+// Serve answers agent requests on ln until it is closed. Connections from
+// other users are dropped, as in the vault agent.
+func Serve(ln net.Listener, keyring agent.Agent, sameUser func(net.Conn) bool) error {
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer func() { _ = conn.Close() }()
+			if sameUser(conn) {
+				_ = agent.ServeAgent(keyring, conn)
+			}
+		}()
+	}
+}
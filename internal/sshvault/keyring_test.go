@@ -0,0 +1,109 @@
+package sshvault
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/7StaSH7/practicum-diploma/internal/secretkind"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func generatedKey(t *testing.T, comment string) Key {
+	t.Helper()
+	data, err := Generate(comment)
+	require.NoError(t, err)
+	signer, err := ParseKey(data)
+	require.NoError(t, err)
+	return Key{Comment: comment, Signer: signer}
+}
+
+func TestGenerateMakesValidPayload(t *testing.T) {
+	data, err := Generate("deploy@ci")
+	require.NoError(t, err)
+	_, err = secretkind.Encode(secretkind.Payload{Kind: secretkind.SSHKey, SSHKey: &data})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(data.PublicKey, "ssh-ed25519 "))
+	assert.True(t, strings.HasSuffix(data.PublicKey, " deploy@ci"))
+
+	signer, err := ParseKey(data)
+	require.NoError(t, err)
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(data.PublicKey))
+	require.NoError(t, err)
+	assert.Equal(t, pub.Marshal(), signer.PublicKey().Marshal())
+}
+
+func TestAgentListsAndSignsWithVaultKeys(t *testing.T) {
+	deploy := generatedKey(t, "deploy")
+	keys := []Key{deploy}
+	allowed := true
+	var asked []string
+	keyring := NewKeyring(func() ([]Key, error) { return keys, nil }, func(comment string) bool {
+		asked = append(asked, comment)
+		return allowed
+	})
+
+	// Socket paths are limited to about a hundred bytes.
+	dir, err := os.MkdirTemp("", "pkssh")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	ln, err := net.Listen("unix", filepath.Join(dir, "agent.sock"))
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- Serve(ln, keyring, func(net.Conn) bool { return true }) }()
+	t.Cleanup(func() {
+		_ = ln.Close()
+		require.NoError(t, <-done)
+	})
+
+	conn, err := net.Dial("unix", ln.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	client := agent.NewClient(conn)
+
+	listed, err := client.List()
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "deploy", listed[0].Comment)
+
+	sig, err := client.Sign(deploy.Signer.PublicKey(), []byte("challenge"))
+	require.NoError(t, err)
+	require.NoError(t, deploy.Signer.PublicKey().Verify([]byte("challenge"), sig))
+	assert.Equal(t, []string{"deploy"}, asked)
+
+	allowed = false
+	_, err = client.Sign(deploy.Signer.PublicKey(), []byte("challenge"))
+	require.Error(t, err, "a refused confirmation must not sign")
+
+	// A key added to the vault after the last listing is found on sign.
+	personal := generatedKey(t, "personal")
+	keys = append(keys, personal)
+	allowed = true
+	sig, err = client.Sign(personal.Signer.PublicKey(), []byte("challenge"))
+	require.NoError(t, err)
+	require.NoError(t, personal.Signer.PublicKey().Verify([]byte("challenge"), sig))
+
+	require.Error(t, client.RemoveAll(), "keys live in the vault, not in the agent")
+}
+
+func TestServeDropsOtherUsers(t *testing.T) {
+	keyring := NewKeyring(func() ([]Key, error) { return []Key{generatedKey(t, "deploy")}, nil }, nil)
+	dir, err := os.MkdirTemp("", "pkssh")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	ln, err := net.Listen("unix", filepath.Join(dir, "agent.sock"))
+	require.NoError(t, err)
+	go func() { _ = Serve(ln, keyring, func(net.Conn) bool { return false }) }()
+	t.Cleanup(func() { _ = ln.Close() })
+
+	conn, err := net.Dial("unix", ln.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	_, err = agent.NewClient(conn).List()
+	require.Error(t, err)
+}
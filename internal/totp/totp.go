@@ -0,0 +1,153 @@
+// Package totp generates RFC 6238 time-based one-time passwords from
+// otpauth:// key URIs.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AlgorithmSHA1   = "SHA1"
+	AlgorithmSHA256 = "SHA256"
+	AlgorithmSHA512 = "SHA512"
+
+	DefaultDigits = 6
+	DefaultPeriod = 30
+)
+
+var ErrInvalidURI = errors.New("invalid otpauth uri")
+
+// Key is a parsed otpauth://totp key.
+type Key struct {
+	Issuer    string
+	Account   string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	Period    int
+}
+
+// ParseURI parses an otpauth://totp URI as written by authenticator apps.
+// Missing algorithm, digits and period fall back to SHA1, 6 and 30 seconds.
+func ParseURI(raw string) (Key, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalidURI, err)
+	}
+	if !strings.EqualFold(parsed.Scheme, "otpauth") {
+		return Key{}, fmt.Errorf("%w: scheme must be otpauth", ErrInvalidURI)
+	}
+	if !strings.EqualFold(parsed.Host, "totp") {
+		return Key{}, fmt.Errorf("%w: only totp keys are supported", ErrInvalidURI)
+	}
+
+	key := Key{Algorithm: AlgorithmSHA1, Digits: DefaultDigits, Period: DefaultPeriod}
+	label := strings.TrimPrefix(parsed.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		key.Issuer = strings.TrimSpace(issuer)
+		key.Account = strings.TrimSpace(account)
+	} else {
+		key.Account = strings.TrimSpace(label)
+	}
+
+	query := parsed.Query()
+	if issuer := strings.TrimSpace(query.Get("issuer")); issuer != "" {
+		key.Issuer = issuer
+	}
+	key.Secret, err = DecodeSecret(query.Get("secret"))
+	if err != nil {
+		return Key{}, err
+	}
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		key.Algorithm = strings.ToUpper(algorithm)
+		if newHash(key.Algorithm) == nil {
+			return Key{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidURI, algorithm)
+		}
+	}
+	if digits := query.Get("digits"); digits != "" {
+		key.Digits, err = strconv.Atoi(digits)
+		if err != nil || key.Digits < 6 || key.Digits > 8 {
+			return Key{}, fmt.Errorf("%w: digits must be 6 to 8", ErrInvalidURI)
+		}
+	}
+	if period := query.Get("period"); period != "" {
+		key.Period, err = strconv.Atoi(period)
+		if err != nil || key.Period <= 0 {
+			return Key{}, fmt.Errorf("%w: period must be a positive number of seconds", ErrInvalidURI)
+		}
+	}
+	return key, nil
+}
+
+// DecodeSecret decodes a base32 shared secret, tolerating lower case,
+// spaces and missing padding.
+func DecodeSecret(secret string) ([]byte, error) {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	normalized = strings.TrimRight(normalized, "=")
+	if normalized == "" {
+		return nil, fmt.Errorf("%w: secret is empty", ErrInvalidURI)
+	}
+	decoded, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("%w: secret must be base32", ErrInvalidURI)
+	}
+	return decoded, nil
+}
+
+// Code returns the one-time password valid at t.
+func (k Key) Code(t time.Time) string {
+	return Generate(k.Secret, k.Algorithm, k.Digits, uint64(t.Unix())/uint64(k.Period))
+}
+
+// Remaining returns how long the code valid at t stays valid.
+func (k Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.Period)
+	elapsed := t.Unix() % period
+	return time.Duration(period-elapsed) * time.Second
+}
+
+// Generate computes the HOTP value (RFC 4226) for counter. TOTP passes the
+// number of periods since the Unix epoch as the counter.
+func Generate(secret []byte, algorithm string, digits int, counter uint64) string {
+	newHashFunc := newHash(algorithm)
+	if newHashFunc == nil {
+		newHashFunc = sha1.New
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(newHashFunc, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+func newHash(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case AlgorithmSHA1:
+		return sha1.New
+	case AlgorithmSHA256:
+		return sha256.New
+	case AlgorithmSHA512:
+		return sha512.New
+	}
+	return nil
+}
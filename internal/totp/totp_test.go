@@ -0,0 +1,100 @@
+package totp
+
+import (
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B test vectors.
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	seeds := map[string]string{
+		AlgorithmSHA1:   "12345678901234567890",
+		AlgorithmSHA256: "12345678901234567890123456789012",
+		AlgorithmSHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	cases := []struct {
+		unix      int64
+		algorithm string
+		code      string
+	}{
+		{59, AlgorithmSHA1, "94287082"},
+		{59, AlgorithmSHA256, "46119246"},
+		{59, AlgorithmSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, "07081804"},
+		{1111111109, AlgorithmSHA256, "68084774"},
+		{1111111109, AlgorithmSHA512, "25091201"},
+		{1111111111, AlgorithmSHA1, "14050471"},
+		{1111111111, AlgorithmSHA256, "67062674"},
+		{1111111111, AlgorithmSHA512, "99943326"},
+		{1234567890, AlgorithmSHA1, "89005924"},
+		{1234567890, AlgorithmSHA256, "91819424"},
+		{1234567890, AlgorithmSHA512, "93441116"},
+		{2000000000, AlgorithmSHA1, "69279037"},
+		{2000000000, AlgorithmSHA256, "90698825"},
+		{2000000000, AlgorithmSHA512, "38618901"},
+		{20000000000, AlgorithmSHA1, "65353130"},
+		{20000000000, AlgorithmSHA256, "77737706"},
+		{20000000000, AlgorithmSHA512, "47863826"},
+	}
+	for _, tc := range cases {
+		key := Key{Secret: []byte(seeds[tc.algorithm]), Algorithm: tc.algorithm, Digits: 8, Period: 30}
+		if got := key.Code(time.Unix(tc.unix, 0)); got != tc.code {
+			t.Errorf("%s at %d: got %s, want %s", tc.algorithm, tc.unix, got, tc.code)
+		}
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	key, err := ParseURI("otpauth://totp/ACME%20Co:alice@example.com?secret=" + secret + "&issuer=ACME%20Co&algorithm=sha256&digits=8&period=60")
+	if err != nil {
+		t.Fatalf("parse uri: %v", err)
+	}
+	if key.Issuer != "ACME Co" || key.Account != "alice@example.com" {
+		t.Fatalf("unexpected label: %+v", key)
+	}
+	if key.Algorithm != AlgorithmSHA256 || key.Digits != 8 || key.Period != 60 {
+		t.Fatalf("unexpected parameters: %+v", key)
+	}
+	if string(key.Secret) != "12345678901234567890" {
+		t.Fatalf("unexpected secret: %q", key.Secret)
+	}
+}
+
+func TestParseURIDefaults(t *testing.T) {
+	key, err := ParseURI("otpauth://totp/alice?secret=gezdgnbvgy3tqojq")
+	if err != nil {
+		t.Fatalf("parse uri: %v", err)
+	}
+	if key.Account != "alice" || key.Algorithm != AlgorithmSHA1 || key.Digits != DefaultDigits || key.Period != DefaultPeriod {
+		t.Fatalf("unexpected defaults: %+v", key)
+	}
+}
+
+func TestParseURIRejectsInvalidKeys(t *testing.T) {
+	for _, raw := range []string{
+		"https://example.com/?secret=GEZDGNBV",
+		"otpauth://hotp/alice?secret=GEZDGNBV",
+		"otpauth://totp/alice",
+		"otpauth://totp/alice?secret=not-base32!",
+		"otpauth://totp/alice?secret=GEZDGNBV&algorithm=MD5",
+		"otpauth://totp/alice?secret=GEZDGNBV&digits=12",
+		"otpauth://totp/alice?secret=GEZDGNBV&period=0",
+	} {
+		if _, err := ParseURI(raw); !errors.Is(err, ErrInvalidURI) {
+			t.Errorf("%s: expected ErrInvalidURI, got %v", raw, err)
+		}
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	key := Key{Period: 30}
+	if got := key.Remaining(time.Unix(59, 0)); got != time.Second {
+		t.Fatalf("unexpected remaining: %v", got)
+	}
+	if got := key.Remaining(time.Unix(60, 0)); got != 30*time.Second {
+		t.Fatalf("unexpected remaining: %v", got)
+	}
+}
@@ -0,0 +1,278 @@
+package vaultagent
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultIdle = 15 * time.Minute
+	DefaultMax  = 4 * time.Hour
+
+	// requestTimeout bounds how long a client may take to send a request,
+	// so a stuck client does not hold a connection forever.
+	requestTimeout = time.Minute
+)
+
+var ErrRunning = errors.New("vault agent is already running")
+
+// Options bound how long an unlocked key stays in memory.
+type Options struct {
+	// Idle locks the vault after this long without a seal or open request.
+	Idle time.Duration
+	// Max locks it this long after the unlock, however busy it is.
+	Max time.Duration
+}
+
+// Status describes the agent's key.
+type Status struct {
+	Unlocked bool   `json:"unlocked"`
+	Account  string `json:"account,omitempty"`
+	// LocksAt is when the key is forgotten unless it is used before.
+	LocksAt time.Time `json:"locks_at,omitempty"`
+}
+
+// Agent holds the vault key of one account in memory and serves seal and
+// open requests from processes of the same user.
+type Agent struct {
+	opts    Options
+	now     func() time.Time
+	uid     int
+	peerUID func(conn net.Conn) (int, error)
+
+	mu         sync.Mutex
+	key        []byte
+	account    string
+	unlockedAt time.Time
+	usedAt     time.Time
+	timer      *time.Timer
+	listener   net.Listener
+}
+
+// New returns a locked agent. Zero options take the defaults.
+func New(opts Options) *Agent {
+	if opts.Idle <= 0 {
+		opts.Idle = DefaultIdle
+	}
+	if opts.Max <= 0 {
+		opts.Max = DefaultMax
+	}
+	return &Agent{opts: opts, now: time.Now, uid: os.Getuid(), peerUID: peerUID}
+}
+
+// Listen opens the agent socket at path, in a directory only the user can
+// enter. A socket left behind by an agent that died is replaced; a live one
+// gives ErrRunning.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return nil, ErrRunning
+	}
+	_ = os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Permissions keep other users out on most systems; the peer check in
+	// serveConn is what the agent relies on.
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// SameUser reports whether the peer of a Unix socket connection runs as
+// the current user. Other sockets serving vault data use it too.
+func SameUser(conn net.Conn) bool {
+	uid, err := peerUID(conn)
+	return err == nil && uid == os.Getuid()
+}
+
+// Serve answers requests on ln until it is closed or a client asks the
+// agent to stop. The key is forgotten on return.
+func (a *Agent) Serve(ln net.Listener) error {
+	a.mu.Lock()
+	a.listener = ln
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.lock()
+		a.mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go a.serveConn(conn)
+	}
+}
+
+func (a *Agent) serveConn(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	enc := json.NewEncoder(conn)
+	if uid, err := a.peerUID(conn); err != nil || uid != a.uid {
+		_ = enc.Encode(response{Code: codeDenied, Error: errDenied.Error()})
+		return
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(requestTimeout))
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		resp := a.handle(req)
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+		if req.Op == opStop {
+			a.mu.Lock()
+			ln := a.listener
+			a.mu.Unlock()
+			if ln != nil {
+				_ = ln.Close()
+			}
+			return
+		}
+	}
+}
+
+func (a *Agent) handle(req request) response {
+	if req.Op == opUnlock {
+		// Key derivation is slow; it runs before taking the lock so other
+		// clients are not held up meanwhile.
+		return a.unlock(req)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	a.expire(now)
+	switch req.Op {
+	case opStatus:
+		return response{Status: a.status()}
+	case opLock, opStop:
+		a.lock()
+		return response{Status: a.status()}
+	case opSeal, opOpen:
+		if a.key == nil || req.Account != a.account {
+			return errorResponse(ErrLocked)
+		}
+		out := make([][]byte, len(req.Data))
+		for i, data := range req.Data {
+			var err error
+			switch {
+			case req.Op == opSeal:
+				var binding []byte
+				if req.Binding != "" {
+					binding = []byte(req.Binding + "/" + strconv.Itoa(i))
+				}
+				out[i], err = Seal(a.key, binding, data)
+			case IsSealed(data):
+				out[i], err = Open(a.key, data)
+			default:
+				// Stored before vault encryption was turned on.
+				out[i] = data
+			}
+			if err != nil {
+				return errorResponse(err)
+			}
+		}
+		a.usedAt = now
+		a.schedule(now)
+		return response{Data: out}
+	default:
+		return response{Code: codeBadRequest, Error: "unknown operation " + req.Op}
+	}
+}
+
+func (a *Agent) unlock(req request) response {
+	defer wipe(req.Password)
+	if req.Account == "" || len(req.Password) == 0 || len(req.Salt) == 0 {
+		return response{Code: codeBadRequest, Error: "unlock needs an account, a password and a salt"}
+	}
+	key := DeriveKey(req.Password, req.Salt)
+	check := req.Check
+	if len(check) > 0 {
+		if err := verifyKeyCheck(key, check); err != nil {
+			wipe(key)
+			return errorResponse(err)
+		}
+	} else {
+		var err error
+		if check, err = newKeyCheck(key); err != nil {
+			wipe(key)
+			return errorResponse(err)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lock()
+	now := a.now()
+	a.key, a.account = key, req.Account
+	a.unlockedAt, a.usedAt = now, now
+	a.schedule(now)
+	return response{Status: a.status(), Check: check}
+}
+
+func (a *Agent) status() *Status {
+	if a.key == nil {
+		return &Status{}
+	}
+	return &Status{Unlocked: true, Account: a.account, LocksAt: a.deadline()}
+}
+
+func (a *Agent) deadline() time.Time {
+	idle := a.usedAt.Add(a.opts.Idle)
+	hard := a.unlockedAt.Add(a.opts.Max)
+	if idle.Before(hard) {
+		return idle
+	}
+	return hard
+}
+
+// expire forgets the key once a timeout passed. Requests call it first, so
+// a key is never used late even if the timer has not fired yet.
+func (a *Agent) expire(now time.Time) {
+	if a.key != nil && !now.Before(a.deadline()) {
+		a.lock()
+	}
+}
+
+func (a *Agent) schedule(now time.Time) {
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	a.timer = time.AfterFunc(a.deadline().Sub(now), func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.expire(a.now())
+	})
+}
+
+func (a *Agent) lock() {
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	wipe(a.key)
+	a.key = nil
+	a.account = ""
+}
@@ -0,0 +1,189 @@
+package vaultagent
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	kdf.time = 1
+	kdf.memoryKiB = 64
+	kdf.threads = 1
+}
+
+// fakeClock is moved by hand so timeouts can be tested without waiting.
+type fakeClock struct{ at time.Time }
+
+func (c *fakeClock) now() time.Time { return c.at }
+
+func startAgent(t *testing.T, opts Options) (*Agent, *Client, *fakeClock) {
+	t.Helper()
+	// Socket paths are limited to about a hundred bytes, which the test
+	// temp dir can exceed.
+	dir, err := os.MkdirTemp("", "pkagent")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "agent.sock")
+
+	clock := &fakeClock{at: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)}
+	agent := New(opts)
+	agent.now = clock.now
+	ln, err := Listen(path)
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- agent.Serve(ln) }()
+	t.Cleanup(func() {
+		_ = ln.Close()
+		require.NoError(t, <-done)
+	})
+	return agent, NewClient(path), clock
+}
+
+func TestAgentSealsAndOpensOnlyWhileUnlocked(t *testing.T) {
+	_, client, _ := startAgent(t, Options{})
+
+	_, err := client.Seal("u-1", "", [][]byte{[]byte("x")})
+	require.ErrorIs(t, err, ErrLocked)
+
+	status, check, err := client.Unlock("u-1", []byte("master"), []byte("salt-of-u-1"), nil)
+	require.NoError(t, err)
+	assert.True(t, status.Unlocked)
+	assert.NotEmpty(t, check)
+
+	sealed, err := client.Seal("u-1", "", [][]byte{[]byte(`{"text":"one"}`), []byte(`{"text":"two"}`)})
+	require.NoError(t, err)
+	require.Len(t, sealed, 2)
+	assert.True(t, IsSealed(sealed[0]))
+	assert.NotContains(t, string(sealed[0]), "one")
+
+	opened, err := client.Open("u-1", [][]byte{sealed[1], []byte(`{"text":"legacy"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, `{"text":"two"}`, string(opened[0]))
+	assert.Equal(t, `{"text":"legacy"}`, string(opened[1]), "payloads stored before encryption pass through")
+
+	_, err = client.Open("u-2", [][]byte{sealed[0]})
+	require.ErrorIs(t, err, ErrLocked, "the key of one account must not serve another")
+
+	require.NoError(t, client.Lock())
+	_, err = client.Open("u-1", [][]byte{sealed[0]})
+	require.ErrorIs(t, err, ErrLocked)
+
+	_, _, err = client.Unlock("u-1", []byte("wrong"), []byte("salt-of-u-1"), check)
+	require.ErrorIs(t, err, ErrWrongPassword)
+	_, _, err = client.Unlock("u-1", []byte("master"), []byte("salt-of-u-1"), check)
+	require.NoError(t, err)
+	opened, err = client.Open("u-1", [][]byte{sealed[0]})
+	require.NoError(t, err)
+	assert.Equal(t, `{"text":"one"}`, string(opened[0]))
+}
+
+func TestAgentForgetsKeyAfterTimeouts(t *testing.T) {
+	_, client, clock := startAgent(t, Options{Idle: 10 * time.Minute, Max: 25 * time.Minute})
+	unlock := func() {
+		t.Helper()
+		_, _, err := client.Unlock("u-1", []byte("master"), []byte("salt-of-u-1"), nil)
+		require.NoError(t, err)
+	}
+	use := func() error {
+		_, err := client.Seal("u-1", "", [][]byte{[]byte("x")})
+		return err
+	}
+
+	unlock()
+	clock.at = clock.at.Add(10 * time.Minute)
+	require.ErrorIs(t, use(), ErrLocked, "idle timeout")
+
+	// Used every 8 minutes the idle timeout never hits, the hard one does.
+	unlock()
+	start := clock.at
+	for clock.at.Sub(start) < 25*time.Minute-9*time.Minute {
+		clock.at = clock.at.Add(8 * time.Minute)
+		require.NoError(t, use())
+	}
+	status, err := client.Status()
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(25*time.Minute), status.LocksAt)
+	clock.at = start.Add(25 * time.Minute)
+	require.ErrorIs(t, use(), ErrLocked, "absolute timeout")
+}
+
+func TestAgentRefusesOtherUsers(t *testing.T) {
+	agent, client, _ := startAgent(t, Options{})
+	agent.uid = os.Getuid() + 1
+
+	_, err := client.Status()
+	require.ErrorIs(t, err, errDenied)
+}
+
+func TestPeerUIDReportsConnectingUser(t *testing.T) {
+	_, client, _ := startAgent(t, Options{})
+	conn, err := net.Dial("unix", client.path)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	uid, err := peerUID(conn)
+	if err != nil {
+		t.Skipf("peer credentials unavailable: %v", err)
+	}
+	assert.Equal(t, os.Getuid(), uid)
+}
+
+func TestListenRefusesSecondAgentUntilStopped(t *testing.T) {
+	_, client, _ := startAgent(t, Options{})
+	_, err := Listen(client.path)
+	require.ErrorIs(t, err, ErrRunning)
+
+	require.NoError(t, client.Stop())
+	require.Eventually(t, func() bool {
+		_, err := client.Status()
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+	_, err = client.Status()
+	require.ErrorIs(t, err, ErrNoAgent)
+	ln, err := Listen(client.path)
+	require.NoError(t, err)
+	require.NoError(t, ln.Close())
+}
+
+func TestSealRejectsTampering(t *testing.T) {
+	key := DeriveKey([]byte("master"), []byte("salt"))
+	sealed, err := Seal(key, nil, []byte("payload"))
+	require.NoError(t, err)
+	assert.Len(t, sealed, len("payload")+Overhead)
+	sealed[len(sealed)-1] ^= 1
+	_, err = Open(key, sealed)
+	require.ErrorIs(t, err, ErrDecrypt)
+	assert.False(t, IsSealed([]byte(`{"text":"plain"}`)))
+}
+
+func TestSealWithBindingRepeats(t *testing.T) {
+	_, client, _ := startAgent(t, Options{})
+	_, _, err := client.Unlock("u-1", []byte("master"), []byte("salt-of-u-1"), nil)
+	require.NoError(t, err)
+	data := [][]byte{[]byte(`{"text":"one"}`), []byte(`{"text":"one"}`)}
+
+	first, err := client.Seal("u-1", "key-1", data)
+	require.NoError(t, err)
+	again, err := client.Seal("u-1", "key-1", data)
+	require.NoError(t, err)
+	assert.Equal(t, first, again, "a retried upload must send the same bytes")
+	assert.NotEqual(t, first[0], first[1], "items of one request get their own nonces")
+
+	other, err := client.Seal("u-1", "key-2", data)
+	require.NoError(t, err)
+	assert.NotEqual(t, first[0], other[0])
+	random, err := client.Seal("u-1", "", data)
+	require.NoError(t, err)
+	assert.NotEqual(t, first[0], random[0])
+
+	opened, err := client.Open("u-1", first)
+	require.NoError(t, err)
+	assert.Equal(t, data, opened)
+}
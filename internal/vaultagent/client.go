@@ -0,0 +1,175 @@
+package vaultagent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	opStatus = "status"
+	opUnlock = "unlock"
+	opLock   = "lock"
+	opStop   = "stop"
+	opSeal   = "seal"
+	opOpen   = "open"
+
+	codeLocked        = "locked"
+	codeWrongPassword = "wrong_password"
+	codeDecrypt       = "decrypt"
+	codeDenied        = "denied"
+	codeBadRequest    = "bad_request"
+	codeInternal      = "internal"
+
+	dialTimeout = time.Second
+	// callTimeout leaves room for the key derivation of an unlock.
+	callTimeout = 30 * time.Second
+)
+
+var errDenied = errors.New("vault agent refused the connection: it belongs to another user")
+
+// request and response are exchanged as JSON lines over the socket.
+type request struct {
+	Op       string `json:"op"`
+	Account  string `json:"account,omitempty"`
+	Password []byte `json:"password,omitempty"`
+	Salt     []byte `json:"salt,omitempty"`
+	Check    []byte `json:"check,omitempty"`
+	// Binding makes sealing deterministic per request, see Seal.
+	Binding string   `json:"binding,omitempty"`
+	Data    [][]byte `json:"data,omitempty"`
+}
+
+type response struct {
+	Code   string   `json:"code,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	Status *Status  `json:"status,omitempty"`
+	Check  []byte   `json:"check,omitempty"`
+	Data   [][]byte `json:"data,omitempty"`
+}
+
+func errorResponse(err error) response {
+	code := codeInternal
+	switch {
+	case errors.Is(err, ErrLocked):
+		code = codeLocked
+	case errors.Is(err, ErrWrongPassword):
+		code = codeWrongPassword
+	case errors.Is(err, ErrDecrypt):
+		code = codeDecrypt
+	}
+	return response{Code: code, Error: err.Error()}
+}
+
+func (r response) err() error {
+	switch r.Code {
+	case "":
+		return nil
+	case codeLocked:
+		return ErrLocked
+	case codeWrongPassword:
+		return ErrWrongPassword
+	case codeDecrypt:
+		return ErrDecrypt
+	case codeDenied:
+		return errDenied
+	default:
+		return fmt.Errorf("vault agent: %s", r.Error)
+	}
+}
+
+// Client talks to the agent on one socket.
+type Client struct {
+	path string
+}
+
+func NewClient(socketPath string) *Client {
+	return &Client{path: socketPath}
+}
+
+// Path is the socket the client dials.
+func (c *Client) Path() string {
+	return c.path
+}
+
+// Status reports whether the agent holds a key. It fails with ErrNoAgent
+// when no agent listens on the socket.
+func (c *Client) Status() (Status, error) {
+	resp, err := c.call(request{Op: opStatus})
+	if err != nil {
+		return Status{}, err
+	}
+	return *resp.Status, nil
+}
+
+// Unlock derives the key of account from password and salt in the agent.
+// With a check from an earlier unlock, a different password is refused with
+// ErrWrongPassword; without one, the check of this key is returned for the
+// caller to keep.
+func (c *Client) Unlock(account string, password, salt, check []byte) (Status, []byte, error) {
+	resp, err := c.call(request{Op: opUnlock, Account: account, Password: password, Salt: salt, Check: check})
+	if err != nil {
+		return Status{}, nil, err
+	}
+	return *resp.Status, resp.Check, nil
+}
+
+// Lock makes the agent forget its key.
+func (c *Client) Lock() error {
+	_, err := c.call(request{Op: opLock})
+	return err
+}
+
+// Stop locks the agent and makes it exit.
+func (c *Client) Stop() error {
+	_, err := c.call(request{Op: opStop})
+	return err
+}
+
+// Seal encrypts payloads with the key of account. A non-empty binding,
+// such as the idempotency key of the upload, makes the result repeatable.
+func (c *Client) Seal(account, binding string, data [][]byte) ([][]byte, error) {
+	return c.transform(request{Op: opSeal, Account: account, Binding: binding, Data: data})
+}
+
+// Open decrypts sealed payloads; others are returned as they are.
+func (c *Client) Open(account string, data [][]byte) ([][]byte, error) {
+	return c.transform(request{Op: opOpen, Account: account, Data: data})
+}
+
+func (c *Client) transform(req request) ([][]byte, error) {
+	resp, err := c.call(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(req.Data) {
+		return nil, fmt.Errorf("vault agent returned %d payloads for %d", len(resp.Data), len(req.Data))
+	}
+	return resp.Data, nil
+}
+
+func (c *Client) call(req request) (response, error) {
+	conn, err := net.DialTimeout("unix", c.path, dialTimeout)
+	if err != nil {
+		return response{}, fmt.Errorf("%w (%v)", ErrNoAgent, err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(callTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return response{}, err
+	}
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return response{}, fmt.Errorf("vault agent: %w", err)
+	}
+	if err := resp.err(); err != nil {
+		return response{}, err
+	}
+	if resp.Status == nil {
+		resp.Status = &Status{}
+	}
+	return resp, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd
+
+package vaultagent
+
+import (
+	"errors"
+	"net"
+)
+
+// peerUID cannot tell who connected on this platform, so every connection
+// is refused.
+func peerUID(net.Conn) (int, error) {
+	return -1, errors.New("peer credentials are not supported on this platform")
+}
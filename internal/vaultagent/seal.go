@@ -0,0 +1,141 @@
+// Package vaultagent keeps the vault key in a background process so
+// commands can encrypt and decrypt secrets without deriving it from the
+// master password every time, much like ssh-agent holds private keys.
+//
+// The agent listens on a Unix socket only its owner may use. It forgets the
+// key when locked, after an idle period and after a hard maximum lifetime.
+package vaultagent
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// sealMagic starts every sealed payload. The leading zero byte keeps it
+	// apart from the JSON payloads stored before vault encryption.
+	sealMagic   = "\x00PKV"
+	sealVersion = 1
+
+	// Overhead is how many bytes Seal adds to a payload.
+	Overhead = len(sealMagic) + 1 + chacha20poly1305.NonceSizeX + chacha20poly1305.Overhead
+
+	keySize      = chacha20poly1305.KeySize
+	keyCheckText = "pkeeper vault key check"
+	nonceLabel   = "pkeeper seal nonce"
+)
+
+var (
+	ErrLocked        = errors.New("vault is locked, run pkeeper unlock")
+	ErrNoAgent       = errors.New("vault agent is not running, run pkeeper unlock")
+	ErrWrongPassword = errors.New("wrong master password")
+	ErrDecrypt       = errors.New("secret is sealed with another key or damaged")
+)
+
+// kdf follows the RFC 9106 second recommended option. Tests lower it to
+// keep key derivation cheap.
+var kdf = struct {
+	time      uint32
+	memoryKiB uint32
+	threads   uint8
+}{time: 3, memoryKiB: 64 << 10, threads: 4}
+
+// DeriveKey derives the vault key from the master password and the
+// account's KDF salt.
+func DeriveKey(password, salt []byte) []byte {
+	return argon2.IDKey(password, salt, kdf.time, kdf.memoryKiB, kdf.threads, keySize)
+}
+
+// IsSealed reports whether data was produced by Seal.
+func IsSealed(data []byte) bool {
+	return len(data) > len(sealMagic) && bytes.HasPrefix(data, []byte(sealMagic))
+}
+
+func sealPreamble() []byte {
+	return append([]byte(sealMagic), sealVersion)
+}
+
+// Seal encrypts a secret payload with XChaCha20-Poly1305: magic, version
+// byte, 24-byte nonce and the sealed data, with magic and version as AAD.
+//
+// Without a binding the nonce is random. With one it is derived from the
+// binding and the payload, so sealing the same payload for the same
+// request again gives the same bytes: a retried upload then matches the
+// first attempt under its idempotency key. Different bindings still give
+// unrelated ciphertexts.
+func Seal(key, binding, plain []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	out := sealPreamble()
+	nonce := make([]byte, aead.NonceSize())
+	if len(binding) == 0 {
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+	} else {
+		copy(nonce, boundNonce(key, binding, plain))
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plain, sealPreamble()), nil
+}
+
+// boundNonce is HMAC-SHA256 of the binding and payload under a key derived
+// from the vault key for this purpose only.
+func boundNonce(key, binding, plain []byte) []byte {
+	sub := hmac.New(sha256.New, key)
+	sub.Write([]byte(nonceLabel))
+	mac := hmac.New(sha256.New, sub.Sum(nil))
+	mac.Write(binding)
+	mac.Write([]byte{0})
+	mac.Write(plain)
+	return mac.Sum(nil)
+}
+
+// Open decrypts what Seal produced.
+func Open(key, sealed []byte) ([]byte, error) {
+	head := sealPreamble()
+	if !IsSealed(sealed) || sealed[len(sealMagic)] != sealVersion {
+		return nil, ErrDecrypt
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	body := sealed[len(head):]
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	plain, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], head)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+// newKeyCheck seals a known text; a later unlock opens it to tell a wrong
+// master password from the right one.
+func newKeyCheck(key []byte) ([]byte, error) {
+	return Seal(key, nil, []byte(keyCheckText))
+}
+
+func verifyKeyCheck(key, check []byte) error {
+	plain, err := Open(key, check)
+	if err != nil || string(plain) != keyCheckText {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package vaultagent
+
+import "errors"
+
+// Spawn is not supported here; the agent has to be run in the foreground.
+func Spawn(string, ...string) error {
+	return errors.New("cannot start the vault agent in the background here, run pkeeper agent serve")
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package vaultagent
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Spawn starts exe with args as a background process in its own session,
+// so it outlives the command that started it and the terminal.
+func Spawn(exe string, args ...string) error {
+	cmd := exec.Command(exe, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
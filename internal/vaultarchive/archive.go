@@ -0,0 +1,233 @@
+package vaultarchive
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+)
+
+const (
+	manifestEntry    = "manifest.json"
+	secretsEntry     = "secrets.json"
+	attachmentPrefix = "attachments/"
+)
+
+var ErrMalformed = errors.New("malformed vault archive")
+
+// Manifest describes where and when an archive was taken.
+type Manifest struct {
+	CreatedAt   time.Time `json:"created_at"`
+	Server      string    `json:"server,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	Secrets     int       `json:"secrets"`
+	Attachments int       `json:"attachments"`
+}
+
+// Secret is a vault entry as stored on the server, plus the attachments
+// whose content follows in the archive.
+type Secret struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	MetaOpen    models.MetaOpen `json:"meta_open"`
+	Ciphertext  string          `json:"ciphertext"`
+	Version     int64           `json:"version"`
+	UpdatedAt   string          `json:"updated_at"`
+	Attachments []Attachment    `json:"attachments,omitempty"`
+}
+
+type Attachment struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// Writer produces an archive. Content of every declared attachment must be
+// added with AddAttachment before Close.
+type Writer struct {
+	seal    *sealWriter
+	tw      *tar.Writer
+	pending map[string]int64
+	created time.Time
+}
+
+// NewWriter writes the header, manifest and secrets. Manifest counts are
+// filled in from secrets.
+func NewWriter(w io.Writer, passphrase []byte, manifest Manifest, secrets []Secret) (*Writer, error) {
+	seal, err := newSealWriter(w, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aw := &Writer{seal: seal, tw: tar.NewWriter(seal), pending: map[string]int64{}, created: manifest.CreatedAt}
+	if secrets == nil {
+		secrets = []Secret{}
+	}
+	manifest.Secrets = len(secrets)
+	manifest.Attachments = 0
+	for _, secret := range secrets {
+		for _, attachment := range secret.Attachments {
+			if _, ok := aw.pending[attachment.ID]; ok || attachment.ID == "" || strings.Contains(attachment.ID, "/") {
+				return nil, fmt.Errorf("invalid attachment id %q", attachment.ID)
+			}
+			aw.pending[attachment.ID] = attachment.Size
+			manifest.Attachments++
+		}
+	}
+	if err := aw.writeJSON(manifestEntry, manifest); err != nil {
+		return nil, err
+	}
+	if err := aw.writeJSON(secretsEntry, secrets); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+func (w *Writer) writeJSON(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := w.tw.WriteHeader(w.entryHeader(name, int64(len(data)))); err != nil {
+		return err
+	}
+	_, err = w.tw.Write(data)
+	return err
+}
+
+func (w *Writer) entryHeader(name string, size int64) *tar.Header {
+	return &tar.Header{Name: name, Mode: 0o600, Size: size, ModTime: w.created, Format: tar.FormatPAX}
+}
+
+// AddAttachment starts the content of a declared attachment. Exactly the
+// declared size must be written to the returned writer.
+func (w *Writer) AddAttachment(id string) (io.Writer, error) {
+	size, ok := w.pending[id]
+	if !ok {
+		return nil, fmt.Errorf("attachment %s is not declared or already added", id)
+	}
+	delete(w.pending, id)
+	if err := w.tw.WriteHeader(w.entryHeader(attachmentPrefix+id, size)); err != nil {
+		return nil, err
+	}
+	return w.tw, nil
+}
+
+// Close finishes the archive. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if len(w.pending) > 0 {
+		return fmt.Errorf("%d declared attachments were not added", len(w.pending))
+	}
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.seal.Close()
+}
+
+// Reader reads an archive sequentially. Manifest and Secrets are available
+// as soon as Open returns; attachment content follows via Next.
+type Reader struct {
+	Manifest Manifest
+	Secrets  []Secret
+
+	stream   *openReader
+	tr       *tar.Reader
+	declared map[string]Attachment
+}
+
+// Open authenticates the header and reads the manifest and secrets.
+func Open(r io.Reader, passphrase []byte) (*Reader, error) {
+	stream, err := newOpenReader(r, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	ar := &Reader{stream: stream, tr: tar.NewReader(stream), declared: map[string]Attachment{}}
+	if err := ar.readJSON(manifestEntry, &ar.Manifest); err != nil {
+		return nil, err
+	}
+	if err := ar.readJSON(secretsEntry, &ar.Secrets); err != nil {
+		return nil, err
+	}
+	for _, secret := range ar.Secrets {
+		for _, attachment := range secret.Attachments {
+			ar.declared[attachment.ID] = attachment
+		}
+	}
+	return ar, nil
+}
+
+func (r *Reader) readJSON(name string, v any) error {
+	hdr, err := r.tr.Next()
+	if err != nil {
+		return r.streamErr(err)
+	}
+	if hdr.Name != name {
+		return fmt.Errorf("%w: expected %s, got %s", ErrMalformed, name, hdr.Name)
+	}
+	if err := json.NewDecoder(r.tr).Decode(v); err != nil {
+		return r.streamErr(err)
+	}
+	return nil
+}
+
+// Next returns the next attachment and a reader for its content, which is
+// valid until the following call. At the end of the archive it verifies
+// the final chunk and returns io.EOF.
+func (r *Reader) Next() (Attachment, io.Reader, error) {
+	hdr, err := r.tr.Next()
+	if errors.Is(err, io.EOF) {
+		// tar stops at its end marker; the stream must still end at the
+		// chunk sealed as last.
+		if _, err := io.Copy(io.Discard, r.stream); err != nil {
+			return Attachment{}, nil, err
+		}
+		if len(r.declared) > 0 {
+			return Attachment{}, nil, fmt.Errorf("%w: %d attachments missing", ErrMalformed, len(r.declared))
+		}
+		return Attachment{}, nil, io.EOF
+	}
+	if err != nil {
+		return Attachment{}, nil, r.streamErr(err)
+	}
+	id, ok := strings.CutPrefix(hdr.Name, attachmentPrefix)
+	attachment, declared := r.declared[id]
+	if !ok || !declared || attachment.Size != hdr.Size {
+		return Attachment{}, nil, fmt.Errorf("%w: unexpected entry %s", ErrMalformed, hdr.Name)
+	}
+	delete(r.declared, id)
+	return attachment, r.tr, nil
+}
+
+// streamErr prefers the stream's own error: tar and json only see a short
+// read when a chunk fails to authenticate.
+func (r *Reader) streamErr(err error) error {
+	if r.stream.err != nil {
+		return r.stream.err
+	}
+	return fmt.Errorf("%w: %v", ErrMalformed, err)
+}
+
+// Verify reads the whole archive, discarding attachment content, and
+// returns its manifest once every chunk has been authenticated.
+func Verify(r io.Reader, passphrase []byte) (*Reader, error) {
+	ar, err := Open(r, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		_, content, err := ar.Next()
+		if errors.Is(err, io.EOF) {
+			return ar, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(io.Discard, content); err != nil {
+			return nil, ar.streamErr(err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package vaultarchive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var passphrase = []byte("correct horse battery staple")
+
+func init() {
+	defaultKDF.Time = 1
+	defaultKDF.MemoryKiB = 64
+	defaultKDF.Threads = 1
+}
+
+// buildArchive writes two secrets, one with a multi-chunk attachment.
+func buildArchive(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	content := bytes.Repeat([]byte("0123456789abcdef"), 3*defaultChunkSize/16+5)
+	secrets := []Secret{
+		{ID: "s-1", Type: "note", MetaOpen: models.MetaOpen{Title: "Note"}, Ciphertext: "bm90ZQ==", Version: 2},
+		{ID: "s-2", Type: "binary", MetaOpen: models.MetaOpen{Title: "Key", Tags: []string{"ops"}}, Ciphertext: "Ymlu",
+			Attachments: []Attachment{{ID: "a-1", Name: "key.bin", Size: int64(len(content))}}},
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, passphrase, Manifest{CreatedAt: time.Unix(1700000000, 0).UTC(), Server: "http://example.test"}, secrets)
+	require.NoError(t, err)
+	aw, err := w.AddAttachment("a-1")
+	require.NoError(t, err)
+	_, err = aw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes(), content
+}
+
+func TestRoundTrip(t *testing.T) {
+	data, content := buildArchive(t)
+
+	r, err := Open(bytes.NewReader(data), passphrase)
+	require.NoError(t, err)
+	assert.Equal(t, 2, r.Manifest.Secrets)
+	assert.Equal(t, 1, r.Manifest.Attachments)
+	assert.Equal(t, "http://example.test", r.Manifest.Server)
+	require.Len(t, r.Secrets, 2)
+	assert.Equal(t, []string{"ops"}, r.Secrets[1].MetaOpen.Tags)
+
+	attachment, rd, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "a-1", attachment.ID)
+	got, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	_, _, err = r.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestOpenRejectsWrongPassphrase(t *testing.T) {
+	data, _ := buildArchive(t)
+	_, err := Open(bytes.NewReader(data), []byte("wrong"))
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	data, _ := buildArchive(t)
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-defaultChunkSize] ^= 1
+
+	_, err := Verify(bytes.NewReader(tampered), passphrase)
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestVerifyDetectsHeaderChange(t *testing.T) {
+	data, _ := buildArchive(t)
+	changed := bytes.Replace(data, []byte(`"chunk_size":65536`), []byte(`"chunk_size":65537`), 1)
+	require.NotEqual(t, data, changed)
+
+	_, err := Verify(bytes.NewReader(changed), passphrase)
+	assert.Error(t, err)
+}
+
+func TestVerifyDetectsTruncation(t *testing.T) {
+	data, _ := buildArchive(t)
+	h, preamble, err := readPreamble(bytes.NewReader(data))
+	require.NoError(t, err)
+	sealedChunk := h.ChunkSize + 16
+
+	tests := map[string]int{
+		"mid chunk":      len(data) - 10,
+		"chunk boundary": len(preamble) + 2*sealedChunk,
+	}
+	for name, size := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Verify(bytes.NewReader(data[:size]), passphrase)
+			assert.Error(t, err)
+			assert.True(t, errors.Is(err, ErrTruncated) || errors.Is(err, ErrDecrypt), err)
+		})
+	}
+
+	_, err = Verify(bytes.NewReader(data[:len(preamble)+2*sealedChunk]), passphrase)
+	assert.ErrorIs(t, err, ErrTruncated)
+}
+
+func TestOpenRejectsForeignFiles(t *testing.T) {
+	_, err := Open(bytes.NewReader([]byte("PK\x03\x04 zip file")), passphrase)
+	assert.ErrorIs(t, err, ErrNotArchive)
+
+	data, _ := buildArchive(t)
+	future := append([]byte(nil), data...)
+	future[len(magic)] = FormatVersion + 1
+	_, err = Open(bytes.NewReader(future), passphrase)
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestWriterRequiresDeclaredAttachments(t *testing.T) {
+	secrets := []Secret{{ID: "s-1", Type: "binary", Attachments: []Attachment{{ID: "a-1", Name: "f", Size: 1}}}}
+	w, err := NewWriter(io.Discard, passphrase, Manifest{}, secrets)
+	require.NoError(t, err)
+
+	_, err = w.AddAttachment("a-2")
+	assert.Error(t, err)
+	assert.Error(t, w.Close())
+}
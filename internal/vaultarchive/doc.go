@@ -0,0 +1,52 @@
+// Package vaultarchive reads and writes .pkx files: self-contained,
+// encrypted backups of a vault that can be restored without the server
+// that produced them.
+//
+// # File format, version 1
+//
+// All integers are big-endian.
+//
+//	offset  size  field
+//	0       8     magic "PKEEPERX"
+//	8       1     format version, currently 1
+//	9       4     header length N
+//	13      N     header, UTF-8 JSON
+//	13+N    ...   encrypted payload stream
+//
+// The header describes how to derive the key and open the stream:
+//
+//	{
+//	  "kdf": {"name": "argon2id", "salt": "<base64>", "time": 3,
+//	          "memory_kib": 65536, "threads": 4},
+//	  "cipher": "chacha20-poly1305",
+//	  "chunk_size": 65536,
+//	  "nonce_prefix": "<base64, 7 bytes>"
+//	}
+//
+// The 32-byte key is Argon2id(passphrase, salt, time, memory_kib, threads).
+//
+// The payload is split into chunks of chunk_size plaintext bytes, each
+// sealed with ChaCha20-Poly1305 (RFC 8439) and stored as ciphertext
+// followed by the 16-byte tag, with no framing between chunks. Only the
+// last chunk may be shorter than chunk_size, and it may be empty. The
+// 12-byte nonce of chunk i is
+//
+//	nonce_prefix (7) || uint32(i) (4) || last (1)
+//
+// where last is 1 for the final chunk and 0 otherwise. Every chunk uses
+// the file bytes from offset 0 up to the end of the header as associated
+// data. Reordered, dropped, appended or truncated chunks and any change to
+// the header therefore fail authentication.
+//
+// The decrypted payload is a POSIX tar stream with these entries, in order:
+//
+//	manifest.json        Manifest
+//	secrets.json         []Secret
+//	attachments/<id>     raw attachment content, one entry per Attachment
+//
+// Secret ciphertext is copied from the server unchanged, base64-encoded,
+// and decodes to the typed payload described in package secretkind.
+//
+// Readers must reject unknown magic or versions. A future version may
+// change anything after the version byte.
+package vaultarchive
@@ -0,0 +1,307 @@
+package vaultarchive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	magic         = "PKEEPERX"
+	FormatVersion = 1
+
+	kdfArgon2id      = "argon2id"
+	cipherChaCha20   = "chacha20-poly1305"
+	noncePrefixSize  = 7
+	saltSize         = 16
+	keySize          = chacha20poly1305.KeySize
+	defaultChunkSize = 64 << 10
+
+	// Bounds applied to headers read from disk, so a crafted file cannot
+	// make the reader allocate or compute without limit.
+	maxHeaderSize = 64 << 10
+	minChunkSize  = 1 << 10
+	maxChunkSize  = 16 << 20
+	maxKDFMemory  = 1 << 20
+	maxKDFTime    = 64
+)
+
+var (
+	ErrNotArchive  = errors.New("not a vault archive")
+	ErrUnsupported = errors.New("unsupported vault archive")
+	ErrDecrypt     = errors.New("wrong passphrase or damaged archive")
+	ErrTruncated   = errors.New("vault archive is truncated")
+)
+
+type kdfParams struct {
+	Name      string `json:"name"`
+	Salt      []byte `json:"salt"`
+	Time      uint32 `json:"time"`
+	MemoryKiB uint32 `json:"memory_kib"`
+	Threads   uint8  `json:"threads"`
+}
+
+type header struct {
+	KDF         kdfParams `json:"kdf"`
+	Cipher      string    `json:"cipher"`
+	ChunkSize   int       `json:"chunk_size"`
+	NoncePrefix []byte    `json:"nonce_prefix"`
+}
+
+// defaultKDF follows the RFC 9106 second recommended option. Tests lower
+// it to keep key derivation cheap.
+var defaultKDF = kdfParams{Name: kdfArgon2id, Time: 3, MemoryKiB: 64 << 10, Threads: 4}
+
+func (h header) validate() error {
+	switch {
+	case h.KDF.Name != kdfArgon2id:
+		return fmt.Errorf("%w: kdf %q", ErrUnsupported, h.KDF.Name)
+	case h.Cipher != cipherChaCha20:
+		return fmt.Errorf("%w: cipher %q", ErrUnsupported, h.Cipher)
+	case len(h.KDF.Salt) < 8, len(h.NoncePrefix) != noncePrefixSize:
+		return fmt.Errorf("%w: bad salt or nonce", ErrUnsupported)
+	case h.KDF.Time == 0 || h.KDF.Time > maxKDFTime,
+		h.KDF.MemoryKiB == 0 || h.KDF.MemoryKiB > maxKDFMemory,
+		h.KDF.Threads == 0:
+		return fmt.Errorf("%w: kdf parameters out of range", ErrUnsupported)
+	case h.ChunkSize < minChunkSize || h.ChunkSize > maxChunkSize:
+		return fmt.Errorf("%w: chunk size %d", ErrUnsupported, h.ChunkSize)
+	}
+	return nil
+}
+
+func (h header) aead(passphrase []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey(passphrase, h.KDF.Salt, h.KDF.Time, h.KDF.MemoryKiB, h.KDF.Threads, keySize)
+	return chacha20poly1305.New(key)
+}
+
+// encodePreamble renders everything before the payload stream. The result
+// is also the associated data of every chunk.
+func encodePreamble(h header) ([]byte, error) {
+	body, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	buf.WriteByte(FormatVersion)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(body)))
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+func readPreamble(r io.Reader) (header, []byte, error) {
+	fixed := make([]byte, len(magic)+1+4)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return header{}, nil, ErrNotArchive
+	}
+	if string(fixed[:len(magic)]) != magic {
+		return header{}, nil, ErrNotArchive
+	}
+	if version := fixed[len(magic)]; version != FormatVersion {
+		return header{}, nil, fmt.Errorf("%w: format version %d", ErrUnsupported, version)
+	}
+	size := binary.BigEndian.Uint32(fixed[len(magic)+1:])
+	if size > maxHeaderSize {
+		return header{}, nil, fmt.Errorf("%w: header too large", ErrUnsupported)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return header{}, nil, ErrTruncated
+	}
+	var h header
+	if err := json.Unmarshal(body, &h); err != nil {
+		return header{}, nil, fmt.Errorf("%w: %v", ErrNotArchive, err)
+	}
+	if err := h.validate(); err != nil {
+		return header{}, nil, err
+	}
+	return h, append(fixed, body...), nil
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// sealWriter encrypts the payload stream. A full chunk is held back until
+// more data arrives, so Close can mark whichever chunk ends the stream.
+type sealWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	counter uint32
+	size    int
+	buf     []byte
+	closed  bool
+}
+
+// newSealWriter writes the preamble for a fresh salt and nonce prefix and
+// returns the writer for the payload.
+func newSealWriter(w io.Writer, passphrase []byte) (*sealWriter, error) {
+	h := header{KDF: defaultKDF, Cipher: cipherChaCha20, ChunkSize: defaultChunkSize}
+	h.KDF.Salt = make([]byte, saltSize)
+	h.NoncePrefix = make([]byte, noncePrefixSize)
+	if _, err := rand.Read(h.KDF.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(h.NoncePrefix); err != nil {
+		return nil, err
+	}
+	preamble, err := encodePreamble(h)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := h.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(preamble); err != nil {
+		return nil, err
+	}
+	return &sealWriter{
+		w:      w,
+		aead:   aead,
+		aad:    preamble,
+		prefix: h.NoncePrefix,
+		size:   h.ChunkSize,
+		buf:    make([]byte, 0, h.ChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (s *sealWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("write to closed vault archive")
+	}
+	written := 0
+	for len(p) > 0 {
+		if len(s.buf) == s.size {
+			if err := s.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(s.buf[len(s.buf):s.size], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (s *sealWriter) flush(last bool) error {
+	if s.counter == math.MaxUint32 {
+		return errors.New("vault archive is too large")
+	}
+	sealed := s.aead.Seal(s.buf[:0], chunkNonce(s.prefix, s.counter, last), s.buf, s.aad)
+	s.counter++
+	s.buf = s.buf[:0]
+	_, err := s.w.Write(sealed)
+	return err
+}
+
+// Close seals the final chunk. It does not close the underlying writer.
+func (s *sealWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+// openReader authenticates and decrypts the payload stream. It reports
+// io.EOF only after the chunk marked last has been verified.
+type openReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	plain   []byte
+	done    bool
+	err     error
+}
+
+func newOpenReader(r io.Reader, passphrase []byte) (*openReader, error) {
+	h, preamble, err := readPreamble(r)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := h.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &openReader{
+		r:      bufio.NewReader(r),
+		aead:   aead,
+		aad:    preamble,
+		prefix: h.NoncePrefix,
+		buf:    make([]byte, h.ChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (o *openReader) Read(p []byte) (int, error) {
+	for len(o.plain) == 0 {
+		if o.err != nil {
+			return 0, o.err
+		}
+		if o.done {
+			return 0, io.EOF
+		}
+		o.err = o.next()
+	}
+	n := copy(p, o.plain)
+	o.plain = o.plain[n:]
+	return n, nil
+}
+
+func (o *openReader) next() error {
+	n, err := io.ReadFull(o.r, o.buf)
+	last := false
+	switch {
+	case errors.Is(err, io.EOF):
+		return ErrTruncated
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, peekErr := o.r.Peek(1); errors.Is(peekErr, io.EOF) {
+			last = true
+		} else if peekErr != nil {
+			return peekErr
+		}
+	}
+	sealed := o.buf[:n]
+	plain, err := o.aead.Open(nil, chunkNonce(o.prefix, o.counter, last), sealed, o.aad)
+	if err != nil {
+		// A chunk that only opens as a middle chunk means the file was cut
+		// at a chunk boundary.
+		if last {
+			if _, midErr := o.aead.Open(nil, chunkNonce(o.prefix, o.counter, false), sealed, o.aad); midErr == nil {
+				return ErrTruncated
+			}
+		}
+		return ErrDecrypt
+	}
+	o.counter++
+	o.done = last
+	o.plain = plain
+	return nil
+}
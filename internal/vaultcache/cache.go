@@ -0,0 +1,238 @@
+// Package vaultcache keeps an encrypted local replica of a user's secrets so
+// the CLI can read them without the server, plus a queue of writes made
+// while offline that the next sync uploads.
+//
+// The replica holds server state only. Queued writes are kept apart and laid
+// over it when reading, so a refresh from the server never loses them.
+package vaultcache
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+)
+
+// LocalIDPrefix marks IDs of secrets created offline; the server assigns the
+// real ID when the create is uploaded.
+const LocalIDPrefix = "local-"
+
+var ErrNotFound = errors.New("secret is not in the offline cache")
+
+// Op is a write waiting for upload. Each secret has at most one: later
+// writes to the same secret are folded into it.
+type Op struct {
+	// ID is also the Idempotency-Key of the upload.
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	SecretID string `json:"secret_id"`
+	// BaseVersion is the server version the write was made against; zero
+	// for creates.
+	BaseVersion int64 `json:"base_version,omitempty"`
+	// Base is the server state at BaseVersion, the common ancestor a
+	// conflicting upload is merged against.
+	Base     *dtosecret.SecretResponse `json:"base,omitempty"`
+	Secret   *dtosecret.SecretPayload  `json:"secret,omitempty"`
+	QueuedAt time.Time                 `json:"queued_at"`
+	// LastError is why the server rejected the last upload attempt.
+	LastError string `json:"last_error,omitempty"`
+	// Conflict is set while the write waits for the user to pick a side;
+	// it is not uploaded until then.
+	Conflict *Conflict `json:"conflict,omitempty"`
+}
+
+type Cache struct {
+	UserID string `json:"user_id"`
+	// Complete is set once the replica was seeded from a full listing;
+	// before that it only holds secrets seen in passing.
+	Complete bool `json:"complete"`
+	// Cursor is the newest server change applied, in RFC3339.
+	Cursor  string                              `json:"cursor,omitempty"`
+	Secrets map[string]dtosecret.SecretResponse `json:"secrets"`
+	Queue   []Op                                `json:"queue,omitempty"`
+	// Decisions records how conflicts were settled, oldest first.
+	Decisions []Decision `json:"decisions,omitempty"`
+}
+
+func newCache(userID string) *Cache {
+	return &Cache{UserID: userID, Secrets: make(map[string]dtosecret.SecretResponse)}
+}
+
+// IsLocalID reports whether id names a secret created offline.
+func IsLocalID(id string) bool {
+	return strings.HasPrefix(id, LocalIDPrefix)
+}
+
+// Replace seeds the replica from a full listing of live secrets.
+func (c *Cache) Replace(secrets []dtosecret.SecretResponse) {
+	c.Secrets = make(map[string]dtosecret.SecretResponse, len(secrets))
+	for _, secret := range secrets {
+		c.Secrets[secret.ID] = secret
+	}
+	c.Complete = true
+	c.Cursor = latest("", secrets)
+}
+
+// Apply merges changes fetched since Cursor, deletions included, and moves
+// the cursor past them.
+func (c *Cache) Apply(changes []dtosecret.SecretResponse) {
+	for _, secret := range changes {
+		c.Put(secret)
+	}
+	c.Cursor = latest(c.Cursor, changes)
+}
+
+// Put stores one secret the server returned, or drops it when it is
+// deleted. Older versions than the one cached are ignored. Unlike Apply it
+// leaves the cursor alone, as other changes may precede it.
+func (c *Cache) Put(secret dtosecret.SecretResponse) {
+	if current, ok := c.Secrets[secret.ID]; ok && current.Version > secret.Version {
+		return
+	}
+	if secret.DeletedAt != "" {
+		delete(c.Secrets, secret.ID)
+		return
+	}
+	c.Secrets[secret.ID] = secret
+}
+
+// Remove drops a secret deleted on the server.
+func (c *Cache) Remove(id string) {
+	delete(c.Secrets, id)
+}
+
+// List returns the secrets as they read locally, queued writes included,
+// oldest change first like the server listing.
+func (c *Cache) List() []dtosecret.SecretResponse {
+	out := slices.Collect(maps.Values(c.view()))
+	slices.SortFunc(out, func(a, b dtosecret.SecretResponse) int {
+		if byTime := strings.Compare(a.UpdatedAt, b.UpdatedAt); byTime != 0 {
+			return byTime
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out
+}
+
+// Get returns one secret as it reads locally.
+func (c *Cache) Get(id string) (dtosecret.SecretResponse, bool) {
+	secret, ok := c.view()[id]
+	return secret, ok
+}
+
+func (c *Cache) view() map[string]dtosecret.SecretResponse {
+	view := maps.Clone(c.Secrets)
+	for _, op := range c.Queue {
+		op.applyTo(view)
+	}
+	return view
+}
+
+func (op Op) applyTo(view map[string]dtosecret.SecretResponse) {
+	switch op.Kind {
+	case dtosecret.BatchOpCreate:
+		view[op.SecretID] = dtosecret.SecretResponse{ID: op.SecretID, UpdatedAt: formatTime(op.QueuedAt)}
+		fallthrough
+	case dtosecret.BatchOpUpdate:
+		secret, ok := view[op.SecretID]
+		if !ok || op.Secret == nil {
+			return
+		}
+		secret.Type = op.Secret.Type
+		secret.MetaOpen = op.Secret.MetaOpen
+		secret.Ciphertext = op.Secret.Ciphertext
+		secret.UpdatedAt = formatTime(op.QueuedAt)
+		view[op.SecretID] = secret
+	case dtosecret.BatchOpDelete:
+		delete(view, op.SecretID)
+	}
+}
+
+// Enqueue records a write for upload and returns the secret as it now reads
+// locally; deletes return a zero secret. Creates get a local ID. Updates and
+// deletes must target a secret the cache knows, and remember its version so
+// the upload can detect that it changed on the server meanwhile.
+func (c *Cache) Enqueue(op Op) (dtosecret.SecretResponse, error) {
+	op.LastError = ""
+	if op.Kind == dtosecret.BatchOpCreate {
+		op.SecretID = LocalIDPrefix + op.ID
+		c.Queue = append(c.Queue, op)
+		secret, _ := c.Get(op.SecretID)
+		return secret, nil
+	}
+	current, ok := c.Get(op.SecretID)
+	if !ok {
+		return dtosecret.SecretResponse{}, ErrNotFound
+	}
+	i := slices.IndexFunc(c.Queue, func(pending Op) bool { return pending.SecretID == op.SecretID })
+	switch {
+	case i < 0:
+		op.BaseVersion = current.Version
+		if base, ok := c.Secrets[op.SecretID]; ok {
+			op.Base = &base
+		}
+		c.Queue = append(c.Queue, op)
+	case c.Queue[i].Kind == dtosecret.BatchOpCreate && op.Kind == dtosecret.BatchOpDelete:
+		// Never uploaded, so there is nothing to delete on the server.
+		c.Queue = slices.Delete(c.Queue, i, i+1)
+	case c.Queue[i].Kind == dtosecret.BatchOpCreate:
+		op.Kind = dtosecret.BatchOpCreate
+		c.Queue[i] = op
+	default:
+		op.BaseVersion = c.Queue[i].BaseVersion
+		op.Base = c.Queue[i].Base
+		op.Conflict = c.Queue[i].Conflict
+		c.Queue[i] = op
+	}
+	secret, _ := c.Get(op.SecretID)
+	return secret, nil
+}
+
+// Pending returns the queued writes in upload order.
+func (c *Cache) Pending() []Op {
+	return slices.Clone(c.Queue)
+}
+
+// Conflicts returns the queued writes waiting for a decision.
+func (c *Cache) Conflicts() []Op {
+	return slices.DeleteFunc(c.Pending(), func(op Op) bool { return op.Conflict == nil })
+}
+
+// HasPending reports whether secretID has a queued write.
+func (c *Cache) HasPending(secretID string) bool {
+	return slices.ContainsFunc(c.Queue, func(op Op) bool { return op.SecretID == secretID })
+}
+
+// Done removes an uploaded write.
+func (c *Cache) Done(opID string) {
+	c.Queue = slices.DeleteFunc(c.Queue, func(op Op) bool { return op.ID == opID })
+}
+
+// Fail keeps a rejected write queued with the reason.
+func (c *Cache) Fail(opID, reason string) {
+	for i := range c.Queue {
+		if c.Queue[i].ID == opID {
+			c.Queue[i].LastError = reason
+		}
+	}
+}
+
+func latest(cursor string, secrets []dtosecret.SecretResponse) string {
+	newest, _ := time.Parse(time.RFC3339, cursor)
+	for _, secret := range secrets {
+		if parsed, err := time.Parse(time.RFC3339, secret.UpdatedAt); err == nil && parsed.After(newest) {
+			newest = parsed
+		}
+	}
+	if newest.IsZero() {
+		return cursor
+	}
+	return formatTime(newest)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,178 @@
+package vaultcache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func note(id string, version int64, updatedAt string) dtosecret.SecretResponse {
+	return dtosecret.SecretResponse{ID: id, Type: "note", MetaOpen: models.MetaOpen{Title: id}, Ciphertext: "c2VjcmV0LXZhbHVl", Version: version, UpdatedAt: updatedAt}
+}
+
+func TestStoreRoundTripIsEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir, "u-1")
+	require.NoError(t, store.Update(func(c *Cache) error {
+		c.Replace([]dtosecret.SecretResponse{note("s-1", 1, "2026-03-01T10:00:00Z")})
+		return nil
+	}))
+
+	cache, err := store.Load()
+	require.NoError(t, err)
+	assert.True(t, cache.Complete)
+	assert.Equal(t, "2026-03-01T10:00:00Z", cache.Cursor)
+	assert.Len(t, cache.List(), 1)
+
+	raw, err := os.ReadFile(store.path())
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(raw, []byte("c2VjcmV0LXZhbHVl")), "cache file must not hold plaintext")
+	for _, name := range []string{filepath.Base(store.path()), keyFileName} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), name)
+	}
+
+	other, err := NewStore(dir, "u-2").Load()
+	require.NoError(t, err)
+	assert.False(t, other.Complete, "accounts must not share a replica")
+}
+
+func TestStoreRejectsReplacedKeyAndTampering(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir, "u-1")
+	require.NoError(t, store.Update(func(c *Cache) error {
+		c.Replace([]dtosecret.SecretResponse{note("s-1", 1, "2026-03-01T10:00:00Z")})
+		return nil
+	}))
+
+	raw, err := os.ReadFile(store.path())
+	require.NoError(t, err)
+	raw[len(raw)-1] ^= 1
+	require.NoError(t, os.WriteFile(store.path(), raw, 0o600))
+	_, err = store.Load()
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, keyFileName)))
+	_, err = store.Load()
+	require.ErrorIs(t, err, ErrDecrypt)
+}
+
+// xorSealer stands in for the vault agent; locked makes it refuse.
+type xorSealer struct {
+	locked *bool
+}
+
+var errSealerLocked = errors.New("locked")
+
+func (x xorSealer) SealKey(key []byte) ([]byte, error) { return x.OpenKey(key) }
+
+func (x xorSealer) OpenKey(sealed []byte) ([]byte, error) {
+	if *x.locked {
+		return nil, errSealerLocked
+	}
+	out := bytes.Clone(sealed)
+	for i := range out {
+		out[i] ^= 0x5a
+	}
+	return out, nil
+}
+
+func TestKeySealerGuardsCacheAndMigratesDeviceKey(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, NewStore(dir, "u-1").Update(func(c *Cache) error {
+		c.Replace([]dtosecret.SecretResponse{note("s-1", 1, "2026-03-01T10:00:00Z")})
+		return nil
+	}))
+
+	locked := true
+	sealed := func() *Store { return NewStore(dir, "u-1").WithKeySealer(xorSealer{locked: &locked}) }
+	_, err := sealed().Load()
+	require.ErrorIs(t, err, errSealerLocked, "a device-key cache must not be readable past a locked sealer")
+
+	locked = false
+	cache, err := sealed().Load()
+	require.NoError(t, err)
+	assert.Len(t, cache.List(), 1)
+	require.NoError(t, sealed().Update(func(*Cache) error { return nil }))
+
+	raw, err := os.ReadFile(sealed().path())
+	require.NoError(t, err)
+	assert.Equal(t, versionSealedKey, raw[len(magic)])
+	info, err := os.Stat(sealed().sealedKeyPath())
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	_, err = NewStore(dir, "u-1").Load()
+	require.ErrorIs(t, err, ErrSealedKey)
+	locked = true
+	_, err = sealed().Load()
+	require.ErrorIs(t, err, errSealerLocked)
+}
+
+func TestApplyHandlesDeletionsAndStaleVersions(t *testing.T) {
+	cache := newCache("u-1")
+	cache.Replace([]dtosecret.SecretResponse{note("s-1", 2, "2026-03-01T10:00:00Z"), note("s-2", 1, "2026-03-01T11:00:00Z")})
+
+	gone := note("s-2", 1, "2026-03-01T12:00:00Z")
+	gone.DeletedAt = "2026-03-01T12:00:00Z"
+	cache.Apply([]dtosecret.SecretResponse{note("s-1", 1, "2026-03-01T09:00:00Z"), gone, note("s-3", 1, "2026-03-01T12:30:00Z")})
+
+	list := cache.List()
+	require.Len(t, list, 2)
+	assert.Equal(t, int64(2), list[0].Version, "an older version must not overwrite a newer one")
+	assert.Equal(t, "s-3", list[1].ID)
+	assert.Equal(t, "2026-03-01T12:30:00Z", cache.Cursor)
+}
+
+func TestEnqueueFoldsWritesPerSecret(t *testing.T) {
+	cache := newCache("u-1")
+	cache.Replace([]dtosecret.SecretResponse{note("s-1", 4, "2026-03-01T10:00:00Z"), note("s-2", 2, "2026-03-01T10:00:00Z")})
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	payload := func(title string) *dtosecret.SecretPayload {
+		return &dtosecret.SecretPayload{Type: "note", MetaOpen: models.MetaOpen{Title: title}, Ciphertext: "bmV3"}
+	}
+
+	created, err := cache.Enqueue(Op{ID: "op-1", Kind: dtosecret.BatchOpCreate, Secret: payload("draft"), QueuedAt: now})
+	require.NoError(t, err)
+	assert.Equal(t, "local-op-1", created.ID)
+	assert.True(t, IsLocalID(created.ID))
+
+	_, err = cache.Enqueue(Op{ID: "op-2", Kind: dtosecret.BatchOpUpdate, SecretID: created.ID, Secret: payload("final"), QueuedAt: now})
+	require.NoError(t, err)
+	_, err = cache.Enqueue(Op{ID: "op-3", Kind: dtosecret.BatchOpUpdate, SecretID: "s-1", Secret: payload("edited"), QueuedAt: now})
+	require.NoError(t, err)
+	_, err = cache.Enqueue(Op{ID: "op-4", Kind: dtosecret.BatchOpDelete, SecretID: "s-1", QueuedAt: now})
+	require.NoError(t, err)
+
+	pending := cache.Pending()
+	require.Len(t, pending, 2)
+	assert.Equal(t, dtosecret.BatchOpCreate, pending[0].Kind)
+	assert.Equal(t, "final", pending[0].Secret.MetaOpen.Title)
+	assert.Equal(t, created.ID, pending[0].SecretID)
+	assert.Equal(t, dtosecret.BatchOpDelete, pending[1].Kind)
+	assert.Equal(t, int64(4), pending[1].BaseVersion, "folded writes keep the version they started from")
+
+	_, ok := cache.Get("s-1")
+	assert.False(t, ok)
+	_, err = cache.Enqueue(Op{ID: "op-5", Kind: dtosecret.BatchOpUpdate, SecretID: "s-1", Secret: payload("x"), QueuedAt: now})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// A refresh from the server keeps queued writes on top.
+	cache.Replace([]dtosecret.SecretResponse{note("s-1", 4, "2026-03-01T10:00:00Z")})
+	_, ok = cache.Get(created.ID)
+	assert.True(t, ok)
+
+	_, err = cache.Enqueue(Op{ID: "op-6", Kind: dtosecret.BatchOpDelete, SecretID: created.ID, QueuedAt: now})
+	require.NoError(t, err)
+	cache.Done("op-4")
+	assert.Empty(t, cache.Pending())
+}
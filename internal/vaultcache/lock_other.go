@@ -0,0 +1,9 @@
+//go:build !unix
+
+package vaultcache
+
+// lockFile is a no-op where flock is unavailable; the atomic rename in
+// Update still keeps the file itself consistent.
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
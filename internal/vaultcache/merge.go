@@ -0,0 +1,293 @@
+package vaultcache
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/google/uuid"
+)
+
+// Choices of Decide, plus the one recorded when a conflict merged by itself.
+const (
+	KeepMine   = "mine"
+	KeepTheirs = "theirs"
+	KeepBoth   = "both"
+	Merged     = "merged"
+)
+
+// Fields a conflict can be about. Tags never conflict: both sides' additions
+// and removals are kept.
+const (
+	FieldPayload = "payload"
+	FieldTitle   = "title"
+	FieldSite    = "site"
+	FieldKeys    = "keys"
+	FieldTags    = "tags"
+	// FieldDeleted means one side deleted the secret the other edited.
+	FieldDeleted = "deleted"
+)
+
+// ConflictCopySuffix is appended to the title of the copy "keep both"
+// creates from the local version.
+const ConflictCopySuffix = " (conflict copy)"
+
+const maxDecisions = 100
+
+var (
+	ErrNoConflict      = errors.New("no conflict for this secret")
+	ErrUnknownDecision = errors.New("unknown decision, use mine, theirs or both")
+)
+
+// Conflict is a queued write that overlaps a change made on the server.
+type Conflict struct {
+	// Theirs is the server state; nil when the secret was deleted there.
+	Theirs     *dtosecret.SecretResponse `json:"theirs,omitempty"`
+	Fields     []string                  `json:"fields"`
+	DetectedAt time.Time                 `json:"detected_at"`
+}
+
+// Decision records how a conflict was settled.
+type Decision struct {
+	OpID     string   `json:"op_id"`
+	SecretID string   `json:"secret_id"`
+	Choice   string   `json:"choice"`
+	Fields   []string `json:"fields,omitempty"`
+	// NextOpID is the write queued in place of the settled one, if any.
+	NextOpID  string    `json:"next_op_id,omitempty"`
+	DecidedAt time.Time `json:"decided_at"`
+}
+
+// Reconcile handles a queued write the server refused because the secret
+// changed there since BaseVersion. theirs is the current server state, nil
+// when the secret is gone. Changes to different fields are merged against
+// Base: the write is rebased onto theirs and returned for another upload,
+// or dropped when the server already has the result. Changes to the same
+// field are kept as a Conflict for Decide, and nil is returned.
+func (c *Cache) Reconcile(opID string, theirs *dtosecret.SecretResponse, now time.Time) *Op {
+	i := c.index(opID)
+	if i < 0 {
+		return nil
+	}
+	op := c.Queue[i]
+	c.putServer(op.SecretID, theirs)
+
+	var fields []string
+	switch {
+	case op.Kind == dtosecret.BatchOpDelete && theirs == nil:
+		c.settle(i, Merged, nil, nil, now)
+		return nil
+	case theirs == nil:
+		fields = []string{FieldDeleted}
+	case op.Kind == dtosecret.BatchOpDelete:
+		if op.Base == nil || !samePayload(payloadOf(*op.Base), *theirs) {
+			fields = []string{FieldDeleted}
+		}
+	case op.Secret != nil:
+		var merged dtosecret.SecretPayload
+		merged, fields = merge3(op.Base, *op.Secret, *theirs)
+		if len(fields) == 0 && samePayload(merged, *theirs) {
+			c.settle(i, Merged, nil, nil, now)
+			return nil
+		}
+		op.Secret = &merged
+	}
+	if len(fields) > 0 {
+		op.LastError = ""
+		op.Conflict = &Conflict{Theirs: theirs, Fields: fields, DetectedAt: now}
+		c.Queue[i] = op
+		return nil
+	}
+	next := rebase(op, *theirs)
+	c.settle(i, Merged, nil, &next, now)
+	return &next
+}
+
+// Decide settles the conflict of secretID: mine re-queues the local write
+// over the server state, theirs drops it, and both keeps the server state
+// and queues the local version as a new secret. Deletes have nothing to
+// copy, so both keeps theirs. The decision is recorded and returned.
+func (c *Cache) Decide(secretID, choice string, now time.Time) (Decision, error) {
+	i := slices.IndexFunc(c.Queue, func(op Op) bool { return op.SecretID == secretID && op.Conflict != nil })
+	if i < 0 {
+		return Decision{}, ErrNoConflict
+	}
+	op := c.Queue[i]
+	theirs := op.Conflict.Theirs
+	if choice == KeepBoth && op.Kind == dtosecret.BatchOpDelete {
+		choice = KeepTheirs
+	}
+
+	var next *Op
+	switch choice {
+	case KeepMine:
+		if theirs != nil {
+			rebased := rebase(op, *theirs)
+			next = &rebased
+		} else if op.Kind != dtosecret.BatchOpDelete {
+			recreated := recreate(op, "")
+			next = &recreated
+		}
+	case KeepTheirs:
+	case KeepBoth:
+		suffix := ConflictCopySuffix
+		if theirs == nil {
+			// Nothing to keep on the server side, so no copy is needed.
+			suffix = ""
+		}
+		copied := recreate(op, suffix)
+		next = &copied
+	default:
+		return Decision{}, ErrUnknownDecision
+	}
+	c.putServer(secretID, theirs)
+	return c.settle(i, choice, op.Conflict.Fields, next, now), nil
+}
+
+func (c *Cache) index(opID string) int {
+	return slices.IndexFunc(c.Queue, func(op Op) bool { return op.ID == opID })
+}
+
+// putServer stores the server state of id; nil means it was deleted.
+func (c *Cache) putServer(id string, secret *dtosecret.SecretResponse) {
+	if secret == nil || secret.DeletedAt != "" {
+		c.Remove(id)
+		return
+	}
+	c.Secrets[id] = *secret
+}
+
+// settle replaces the write at i with next, or drops it, and records why.
+func (c *Cache) settle(i int, choice string, fields []string, next *Op, now time.Time) Decision {
+	decision := Decision{OpID: c.Queue[i].ID, SecretID: c.Queue[i].SecretID, Choice: choice, Fields: fields, DecidedAt: now}
+	if next != nil {
+		decision.NextOpID = next.ID
+		c.Queue[i] = *next
+	} else {
+		c.Queue = slices.Delete(c.Queue, i, i+1)
+	}
+	c.Decisions = append(c.Decisions, decision)
+	if extra := len(c.Decisions) - maxDecisions; extra > 0 {
+		c.Decisions = slices.Delete(c.Decisions, 0, extra)
+	}
+	return decision
+}
+
+// rebase moves a write onto the server state theirs. It gets a new ID, as
+// the old key is bound to the refused request.
+func rebase(op Op, theirs dtosecret.SecretResponse) Op {
+	op.ID = uuid.NewString()
+	op.BaseVersion = theirs.Version
+	op.Base = &theirs
+	op.LastError = ""
+	op.Conflict = nil
+	return op
+}
+
+// recreate turns an update into the create of a new secret.
+func recreate(op Op, titleSuffix string) Op {
+	op.ID = uuid.NewString()
+	op.Kind = dtosecret.BatchOpCreate
+	op.SecretID = LocalIDPrefix + op.ID
+	op.BaseVersion = 0
+	op.Base = nil
+	op.LastError = ""
+	op.Conflict = nil
+	if op.Secret != nil {
+		secret := *op.Secret
+		secret.MetaOpen.Title += titleSuffix
+		op.Secret = &secret
+	}
+	return op
+}
+
+func payloadOf(secret dtosecret.SecretResponse) dtosecret.SecretPayload {
+	return dtosecret.SecretPayload{Type: secret.Type, MetaOpen: secret.MetaOpen, Ciphertext: secret.Ciphertext}
+}
+
+func samePayload(payload dtosecret.SecretPayload, secret dtosecret.SecretResponse) bool {
+	return payload.Type == secret.Type &&
+		payload.Ciphertext == secret.Ciphertext &&
+		payload.MetaOpen.Title == secret.MetaOpen.Title &&
+		payload.MetaOpen.Site == secret.MetaOpen.Site &&
+		payload.MetaOpen.Match == secret.MetaOpen.Match &&
+		maps.Equal(payload.MetaOpen.Keys, secret.MetaOpen.Keys) &&
+		slices.Equal(payload.MetaOpen.Tags, secret.MetaOpen.Tags)
+}
+
+// merge3 merges mine and theirs against their common ancestor base and
+// returns the fields both changed differently. Without a base every
+// difference is a conflict. The payload is one field: a ciphertext only
+// makes sense together with its type, and a site together with its match
+// rule.
+func merge3(base *dtosecret.SecretResponse, mine dtosecret.SecretPayload, theirs dtosecret.SecretResponse) (dtosecret.SecretPayload, []string) {
+	type payload struct{ kind, ciphertext string }
+	type site struct{ site, match string }
+	var basePayload *payload
+	var baseSite *site
+	var baseTitle *string
+	var baseKeys *map[string]string
+	if base != nil {
+		basePayload = &payload{base.Type, base.Ciphertext}
+		baseSite = &site{base.MetaOpen.Site, base.MetaOpen.Match}
+		baseTitle, baseKeys = &base.MetaOpen.Title, &base.MetaOpen.Keys
+	}
+
+	var fields []string
+	note := func(field string, ok bool) {
+		if !ok {
+			fields = append(fields, field)
+		}
+	}
+	out := mine
+	merged, ok := pick3(basePayload, payload{mine.Type, mine.Ciphertext}, payload{theirs.Type, theirs.Ciphertext}, func(a, b payload) bool { return a == b })
+	out.Type, out.Ciphertext = merged.kind, merged.ciphertext
+	note(FieldPayload, ok)
+	out.MetaOpen.Title, ok = pick3(baseTitle, mine.MetaOpen.Title, theirs.MetaOpen.Title, func(a, b string) bool { return a == b })
+	note(FieldTitle, ok)
+	mergedSite, ok := pick3(baseSite, site{mine.MetaOpen.Site, mine.MetaOpen.Match}, site{theirs.MetaOpen.Site, theirs.MetaOpen.Match}, func(a, b site) bool { return a == b })
+	out.MetaOpen.Site, out.MetaOpen.Match = mergedSite.site, mergedSite.match
+	note(FieldSite, ok)
+	out.MetaOpen.Keys, ok = pick3(baseKeys, mine.MetaOpen.Keys, theirs.MetaOpen.Keys, maps.Equal)
+	note(FieldKeys, ok)
+	if base != nil {
+		out.MetaOpen.Tags = mergeTags(base.MetaOpen.Tags, mine.MetaOpen.Tags, theirs.MetaOpen.Tags)
+	} else {
+		out.MetaOpen.Tags, ok = pick3(nil, mine.MetaOpen.Tags, theirs.MetaOpen.Tags, slices.Equal)
+		note(FieldTags, ok)
+	}
+	return out, fields
+}
+
+// pick3 merges one field: a side that left it as in base takes the other
+// side's value. ok is false when both changed it differently, and mine is
+// returned.
+func pick3[T any](base *T, mine, theirs T, equal func(a, b T) bool) (T, bool) {
+	switch {
+	case equal(mine, theirs):
+		return theirs, true
+	case base == nil:
+		return mine, false
+	case equal(*base, mine):
+		return theirs, true
+	case equal(*base, theirs):
+		return mine, true
+	default:
+		return mine, false
+	}
+}
+
+// mergeTags applies the tags mine added and removed since base to theirs.
+func mergeTags(base, mine, theirs []string) []string {
+	out := slices.DeleteFunc(slices.Clone(theirs), func(tag string) bool {
+		return slices.Contains(base, tag) && !slices.Contains(mine, tag)
+	})
+	for _, tag := range mine {
+		if !slices.Contains(base, tag) && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
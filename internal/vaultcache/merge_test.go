@@ -0,0 +1,180 @@
+package vaultcache
+
+import (
+	"testing"
+	"time"
+
+	dtosecret "github.com/7StaSH7/practicum-diploma/internal/dto/secret"
+	"github.com/7StaSH7/practicum-diploma/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var decidedAt = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+// queuedEdit seeds s-1 at version 1 and queues an update made by edit.
+func queuedEdit(t *testing.T, edit func(p *dtosecret.SecretPayload)) (*Cache, Op) {
+	t.Helper()
+	base := note("s-1", 1, "2026-03-01T10:00:00Z")
+	base.MetaOpen.Tags = []string{"home", "wifi"}
+	cache := newCache("u-1")
+	cache.Replace([]dtosecret.SecretResponse{base})
+
+	payload := payloadOf(base)
+	payload.MetaOpen.Tags = []string{"home", "wifi"}
+	edit(&payload)
+	_, err := cache.Enqueue(Op{ID: "op-1", Kind: dtosecret.BatchOpUpdate, SecretID: "s-1", Secret: &payload, QueuedAt: decidedAt})
+	require.NoError(t, err)
+	return cache, cache.Pending()[0]
+}
+
+func theirsAt(version int64, edit func(s *dtosecret.SecretResponse)) *dtosecret.SecretResponse {
+	secret := note("s-1", version, "2026-03-01T12:00:00Z")
+	secret.MetaOpen.Tags = []string{"home", "wifi"}
+	edit(&secret)
+	return &secret
+}
+
+func TestReconcileMergesDisjointChanges(t *testing.T) {
+	cache, op := queuedEdit(t, func(p *dtosecret.SecretPayload) {
+		p.MetaOpen.Title = "home wifi"
+		p.MetaOpen.Tags = []string{"home", "wifi", "router"}
+	})
+	require.NotNil(t, op.Base)
+
+	next := cache.Reconcile(op.ID, theirsAt(2, func(s *dtosecret.SecretResponse) {
+		s.Ciphertext = "bmV3LXBhc3N3b3Jk"
+		s.MetaOpen.Site = "router.local"
+		s.MetaOpen.Tags = []string{"wifi"}
+	}), decidedAt)
+
+	require.NotNil(t, next)
+	assert.NotEqual(t, op.ID, next.ID, "a rebased write needs a new idempotency key")
+	assert.Equal(t, int64(2), next.BaseVersion)
+	assert.Equal(t, "home wifi", next.Secret.MetaOpen.Title)
+	assert.Equal(t, "router.local", next.Secret.MetaOpen.Site)
+	assert.Equal(t, "bmV3LXBhc3N3b3Jk", next.Secret.Ciphertext)
+	assert.Equal(t, []string{"wifi", "router"}, next.Secret.MetaOpen.Tags)
+	assert.Equal(t, []Op{*next}, cache.Pending())
+	require.Len(t, cache.Decisions, 1)
+	assert.Equal(t, Decision{OpID: "op-1", SecretID: "s-1", Choice: Merged, NextOpID: next.ID, DecidedAt: decidedAt}, cache.Decisions[0])
+}
+
+func TestReconcileDropsWritesTheServerAlreadyHas(t *testing.T) {
+	cache, op := queuedEdit(t, func(p *dtosecret.SecretPayload) { p.MetaOpen.Title = "same" })
+
+	next := cache.Reconcile(op.ID, theirsAt(3, func(s *dtosecret.SecretResponse) { s.MetaOpen.Title = "same" }), decidedAt)
+
+	assert.Nil(t, next)
+	assert.Empty(t, cache.Pending())
+	secret, ok := cache.Get("s-1")
+	require.True(t, ok)
+	assert.Equal(t, int64(3), secret.Version)
+}
+
+func TestReconcileKeepsOverlappingChangesForDecision(t *testing.T) {
+	cache, op := queuedEdit(t, func(p *dtosecret.SecretPayload) {
+		p.Ciphertext = "bWluZQ=="
+		p.MetaOpen.Title = "mine"
+	})
+	theirs := theirsAt(2, func(s *dtosecret.SecretResponse) { s.Ciphertext = "dGhlaXJz" })
+
+	assert.Nil(t, cache.Reconcile(op.ID, theirs, decidedAt))
+
+	conflicts := cache.Conflicts()
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, []string{FieldPayload}, conflicts[0].Conflict.Fields)
+	assert.Equal(t, theirs, conflicts[0].Conflict.Theirs)
+	secret, _ := cache.Get("s-1")
+	assert.Equal(t, "bWluZQ==", secret.Ciphertext, "the local version reads until the user decides")
+
+	// Another offline edit keeps the conflict it is folded into.
+	_, err := cache.Enqueue(Op{ID: "op-2", Kind: dtosecret.BatchOpUpdate, SecretID: "s-1", Secret: &dtosecret.SecretPayload{Type: "note", Ciphertext: "bWluZTI="}, QueuedAt: decidedAt})
+	require.NoError(t, err)
+	assert.Len(t, cache.Conflicts(), 1)
+}
+
+func TestDecideSettlesConflicts(t *testing.T) {
+	conflicted := func(t *testing.T, theirs *dtosecret.SecretResponse) *Cache {
+		cache, op := queuedEdit(t, func(p *dtosecret.SecretPayload) { p.Ciphertext = "bWluZQ==" })
+		cache.Reconcile(op.ID, theirs, decidedAt)
+		require.Len(t, cache.Conflicts(), 1)
+		return cache
+	}
+	edited := theirsAt(2, func(s *dtosecret.SecretResponse) { s.Ciphertext = "dGhlaXJz" })
+
+	t.Run("mine", func(t *testing.T) {
+		cache := conflicted(t, edited)
+		decision, err := cache.Decide("s-1", KeepMine, decidedAt)
+		require.NoError(t, err)
+		pending := cache.Pending()
+		require.Len(t, pending, 1)
+		assert.Nil(t, pending[0].Conflict)
+		assert.Equal(t, int64(2), pending[0].BaseVersion)
+		assert.Equal(t, "bWluZQ==", pending[0].Secret.Ciphertext)
+		assert.Equal(t, pending[0].ID, decision.NextOpID)
+		assert.Equal(t, []string{FieldPayload}, decision.Fields)
+	})
+
+	t.Run("theirs", func(t *testing.T) {
+		cache := conflicted(t, edited)
+		_, err := cache.Decide("s-1", KeepTheirs, decidedAt)
+		require.NoError(t, err)
+		assert.Empty(t, cache.Pending())
+		secret, _ := cache.Get("s-1")
+		assert.Equal(t, "dGhlaXJz", secret.Ciphertext)
+	})
+
+	t.Run("both", func(t *testing.T) {
+		cache := conflicted(t, edited)
+		_, err := cache.Decide("s-1", KeepBoth, decidedAt)
+		require.NoError(t, err)
+		pending := cache.Pending()
+		require.Len(t, pending, 1)
+		assert.Equal(t, dtosecret.BatchOpCreate, pending[0].Kind)
+		assert.Equal(t, "s-1"+ConflictCopySuffix, pending[0].Secret.MetaOpen.Title)
+		assert.Len(t, cache.List(), 2)
+	})
+
+	t.Run("mine after a server delete", func(t *testing.T) {
+		cache := conflicted(t, nil)
+		_, err := cache.Decide("s-1", KeepMine, decidedAt)
+		require.NoError(t, err)
+		pending := cache.Pending()
+		require.Len(t, pending, 1)
+		assert.Equal(t, dtosecret.BatchOpCreate, pending[0].Kind)
+		assert.True(t, IsLocalID(pending[0].SecretID))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		cache := conflicted(t, edited)
+		_, err := cache.Decide("s-1", "maybe", decidedAt)
+		require.ErrorIs(t, err, ErrUnknownDecision)
+		_, err = cache.Decide("s-2", KeepMine, decidedAt)
+		require.ErrorIs(t, err, ErrNoConflict)
+	})
+}
+
+func TestReconcileDeletes(t *testing.T) {
+	cache := newCache("u-1")
+	cache.Replace([]dtosecret.SecretResponse{note("s-1", 1, "2026-03-01T10:00:00Z"), note("s-2", 1, "2026-03-01T10:00:00Z")})
+	for _, id := range []string{"s-1", "s-2"} {
+		_, err := cache.Enqueue(Op{ID: "del-" + id, Kind: dtosecret.BatchOpDelete, SecretID: id, QueuedAt: decidedAt})
+		require.NoError(t, err)
+	}
+
+	assert.Nil(t, cache.Reconcile("del-s-1", nil, decidedAt), "deleted on both sides")
+	edited := note("s-2", 2, "2026-03-01T12:00:00Z")
+	edited.MetaOpen = models.MetaOpen{Title: "renamed"}
+	assert.Nil(t, cache.Reconcile("del-s-2", &edited, decidedAt))
+
+	conflicts := cache.Conflicts()
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, []string{FieldDeleted}, conflicts[0].Conflict.Fields)
+	decision, err := cache.Decide("s-2", KeepBoth, decidedAt)
+	require.NoError(t, err)
+	assert.Equal(t, KeepTheirs, decision.Choice, "a delete has nothing to copy")
+	secret, ok := cache.Get("s-2")
+	require.True(t, ok)
+	assert.Equal(t, "renamed", secret.MetaOpen.Title)
+}
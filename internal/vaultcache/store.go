@@ -0,0 +1,325 @@
+package vaultcache
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	magic = "PKEEPERC"
+	// The version byte also tells which key sealed the file.
+	versionDeviceKey byte = 1
+	versionSealedKey byte = 2
+
+	keyFileName = "vault.key"
+	keySize     = chacha20poly1305.KeySize
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported offline cache")
+	ErrDecrypt     = errors.New("offline cache is damaged or its key was replaced")
+	// ErrSealedKey means the cache key is sealed but the store has no
+	// KeySealer to open it.
+	ErrSealedKey = errors.New("offline cache key is sealed by the vault")
+)
+
+// KeySealer protects the cache key with a key kept outside the cache
+// directory, such as the vault key held by the agent. While it cannot open
+// the key, for example because the vault is locked, the cache cannot be
+// read either.
+type KeySealer interface {
+	SealKey(key []byte) ([]byte, error)
+	OpenKey(sealed []byte) ([]byte, error)
+}
+
+// Store keeps one user's cache in a directory, sealed with XChaCha20-Poly1305
+// under a random key. Without a KeySealer that is a device key stored next
+// to it, so a copied cache file on its own reveals nothing. With one, every
+// account gets its own key, stored only in sealed form.
+//
+// File layout: magic "PKEEPERC", a version byte, a 24-byte nonce and the
+// sealed JSON cache. The magic and version are authenticated as AAD.
+type Store struct {
+	dir    string
+	userID string
+	sealer KeySealer
+}
+
+// NewStore returns the store of userID in dir. Every account gets its own
+// file, so switching accounts neither mixes replicas nor drops queued
+// writes.
+func NewStore(dir, userID string) *Store {
+	return &Store{dir: dir, userID: userID}
+}
+
+// WithKeySealer makes the store seal its key with k. A cache written under
+// the device key is still read, once k works, and sealed again under the
+// new key by the next Update.
+func (s *Store) WithKeySealer(k KeySealer) *Store {
+	s.sealer = k
+	return s
+}
+
+func (s *Store) path() string {
+	return s.accountFile("cache")
+}
+
+func (s *Store) sealedKeyPath() string {
+	return s.accountFile("key")
+}
+
+func (s *Store) accountFile(ext string) string {
+	sum := sha256.Sum256([]byte(s.userID))
+	return filepath.Join(s.dir, fmt.Sprintf("vault-%x.%s", sum[:8], ext))
+}
+
+// Load reads the cache. A missing file gives an empty cache.
+func (s *Store) Load() (*Cache, error) {
+	sealed, err := os.ReadFile(s.path())
+	if errors.Is(err, fs.ErrNotExist) {
+		return newCache(s.userID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.openKey(sealed)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrDecrypt
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain, err := open(key, sealed)
+	if err != nil {
+		return nil, err
+	}
+	cache := newCache(s.userID)
+	if err := json.Unmarshal(plain, cache); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	if cache.UserID != s.userID {
+		return newCache(s.userID), nil
+	}
+	if cache.Secrets == nil {
+		cache.Secrets = newCache(s.userID).Secrets
+	}
+	return cache, nil
+}
+
+// Update loads the cache, applies fn and writes the result back. Concurrent
+// updates from other pkeeper processes wait for each other, so a sync does
+// not drop a write queued next to it. Nothing is written when fn fails.
+func (s *Store) Update(fn func(c *Cache) error) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(s.path() + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cache, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if err := fn(cache); err != nil {
+		return err
+	}
+	plain, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	version, key := versionDeviceKey, []byte(nil)
+	if s.sealer != nil {
+		version = versionSealedKey
+		key, err = s.sealedKey(true)
+	} else {
+		key, err = s.deviceKey(true)
+	}
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(key, version, plain)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(s.path(), sealed)
+}
+
+// openKey returns the key the cache file was sealed with.
+func (s *Store) openKey(sealed []byte) ([]byte, error) {
+	version, err := fileVersion(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if version == versionSealedKey {
+		if s.sealer == nil {
+			return nil, ErrSealedKey
+		}
+		return s.sealedKey(false)
+	}
+	if s.sealer != nil {
+		// Written before the key was sealed: reading it takes a working
+		// sealer all the same, so it cannot be used to get around it.
+		if _, err := s.sealedKey(true); err != nil {
+			return nil, err
+		}
+	}
+	return s.deviceKey(false)
+}
+
+// sealedKey opens the account key through the sealer, creating it on first
+// use when create is set.
+func (s *Store) sealedKey(create bool) ([]byte, error) {
+	path := s.sealedKeyPath()
+	sealed, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && create {
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		sealed, err := s.sealer.SealKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeNew(path, sealed); errors.Is(err, fs.ErrExist) {
+			// Another process created it first.
+			return s.sealedKey(false)
+		} else if err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.sealer.OpenKey(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("%w: bad key file %s", ErrDecrypt, path)
+	}
+	return key, nil
+}
+
+// deviceKey reads the device key, creating it on first use when create is
+// set.
+func (s *Store) deviceKey(create bool) ([]byte, error) {
+	path := filepath.Join(s.dir, keyFileName)
+	key, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && create {
+		key = make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := writeNew(path, key); errors.Is(err, fs.ErrExist) {
+			// Another process created it first.
+			return s.deviceKey(false)
+		} else if err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("%w: bad key file %s", ErrDecrypt, path)
+	}
+	return key, nil
+}
+
+func preamble(version byte) []byte {
+	return append([]byte(magic), version)
+}
+
+func fileVersion(sealed []byte) (byte, error) {
+	if len(sealed) <= len(magic) || string(sealed[:len(magic)]) != magic {
+		return 0, fmt.Errorf("%w: not a cache file", ErrUnsupported)
+	}
+	version := sealed[len(magic)]
+	if version != versionDeviceKey && version != versionSealedKey {
+		return 0, fmt.Errorf("%w: version %d", ErrUnsupported, version)
+	}
+	return version, nil
+}
+
+func seal(key []byte, version byte, plain []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	out := preamble(version)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plain, preamble(version)), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	version, err := fileVersion(sealed)
+	if err != nil {
+		return nil, err
+	}
+	head := preamble(version)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	body := sealed[len(head):]
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	plain, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], head)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+// writeAtomic replaces path so readers see either the old or the new cache,
+// never a partial one.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".vault-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func writeNew(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS secret_versions (
+    secret_id UUID NOT NULL REFERENCES secrets(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    version BIGINT NOT NULL,
+    type TEXT NOT NULL,
+    meta_open JSONB NOT NULL DEFAULT '{}'::jsonb,
+    ciphertext BYTEA NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (secret_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS secret_versions_user_idx ON secret_versions(user_id, secret_id);
+CREATE INDEX IF NOT EXISTS secret_versions_archived_idx ON secret_versions(archived_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS secrets_deleted_idx;
+DELETE FROM secrets WHERE deleted_at IS NOT NULL;
+ALTER TABLE secrets DROP COLUMN IF EXISTS deleted_at;
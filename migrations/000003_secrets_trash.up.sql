@@ -0,0 +1,3 @@
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS secrets_deleted_idx ON secrets(deleted_at) WHERE deleted_at IS NOT NULL;
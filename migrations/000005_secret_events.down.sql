@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS secrets_record_event ON secrets;
+DROP FUNCTION IF EXISTS record_secret_event();
+DROP TABLE IF EXISTS secret_events;
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS secret_events (
+    seq BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    secret_id UUID NOT NULL,
+    kind TEXT NOT NULL,
+    version BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS secret_events_user_seq_idx ON secret_events(user_id, seq);
+CREATE INDEX IF NOT EXISTS secret_events_created_idx ON secret_events(created_at);
+
+CREATE OR REPLACE FUNCTION record_secret_event() RETURNS trigger AS $$
+DECLARE
+    event_kind TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO secret_events (user_id, secret_id, kind, version)
+        VALUES (OLD.user_id, OLD.id, 'purged', OLD.version);
+        RETURN OLD;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        event_kind := 'created';
+    ELSIF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+        event_kind := 'deleted';
+    ELSIF NEW.deleted_at IS NULL AND OLD.deleted_at IS NOT NULL THEN
+        event_kind := 'restored';
+    ELSE
+        event_kind := 'updated';
+    END IF;
+
+    INSERT INTO secret_events (user_id, secret_id, kind, version)
+    VALUES (NEW.user_id, NEW.id, event_kind, NEW.version);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS secrets_record_event ON secrets;
+CREATE TRIGGER secrets_record_event
+    AFTER INSERT OR UPDATE OR DELETE ON secrets
+    FOR EACH ROW EXECUTE FUNCTION record_secret_event();
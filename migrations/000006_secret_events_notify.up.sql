@@ -0,0 +1,30 @@
+-- Every recorded event also notifies the secret_events channel with the
+-- owner's id. Notifications are delivered on commit, so listeners on other
+-- instances only wake up for changes that are visible in the log.
+CREATE OR REPLACE FUNCTION record_secret_event() RETURNS trigger AS $$
+DECLARE
+    event_kind TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO secret_events (user_id, secret_id, kind, version)
+        VALUES (OLD.user_id, OLD.id, 'purged', OLD.version);
+        PERFORM pg_notify('secret_events', OLD.user_id::text);
+        RETURN OLD;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        event_kind := 'created';
+    ELSIF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+        event_kind := 'deleted';
+    ELSIF NEW.deleted_at IS NULL AND OLD.deleted_at IS NOT NULL THEN
+        event_kind := 'restored';
+    ELSE
+        event_kind := 'updated';
+    END IF;
+
+    INSERT INTO secret_events (user_id, secret_id, kind, version)
+    VALUES (NEW.user_id, NEW.id, event_kind, NEW.version);
+    PERFORM pg_notify('secret_events', NEW.user_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
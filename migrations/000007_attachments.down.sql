@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS attachments_queue_blob ON attachments;
+DROP FUNCTION IF EXISTS queue_attachment_blob();
+DROP TABLE IF EXISTS attachment_blob_gc;
+DROP TABLE IF EXISTS attachments;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id UUID PRIMARY KEY,
+    secret_id UUID NOT NULL REFERENCES secrets(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    received BIGINT NOT NULL DEFAULT 0,
+    blob_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS attachments_secret_idx ON attachments(secret_id);
+CREATE INDEX IF NOT EXISTS attachments_pending_idx ON attachments(created_at) WHERE completed_at IS NULL;
+
+-- Blobs live outside the attachments table, so deleted rows (including rows
+-- removed by cascades from secrets and users) queue their blob for cleanup.
+CREATE TABLE IF NOT EXISTS attachment_blob_gc (
+    blob_key TEXT PRIMARY KEY,
+    queued_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE OR REPLACE FUNCTION queue_attachment_blob() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO attachment_blob_gc (blob_key) VALUES (OLD.blob_key)
+    ON CONFLICT (blob_key) DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS attachments_queue_blob ON attachments;
+CREATE TRIGGER attachments_queue_blob
+    AFTER DELETE ON attachments
+    FOR EACH ROW EXECUTE FUNCTION queue_attachment_blob();
@@ -0,0 +1,41 @@
+-- Events are recorded at commit instead of at the change, under a per-user
+-- lock held until the commit is visible. A user's events therefore get their
+-- seq in commit order, and a stream reading seq > cursor cannot move past an
+-- event that commits later with a lower number. The lock is only taken once
+-- the transaction has finished its statements, so it never waits on row
+-- locks while holding it.
+CREATE OR REPLACE FUNCTION record_secret_event() RETURNS trigger AS $$
+DECLARE
+    event_kind TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_advisory_xact_lock(hashtext('secret_events'), hashtext(OLD.user_id::text));
+        INSERT INTO secret_events (user_id, secret_id, kind, version)
+        VALUES (OLD.user_id, OLD.id, 'purged', OLD.version);
+        PERFORM pg_notify('secret_events', OLD.user_id::text);
+        RETURN OLD;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        event_kind := 'created';
+    ELSIF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+        event_kind := 'deleted';
+    ELSIF NEW.deleted_at IS NULL AND OLD.deleted_at IS NOT NULL THEN
+        event_kind := 'restored';
+    ELSE
+        event_kind := 'updated';
+    END IF;
+
+    PERFORM pg_advisory_xact_lock(hashtext('secret_events'), hashtext(NEW.user_id::text));
+    INSERT INTO secret_events (user_id, secret_id, kind, version)
+    VALUES (NEW.user_id, NEW.id, event_kind, NEW.version);
+    PERFORM pg_notify('secret_events', NEW.user_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS secrets_record_event ON secrets;
+CREATE CONSTRAINT TRIGGER secrets_record_event
+    AFTER INSERT OR UPDATE OR DELETE ON secrets
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION record_secret_event();
@@ -0,0 +1,2 @@
+-- The free-form types are not kept, the secrets stay notes.
+SELECT 1;
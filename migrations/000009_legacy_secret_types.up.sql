@@ -0,0 +1,8 @@
+-- Secrets written before typed kinds carry free-form types such as "text".
+-- Their plaintext payloads decode as notes, so they are stored as notes and
+-- can be updated and restored like any other secret.
+UPDATE secrets SET type = 'note'
+WHERE type NOT IN ('login', 'card', 'note', 'binary', 'ssh_key', 'api_token', 'totp');
+
+UPDATE secret_versions SET type = 'note'
+WHERE type NOT IN ('login', 'card', 'note', 'binary', 'ssh_key', 'api_token', 'totp');
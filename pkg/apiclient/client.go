@@ -7,9 +7,28 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// IdempotencyKeyHeader lets the server recognise retries of a mutating
+// request and replay the stored response instead of applying it again.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKeyCtx struct{}
+
+// WithIdempotencyKey attaches key to every non-GET request made with ctx.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+// IdempotencyKey returns the key attached by WithIdempotencyKey.
+func IdempotencyKey(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyCtx{}).(string)
+	return key, ok && key != ""
+}
+
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
@@ -18,6 +37,9 @@ type Client struct {
 type HTTPError struct {
 	StatusCode int
 	Body       string
+	// RetryAfter is the delay the server asked for before a retry, or zero
+	// when the response carried no Retry-After header.
+	RetryAfter time.Duration
 }
 
 func (e *HTTPError) Error() string {
@@ -52,6 +74,9 @@ func (c *Client) DoJSON(ctx context.Context, method, path string, headers map[st
 	if in != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if key, ok := IdempotencyKey(ctx); ok && method != http.MethodGet {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
@@ -60,6 +85,10 @@ func (c *Client) DoJSON(ctx context.Context, method, path string, headers map[st
 	if err != nil {
 		return err
 	}
+	return decodeResponse(resp, out)
+}
+
+func decodeResponse(resp *http.Response, out any) error {
 	defer resp.Body.Close()
 
 	rawBody, err := io.ReadAll(resp.Body)
@@ -67,13 +96,27 @@ func (c *Client) DoJSON(ctx context.Context, method, path string, headers map[st
 		return err
 	}
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return &HTTPError{
-			StatusCode: resp.StatusCode,
-			Body:       strings.TrimSpace(string(rawBody)),
-		}
+		return newHTTPError(resp, rawBody)
 	}
 	if out == nil || len(rawBody) == 0 {
 		return nil
 	}
 	return json.Unmarshal(rawBody, out)
 }
+
+func newHTTPError(resp *http.Response, body []byte) *HTTPError {
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// retryAfter parses the delay-seconds form of Retry-After.
+func retryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -71,6 +72,24 @@ func TestDoJSONReturnsHTTPError(t *testing.T) {
 	assert.Equal(t, "unauthorized", httpErr.Body)
 }
 
+func TestDoJSONReportsRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusConflict)
+	}))
+	t.Cleanup(server.Close)
+
+	client := New(server.URL, server.Client())
+	err := client.DoJSON(context.Background(), http.MethodPost, "/v1/secrets", nil, nil, nil)
+
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusConflict, httpErr.StatusCode)
+	assert.Equal(t, 2*time.Second, httpErr.RetryAfter)
+}
+
 func TestDoJSONReturnsNilForEmptyBodyWhenOutNil(t *testing.T) {
 	t.Parallel()
 
@@ -83,3 +102,62 @@ func TestDoJSONReturnsNilForEmptyBodyWhenOutNil(t *testing.T) {
 	err := client.DoJSON(context.Background(), http.MethodDelete, "/v1/resource", nil, nil, nil)
 	assert.NoError(t, err)
 }
+
+func TestDoJSONSendsIdempotencyKeyOnMutatingRequests(t *testing.T) {
+	t.Parallel()
+
+	seen := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen[r.Method] = r.Header.Get(IdempotencyKeyHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	client := New(server.URL, server.Client())
+	ctx := WithIdempotencyKey(context.Background(), "key-1")
+	require.NoError(t, client.DoJSON(ctx, http.MethodPost, "/r", nil, map[string]string{}, nil))
+	require.NoError(t, client.DoJSON(ctx, http.MethodGet, "/r", nil, nil, nil))
+
+	assert.Equal(t, "key-1", seen[http.MethodPost])
+	assert.Empty(t, seen[http.MethodGet])
+}
+
+func TestStreamParsesEvents(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		require.Equal(t, "5", r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(":\n\nid:6\nevent:created\ndata:{\"seq\":6}\n\nid: 7\nevent: deleted\ndata: a\ndata: b\n\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := New(server.URL, server.Client())
+	var events []Event
+	err := client.Stream(context.Background(), "/events", map[string]string{"Last-Event-ID": "5"}, func(event Event) error {
+		events = append(events, event)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Event{
+		{ID: "6", Event: "created", Data: `{"seq":6}`},
+		{ID: "7", Event: "deleted", Data: "a\nb"},
+	}, events)
+}
+
+func TestStreamReturnsHTTPError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	client := New(server.URL, server.Client())
+	err := client.Stream(context.Background(), "/events", nil, func(Event) error { return nil })
+
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+}
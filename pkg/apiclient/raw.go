@@ -0,0 +1,55 @@
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// DoRaw sends body as an opaque application/octet-stream payload and decodes
+// a JSON response into out. Raw bodies carry no idempotency key; callers make
+// retries safe through the request itself, e.g. an explicit upload offset.
+func (c *Client) DoRaw(ctx context.Context, method, path string, headers map[string]string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, out)
+}
+
+// Download copies the response body of a GET request to w. Like Stream, it
+// is bounded by ctx rather than the client timeout, so large bodies are not
+// cut off mid-transfer.
+func (c *Client) Download(ctx context.Context, path string, headers map[string]string, w io.Writer) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	downloadClient := *c.httpClient
+	downloadClient.Timeout = 0
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		rawBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return 0, newHTTPError(resp, rawBody)
+	}
+	return io.Copy(w, resp.Body)
+}
@@ -0,0 +1,72 @@
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoRawSendsOctetStream(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		require.Equal(t, "application/octet-stream", r.Header.Get("Content-Type"))
+		require.Equal(t, "3", r.Header.Get("Upload-Offset"))
+		require.Empty(t, r.Header.Get(IdempotencyKeyHeader))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0, 1, 2}, body)
+		_, _ = w.Write([]byte(`{"received":6}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := New(server.URL, server.Client())
+	out := struct {
+		Received int64 `json:"received"`
+	}{}
+	ctx := WithIdempotencyKey(context.Background(), "key")
+	err := client.DoRaw(ctx, http.MethodPut, "/blob", map[string]string{"Upload-Offset": "3"}, []byte{0, 1, 2}, &out)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), out.Received)
+}
+
+func TestDownloadCopiesBody(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("payload"))
+	}))
+	t.Cleanup(server.Close)
+
+	var buf bytes.Buffer
+	n, err := New(server.URL, server.Client()).Download(context.Background(), "/blob", nil, &buf)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), n)
+	assert.Equal(t, "payload", buf.String())
+}
+
+func TestDownloadReturnsHTTPError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	t.Cleanup(server.Close)
+
+	var buf bytes.Buffer
+	_, err := New(server.URL, server.Client()).Download(context.Background(), "/blob", nil, &buf)
+
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusConflict, httpErr.StatusCode)
+	assert.Zero(t, buf.Len())
+}
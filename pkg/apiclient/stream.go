@@ -0,0 +1,83 @@
+package apiclient
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Event is a single Server-Sent Event.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// Stream opens a Server-Sent Events stream and calls fn for every event until
+// the server closes the stream, ctx is cancelled or fn returns an error. The
+// client timeout does not apply to the stream, only ctx does.
+func (c *Client) Stream(ctx context.Context, path string, headers map[string]string, fn func(Event) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		rawBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return newHTTPError(resp, rawBody)
+	}
+	return readEvents(resp.Body, fn)
+}
+
+func readEvents(r io.Reader, fn func(Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var (
+		event Event
+		data  []string
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 || event.Event != "" {
+				event.Data = strings.Join(data, "\n")
+				if err := fn(event); err != nil {
+					return err
+				}
+			}
+			event = Event{}
+			data = data[:0]
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return scanner.Err()
+}